@@ -0,0 +1,78 @@
+/*
+Copyright 2026 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +k8s:deepcopy-gen=true
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type NFSSnapshotSchedule struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              NFSSnapshotScheduleSpec    `json:"spec"`
+	Status            *NFSSnapshotScheduleStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen=true
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type NFSSnapshotScheduleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+	Items           []NFSSnapshotSchedule `json:"items"`
+}
+
+// +k8s:deepcopy-gen=true
+type NFSSnapshotScheduleSpec struct {
+	Schedule         string                        `json:"schedule"`
+	StorageClassName string                        `json:"storageClassName"`
+	PVCSelector      *metav1.LabelSelector         `json:"pvcSelector,omitempty"`
+	Retention        *NFSSnapshotScheduleRetention `json:"retention,omitempty"`
+	Suspend          bool                          `json:"suspend,omitempty"`
+}
+
+// +k8s:deepcopy-gen=true
+type NFSSnapshotScheduleRetention struct {
+	KeepLast   int `json:"keepLast,omitempty"`
+	KeepDaily  int `json:"keepDaily,omitempty"`
+	KeepWeekly int `json:"keepWeekly,omitempty"`
+}
+
+// +k8s:deepcopy-gen=true
+type NFSSnapshotScheduleStatus struct {
+	LastScheduleTime *metav1.Time                  `json:"lastScheduleTime,omitempty"`
+	NextScheduleTime *metav1.Time                  `json:"nextScheduleTime,omitempty"`
+	RecentSnapshots  []NFSSnapshotScheduleSnapshot `json:"recentSnapshots,omitempty"`
+	Failures         []NFSSnapshotScheduleFailure  `json:"failures,omitempty"`
+}
+
+// +k8s:deepcopy-gen=true
+type NFSSnapshotScheduleSnapshot struct {
+	Name                      string      `json:"name"`
+	PersistentVolumeClaimName string      `json:"persistentVolumeClaimName"`
+	ScheduleTime              metav1.Time `json:"scheduleTime"`
+	ReadyToUse                bool        `json:"readyToUse"`
+}
+
+// +k8s:deepcopy-gen=true
+type NFSSnapshotScheduleFailure struct {
+	Time                      metav1.Time `json:"time"`
+	PersistentVolumeClaimName string      `json:"persistentVolumeClaimName,omitempty"`
+	SnapshotName              string      `json:"snapshotName,omitempty"`
+	Message                   string      `json:"message"`
+}
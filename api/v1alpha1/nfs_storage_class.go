@@ -39,13 +39,20 @@ type NFSStorageClassList struct {
 
 // +k8s:deepcopy-gen=true
 type NFSStorageClassSpec struct {
-	Connection        *NFSStorageClassConnection    `json:"connection,omitempty"`
-	MountOptions      *NFSStorageClassMountOptions  `json:"mountOptions,omitempty"`
-	ChmodPermissions  string                        `json:"chmodPermissions,omitempty"`
-	ReclaimPolicy     string                        `json:"reclaimPolicy"`
-	VolumeBindingMode string                        `json:"volumeBindingMode"`
-	WorkloadNodes     *NFSStorageClassWorkloadNodes `json:"workloadNodes,omitempty"`
-	VolumeCleanup     string                        `json:"volumeCleanup,omitempty"`
+	Connection              *NFSStorageClassConnection             `json:"connection,omitempty"`
+	MountOptions            *NFSStorageClassMountOptions           `json:"mountOptions,omitempty"`
+	ChmodPermissions        string                                 `json:"chmodPermissions,omitempty"`
+	ReclaimPolicy           string                                 `json:"reclaimPolicy"`
+	VolumeBindingMode       string                                 `json:"volumeBindingMode"`
+	WorkloadNodes           *NFSStorageClassWorkloadNodes          `json:"workloadNodes,omitempty"`
+	VolumeCleanup           string                                 `json:"volumeCleanup,omitempty"`
+	SnapshotCompression     string                                 `json:"snapshotCompression,omitempty"`
+	SnapshotExport          *NFSStorageClassSnapshotExport         `json:"snapshotExport,omitempty"`
+	VolumeUsage             *NFSStorageClassVolumeUsage            `json:"volumeUsage,omitempty"`
+	OrphanCleanup           *NFSStorageClassOrphanCleanup          `json:"orphanCleanup,omitempty"`
+	OnDelete                string                                 `json:"onDelete,omitempty"`
+	RetainArchivedFor       string                                 `json:"retainArchivedFor,omitempty"`
+	VolumeAttributesClasses []NFSStorageClassVolumeAttributesClass `json:"volumeAttributesClasses,omitempty"`
 }
 
 // +k8s:deepcopy-gen=true
@@ -55,6 +62,7 @@ type NFSStorageClassConnection struct {
 	NFSVersion string `json:"nfsVersion"`
 	Tls        bool   `json:"tls"`
 	Mtls       bool   `json:"mtls"`
+	Zone       string `json:"zone,omitempty"`
 }
 
 // +k8s:deepcopy-gen=true
@@ -70,8 +78,65 @@ type NFSStorageClassWorkloadNodes struct {
 	NodeSelector *metav1.LabelSelector `json:"nodeSelector"`
 }
 
+// +k8s:deepcopy-gen=true
+type NFSStorageClassSnapshotExport struct {
+	S3 *NFSStorageClassSnapshotExportS3 `json:"s3"`
+}
+
+// +k8s:deepcopy-gen=true
+type NFSStorageClassSnapshotExportS3 struct {
+	Endpoint              string `json:"endpoint"`
+	Bucket                string `json:"bucket"`
+	Region                string `json:"region,omitempty"`
+	Prefix                string `json:"prefix,omitempty"`
+	CredentialsSecretName string `json:"credentialsSecretName"`
+}
+
+// +k8s:deepcopy-gen=true
+type NFSStorageClassVolumeUsage struct {
+	Enforcement  string                       `json:"enforcement,omitempty"`
+	ProjectQuota *NFSStorageClassProjectQuota `json:"projectQuota,omitempty"`
+}
+
+// +k8s:deepcopy-gen=true
+type NFSStorageClassProjectQuota struct {
+	SSHSecretName string `json:"sshSecretName"`
+	ExportPath    string `json:"exportPath,omitempty"`
+}
+
+// +k8s:deepcopy-gen=true
+type NFSStorageClassOrphanCleanup struct {
+	DeleteOlderThan string `json:"deleteOlderThan"`
+}
+
+// +k8s:deepcopy-gen=true
+type NFSStorageClassVolumeAttributesClass struct {
+	Name         string   `json:"name"`
+	MountOptions []string `json:"mountOptions"`
+}
+
 // +k8s:deepcopy-gen=true
 type NFSStorageClassStatus struct {
-	Phase  string `json:"phase,omitempty"`
-	Reason string `json:"reason,omitempty"`
+	Phase      string                  `json:"phase,omitempty"`
+	Reason     string                  `json:"reason,omitempty"`
+	Orphans    *NFSStorageClassOrphans `json:"orphans,omitempty"`
+	Conditions []metav1.Condition      `json:"conditions,omitempty"`
+}
+
+// +k8s:deepcopy-gen=true
+type NFSStorageClassOrphans struct {
+	LastScanTime   metav1.Time             `json:"lastScanTime,omitempty"`
+	Count          int                     `json:"count,omitempty"`
+	TotalSizeBytes int64                   `json:"totalSizeBytes,omitempty"`
+	Deleted        int                     `json:"deleted,omitempty"`
+	Items          []NFSStorageClassOrphan `json:"items,omitempty"`
+	Error          string                  `json:"error,omitempty"`
+}
+
+// +k8s:deepcopy-gen=true
+type NFSStorageClassOrphan struct {
+	Name             string      `json:"name"`
+	Kind             string      `json:"kind"`
+	SizeBytes        int64       `json:"sizeBytes"`
+	ModificationTime metav1.Time `json:"modificationTime"`
 }
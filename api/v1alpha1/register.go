@@ -23,10 +23,11 @@ import (
 )
 
 const (
-	NFSStorageClassKind = "NFSStorageClass"
-	APIGroup            = "storage.deckhouse.io"
-	APIVersion          = "v1alpha1"
-	APIGroupMC          = "deckhouse.io"
+	NFSStorageClassKind     = "NFSStorageClass"
+	NFSSnapshotScheduleKind = "NFSSnapshotSchedule"
+	APIGroup                = "storage.deckhouse.io"
+	APIVersion              = "v1alpha1"
+	APIGroupMC              = "deckhouse.io"
 )
 
 // SchemeGroupVersion is group version used to register these objects
@@ -49,6 +50,8 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 	scheme.AddKnownTypes(SchemeGroupVersion,
 		&NFSStorageClass{},
 		&NFSStorageClassList{},
+		&NFSSnapshotSchedule{},
+		&NFSSnapshotScheduleList{},
 	)
 	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
 	metav1.AddToGroupVersion(scheme, SchemeGroupVersionMC)
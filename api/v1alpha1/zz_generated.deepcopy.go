@@ -22,9 +22,189 @@ limitations under the License.
 package v1alpha1
 
 import (
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NFSSnapshotSchedule) DeepCopyInto(out *NFSSnapshotSchedule) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	if in.Status != nil {
+		in, out := &in.Status, &out.Status
+		*out = new(NFSSnapshotScheduleStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NFSSnapshotSchedule.
+func (in *NFSSnapshotSchedule) DeepCopy() *NFSSnapshotSchedule {
+	if in == nil {
+		return nil
+	}
+	out := new(NFSSnapshotSchedule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *NFSSnapshotSchedule) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NFSSnapshotScheduleFailure) DeepCopyInto(out *NFSSnapshotScheduleFailure) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NFSSnapshotScheduleFailure.
+func (in *NFSSnapshotScheduleFailure) DeepCopy() *NFSSnapshotScheduleFailure {
+	if in == nil {
+		return nil
+	}
+	out := new(NFSSnapshotScheduleFailure)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NFSSnapshotScheduleList) DeepCopyInto(out *NFSSnapshotScheduleList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]NFSSnapshotSchedule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NFSSnapshotScheduleList.
+func (in *NFSSnapshotScheduleList) DeepCopy() *NFSSnapshotScheduleList {
+	if in == nil {
+		return nil
+	}
+	out := new(NFSSnapshotScheduleList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *NFSSnapshotScheduleList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NFSSnapshotScheduleRetention) DeepCopyInto(out *NFSSnapshotScheduleRetention) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NFSSnapshotScheduleRetention.
+func (in *NFSSnapshotScheduleRetention) DeepCopy() *NFSSnapshotScheduleRetention {
+	if in == nil {
+		return nil
+	}
+	out := new(NFSSnapshotScheduleRetention)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NFSSnapshotScheduleSnapshot) DeepCopyInto(out *NFSSnapshotScheduleSnapshot) {
+	*out = *in
+	in.ScheduleTime.DeepCopyInto(&out.ScheduleTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NFSSnapshotScheduleSnapshot.
+func (in *NFSSnapshotScheduleSnapshot) DeepCopy() *NFSSnapshotScheduleSnapshot {
+	if in == nil {
+		return nil
+	}
+	out := new(NFSSnapshotScheduleSnapshot)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NFSSnapshotScheduleSpec) DeepCopyInto(out *NFSSnapshotScheduleSpec) {
+	*out = *in
+	if in.PVCSelector != nil {
+		in, out := &in.PVCSelector, &out.PVCSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Retention != nil {
+		in, out := &in.Retention, &out.Retention
+		*out = new(NFSSnapshotScheduleRetention)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NFSSnapshotScheduleSpec.
+func (in *NFSSnapshotScheduleSpec) DeepCopy() *NFSSnapshotScheduleSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(NFSSnapshotScheduleSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NFSSnapshotScheduleStatus) DeepCopyInto(out *NFSSnapshotScheduleStatus) {
+	*out = *in
+	if in.LastScheduleTime != nil {
+		in, out := &in.LastScheduleTime, &out.LastScheduleTime
+		*out = (*in).DeepCopy()
+	}
+	if in.NextScheduleTime != nil {
+		in, out := &in.NextScheduleTime, &out.NextScheduleTime
+		*out = (*in).DeepCopy()
+	}
+	if in.RecentSnapshots != nil {
+		in, out := &in.RecentSnapshots, &out.RecentSnapshots
+		*out = make([]NFSSnapshotScheduleSnapshot, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Failures != nil {
+		in, out := &in.Failures, &out.Failures
+		*out = make([]NFSSnapshotScheduleFailure, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NFSSnapshotScheduleStatus.
+func (in *NFSSnapshotScheduleStatus) DeepCopy() *NFSSnapshotScheduleStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(NFSSnapshotScheduleStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NFSStorageClass) DeepCopyInto(out *NFSStorageClass) {
 	*out = *in
@@ -34,7 +214,7 @@ func (in *NFSStorageClass) DeepCopyInto(out *NFSStorageClass) {
 	if in.Status != nil {
 		in, out := &in.Status, &out.Status
 		*out = new(NFSStorageClassStatus)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 	return
 }
@@ -127,6 +307,116 @@ func (in *NFSStorageClassMountOptions) DeepCopy() *NFSStorageClassMountOptions {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NFSStorageClassOrphan) DeepCopyInto(out *NFSStorageClassOrphan) {
+	*out = *in
+	in.ModificationTime.DeepCopyInto(&out.ModificationTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NFSStorageClassOrphan.
+func (in *NFSStorageClassOrphan) DeepCopy() *NFSStorageClassOrphan {
+	if in == nil {
+		return nil
+	}
+	out := new(NFSStorageClassOrphan)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NFSStorageClassOrphanCleanup) DeepCopyInto(out *NFSStorageClassOrphanCleanup) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NFSStorageClassOrphanCleanup.
+func (in *NFSStorageClassOrphanCleanup) DeepCopy() *NFSStorageClassOrphanCleanup {
+	if in == nil {
+		return nil
+	}
+	out := new(NFSStorageClassOrphanCleanup)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NFSStorageClassOrphans) DeepCopyInto(out *NFSStorageClassOrphans) {
+	*out = *in
+	in.LastScanTime.DeepCopyInto(&out.LastScanTime)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]NFSStorageClassOrphan, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NFSStorageClassOrphans.
+func (in *NFSStorageClassOrphans) DeepCopy() *NFSStorageClassOrphans {
+	if in == nil {
+		return nil
+	}
+	out := new(NFSStorageClassOrphans)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NFSStorageClassProjectQuota) DeepCopyInto(out *NFSStorageClassProjectQuota) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NFSStorageClassProjectQuota.
+func (in *NFSStorageClassProjectQuota) DeepCopy() *NFSStorageClassProjectQuota {
+	if in == nil {
+		return nil
+	}
+	out := new(NFSStorageClassProjectQuota)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NFSStorageClassSnapshotExport) DeepCopyInto(out *NFSStorageClassSnapshotExport) {
+	*out = *in
+	if in.S3 != nil {
+		in, out := &in.S3, &out.S3
+		*out = new(NFSStorageClassSnapshotExportS3)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NFSStorageClassSnapshotExport.
+func (in *NFSStorageClassSnapshotExport) DeepCopy() *NFSStorageClassSnapshotExport {
+	if in == nil {
+		return nil
+	}
+	out := new(NFSStorageClassSnapshotExport)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NFSStorageClassSnapshotExportS3) DeepCopyInto(out *NFSStorageClassSnapshotExportS3) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NFSStorageClassSnapshotExportS3.
+func (in *NFSStorageClassSnapshotExportS3) DeepCopy() *NFSStorageClassSnapshotExportS3 {
+	if in == nil {
+		return nil
+	}
+	out := new(NFSStorageClassSnapshotExportS3)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NFSStorageClassSpec) DeepCopyInto(out *NFSStorageClassSpec) {
 	*out = *in
@@ -140,6 +430,33 @@ func (in *NFSStorageClassSpec) DeepCopyInto(out *NFSStorageClassSpec) {
 		*out = new(NFSStorageClassMountOptions)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.WorkloadNodes != nil {
+		in, out := &in.WorkloadNodes, &out.WorkloadNodes
+		*out = new(NFSStorageClassWorkloadNodes)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SnapshotExport != nil {
+		in, out := &in.SnapshotExport, &out.SnapshotExport
+		*out = new(NFSStorageClassSnapshotExport)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.VolumeUsage != nil {
+		in, out := &in.VolumeUsage, &out.VolumeUsage
+		*out = new(NFSStorageClassVolumeUsage)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.OrphanCleanup != nil {
+		in, out := &in.OrphanCleanup, &out.OrphanCleanup
+		*out = new(NFSStorageClassOrphanCleanup)
+		**out = **in
+	}
+	if in.VolumeAttributesClasses != nil {
+		in, out := &in.VolumeAttributesClasses, &out.VolumeAttributesClasses
+		*out = make([]NFSStorageClassVolumeAttributesClass, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -156,6 +473,18 @@ func (in *NFSStorageClassSpec) DeepCopy() *NFSStorageClassSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NFSStorageClassStatus) DeepCopyInto(out *NFSStorageClassStatus) {
 	*out = *in
+	if in.Orphans != nil {
+		in, out := &in.Orphans, &out.Orphans
+		*out = new(NFSStorageClassOrphans)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -168,3 +497,66 @@ func (in *NFSStorageClassStatus) DeepCopy() *NFSStorageClassStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NFSStorageClassVolumeAttributesClass) DeepCopyInto(out *NFSStorageClassVolumeAttributesClass) {
+	*out = *in
+	if in.MountOptions != nil {
+		in, out := &in.MountOptions, &out.MountOptions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NFSStorageClassVolumeAttributesClass.
+func (in *NFSStorageClassVolumeAttributesClass) DeepCopy() *NFSStorageClassVolumeAttributesClass {
+	if in == nil {
+		return nil
+	}
+	out := new(NFSStorageClassVolumeAttributesClass)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NFSStorageClassVolumeUsage) DeepCopyInto(out *NFSStorageClassVolumeUsage) {
+	*out = *in
+	if in.ProjectQuota != nil {
+		in, out := &in.ProjectQuota, &out.ProjectQuota
+		*out = new(NFSStorageClassProjectQuota)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NFSStorageClassVolumeUsage.
+func (in *NFSStorageClassVolumeUsage) DeepCopy() *NFSStorageClassVolumeUsage {
+	if in == nil {
+		return nil
+	}
+	out := new(NFSStorageClassVolumeUsage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NFSStorageClassWorkloadNodes) DeepCopyInto(out *NFSStorageClassWorkloadNodes) {
+	*out = *in
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NFSStorageClassWorkloadNodes.
+func (in *NFSStorageClassWorkloadNodes) DeepCopy() *NFSStorageClassWorkloadNodes {
+	if in == nil {
+		return nil
+	}
+	out := new(NFSStorageClassWorkloadNodes)
+	in.DeepCopyInto(out)
+	return out
+}
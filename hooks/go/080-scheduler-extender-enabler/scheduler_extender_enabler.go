@@ -47,6 +47,8 @@ var (
 					AllowFailure:                 ptr(true),
 				},
 			},
+			// run on the changes of the module settings to switch between the scheduler extender and the webhook
+			OnBeforeHelm: &pkg.OrderedConfig{Order: 10},
 			Settings: &pkg.HookConfigSettings{
 				ExecutionMinInterval: time.Second * 3,
 				ExecutionBurst:       1,
@@ -125,9 +127,12 @@ func mainHook(ctx context.Context, input *pkg.HookInput) error {
 		}
 	}
 
+	// the workloadNodes are enforced either by the scheduler extender or by the mutating pod webhook
+	webhookMode := input.Values.Get(fmt.Sprintf("%v.workloadNodesEnforcement", consts.ModuleName)).String() == consts.WorkloadNodesEnforcementMutatingWebhook
 	enableLabel := fmt.Sprintf("%v.internal.shedulerExtenderEnabled", consts.ModuleName)
+	webhookEnableLabel := fmt.Sprintf("%v.internal.podWebhookEnabled", consts.ModuleName)
 
-	if shouldEnable {
+	if shouldEnable && !webhookMode {
 		fmt.Println("Enable scheduler extender")
 		input.Values.Set(enableLabel, true)
 	} else {
@@ -135,6 +140,14 @@ func mainHook(ctx context.Context, input *pkg.HookInput) error {
 		input.Values.Set(enableLabel, false)
 	}
 
+	if shouldEnable && webhookMode {
+		fmt.Println("Enable mutating pod webhook")
+		input.Values.Set(webhookEnableLabel, true)
+	} else {
+		fmt.Println("Disable mutating pod webhook")
+		input.Values.Set(webhookEnableLabel, false)
+	}
+
 	return nil
 }
 
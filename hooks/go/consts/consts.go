@@ -22,6 +22,8 @@ const (
 	ModulePluralName string = "csi-nfs"
 	WebhookCertCn    string = "webhooks"
 	SchedulerCertCn  string = "scheduler-extender"
+
+	WorkloadNodesEnforcementMutatingWebhook string = "MutatingWebhook"
 )
 
 var AllowedProvisioners = []string{
@@ -31,6 +31,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 
 	cn "github.com/deckhouse/csi-nfs/api/v1alpha1"
 	"github.com/deckhouse/csi-nfs/images/controller/pkg/config"
@@ -95,7 +96,9 @@ func main() {
 	managerOpts := manager.Options{
 		Scheme: scheme,
 		Cache:  cacheOpt,
-		//MetricsBindAddress: cfgParams.MetricsPort,
+		Metrics: metricsserver.Options{
+			BindAddress: cfgParams.MetricsBindAddress,
+		},
 		HealthProbeBindAddress:  cfgParams.HealthProbeBindAddress,
 		LeaderElection:          true,
 		LeaderElectionNamespace: cfgParams.ControllerNamespace,
@@ -122,6 +125,11 @@ func main() {
 
 	controller.RunNodeSelectorReconciler(ctx, mgr, *cfgParams, *log)
 
+	if err = controller.RunNFSSnapshotScheduleReconciler(mgr, *cfgParams, *log); err != nil {
+		log.Error(err, fmt.Sprintf("[main] unable to run %s", controller.NFSSnapshotScheduleReconcilerName))
+		os.Exit(1)
+	}
+
 	if err = mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		log.Error(err, "[main] unable to mgr.AddHealthzCheck")
 		os.Exit(1)
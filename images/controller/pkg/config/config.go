@@ -26,17 +26,19 @@ import (
 )
 
 const (
-	LogLevelEnvName                      = "LOG_LEVEL"
-	ControllerNamespaceEnv               = "CONTROLLER_NAMESPACE"
-	HardcodedControllerNS                = "d8-csi-nfs"
-	ControllerName                       = "d8-controller"
-	DefaultHealthProbeBindAddressEnvName = "HEALTH_PROBE_BIND_ADDRESS"
-	DefaultHealthProbeBindAddress        = ":8081"
-	DefaultRequeueStorageClassInterval   = 10
-	DefaultRequeueModuleConfigInterval   = 10
-	CsiNfsModuleName                     = "csi-nfs"
-	DefaultRequeueNodeSelectorInterval   = 10
-	ConfigSecretName                     = "d8-csi-nfs-controller-config"
+	LogLevelEnvName                        = "LOG_LEVEL"
+	ControllerNamespaceEnv                 = "CONTROLLER_NAMESPACE"
+	HardcodedControllerNS                  = "d8-csi-nfs"
+	ControllerName                         = "d8-controller"
+	DefaultHealthProbeBindAddressEnvName   = "HEALTH_PROBE_BIND_ADDRESS"
+	DefaultHealthProbeBindAddress          = ":8081"
+	DefaultMetricsBindAddress              = ":8080"
+	DefaultRequeueStorageClassInterval     = 10
+	DefaultRequeueModuleConfigInterval     = 10
+	CsiNfsModuleName                       = "csi-nfs"
+	DefaultRequeueNodeSelectorInterval     = 10
+	DefaultRequeueSnapshotScheduleInterval = 30
+	ConfigSecretName                       = "d8-csi-nfs-controller-config"
 	// StorageClassLabelIgnoredPrefixesEnvName carries a comma-separated list of label-key
 	// prefixes whose matching labels MUST NOT be propagated from an NFSStorageClass to
 	// the managed Kubernetes StorageClass.
@@ -44,14 +46,16 @@ const (
 )
 
 type Options struct {
-	Loglevel                    logger.Verbosity
-	RequeueStorageClassInterval time.Duration
-	RequeueModuleConfigInterval time.Duration
-	RequeueNodeSelectorInterval time.Duration
-	ConfigSecretName            string
-	HealthProbeBindAddress      string
-	ControllerNamespace         string
-	CsiNfsModuleName            string
+	Loglevel                        logger.Verbosity
+	RequeueStorageClassInterval     time.Duration
+	RequeueModuleConfigInterval     time.Duration
+	RequeueNodeSelectorInterval     time.Duration
+	RequeueSnapshotScheduleInterval time.Duration
+	ConfigSecretName                string
+	HealthProbeBindAddress          string
+	MetricsBindAddress              string
+	ControllerNamespace             string
+	CsiNfsModuleName                string
 	// StorageClassLabelIgnoredPrefixes is the union of a system (hardcoded in Helm
 	// internal values) and a user-configured (ModuleConfig) list of label-key prefixes.
 	// Labels on an NFSStorageClass whose keys start with any of these prefixes are
@@ -74,6 +78,8 @@ func NewConfig() *Options {
 		opts.HealthProbeBindAddress = DefaultHealthProbeBindAddress
 	}
 
+	opts.MetricsBindAddress = DefaultMetricsBindAddress
+
 	opts.ControllerNamespace = os.Getenv(ControllerNamespaceEnv)
 	if opts.ControllerNamespace == "" {
 		namespace, err := os.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/namespace")
@@ -92,6 +98,7 @@ func NewConfig() *Options {
 
 	opts.CsiNfsModuleName = CsiNfsModuleName
 	opts.RequeueNodeSelectorInterval = DefaultRequeueNodeSelectorInterval
+	opts.RequeueSnapshotScheduleInterval = DefaultRequeueSnapshotScheduleInterval
 	opts.ConfigSecretName = ConfigSecretName
 
 	opts.StorageClassLabelIgnoredPrefixes = parseStorageClassLabelIgnoredPrefixes(os.Getenv(StorageClassLabelIgnoredPrefixesEnvName))
@@ -27,7 +27,9 @@ import (
 	v1 "k8s.io/api/apps/v1"
 	coordinationv1 "k8s.io/api/coordination/v1"
 	sv1 "k8s.io/api/storage/v1"
+	storagev1beta1 "k8s.io/api/storage/v1beta1"
 	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	apiruntime "k8s.io/apimachinery/pkg/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -60,8 +62,12 @@ func NewFakeClient() client.Client {
 		}
 	}
 
+	// the version of the VolumeAttributesClasses is looked up in the RESTMapper
+	restMapper := meta.NewDefaultRESTMapper(nil)
+	restMapper.Add(storagev1beta1.SchemeGroupVersion.WithKind("VolumeAttributesClass"), meta.RESTScopeRoot)
+
 	// See https://github.com/kubernetes-sigs/controller-runtime/issues/2362#issuecomment-1837270195
-	builder := fake.NewClientBuilder().WithScheme(scheme).WithStatusSubresource(&v1alpha1.NFSStorageClass{})
+	builder := fake.NewClientBuilder().WithScheme(scheme).WithRESTMapper(restMapper).WithStatusSubresource(&v1alpha1.NFSStorageClass{}, &v1alpha1.NFSSnapshotSchedule{})
 
 	cl := builder.Build()
 	return cl
@@ -70,9 +70,11 @@ func RunModuleConfigWatcherController(
 
 			if mc.Name == "" {
 				log.Info(fmt.Sprintf("[ModuleConfigReconciler] seems like the ModuleConfig for the request %s was deleted. Reconcile retrying will stop.", request.Name))
+				tlsCertificateExpiration.Reset()
 				return reconcile.Result{}, nil
 			}
 
+			result := reconcile.Result{}
 			if mc.DeletionTimestamp != nil {
 				log.Debug(fmt.Sprintf("[ModuleConfigReconciler] reconcile operation for ModuleConfig %s: Delete", mc.Name))
 			} else {
@@ -97,16 +99,27 @@ func RunModuleConfigWatcherController(
 					log.Error(err, fmt.Sprintf("[ModuleConfigReconciler] an error occurred while reconciles the ModuleConfig, name: %s", mc.Name))
 				}
 
+				shouldRecheck, tlsErr := RunTLSCertificatesReconcile(ctx, cl, log, mc, nscList)
+				if tlsErr != nil {
+					log.Error(tlsErr, fmt.Sprintf("[ModuleConfigReconciler] an error occurred while checks the TLS certificates of the ModuleConfig, name: %s", mc.Name))
+					shouldRequeue = true
+				}
+
 				if shouldRequeue {
 					log.Warning(fmt.Sprintf("[ModuleConfigReconciler] Reconciler will requeue the request, name: %s", request.Name))
 					return reconcile.Result{
 						RequeueAfter: cfg.RequeueModuleConfigInterval * time.Second,
 					}, nil
 				}
+
+				if shouldRecheck {
+					log.Debug(fmt.Sprintf("[ModuleConfigReconciler] the TLS certificates of the ModuleConfig %s will be checked again in %s", mc.Name, tlsCertificatesRecheckInterval))
+					result.RequeueAfter = tlsCertificatesRecheckInterval
+				}
 			}
 
 			log.Info(fmt.Sprintf("[ModuleConfigReconciler] ends Reconcile for the ModuleConfig %q", request.Name))
-			return reconcile.Result{}, nil
+			return result, nil
 		}),
 	})
 	if err != nil {
@@ -232,8 +245,8 @@ func RunModuleConfigEventReconcile(
 func validateModuleConfig(log logger.Logger, mc *d8commonapi.ModuleConfig, nscList *v1alpha1.NFSStorageClassList) map[string]string {
 	alertMap := make(map[string]string)
 	for _, nsc := range nscList.Items {
-		if err := commonvalidating.ValidateNFSStorageClass(mc, &nsc); err != nil {
-			log.Warning(fmt.Sprintf("[validateModuleConfig] invalid NFSStorageClass (%v)", err))
+		if errs, _ := commonvalidating.ValidateNFSStorageClass(mc, &nsc); len(errs) > 0 {
+			log.Warning(fmt.Sprintf("[validateModuleConfig] invalid NFSStorageClass %s (%v)", nsc.Name, errs.ToAggregate()))
 			alertMap[nsc.Name] = "true"
 		}
 	}
@@ -0,0 +1,473 @@
+/*
+Copyright 2026 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
+	"github.com/robfig/cron/v3"
+	corev1 "k8s.io/api/core/v1"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	v1alpha1 "github.com/deckhouse/csi-nfs/api/v1alpha1"
+	"github.com/deckhouse/csi-nfs/images/controller/pkg/config"
+	"github.com/deckhouse/csi-nfs/images/controller/pkg/logger"
+)
+
+const (
+	NFSSnapshotScheduleReconcilerName = "nfs-snapshot-schedule-reconciler"
+
+	NFSSnapshotScheduleLabelKey          = "storage.deckhouse.io/nfs-snapshot-schedule"
+	NFSSnapshotScheduleTimeAnnotationKey = "storage.deckhouse.io/nfs-snapshot-schedule-time"
+
+	// snapshotScheduleStatusLimit is the number of recent snapshots and failures kept in the status
+	snapshotScheduleStatusLimit = 10
+
+	snapshotNameMaxLength  = 253
+	snapshotNameTimeFormat = "20060102-1504"
+)
+
+// RunNFSSnapshotScheduleReconciler periodically takes and prunes the snapshots of all NFSSnapshotSchedules.
+// It runs only on the leader, so a snapshot is never taken twice.
+func RunNFSSnapshotScheduleReconciler(mgr manager.Manager, cfg config.Options, log logger.Logger) error {
+	cl := mgr.GetClient()
+	clusterWideClient := mgr.GetAPIReader()
+
+	return mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+		for {
+			log.Info("Start reconcile of NFSSnapshotSchedules.")
+			err := ReconcileNFSSnapshotSchedules(ctx, cl, clusterWideClient, log, time.Now())
+			if err != nil {
+				log.Error(err, "Failed reconcile of NFSSnapshotSchedules.")
+			}
+			log.Info("END reconcile of NFSSnapshotSchedules.")
+
+			timer := time.NewTimer(cfg.RequeueSnapshotScheduleInterval * time.Second)
+
+			select {
+			case <-ctx.Done():
+				log.Info("Context cancelled. Stopping NFSSnapshotScheduleReconciler.")
+				timer.Stop()
+				return nil
+			case <-timer.C:
+			}
+		}
+	}))
+}
+
+func ReconcileNFSSnapshotSchedules(ctx context.Context, cl client.Client, clusterWideClient client.Reader, log logger.Logger, now time.Time) error {
+	schedules := &v1alpha1.NFSSnapshotScheduleList{}
+	err := clusterWideClient.List(ctx, schedules)
+	if err != nil {
+		err = fmt.Errorf("[ReconcileNFSSnapshotSchedules] Failed get NFSSnapshotSchedules: %w", err)
+		return err
+	}
+
+	log.Debug(fmt.Sprintf("[ReconcileNFSSnapshotSchedules] Found %d NFSSnapshotSchedules.", len(schedules.Items)))
+
+	var errs []error
+	for i := range schedules.Items {
+		schedule := &schedules.Items[i]
+		if schedule.DeletionTimestamp != nil {
+			continue
+		}
+		err := ReconcileNFSSnapshotSchedule(ctx, cl, clusterWideClient, log, schedule, now)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("[ReconcileNFSSnapshotSchedules] Failed reconcile NFSSnapshotSchedule %s/%s: %w", schedule.Namespace, schedule.Name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// ReconcileNFSSnapshotSchedule takes the snapshots of the latest missed schedule time, prunes the snapshots
+// not kept by the retention rules and updates the status. Errors of taking and pruning snapshots are reported
+// in the status, the returned error is about the status update only.
+func ReconcileNFSSnapshotSchedule(ctx context.Context, cl client.Client, clusterWideClient client.Reader, log logger.Logger, schedule *v1alpha1.NFSSnapshotSchedule, now time.Time) error {
+	newStatus := &v1alpha1.NFSSnapshotScheduleStatus{}
+	if schedule.Status != nil {
+		newStatus = schedule.Status.DeepCopy()
+	}
+	var failures []v1alpha1.NFSSnapshotScheduleFailure
+
+	cronSchedule, err := cron.ParseStandard(schedule.Spec.Schedule)
+	if err != nil {
+		log.Error(err, fmt.Sprintf("[ReconcileNFSSnapshotSchedule] invalid schedule of NFSSnapshotSchedule %s/%s", schedule.Namespace, schedule.Name))
+		failures = append(failures, newSnapshotScheduleFailure(now, "", "", fmt.Sprintf("invalid schedule %q: %v", schedule.Spec.Schedule, err)))
+		newStatus.NextScheduleTime = nil
+	} else {
+		since := schedule.CreationTimestamp.Time
+		if newStatus.LastScheduleTime != nil {
+			since = newStatus.LastScheduleTime.Time
+		}
+
+		scheduleTime := GetLastScheduleTime(cronSchedule, since, now)
+		switch {
+		case scheduleTime.IsZero():
+			log.Debug(fmt.Sprintf("[ReconcileNFSSnapshotSchedule] NFSSnapshotSchedule %s/%s is not due yet", schedule.Namespace, schedule.Name))
+		case schedule.Spec.Suspend:
+			log.Debug(fmt.Sprintf("[ReconcileNFSSnapshotSchedule] NFSSnapshotSchedule %s/%s is suspended", schedule.Namespace, schedule.Name))
+		default:
+			log.Info(fmt.Sprintf("[ReconcileNFSSnapshotSchedule] take snapshots of NFSSnapshotSchedule %s/%s scheduled at %s", schedule.Namespace, schedule.Name, scheduleTime.UTC().Format(time.RFC3339)))
+			failures = append(failures, takeScheduledSnapshots(ctx, cl, clusterWideClient, log, schedule, scheduleTime, now)...)
+			newStatus.LastScheduleTime = &metav1.Time{Time: scheduleTime}
+		}
+
+		nextScheduleTime := metav1.NewTime(cronSchedule.Next(now))
+		newStatus.NextScheduleTime = &nextScheduleTime
+	}
+
+	snapshots, pruneFailures := pruneScheduledSnapshots(ctx, cl, clusterWideClient, log, schedule, now)
+	failures = append(failures, pruneFailures...)
+
+	newStatus.RecentSnapshots = GetRecentScheduledSnapshots(snapshots)
+	for _, snapshot := range snapshots {
+		if snapshot.Status != nil && snapshot.Status.Error != nil && snapshot.Status.Error.Message != nil {
+			failureTime := now
+			if snapshot.Status.Error.Time != nil {
+				failureTime = snapshot.Status.Error.Time.Time
+			}
+			failures = append(failures, newSnapshotScheduleFailure(failureTime, getSnapshotSourcePVCName(&snapshot), snapshot.Name, *snapshot.Status.Error.Message))
+		}
+	}
+	newStatus.Failures = MergeSnapshotScheduleFailures(newStatus.Failures, failures)
+
+	if schedule.Status != nil && reflect.DeepEqual(*schedule.Status, *newStatus) {
+		return nil
+	}
+
+	schedule.Status = newStatus
+	err = cl.Status().Update(ctx, schedule)
+	if err != nil {
+		err = fmt.Errorf("[ReconcileNFSSnapshotSchedule] Failed update status of NFSSnapshotSchedule %s/%s: %w", schedule.Namespace, schedule.Name, err)
+		return err
+	}
+	log.Debug(fmt.Sprintf("[ReconcileNFSSnapshotSchedule] successfully updated status of NFSSnapshotSchedule %s/%s", schedule.Namespace, schedule.Name))
+
+	return nil
+}
+
+// GetLastScheduleTime returns the latest schedule time after since that is not after now, or the zero time if
+// there is none. Only the latest of the missed schedule times is taken, like a CronJob does.
+func GetLastScheduleTime(cronSchedule cron.Schedule, since, now time.Time) time.Time {
+	var last time.Time
+	for t := cronSchedule.Next(since); !t.IsZero() && !t.After(now); t = cronSchedule.Next(t) {
+		last = t
+	}
+	return last
+}
+
+func takeScheduledSnapshots(ctx context.Context, cl client.Client, clusterWideClient client.Reader, log logger.Logger, schedule *v1alpha1.NFSSnapshotSchedule, scheduleTime, now time.Time) []v1alpha1.NFSSnapshotScheduleFailure {
+	nsc := &v1alpha1.NFSStorageClass{}
+	err := clusterWideClient.Get(ctx, types.NamespacedName{Name: schedule.Spec.StorageClassName}, nsc)
+	if err != nil {
+		return []v1alpha1.NFSSnapshotScheduleFailure{newSnapshotScheduleFailure(now, "", "", fmt.Sprintf("unable to get NFSStorageClass %s: %v", schedule.Spec.StorageClassName, err))}
+	}
+
+	pvcs, err := GetScheduledPersistentVolumeClaims(ctx, clusterWideClient, schedule)
+	if err != nil {
+		return []v1alpha1.NFSSnapshotScheduleFailure{newSnapshotScheduleFailure(now, "", "", err.Error())}
+	}
+	log.Debug(fmt.Sprintf("[takeScheduledSnapshots] Found %d PersistentVolumeClaims for NFSSnapshotSchedule %s/%s.", len(pvcs), schedule.Namespace, schedule.Name))
+
+	var failures []v1alpha1.NFSSnapshotScheduleFailure
+	for _, pvc := range pvcs {
+		snapshot := ConfigureScheduledSnapshot(schedule, pvc.Name, scheduleTime)
+		err := cl.Create(ctx, snapshot)
+		if err != nil && !k8serr.IsAlreadyExists(err) {
+			log.Error(err, fmt.Sprintf("[takeScheduledSnapshots] unable to create VolumeSnapshot %s/%s", snapshot.Namespace, snapshot.Name))
+			failures = append(failures, newSnapshotScheduleFailure(now, pvc.Name, snapshot.Name, fmt.Sprintf("unable to create VolumeSnapshot: %v", err)))
+			continue
+		}
+		log.Info(fmt.Sprintf("[takeScheduledSnapshots] VolumeSnapshot %s/%s of PVC %s is created", snapshot.Namespace, snapshot.Name, pvc.Name))
+	}
+
+	return failures
+}
+
+// GetScheduledPersistentVolumeClaims returns the bound PersistentVolumeClaims of the schedule's StorageClass
+// selected by its pvcSelector.
+func GetScheduledPersistentVolumeClaims(ctx context.Context, clusterWideClient client.Reader, schedule *v1alpha1.NFSSnapshotSchedule) ([]corev1.PersistentVolumeClaim, error) {
+	listOpts := []client.ListOption{client.InNamespace(schedule.Namespace)}
+	if schedule.Spec.PVCSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(schedule.Spec.PVCSelector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pvcSelector: %w", err)
+		}
+		listOpts = append(listOpts, client.MatchingLabelsSelector{Selector: selector})
+	}
+
+	pvcList := &corev1.PersistentVolumeClaimList{}
+	err := clusterWideClient.List(ctx, pvcList, listOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list PersistentVolumeClaims: %w", err)
+	}
+
+	var pvcs []corev1.PersistentVolumeClaim
+	for _, pvc := range pvcList.Items {
+		if pvc.DeletionTimestamp != nil || pvc.Status.Phase != corev1.ClaimBound {
+			continue
+		}
+		if pvc.Spec.StorageClassName == nil || *pvc.Spec.StorageClassName != schedule.Spec.StorageClassName {
+			continue
+		}
+		pvcs = append(pvcs, pvc)
+	}
+
+	return pvcs, nil
+}
+
+// ConfigureScheduledSnapshot returns the VolumeSnapshot of the PVC for the schedule time. The name is derived
+// from the schedule time, so a retried attempt never takes a second snapshot.
+func ConfigureScheduledSnapshot(schedule *v1alpha1.NFSSnapshotSchedule, pvcName string, scheduleTime time.Time) *snapshotv1.VolumeSnapshot {
+	vsClassName := schedule.Spec.StorageClassName
+	sourcePVCName := pvcName
+
+	return &snapshotv1.VolumeSnapshot{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      GetScheduledSnapshotName(schedule.Name, pvcName, scheduleTime),
+			Namespace: schedule.Namespace,
+			Labels: map[string]string{
+				NFSSnapshotScheduleLabelKey: schedule.Name,
+			},
+			Annotations: map[string]string{
+				NFSSnapshotScheduleTimeAnnotationKey: scheduleTime.UTC().Format(time.RFC3339),
+			},
+		},
+		Spec: snapshotv1.VolumeSnapshotSpec{
+			Source: snapshotv1.VolumeSnapshotSource{
+				PersistentVolumeClaimName: &sourcePVCName,
+			},
+			VolumeSnapshotClassName: &vsClassName,
+		},
+	}
+}
+
+func GetScheduledSnapshotName(scheduleName, pvcName string, scheduleTime time.Time) string {
+	suffix := "-" + scheduleTime.UTC().Format(snapshotNameTimeFormat)
+	name := scheduleName + "-" + pvcName
+	if len(name)+len(suffix) > snapshotNameMaxLength {
+		hash := sha256.Sum256([]byte(name))
+		hashSuffix := "-" + hex.EncodeToString(hash[:])[:8]
+		name = name[:snapshotNameMaxLength-len(suffix)-len(hashSuffix)] + hashSuffix
+	}
+	return name + suffix
+}
+
+// pruneScheduledSnapshots deletes the snapshots of the schedule not kept by its retention rules and returns the
+// remaining ones.
+func pruneScheduledSnapshots(ctx context.Context, cl client.Client, clusterWideClient client.Reader, log logger.Logger, schedule *v1alpha1.NFSSnapshotSchedule, now time.Time) ([]snapshotv1.VolumeSnapshot, []v1alpha1.NFSSnapshotScheduleFailure) {
+	snapshotList := &snapshotv1.VolumeSnapshotList{}
+	err := clusterWideClient.List(ctx, snapshotList, client.InNamespace(schedule.Namespace), client.MatchingLabels{NFSSnapshotScheduleLabelKey: schedule.Name})
+	if err != nil {
+		return nil, []v1alpha1.NFSSnapshotScheduleFailure{newSnapshotScheduleFailure(now, "", "", fmt.Sprintf("unable to list VolumeSnapshots: %v", err))}
+	}
+
+	snapshotsByPVC := make(map[string][]snapshotv1.VolumeSnapshot)
+	for _, snapshot := range snapshotList.Items {
+		if snapshot.DeletionTimestamp != nil {
+			continue
+		}
+		pvcName := getSnapshotSourcePVCName(&snapshot)
+		snapshotsByPVC[pvcName] = append(snapshotsByPVC[pvcName], snapshot)
+	}
+
+	var remaining []snapshotv1.VolumeSnapshot
+	var failures []v1alpha1.NFSSnapshotScheduleFailure
+	for pvcName, snapshots := range snapshotsByPVC {
+		toPrune := SelectSnapshotsToPrune(snapshots, schedule.Spec.Retention)
+		pruned := make(map[string]struct{}, len(toPrune))
+		for _, snapshot := range toPrune {
+			err := cl.Delete(ctx, &snapshot)
+			if err != nil && !k8serr.IsNotFound(err) {
+				log.Error(err, fmt.Sprintf("[pruneScheduledSnapshots] unable to delete VolumeSnapshot %s/%s", snapshot.Namespace, snapshot.Name))
+				failures = append(failures, newSnapshotScheduleFailure(now, pvcName, snapshot.Name, fmt.Sprintf("unable to delete VolumeSnapshot: %v", err)))
+				continue
+			}
+			log.Info(fmt.Sprintf("[pruneScheduledSnapshots] VolumeSnapshot %s/%s of PVC %s is pruned", snapshot.Namespace, snapshot.Name, pvcName))
+			pruned[snapshot.Name] = struct{}{}
+		}
+
+		for _, snapshot := range snapshots {
+			if _, ok := pruned[snapshot.Name]; !ok {
+				remaining = append(remaining, snapshot)
+			}
+		}
+	}
+
+	return remaining, failures
+}
+
+// SelectSnapshotsToPrune returns the snapshots of a single PVC that are kept by none of the retention rules:
+// the keepLast latest ready to use snapshots, the latest snapshot of each of the keepDaily latest days and the
+// latest snapshot of each of the keepWeekly latest weeks are kept. The failed snapshots are not counted by keepLast,
+// and the ones not ready yet are kept by it while newer than every ready one. Days and weeks are counted in UTC.
+// Without any rule nothing is pruned.
+func SelectSnapshotsToPrune(snapshots []snapshotv1.VolumeSnapshot, retention *v1alpha1.NFSSnapshotScheduleRetention) []snapshotv1.VolumeSnapshot {
+	if retention == nil || (retention.KeepLast == 0 && retention.KeepDaily == 0 && retention.KeepWeekly == 0) {
+		return nil
+	}
+
+	sorted := make([]snapshotv1.VolumeSnapshot, len(snapshots))
+	copy(sorted, snapshots)
+	sortSnapshotsNewestFirst(sorted)
+
+	keep := make(map[string]struct{})
+	days := make(map[string]struct{})
+	weeks := make(map[string]struct{})
+	ready := 0
+	for _, snapshot := range sorted {
+		switch {
+		case isSnapshotReadyToUse(&snapshot):
+			if ready < retention.KeepLast {
+				keep[snapshot.Name] = struct{}{}
+			}
+			ready++
+		case snapshot.Status != nil && snapshot.Status.Error != nil:
+		case ready == 0 && retention.KeepLast > 0:
+			// still being taken
+			keep[snapshot.Name] = struct{}{}
+		}
+
+		snapshotTime := getScheduledSnapshotTime(&snapshot).UTC()
+		day := snapshotTime.Format(time.DateOnly)
+		if _, ok := days[day]; !ok && len(days) < retention.KeepDaily {
+			days[day] = struct{}{}
+			keep[snapshot.Name] = struct{}{}
+		}
+
+		year, week := snapshotTime.ISOWeek()
+		weekKey := fmt.Sprintf("%d-%d", year, week)
+		if _, ok := weeks[weekKey]; !ok && len(weeks) < retention.KeepWeekly {
+			weeks[weekKey] = struct{}{}
+			keep[snapshot.Name] = struct{}{}
+		}
+	}
+
+	var toPrune []snapshotv1.VolumeSnapshot
+	for _, snapshot := range sorted {
+		if _, ok := keep[snapshot.Name]; !ok {
+			toPrune = append(toPrune, snapshot)
+		}
+	}
+	return toPrune
+}
+
+func GetRecentScheduledSnapshots(snapshots []snapshotv1.VolumeSnapshot) []v1alpha1.NFSSnapshotScheduleSnapshot {
+	sorted := make([]snapshotv1.VolumeSnapshot, len(snapshots))
+	copy(sorted, snapshots)
+	sortSnapshotsNewestFirst(sorted)
+
+	var recent []v1alpha1.NFSSnapshotScheduleSnapshot
+	for _, snapshot := range sorted {
+		if len(recent) == snapshotScheduleStatusLimit {
+			break
+		}
+		recent = append(recent, v1alpha1.NFSSnapshotScheduleSnapshot{
+			Name:                      snapshot.Name,
+			PersistentVolumeClaimName: getSnapshotSourcePVCName(&snapshot),
+			ScheduleTime:              metav1.NewTime(getScheduledSnapshotTime(&snapshot)),
+			ReadyToUse:                isSnapshotReadyToUse(&snapshot),
+		})
+	}
+	return recent
+}
+
+// MergeSnapshotScheduleFailures adds the new failures to the reported ones and keeps the latest of them.
+// A failure that is already reported for the same PVC and snapshot keeps the time it was first reported at,
+// so a persistent error does not push the other failures out of the status.
+func MergeSnapshotScheduleFailures(reported, failures []v1alpha1.NFSSnapshotScheduleFailure) []v1alpha1.NFSSnapshotScheduleFailure {
+	merged := make([]v1alpha1.NFSSnapshotScheduleFailure, 0, len(reported)+len(failures))
+	merged = append(merged, reported...)
+	for _, failure := range failures {
+		found := false
+		for _, r := range merged {
+			if r.PersistentVolumeClaimName == failure.PersistentVolumeClaimName && r.SnapshotName == failure.SnapshotName &&
+				r.Message == failure.Message {
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, failure)
+		}
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[j].Time.Before(&merged[i].Time)
+	})
+	if len(merged) > snapshotScheduleStatusLimit {
+		merged = merged[:snapshotScheduleStatusLimit]
+	}
+	if len(merged) == 0 {
+		return nil
+	}
+	return merged
+}
+
+func sortSnapshotsNewestFirst(snapshots []snapshotv1.VolumeSnapshot) {
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		ti, tj := getScheduledSnapshotTime(&snapshots[i]), getScheduledSnapshotTime(&snapshots[j])
+		if !ti.Equal(tj) {
+			return ti.After(tj)
+		}
+		return snapshots[i].Name > snapshots[j].Name
+	})
+}
+
+// getScheduledSnapshotTime returns the schedule time the snapshot was taken for, or its creation time if the
+// annotation is missing or malformed.
+func getScheduledSnapshotTime(snapshot *snapshotv1.VolumeSnapshot) time.Time {
+	if value, ok := snapshot.Annotations[NFSSnapshotScheduleTimeAnnotationKey]; ok {
+		if t, err := time.Parse(time.RFC3339, value); err == nil {
+			return t
+		}
+	}
+	return snapshot.CreationTimestamp.Time
+}
+
+func isSnapshotReadyToUse(snapshot *snapshotv1.VolumeSnapshot) bool {
+	return snapshot.Status != nil && snapshot.Status.ReadyToUse != nil && *snapshot.Status.ReadyToUse
+}
+
+func getSnapshotSourcePVCName(snapshot *snapshotv1.VolumeSnapshot) string {
+	if snapshot.Spec.Source.PersistentVolumeClaimName == nil {
+		return ""
+	}
+	return *snapshot.Spec.Source.PersistentVolumeClaimName
+}
+
+func newSnapshotScheduleFailure(t time.Time, pvcName, snapshotName, message string) v1alpha1.NFSSnapshotScheduleFailure {
+	return v1alpha1.NFSSnapshotScheduleFailure{
+		Time:                      metav1.NewTime(t),
+		PersistentVolumeClaimName: pvcName,
+		SnapshotName:              snapshotName,
+		Message:                   message,
+	}
+}
@@ -0,0 +1,276 @@
+/*
+Copyright 2026 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller_test
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	v1alpha1 "github.com/deckhouse/csi-nfs/api/v1alpha1"
+	"github.com/deckhouse/csi-nfs/images/controller/pkg/controller"
+	"github.com/deckhouse/csi-nfs/images/controller/pkg/logger"
+)
+
+var _ = Describe(controller.NFSSnapshotScheduleReconcilerName, func() {
+	var (
+		ctx           context.Context
+		cl            client.Client
+		log           logger.Logger
+		testNamespace string
+		scName        string
+		created       time.Time
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		log = logger.Logger{}
+		cl = NewFakeClient()
+		testNamespace = "test-namespace"
+		scName = "test-nfs-sc"
+		created = time.Date(2026, 3, 2, 0, 30, 0, 0, time.UTC)
+
+		nsc := generateNFSStorageClass(NFSStorageClassConfig{
+			Name:              scName,
+			Host:              "server",
+			Share:             "/share",
+			NFSVersion:        "4.1",
+			MountMode:         "hard",
+			ReclaimPolicy:     string(corev1.PersistentVolumeReclaimDelete),
+			VolumeBindingMode: string(storagev1.VolumeBindingWaitForFirstConsumer),
+		})
+		Expect(cl.Create(ctx, nsc)).To(Succeed())
+
+		prepareScheduledPVC(ctx, cl, testNamespace, "pvc-selected", scName, map[string]string{"backup": "daily"}, corev1.ClaimBound)
+		prepareScheduledPVC(ctx, cl, testNamespace, "pvc-not-selected", scName, map[string]string{"backup": "never"}, corev1.ClaimBound)
+		prepareScheduledPVC(ctx, cl, testNamespace, "pvc-other-sc", "other-sc", map[string]string{"backup": "daily"}, corev1.ClaimBound)
+		prepareScheduledPVC(ctx, cl, testNamespace, "pvc-pending", scName, map[string]string{"backup": "daily"}, corev1.ClaimPending)
+		prepareScheduledPVC(ctx, cl, "other-namespace", "pvc-other-ns", scName, map[string]string{"backup": "daily"}, corev1.ClaimBound)
+	})
+
+	It("Takes snapshots of the selected PVCs once per schedule time", func() {
+		schedule := prepareSnapshotSchedule(ctx, cl, testNamespace, "daily", scName, "0 3 * * *", created, nil)
+
+		// not due yet
+		now := time.Date(2026, 3, 2, 2, 0, 0, 0, time.UTC)
+		Expect(controller.ReconcileNFSSnapshotSchedules(ctx, cl, cl, log, now)).To(Succeed())
+		Expect(listScheduledSnapshots(ctx, cl, testNamespace, schedule.Name)).To(BeEmpty())
+		schedule = getSnapshotSchedule(ctx, cl, testNamespace, schedule.Name)
+		Expect(schedule.Status.LastScheduleTime).To(BeNil())
+		Expect(schedule.Status.NextScheduleTime.Time).To(BeTemporally("==", time.Date(2026, 3, 2, 3, 0, 0, 0, time.UTC)))
+
+		now = time.Date(2026, 3, 2, 3, 0, 20, 0, time.UTC)
+		Expect(controller.ReconcileNFSSnapshotSchedules(ctx, cl, cl, log, now)).To(Succeed())
+		Expect(controller.ReconcileNFSSnapshotSchedules(ctx, cl, cl, log, now.Add(time.Minute))).To(Succeed())
+
+		snapshots := listScheduledSnapshots(ctx, cl, testNamespace, schedule.Name)
+		Expect(snapshots).To(HaveLen(1))
+		Expect(snapshots[0].Name).To(Equal("daily-pvc-selected-20260302-0300"))
+		Expect(*snapshots[0].Spec.Source.PersistentVolumeClaimName).To(Equal("pvc-selected"))
+		Expect(*snapshots[0].Spec.VolumeSnapshotClassName).To(Equal(scName))
+
+		schedule = getSnapshotSchedule(ctx, cl, testNamespace, schedule.Name)
+		Expect(schedule.Status.LastScheduleTime.Time).To(BeTemporally("==", time.Date(2026, 3, 2, 3, 0, 0, 0, time.UTC)))
+		Expect(schedule.Status.NextScheduleTime.Time).To(BeTemporally("==", time.Date(2026, 3, 3, 3, 0, 0, 0, time.UTC)))
+		Expect(schedule.Status.RecentSnapshots).To(HaveLen(1))
+		Expect(schedule.Status.RecentSnapshots[0].PersistentVolumeClaimName).To(Equal("pvc-selected"))
+		Expect(schedule.Status.Failures).To(BeEmpty())
+	})
+
+	It("Takes only the latest missed schedule time and skips suspended schedules", func() {
+		schedule := prepareSnapshotSchedule(ctx, cl, testNamespace, "hourly", scName, "0 * * * *", created, nil)
+		schedule.Spec.Suspend = true
+		Expect(cl.Update(ctx, schedule)).To(Succeed())
+
+		now := time.Date(2026, 3, 2, 5, 30, 0, 0, time.UTC)
+		Expect(controller.ReconcileNFSSnapshotSchedules(ctx, cl, cl, log, now)).To(Succeed())
+		Expect(listScheduledSnapshots(ctx, cl, testNamespace, schedule.Name)).To(BeEmpty())
+
+		schedule = getSnapshotSchedule(ctx, cl, testNamespace, schedule.Name)
+		schedule.Spec.Suspend = false
+		Expect(cl.Update(ctx, schedule)).To(Succeed())
+
+		Expect(controller.ReconcileNFSSnapshotSchedules(ctx, cl, cl, log, now)).To(Succeed())
+		snapshots := listScheduledSnapshots(ctx, cl, testNamespace, schedule.Name)
+		Expect(snapshots).To(HaveLen(1))
+		Expect(snapshots[0].Name).To(Equal("hourly-pvc-selected-20260302-0500"))
+	})
+
+	It("Reports an invalid schedule and a missing NFSStorageClass as failures", func() {
+		prepareSnapshotSchedule(ctx, cl, testNamespace, "invalid", scName, "every day", created, nil)
+		missing := prepareSnapshotSchedule(ctx, cl, testNamespace, "missing-sc", "missing-sc", "0 3 * * *", created, nil)
+
+		now := time.Date(2026, 3, 2, 3, 0, 0, 0, time.UTC)
+		Expect(controller.ReconcileNFSSnapshotSchedules(ctx, cl, cl, log, now)).To(Succeed())
+		Expect(controller.ReconcileNFSSnapshotSchedules(ctx, cl, cl, log, now.Add(time.Minute))).To(Succeed())
+
+		schedule := getSnapshotSchedule(ctx, cl, testNamespace, "invalid")
+		Expect(schedule.Status.Failures).To(HaveLen(1))
+		Expect(schedule.Status.Failures[0].Message).To(ContainSubstring("invalid schedule"))
+		Expect(schedule.Status.NextScheduleTime).To(BeNil())
+
+		schedule = getSnapshotSchedule(ctx, cl, testNamespace, missing.Name)
+		Expect(schedule.Status.Failures).To(HaveLen(1))
+		Expect(schedule.Status.Failures[0].Message).To(ContainSubstring("unable to get NFSStorageClass missing-sc"))
+		Expect(listScheduledSnapshots(ctx, cl, testNamespace, missing.Name)).To(BeEmpty())
+	})
+
+	It("Prunes the snapshots not kept by the retention rules", func() {
+		schedule := prepareSnapshotSchedule(ctx, cl, testNamespace, "daily", scName, "0 3 * * *", created, &v1alpha1.NFSSnapshotScheduleRetention{KeepLast: 2})
+
+		for day := 2; day <= 5; day++ {
+			now := time.Date(2026, 3, day, 3, 0, 0, 0, time.UTC)
+			Expect(controller.ReconcileNFSSnapshotSchedules(ctx, cl, cl, log, now)).To(Succeed())
+			// the snapshots are taken before the next run
+			for _, snapshot := range listScheduledSnapshots(ctx, cl, testNamespace, schedule.Name) {
+				snapshot.Status = &snapshotv1.VolumeSnapshotStatus{ReadyToUse: ptr.To(true)}
+				Expect(cl.Update(ctx, &snapshot)).To(Succeed())
+			}
+		}
+
+		scheduledNames := func() []string {
+			var names []string
+			for _, snapshot := range listScheduledSnapshots(ctx, cl, testNamespace, schedule.Name) {
+				names = append(names, snapshot.Name)
+			}
+			return names
+		}
+		// the last snapshot was not ready when the others were pruned
+		Expect(scheduledNames()).To(ConsistOf("daily-pvc-selected-20260303-0300", "daily-pvc-selected-20260304-0300", "daily-pvc-selected-20260305-0300"))
+		Expect(controller.ReconcileNFSSnapshotSchedules(ctx, cl, cl, log, time.Date(2026, 3, 5, 4, 0, 0, 0, time.UTC))).To(Succeed())
+		Expect(scheduledNames()).To(ConsistOf("daily-pvc-selected-20260304-0300", "daily-pvc-selected-20260305-0300"))
+
+		schedule = getSnapshotSchedule(ctx, cl, testNamespace, schedule.Name)
+		Expect(schedule.Status.RecentSnapshots).To(HaveLen(2))
+		Expect(schedule.Status.RecentSnapshots[0].Name).To(Equal("daily-pvc-selected-20260305-0300"))
+	})
+
+	It("Selects snapshots to prune by keep-last, keep-daily and keep-weekly rules", func() {
+		// Monday 2026-03-02 ... Sunday 2026-03-15, two snapshots a day
+		var snapshots []snapshotv1.VolumeSnapshot
+		for day := 2; day <= 15; day++ {
+			for _, hour := range []int{3, 15} {
+				t := time.Date(2026, 3, day, hour, 0, 0, 0, time.UTC)
+				snapshot := controller.ConfigureScheduledSnapshot(&v1alpha1.NFSSnapshotSchedule{
+					ObjectMeta: metav1.ObjectMeta{Name: "s", Namespace: testNamespace},
+					Spec:       v1alpha1.NFSSnapshotScheduleSpec{StorageClassName: scName},
+				}, "pvc", t)
+				snapshot.Status = &snapshotv1.VolumeSnapshotStatus{ReadyToUse: ptr.To(true)}
+				snapshots = append(snapshots, *snapshot)
+			}
+		}
+
+		Expect(controller.SelectSnapshotsToPrune(snapshots, nil)).To(BeEmpty())
+		Expect(controller.SelectSnapshotsToPrune(snapshots, &v1alpha1.NFSSnapshotScheduleRetention{})).To(BeEmpty())
+
+		kept := func(retention *v1alpha1.NFSSnapshotScheduleRetention) []string {
+			pruned := map[string]bool{}
+			for _, snapshot := range controller.SelectSnapshotsToPrune(snapshots, retention) {
+				pruned[snapshot.Name] = true
+			}
+			var names []string
+			for _, snapshot := range snapshots {
+				if !pruned[snapshot.Name] {
+					names = append(names, strings.TrimPrefix(snapshot.Name, "s-pvc-"))
+				}
+			}
+			return names
+		}
+
+		Expect(kept(&v1alpha1.NFSSnapshotScheduleRetention{KeepLast: 3})).To(ConsistOf("20260314-1500", "20260315-0300", "20260315-1500"))
+		Expect(kept(&v1alpha1.NFSSnapshotScheduleRetention{KeepDaily: 2})).To(ConsistOf("20260314-1500", "20260315-1500"))
+		// weeks start on Monday: 2026-03-08 and 2026-03-15 are the last days of their weeks
+		Expect(kept(&v1alpha1.NFSSnapshotScheduleRetention{KeepWeekly: 2})).To(ConsistOf("20260308-1500", "20260315-1500"))
+		Expect(kept(&v1alpha1.NFSSnapshotScheduleRetention{KeepLast: 1, KeepDaily: 2, KeepWeekly: 2})).To(ConsistOf("20260308-1500", "20260314-1500", "20260315-1500"))
+
+		// keepLast counts only the ready snapshots: the failed ones are pruned, the newest one is still being taken
+		snapshots[len(snapshots)-1].Status = &snapshotv1.VolumeSnapshotStatus{ReadyToUse: ptr.To(false)}
+		snapshots[len(snapshots)-2].Status = &snapshotv1.VolumeSnapshotStatus{ReadyToUse: ptr.To(false), Error: &snapshotv1.VolumeSnapshotError{Message: ptr.To("failed")}}
+		snapshots[len(snapshots)-4].Status = &snapshotv1.VolumeSnapshotStatus{ReadyToUse: ptr.To(false), Error: &snapshotv1.VolumeSnapshotError{Message: ptr.To("failed")}}
+		Expect(kept(&v1alpha1.NFSSnapshotScheduleRetention{KeepLast: 3})).To(ConsistOf("20260313-0300", "20260313-1500", "20260314-1500", "20260315-1500"))
+		// a snapshot not ready older than a ready one is not kept
+		snapshots[len(snapshots)-5].Status = &snapshotv1.VolumeSnapshotStatus{}
+		Expect(kept(&v1alpha1.NFSSnapshotScheduleRetention{KeepLast: 2})).To(ConsistOf("20260313-0300", "20260314-1500", "20260315-1500"))
+	})
+
+	It("Limits the length of the snapshot name", func() {
+		name := controller.GetScheduledSnapshotName(strings.Repeat("s", 63), strings.Repeat("p", 253), time.Date(2026, 3, 2, 3, 0, 0, 0, time.UTC))
+		Expect(len(name)).To(Equal(253))
+		Expect(name).To(HaveSuffix("-20260302-0300"))
+	})
+})
+
+func prepareScheduledPVC(ctx context.Context, cl client.Client, namespace, name, scName string, labels map[string]string, phase corev1.PersistentVolumeClaimPhase) {
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    labels,
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			StorageClassName: ptr.To(scName),
+		},
+		Status: corev1.PersistentVolumeClaimStatus{
+			Phase: phase,
+		},
+	}
+	Expect(cl.Create(ctx, pvc)).To(Succeed())
+}
+
+func prepareSnapshotSchedule(ctx context.Context, cl client.Client, namespace, name, scName, cronSchedule string, created time.Time, retention *v1alpha1.NFSSnapshotScheduleRetention) *v1alpha1.NFSSnapshotSchedule {
+	schedule := &v1alpha1.NFSSnapshotSchedule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         namespace,
+			CreationTimestamp: metav1.NewTime(created),
+		},
+		Spec: v1alpha1.NFSSnapshotScheduleSpec{
+			Schedule:         cronSchedule,
+			StorageClassName: scName,
+			PVCSelector: &metav1.LabelSelector{
+				MatchExpressions: []metav1.LabelSelectorRequirement{
+					{Key: "backup", Operator: metav1.LabelSelectorOpIn, Values: []string{"daily"}},
+				},
+			},
+			Retention: retention,
+		},
+	}
+	Expect(cl.Create(ctx, schedule)).To(Succeed())
+	return schedule
+}
+
+func getSnapshotSchedule(ctx context.Context, cl client.Client, namespace, name string) *v1alpha1.NFSSnapshotSchedule {
+	schedule := &v1alpha1.NFSSnapshotSchedule{}
+	Expect(cl.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, schedule)).To(Succeed())
+	Expect(schedule.Status).NotTo(BeNil())
+	return schedule
+}
+
+func listScheduledSnapshots(ctx context.Context, cl client.Client, namespace, scheduleName string) []snapshotv1.VolumeSnapshot {
+	snapshots := &snapshotv1.VolumeSnapshotList{}
+	Expect(cl.List(ctx, snapshots, client.InNamespace(namespace), client.MatchingLabels{controller.NFSSnapshotScheduleLabelKey: scheduleName})).To(Succeed())
+	return snapshots.Items
+}
@@ -0,0 +1,203 @@
+/*
+Copyright 2026 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	storagev1beta1 "k8s.io/api/storage/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	v1alpha1 "github.com/deckhouse/csi-nfs/api/v1alpha1"
+	"github.com/deckhouse/csi-nfs/images/controller/pkg/controller"
+	"github.com/deckhouse/csi-nfs/images/controller/pkg/logger"
+)
+
+var _ = Describe("NFSStorageClass VolumeAttributesClasses", func() {
+	var (
+		ctx = context.Background()
+		cl  = NewFakeClient()
+		log = logger.Logger{}
+	)
+
+	reconcileNSCWithRequeue := func(nsc *v1alpha1.NFSStorageClass) bool {
+		scList := &storagev1.StorageClassList{}
+		Expect(cl.List(ctx, scList)).To(Succeed())
+
+		shouldRequeue, err := controller.RunEventReconcile(ctx, cl, log, scList, nsc, controllerNamespace, nil)
+		Expect(err).NotTo(HaveOccurred())
+		return shouldRequeue
+	}
+
+	reconcileNSC := func(nsc *v1alpha1.NFSStorageClass) {
+		Expect(reconcileNSCWithRequeue(nsc)).To(BeFalse())
+	}
+
+	getVACs := func() map[string]storagev1beta1.VolumeAttributesClass {
+		vacList := &storagev1beta1.VolumeAttributesClassList{}
+		Expect(cl.List(ctx, vacList)).To(Succeed())
+		vacs := map[string]storagev1beta1.VolumeAttributesClass{}
+		for _, vac := range vacList.Items {
+			vacs[vac.Name] = vac
+		}
+		return vacs
+	}
+
+	It("creates, recreates and deletes the VolumeAttributesClasses of the NFSStorageClass", func() {
+		foreign := &storagev1beta1.VolumeAttributesClass{
+			ObjectMeta: metav1.ObjectMeta{Name: "foreign"},
+			DriverName: "other.csi.k8s.io",
+			Parameters: map[string]string{"iops": "100"},
+		}
+		Expect(cl.Create(ctx, foreign)).To(Succeed())
+
+		nsc := generateNFSStorageClass(NFSStorageClassConfig{
+			Name:              "vac-example",
+			Host:              "192.168.1.100",
+			Share:             "/data",
+			NFSVersion:        "4.1",
+			MountMode:         "hard",
+			ReclaimPolicy:     string(corev1.PersistentVolumeReclaimDelete),
+			VolumeBindingMode: string(storagev1.VolumeBindingWaitForFirstConsumer),
+		})
+		nsc.Spec.VolumeAttributesClasses = []v1alpha1.NFSStorageClassVolumeAttributesClass{
+			{Name: "no-cache", MountOptions: []string{"actimeo=0", "lookupcache=none"}},
+			{Name: "fast", MountOptions: []string{"nconnect=8"}},
+		}
+		Expect(cl.Create(ctx, nsc)).To(Succeed())
+		reconcileNSC(nsc)
+
+		vacs := getVACs()
+		Expect(vacs).To(HaveLen(3))
+		Expect(vacs).To(HaveKey("foreign"))
+		vac := vacs["vac-example-no-cache"]
+		Expect(vac.DriverName).To(Equal(controller.NFSStorageClassProvisioner))
+		Expect(vac.Parameters).To(Equal(map[string]string{controller.MountOptionsParamKey: "actimeo=0,lookupcache=none"}))
+		Expect(vac.Labels).To(HaveKeyWithValue(controller.NFSStorageClassManagedLabelKey, controller.NFSStorageClassManagedLabelValue))
+		Expect(vac.Labels).To(HaveKeyWithValue(controller.NFSStorageClassNameLabelKey, nsc.Name))
+		Expect(vacs["vac-example-fast"].Parameters).To(HaveKeyWithValue(controller.MountOptionsParamKey, "nconnect=8"))
+
+		Expect(cl.Get(ctx, client.ObjectKey{Name: nsc.Name}, nsc)).To(Succeed())
+		nsc.Spec.VolumeAttributesClasses = []v1alpha1.NFSStorageClassVolumeAttributesClass{
+			{Name: "fast", MountOptions: []string{"nconnect=16", "noac"}},
+		}
+		Expect(cl.Update(ctx, nsc)).To(Succeed())
+		// the changed VolumeAttributesClass is deleted first and created on the next reconciliation
+		Expect(reconcileNSCWithRequeue(nsc)).To(BeTrue())
+		Expect(getVACs()).NotTo(HaveKey("vac-example-fast"))
+		reconcileNSC(nsc)
+
+		vacs = getVACs()
+		Expect(vacs).To(HaveLen(2))
+		Expect(vacs).To(HaveKey("foreign"))
+		Expect(vacs["vac-example-fast"].Parameters).To(HaveKeyWithValue(controller.MountOptionsParamKey, "nconnect=16,noac"))
+
+		Expect(cl.Get(ctx, client.ObjectKey{Name: nsc.Name}, nsc)).To(Succeed())
+		Expect(cl.Delete(ctx, nsc)).To(Succeed())
+		Expect(cl.Get(ctx, client.ObjectKey{Name: nsc.Name}, nsc)).To(Succeed())
+		Expect(nsc.DeletionTimestamp).NotTo(BeNil())
+		reconcileNSC(nsc)
+
+		vacs = getVACs()
+		Expect(vacs).To(HaveLen(1))
+		Expect(vacs).To(HaveKey("foreign"))
+	})
+
+	It("waits for the VolumeAttributesClass in use to be deleted without failing the NFSStorageClass", func() {
+		nsc := generateNFSStorageClass(NFSStorageClassConfig{
+			Name:              "vac-in-use",
+			Host:              "192.168.1.100",
+			Share:             "/data",
+			NFSVersion:        "4.1",
+			MountMode:         "hard",
+			ReclaimPolicy:     string(corev1.PersistentVolumeReclaimDelete),
+			VolumeBindingMode: string(storagev1.VolumeBindingWaitForFirstConsumer),
+		})
+		nsc.Spec.VolumeAttributesClasses = []v1alpha1.NFSStorageClassVolumeAttributesClass{
+			{Name: "slow", MountOptions: []string{"rsize=65536"}},
+		}
+		Expect(cl.Create(ctx, nsc)).To(Succeed())
+		reconcileNSC(nsc)
+
+		// a PVC refers to the VolumeAttributesClass removed and added again
+		vac := &storagev1beta1.VolumeAttributesClass{}
+		Expect(cl.Get(ctx, client.ObjectKey{Name: "vac-in-use-slow"}, vac)).To(Succeed())
+		vac.Finalizers = []string{"kubernetes.io/vac-protection"}
+		Expect(cl.Update(ctx, vac)).To(Succeed())
+		Expect(cl.Delete(ctx, vac)).To(Succeed())
+
+		Expect(cl.Get(ctx, client.ObjectKey{Name: nsc.Name}, nsc)).To(Succeed())
+		Expect(reconcileNSCWithRequeue(nsc)).To(BeTrue())
+		Expect(cl.Get(ctx, client.ObjectKey{Name: nsc.Name}, nsc)).To(Succeed())
+		Expect(nsc.Status.Phase).NotTo(Equal(controller.FailedStatusPhase))
+
+		Expect(cl.Get(ctx, client.ObjectKey{Name: "vac-in-use-slow"}, vac)).To(Succeed())
+		vac.Finalizers = nil
+		Expect(cl.Update(ctx, vac)).To(Succeed())
+		reconcileNSC(nsc)
+		Expect(getVACs()).To(HaveKey("vac-in-use-slow"))
+	})
+
+	It("refuses the name of a VolumeAttributesClass of another NFSStorageClass", func() {
+		other := generateNFSStorageClass(NFSStorageClassConfig{
+			Name:              "vac-name-clash",
+			Host:              "192.168.1.100",
+			Share:             "/data",
+			NFSVersion:        "4.1",
+			MountMode:         "hard",
+			ReclaimPolicy:     string(corev1.PersistentVolumeReclaimDelete),
+			VolumeBindingMode: string(storagev1.VolumeBindingWaitForFirstConsumer),
+		})
+		other.Spec.VolumeAttributesClasses = []v1alpha1.NFSStorageClassVolumeAttributesClass{
+			{Name: "fast", MountOptions: []string{"nconnect=8"}},
+		}
+		Expect(cl.Create(ctx, other)).To(Succeed())
+		reconcileNSC(other)
+
+		nsc := generateNFSStorageClass(NFSStorageClassConfig{
+			Name:              "vac-name",
+			Host:              "192.168.1.100",
+			Share:             "/data",
+			NFSVersion:        "4.1",
+			MountMode:         "hard",
+			ReclaimPolicy:     string(corev1.PersistentVolumeReclaimDelete),
+			VolumeBindingMode: string(storagev1.VolumeBindingWaitForFirstConsumer),
+		})
+		nsc.Spec.VolumeAttributesClasses = []v1alpha1.NFSStorageClassVolumeAttributesClass{
+			{Name: "clash-fast", MountOptions: []string{"noac"}},
+		}
+		Expect(cl.Create(ctx, nsc)).To(Succeed())
+
+		scList := &storagev1.StorageClassList{}
+		Expect(cl.List(ctx, scList)).To(Succeed())
+		_, err := controller.RunEventReconcile(ctx, cl, log, scList, nsc, controllerNamespace, nil)
+		Expect(err).To(HaveOccurred())
+
+		Expect(cl.Get(ctx, client.ObjectKey{Name: nsc.Name}, nsc)).To(Succeed())
+		Expect(nsc.Status.Phase).To(Equal(controller.FailedStatusPhase))
+		Expect(nsc.Status.Reason).To(ContainSubstring("the NFSStorageClass vac-name-clash"))
+
+		vac := getVACs()["vac-name-clash-fast"]
+		Expect(vac.Labels).To(HaveKeyWithValue(controller.NFSStorageClassNameLabelKey, other.Name))
+		Expect(vac.Parameters).To(HaveKeyWithValue(controller.MountOptionsParamKey, "nconnect=8"))
+	})
+})
@@ -55,8 +55,14 @@ const (
 	NFSStorageClassControllerFinalizerName = "storage.deckhouse.io/nfs-storage-class-controller"
 	NFSStorageClassManagedLabelKey         = "storage.deckhouse.io/managed-by"
 	NFSStorageClassManagedLabelValue       = "nfs-storage-class-controller"
+	// NFSStorageClassNameLabelKey marks the VolumeAttributesClasses of an NFSStorageClass
+	NFSStorageClassNameLabelKey = "storage.deckhouse.io/nfs-storage-class"
 
 	NFSStorageClassVolumeSnapshotClassAnnotationKey = "storage.deckhouse.io/volumesnapshotclass"
+	// VolumeUsageEnforcementAnnotationKey passes spec.volumeUsage.enforcement to the node plugin,
+	// which measures the usage of the volumes of the classes with the annotation
+	VolumeUsageEnforcementAnnotationKey = "storage.deckhouse.io/volume-usage-enforcement"
+	VolumeUsageEnforcementDefault       = "Warn"
 
 	StorageClassDefaultAnnotationKey     = "storageclass.kubernetes.io/is-default-class"
 	StorageClassDefaultAnnotationValTrue = "true"
@@ -71,20 +77,49 @@ const (
 	RecreateReconcile = "Recreate"
 	DeleteReconcile   = "Delete"
 
-	serverParamKey           = "server"
-	shareParamKey            = "share"
-	MountPermissionsParamKey = "mountPermissions"
-	MountOptionsParamKey     = "mountOptions"
-	SubDirParamKey           = "subdir"
-	MountOptionsSecretKey    = "mountOptions"
+	serverParamKey              = "server"
+	shareParamKey               = "share"
+	MountPermissionsParamKey    = "mountPermissions"
+	MountOptionsParamKey        = "mountOptions"
+	SubDirParamKey              = "subdir"
+	MountOptionsSecretKey       = "mountOptions"
+	SnapshotCompressionParamKey = "snapshotCompression"
+	OnDeleteParamKey            = "onDelete"
 
 	SecretForMountOptionsPrefix   = "nfs-mount-options-for-"
 	ProvisionerSecretNameKey      = "csi.storage.k8s.io/provisioner-secret-name"
 	ProvisionerSecretNamespaceKey = "csi.storage.k8s.io/provisioner-secret-namespace"
 	SnapshotterSecretNameKey      = "csi.storage.k8s.io/snapshotter-secret-name"
 	SnapshotterSecretNamespaceKey = "csi.storage.k8s.io/snapshotter-secret-namespace"
+	// the project quota is also changed by ControllerExpandVolume
+	ControllerExpandSecretNameKey      = "csi.storage.k8s.io/controller-expand-secret-name"
+	ControllerExpandSecretNamespaceKey = "csi.storage.k8s.io/controller-expand-secret-namespace"
 
 	volumeCleanupMethodKey = "volumeCleanup"
+
+	orphanDeleteOlderThanKey = "orphanDeleteOlderThan"
+	retainArchivedForKey     = "retainArchivedFor"
+
+	contentSourceMountOptionsKey = "contentSourceMountOptions"
+
+	snapshotExportS3EndpointKey        = "snapshotExportS3Endpoint"
+	snapshotExportS3BucketKey          = "snapshotExportS3Bucket"
+	snapshotExportS3RegionKey          = "snapshotExportS3Region"
+	snapshotExportS3PrefixKey          = "snapshotExportS3Prefix"
+	snapshotExportS3AccessKeyIDKey     = "snapshotExportS3AccessKeyID"
+	snapshotExportS3SecretAccessKeyKey = "snapshotExportS3SecretAccessKey"
+
+	SnapshotExportAccessKeyIDSecretKey     = "accessKeyID"
+	SnapshotExportSecretAccessKeySecretKey = "secretAccessKey"
+
+	projectQuotaExportPathKey    = "projectQuotaExportPath"
+	projectQuotaSSHUserKey       = "projectQuotaSSHUser"
+	projectQuotaSSHPrivateKeyKey = "projectQuotaSSHPrivateKey"
+	projectQuotaSSHKnownHostsKey = "projectQuotaSSHKnownHosts"
+
+	ProjectQuotaSSHUserSecretKey       = "user"
+	ProjectQuotaSSHPrivateKeySecretKey = "privateKey"
+	ProjectQuotaSSHKnownHostsSecretKey = "knownHosts"
 )
 
 var (
@@ -126,7 +161,20 @@ func RunNFSStorageClassWatcherController(
 					return reconcile.Result{}, err
 				}
 
-				if err := commonvalidating.ValidateNFSStorageClass(nfsModuleConfig, nsc); err != nil {
+				if setTLSCertificatesCondition(nsc, checkTLSCertificates(nfsModuleConfig, time.Now())) {
+					err = cl.Status().Update(ctx, nsc)
+					if err != nil {
+						log.Error(err, fmt.Sprintf("[NFSStorageClassReconciler] unable to update the %s condition of the NFSStorageClass %s", TLSCertificatesValidConditionType, nsc.Name))
+						return reconcile.Result{}, err
+					}
+				}
+
+				errs, warnings := commonvalidating.ValidateNFSStorageClass(nfsModuleConfig, nsc)
+				for _, warning := range warnings {
+					log.Warning(fmt.Sprintf("[NFSStorageClassReconciler] NFSStorageClass %s: %s", nsc.Name, warning))
+				}
+				if len(errs) > 0 {
+					var err error = errs.ToAggregate()
 					log.Error(err, "[NFSStorageClassReconciler] invalid NFSStorageClass")
 					upError := updateNFSStorageClassPhase(ctx, cl, nsc, FailedStatusPhase, err.Error())
 					if upError != nil {
@@ -166,12 +214,13 @@ func RunNFSStorageClassWatcherController(
 	}
 
 	err = c.Watch(source.Kind(mgr.GetCache(), &v1alpha1.NFSStorageClass{}, handler.TypedFuncs[*v1alpha1.NFSStorageClass, reconcile.Request]{
-		CreateFunc: func(_ context.Context, e event.TypedCreateEvent[*v1alpha1.NFSStorageClass], q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+		CreateFunc: func(ctx context.Context, e event.TypedCreateEvent[*v1alpha1.NFSStorageClass], q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
 			log.Info(fmt.Sprintf("[CreateFunc] get event for NFSStorageClass %q. Add to the queue", e.Object.GetName()))
 			request := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: e.Object.GetNamespace(), Name: e.Object.GetName()}}
 			q.Add(request)
+			enqueueContentSourceTargets(ctx, cl, log, e.Object.GetName(), q)
 		},
-		UpdateFunc: func(_ context.Context, e event.TypedUpdateEvent[*v1alpha1.NFSStorageClass], q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+		UpdateFunc: func(ctx context.Context, e event.TypedUpdateEvent[*v1alpha1.NFSStorageClass], q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
 			log.Info(fmt.Sprintf("[UpdateFunc] get event for NFSStorageClass %q. Check if it should be reconciled", e.ObjectNew.GetName()))
 
 			if reflect.DeepEqual(e.ObjectOld.Spec, e.ObjectNew.Spec) &&
@@ -184,6 +233,7 @@ func RunNFSStorageClassWatcherController(
 			log.Info(fmt.Sprintf("[UpdateFunc] the NFSStorageClass %q will be reconciled. Add to the queue", e.ObjectNew.Name))
 			request := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: e.ObjectNew.Namespace, Name: e.ObjectNew.Name}}
 			q.Add(request)
+			enqueueContentSourceTargets(ctx, cl, log, e.ObjectNew.Name, q)
 		},
 	}))
 	if err != nil {
@@ -191,9 +241,66 @@ func RunNFSStorageClassWatcherController(
 		return nil, err
 	}
 
+	// the snapshot export and the project quota credentials are copied to the
+	// mount options Secret, so the NFSStorageClasses referring to a changed
+	// Secret are reconciled
+	enqueueBySecret := func(ctx context.Context, secret *corev1.Secret, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+		if secret.Namespace != cfg.ControllerNamespace {
+			return
+		}
+		nscList := &v1alpha1.NFSStorageClassList{}
+		if err := cl.List(ctx, nscList); err != nil {
+			log.Error(err, "[RunNFSStorageClassWatcherController] unable to list NFSStorageClasses")
+			return
+		}
+		for _, nsc := range nscList.Items {
+			if nsc.Spec.SnapshotExport != nil && nsc.Spec.SnapshotExport.S3 != nil && nsc.Spec.SnapshotExport.S3.CredentialsSecretName == secret.Name {
+				log.Info(fmt.Sprintf("[RunNFSStorageClassWatcherController] the snapshot export credentials Secret %q of the NFSStorageClass %q changed. Add to the queue", secret.Name, nsc.Name))
+				q.Add(reconcile.Request{NamespacedName: types.NamespacedName{Name: nsc.Name}})
+			}
+			if nsc.Spec.VolumeUsage != nil && nsc.Spec.VolumeUsage.ProjectQuota != nil && nsc.Spec.VolumeUsage.ProjectQuota.SSHSecretName == secret.Name {
+				log.Info(fmt.Sprintf("[RunNFSStorageClassWatcherController] the project quota credentials Secret %q of the NFSStorageClass %q changed. Add to the queue", secret.Name, nsc.Name))
+				q.Add(reconcile.Request{NamespacedName: types.NamespacedName{Name: nsc.Name}})
+			}
+		}
+	}
+	err = c.Watch(source.Kind(mgr.GetCache(), &corev1.Secret{}, handler.TypedFuncs[*corev1.Secret, reconcile.Request]{
+		CreateFunc: func(ctx context.Context, e event.TypedCreateEvent[*corev1.Secret], q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			enqueueBySecret(ctx, e.Object, q)
+		},
+		UpdateFunc: func(ctx context.Context, e event.TypedUpdateEvent[*corev1.Secret], q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			if reflect.DeepEqual(e.ObjectOld.Data, e.ObjectNew.Data) {
+				return
+			}
+			enqueueBySecret(ctx, e.ObjectNew, q)
+		},
+	}))
+	if err != nil {
+		log.Error(err, "[RunNFSStorageClassWatcherController] unable to watch the Secrets")
+		return nil, err
+	}
+
 	return c, nil
 }
 
+// enqueueContentSourceTargets adds the NFSStorageClasses other than name to the
+// queue: the mount options of the shares of all the NFSStorageClasses are
+// written to the Secret of every NFSStorageClass to restore and clone volumes
+// across servers.
+func enqueueContentSourceTargets(ctx context.Context, cl client.Client, log logger.Logger, name string, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+	nscList := &v1alpha1.NFSStorageClassList{}
+	if err := cl.List(ctx, nscList); err != nil {
+		log.Error(err, "[enqueueContentSourceTargets] unable to list NFSStorageClasses")
+		return
+	}
+	for _, nsc := range nscList.Items {
+		if nsc.Name != name {
+			log.Debug(fmt.Sprintf("[enqueueContentSourceTargets] the NFSStorageClass %q changed. Add the NFSStorageClass %q to the queue", name, nsc.Name))
+			q.Add(reconcile.Request{NamespacedName: types.NamespacedName{Name: nsc.Name}})
+		}
+	}
+}
+
 func RunEventReconcile(ctx context.Context, cl client.Client, log logger.Logger, scList *v1.StorageClassList, nsc *v1alpha1.NFSStorageClass, controllerNamespace string, ignoredLabelPrefixes []string) (shouldRequeue bool, err error) {
 	added, err := addFinalizerIfNotExists(ctx, cl, nsc, NFSStorageClassControllerFinalizerName)
 	if err != nil {
@@ -240,7 +347,19 @@ func RunEventReconcile(ctx context.Context, cl client.Client, log logger.Logger,
 		return true, err
 	}
 
-	reconcileTypeForSecret, err := IdentifyReconcileFuncForSecret(log, secretList, nsc, controllerNamespace)
+	nscList := &v1alpha1.NFSStorageClassList{}
+	err = cl.List(ctx, nscList)
+	if err != nil {
+		err = fmt.Errorf("[runEventReconcile] unable to list NFSStorageClasses: %w", err)
+		upError := updateNFSStorageClassPhase(ctx, cl, nsc, FailedStatusPhase, err.Error())
+		if upError != nil {
+			upError = fmt.Errorf("[runEventReconcile] unable to update the NFSStorageClass %s: %w", nsc.Name, upError)
+			err = errors.Join(err, upError)
+		}
+		return true, err
+	}
+
+	reconcileTypeForSecret, err := IdentifyReconcileFuncForSecret(log, secretList, nscList, nsc, controllerNamespace)
 
 	if err != nil {
 		log.Error(err, fmt.Sprintf("[runEventReconcile] error occurred while identifying the reconcile function for the Secret %q", SecretForMountOptionsPrefix+nsc.Name))
@@ -251,10 +370,10 @@ func RunEventReconcile(ctx context.Context, cl client.Client, log logger.Logger,
 	switch reconcileTypeForSecret {
 	case CreateReconcile:
 		log.Debug(fmt.Sprintf("[runEventReconcile] CreateReconcile starts reconciliataion of Secret, name: %s", SecretForMountOptionsPrefix+nsc.Name))
-		shouldRequeue, err = reconcileSecretCreateFunc(ctx, cl, log, nsc, controllerNamespace)
+		shouldRequeue, err = reconcileSecretCreateFunc(ctx, cl, log, secretList, nscList, nsc, controllerNamespace)
 	case UpdateReconcile:
 		log.Debug(fmt.Sprintf("[runEventReconcile] UpdateReconcile starts reconciliataion of Secret, name: %s", SecretForMountOptionsPrefix+nsc.Name))
-		shouldRequeue, err = reconcileSecretUpdateFunc(ctx, cl, log, secretList, nsc, controllerNamespace)
+		shouldRequeue, err = reconcileSecretUpdateFunc(ctx, cl, log, secretList, nscList, nsc, controllerNamespace)
 	case DeleteReconcile:
 		log.Debug(fmt.Sprintf("[runEventReconcile] DeleteReconcile starts reconciliataion of Secret, name: %s", SecretForMountOptionsPrefix+nsc.Name))
 		shouldRequeue, err = reconcileSecretDeleteFunc(ctx, cl, log, secretList, nsc)
@@ -300,6 +419,13 @@ func RunEventReconcile(ctx context.Context, cl client.Client, log logger.Logger,
 		return shouldRequeue, err
 	}
 
+	shouldRequeue, err = reconcileVolumeAttributesClasses(ctx, cl, log, nsc)
+	log.Debug(fmt.Sprintf("[runEventReconcile] ends reconciliataion of VolumeAttributesClasses, name: %s, shouldRequeue: %t, err: %v", nsc.Name, shouldRequeue, err))
+
+	if err != nil || shouldRequeue {
+		return shouldRequeue, err
+	}
+
 	if nsc.DeletionTimestamp == nil {
 		err = updateNFSStorageClassPhase(ctx, cl, nsc, CreatedStatusPhase, "")
 		if err != nil {
@@ -18,8 +18,10 @@ package controller
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"path"
 	"reflect"
 	"slices"
 	"strconv"
@@ -29,13 +31,19 @@ import (
 	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
 	corev1 "k8s.io/api/core/v1"
 	storagev1 "k8s.io/api/storage/v1"
+	storagev1beta1 "k8s.io/api/storage/v1beta1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	v1alpha1 "github.com/deckhouse/csi-nfs/api/v1alpha1"
 	"github.com/deckhouse/csi-nfs/images/controller/pkg/logger"
 	commonfeature "github.com/deckhouse/csi-nfs/lib/go/common/pkg/feature"
+	commonvalidating "github.com/deckhouse/csi-nfs/lib/go/common/pkg/validating"
 )
 
 func reconcileStorageClassCreateFunc(
@@ -156,14 +164,23 @@ func reconcileStorageClassDeleteFunc(
 	return false, nil
 }
 
-func reconcileSecretCreateFunc(ctx context.Context, cl client.Client, log logger.Logger, nsc *v1alpha1.NFSStorageClass, controllerNamespace string) (bool, error) {
+func reconcileSecretCreateFunc(ctx context.Context, cl client.Client, log logger.Logger, secretList *corev1.SecretList, nscList *v1alpha1.NFSStorageClassList, nsc *v1alpha1.NFSStorageClass, controllerNamespace string) (bool, error) {
 	log.Debug(fmt.Sprintf("[reconcileSecretCreateFunc] starts for NFSStorageClass %q", nsc.Name))
 
-	newSecret := configureSecret(nsc, controllerNamespace)
+	newSecret, err := configureSecret(nsc, controllerNamespace, secretList, nscList)
+	if err != nil {
+		err = fmt.Errorf("[reconcileSecretCreateFunc] unable to configure a Secret for the NFSStorageClass %s: %w", nsc.Name, err)
+		upError := updateNFSStorageClassPhase(ctx, cl, nsc, FailedStatusPhase, err.Error())
+		if upError != nil {
+			upError = fmt.Errorf("[reconcileSecretCreateFunc] unable to update the NFSStorageClass %s: %w", nsc.Name, upError)
+			err = errors.Join(err, upError)
+		}
+		return true, err
+	}
 	log.Debug(fmt.Sprintf("[reconcileSecretCreateFunc] successfully configurated secret for the NFSStorageClass, name: %s", nsc.Name))
 	log.Trace(fmt.Sprintf("[reconcileSecretCreateFunc] secret: %+v", newSecret))
 
-	err := cl.Create(ctx, newSecret)
+	err = cl.Create(ctx, newSecret)
 	if err != nil {
 		err = fmt.Errorf("[reconcileSecretCreateFunc] unable to create a Secret %s: %w", newSecret.Name, err)
 		upError := updateNFSStorageClassPhase(ctx, cl, nsc, FailedStatusPhase, err.Error())
@@ -177,7 +194,7 @@ func reconcileSecretCreateFunc(ctx context.Context, cl client.Client, log logger
 	return false, nil
 }
 
-func reconcileSecretUpdateFunc(ctx context.Context, cl client.Client, log logger.Logger, secretList *corev1.SecretList, nsc *v1alpha1.NFSStorageClass, controllerNamespace string) (bool, error) {
+func reconcileSecretUpdateFunc(ctx context.Context, cl client.Client, log logger.Logger, secretList *corev1.SecretList, nscList *v1alpha1.NFSStorageClassList, nsc *v1alpha1.NFSStorageClass, controllerNamespace string) (bool, error) {
 	log.Debug(fmt.Sprintf("[reconcileSecretUpdateFunc] starts for secret %q", SecretForMountOptionsPrefix+nsc.Name))
 
 	var oldSecret *corev1.Secret
@@ -200,12 +217,21 @@ func reconcileSecretUpdateFunc(ctx context.Context, cl client.Client, log logger
 
 	log.Debug(fmt.Sprintf("[reconcileSecretUpdateFunc] successfully found a secret %q for the NFSStorageClass, name: %q", oldSecret.Name, nsc.Name))
 
-	newSecret := configureSecret(nsc, controllerNamespace)
+	newSecret, err := configureSecret(nsc, controllerNamespace, secretList, nscList)
+	if err != nil {
+		err = fmt.Errorf("[reconcileSecretUpdateFunc] unable to configure a Secret for the NFSStorageClass %s: %w", nsc.Name, err)
+		upError := updateNFSStorageClassPhase(ctx, cl, nsc, FailedStatusPhase, err.Error())
+		if upError != nil {
+			upError = fmt.Errorf("[reconcileSecretUpdateFunc] unable to update the NFSStorageClass %s: %w", nsc.Name, upError)
+			err = errors.Join(err, upError)
+		}
+		return true, err
+	}
 
 	log.Trace(fmt.Sprintf("[reconcileSecretUpdateFunc] old secret: %+v", oldSecret))
 	log.Trace(fmt.Sprintf("[reconcileSecretUpdateFunc] new secret: %+v", newSecret))
 
-	err := cl.Update(ctx, newSecret)
+	err = cl.Update(ctx, newSecret)
 	if err != nil {
 		err = fmt.Errorf("[reconcileSecretUpdateFunc] unable to update a Secret %s: %w", newSecret.Name, err)
 		upError := updateNFSStorageClassPhase(ctx, cl, nsc, FailedStatusPhase, err.Error())
@@ -469,8 +495,19 @@ func ConfigureStorageClass(oldSC *storagev1.StorageClass, nsc *v1alpha1.NFSStora
 		AllowVolumeExpansion: &AllowVolumeExpansion,
 	}
 
+	if nsc.Spec.VolumeUsage != nil {
+		enforcement := nsc.Spec.VolumeUsage.Enforcement
+		if enforcement == "" {
+			enforcement = VolumeUsageEnforcementDefault
+		}
+		newSc.Annotations[VolumeUsageEnforcementAnnotationKey] = enforcement
+	}
+
 	if oldSC != nil && oldSC.Annotations != nil {
 		newSc.Annotations = labels.Merge(oldSC.Annotations, newSc.Annotations)
+		if nsc.Spec.VolumeUsage == nil {
+			delete(newSc.Annotations, VolumeUsageEnforcementAnnotationKey)
+		}
 	}
 
 	filteredLabels := filterLabelsForStorageClass(nsc.Labels, ignoredLabelPrefixes)
@@ -613,14 +650,24 @@ func GetSCParams(nsc *v1alpha1.NFSStorageClass, controllerNamespace string) map[
 	params[ProvisionerSecretNameKey] = SecretForMountOptionsPrefix + nsc.Name
 	params[ProvisionerSecretNamespaceKey] = controllerNamespace
 
+	if nsc.Spec.VolumeUsage != nil && nsc.Spec.VolumeUsage.ProjectQuota != nil {
+		params[ControllerExpandSecretNameKey] = SecretForMountOptionsPrefix + nsc.Name
+		params[ControllerExpandSecretNamespaceKey] = controllerNamespace
+	}
+
 	if nsc.Spec.ChmodPermissions != "" {
 		params[MountPermissionsParamKey] = nsc.Spec.ChmodPermissions
 	}
 
+	// the driver expects delete, retain or archive
+	if nsc.Spec.OnDelete != "" {
+		params[OnDeleteParamKey] = strings.ToLower(nsc.Spec.OnDelete)
+	}
+
 	return params
 }
 
-func IdentifyReconcileFuncForSecret(log logger.Logger, secretList *corev1.SecretList, nsc *v1alpha1.NFSStorageClass, controllerNamespace string) (reconcileType string, err error) {
+func IdentifyReconcileFuncForSecret(log logger.Logger, secretList *corev1.SecretList, nscList *v1alpha1.NFSStorageClassList, nsc *v1alpha1.NFSStorageClass, controllerNamespace string) (reconcileType string, err error) {
 	if shouldReconcileByDeleteFunc(nsc) {
 		return DeleteReconcile, nil
 	}
@@ -629,7 +676,7 @@ func IdentifyReconcileFuncForSecret(log logger.Logger, secretList *corev1.Secret
 		return CreateReconcile, nil
 	}
 
-	should, err := shouldReconcileSecretByUpdateFunc(log, secretList, nsc, controllerNamespace)
+	should, err := shouldReconcileSecretByUpdateFunc(log, secretList, nscList, nsc, controllerNamespace)
 	if err != nil {
 		return "", err
 	}
@@ -654,7 +701,7 @@ func shouldReconcileSecretByCreateFunc(secretList *corev1.SecretList, nsc *v1alp
 	return true
 }
 
-func shouldReconcileSecretByUpdateFunc(log logger.Logger, secretList *corev1.SecretList, nsc *v1alpha1.NFSStorageClass, controllerNamespace string) (bool, error) {
+func shouldReconcileSecretByUpdateFunc(log logger.Logger, secretList *corev1.SecretList, nscList *v1alpha1.NFSStorageClassList, nsc *v1alpha1.NFSStorageClass, controllerNamespace string) (bool, error) {
 	if nsc.DeletionTimestamp != nil {
 		return false, nil
 	}
@@ -665,7 +712,10 @@ func shouldReconcileSecretByUpdateFunc(log logger.Logger, secretList *corev1.Sec
 
 	for _, oldSecret := range secretList.Items {
 		if oldSecret.Name == SecretForMountOptionsPrefix+nsc.Name {
-			newSecret := configureSecret(nsc, controllerNamespace)
+			newSecret, err := configureSecret(nsc, controllerNamespace, secretList, nscList)
+			if err != nil {
+				return false, err
+			}
 			if !reflect.DeepEqual(oldSecret.StringData, newSecret.StringData) {
 				log.Debug(fmt.Sprintf("[shouldReconcileSecretByUpdateFunc] a secret %s should be updated", oldSecret.Name))
 				if !labels.Set(oldSecret.Labels).AsSelector().Matches(secretSelector) {
@@ -689,7 +739,7 @@ func shouldReconcileSecretByUpdateFunc(log logger.Logger, secretList *corev1.Sec
 	return true, nil
 }
 
-func configureSecret(nsc *v1alpha1.NFSStorageClass, controllerNamespace string) *corev1.Secret {
+func configureSecret(nsc *v1alpha1.NFSStorageClass, controllerNamespace string, secretList *corev1.SecretList, nscList *v1alpha1.NFSStorageClassList) (*corev1.Secret, error) {
 	mountOptions := GetSCMountOptions(nsc)
 	secret := &corev1.Secret{
 		TypeMeta: metav1.TypeMeta{
@@ -713,7 +763,129 @@ func configureSecret(nsc *v1alpha1.NFSStorageClass, controllerNamespace string)
 		secret.StringData[volumeCleanupMethodKey] = nsc.Spec.VolumeCleanup
 	}
 
-	return secret
+	if nsc.Spec.OrphanCleanup != nil {
+		secret.StringData[orphanDeleteOlderThanKey] = nsc.Spec.OrphanCleanup.DeleteOlderThan
+	}
+
+	if nsc.Spec.RetainArchivedFor != "" {
+		secret.StringData[retainArchivedForKey] = nsc.Spec.RetainArchivedFor
+	}
+
+	if nsc.Spec.SnapshotExport != nil && nsc.Spec.SnapshotExport.S3 != nil {
+		s3 := nsc.Spec.SnapshotExport.S3
+		accessKeyID, secretAccessKey, err := GetSnapshotExportCredentials(secretList, s3.CredentialsSecretName)
+		if err != nil {
+			return nil, err
+		}
+		secret.StringData[snapshotExportS3EndpointKey] = s3.Endpoint
+		secret.StringData[snapshotExportS3BucketKey] = s3.Bucket
+		secret.StringData[snapshotExportS3AccessKeyIDKey] = accessKeyID
+		secret.StringData[snapshotExportS3SecretAccessKeyKey] = secretAccessKey
+		if s3.Region != "" {
+			secret.StringData[snapshotExportS3RegionKey] = s3.Region
+		}
+		if s3.Prefix != "" {
+			secret.StringData[snapshotExportS3PrefixKey] = s3.Prefix
+		}
+	}
+
+	if nsc.Spec.VolumeUsage != nil && nsc.Spec.VolumeUsage.ProjectQuota != nil {
+		quota := nsc.Spec.VolumeUsage.ProjectQuota
+		user, privateKey, knownHosts, err := GetProjectQuotaCredentials(secretList, quota.SSHSecretName)
+		if err != nil {
+			return nil, err
+		}
+		exportPath := quota.ExportPath
+		if exportPath == "" {
+			exportPath = nsc.Spec.Connection.Share
+		}
+		secret.StringData[projectQuotaExportPathKey] = exportPath
+		secret.StringData[projectQuotaSSHUserKey] = user
+		secret.StringData[projectQuotaSSHPrivateKeyKey] = privateKey
+		secret.StringData[projectQuotaSSHKnownHostsKey] = knownHosts
+	}
+
+	contentSourceMountOptions, err := GetContentSourceMountOptions(nscList, nsc)
+	if err != nil {
+		return nil, err
+	}
+	secret.StringData[contentSourceMountOptionsKey] = contentSourceMountOptions
+
+	return secret, nil
+}
+
+// GetContentSourceMountOptions returns the mount options of the shares of the
+// other NFSStorageClasses whose snapshots and volumes may be restored or cloned
+// into the NFSStorageClass, as a JSON object keyed by "<host>:<share>". The
+// driver mounts a content source on another server with these options.
+func GetContentSourceMountOptions(nscList *v1alpha1.NFSStorageClassList, nsc *v1alpha1.NFSStorageClass) (string, error) {
+	shares := map[string]string{}
+	for _, source := range nscList.Items {
+		if source.Name == nsc.Name || source.DeletionTimestamp != nil || source.Spec.Connection == nil {
+			continue
+		}
+		if err := commonvalidating.ValidateNFSStorageClassContentSource(&source, nsc); err != nil {
+			continue
+		}
+
+		key := source.Spec.Connection.Host + ":" + path.Join("/", source.Spec.Connection.Share)
+		if key == nsc.Spec.Connection.Host+":"+path.Join("/", nsc.Spec.Connection.Share) {
+			continue
+		}
+		shares[key] = strings.Join(GetSCMountOptions(&source), ",")
+	}
+
+	data, err := json.Marshal(shares)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// GetSnapshotExportCredentials returns the S3 credentials from the Secret the
+// snapshot export of an NFSStorageClass refers to.
+func GetSnapshotExportCredentials(secretList *corev1.SecretList, secretName string) (accessKeyID, secretAccessKey string, err error) {
+	for _, s := range secretList.Items {
+		if s.Name != secretName {
+			continue
+		}
+
+		accessKeyID = getSecretValue(&s, SnapshotExportAccessKeyIDSecretKey)
+		secretAccessKey = getSecretValue(&s, SnapshotExportSecretAccessKeySecretKey)
+		if accessKeyID == "" || secretAccessKey == "" {
+			return "", "", fmt.Errorf("the snapshot export credentials Secret %s must have the %s and %s keys", secretName, SnapshotExportAccessKeyIDSecretKey, SnapshotExportSecretAccessKeySecretKey)
+		}
+		return accessKeyID, secretAccessKey, nil
+	}
+
+	return "", "", fmt.Errorf("the snapshot export credentials Secret %s not found", secretName)
+}
+
+// GetProjectQuotaCredentials returns the SSH credentials of the NFS server
+// from the Secret the project quota of an NFSStorageClass refers to.
+func GetProjectQuotaCredentials(secretList *corev1.SecretList, secretName string) (user, privateKey, knownHosts string, err error) {
+	for _, s := range secretList.Items {
+		if s.Name != secretName {
+			continue
+		}
+
+		user = getSecretValue(&s, ProjectQuotaSSHUserSecretKey)
+		privateKey = getSecretValue(&s, ProjectQuotaSSHPrivateKeySecretKey)
+		knownHosts = getSecretValue(&s, ProjectQuotaSSHKnownHostsSecretKey)
+		if user == "" || privateKey == "" || knownHosts == "" {
+			return "", "", "", fmt.Errorf("the project quota credentials Secret %s must have the %s, %s and %s keys", secretName, ProjectQuotaSSHUserSecretKey, ProjectQuotaSSHPrivateKeySecretKey, ProjectQuotaSSHKnownHostsSecretKey)
+		}
+		return user, privateKey, knownHosts, nil
+	}
+
+	return "", "", "", fmt.Errorf("the project quota credentials Secret %s not found", secretName)
+}
+
+func getSecretValue(secret *corev1.Secret, key string) string {
+	if value, ok := secret.Data[key]; ok {
+		return string(value)
+	}
+	return secret.StringData[key]
 }
 
 // VolumeSnaphotClass
@@ -807,6 +979,10 @@ func ConfigureVSClass(oldVSClass *snapshotv1.VolumeSnapshotClass, nsc *v1alpha1.
 		},
 	}
 
+	if nsc.Spec.SnapshotCompression != "" {
+		newVSClass.Parameters[SnapshotCompressionParamKey] = nsc.Spec.SnapshotCompression
+	}
+
 	if oldVSClass != nil {
 		if oldVSClass.Labels != nil {
 			newVSClass.Labels = labels.Merge(oldVSClass.Labels, newVSClass.Labels)
@@ -954,3 +1130,253 @@ func deleteVolumeSnapshotClass(ctx context.Context, cl client.Client, vsClass *s
 
 	return nil
 }
+
+// VolumeAttributesClassName returns the name of the VolumeAttributesClass of
+// an element of spec.volumeAttributesClasses of the NFSStorageClass.
+func VolumeAttributesClassName(nsc *v1alpha1.NFSStorageClass, name string) string {
+	return nsc.Name + "-" + name
+}
+
+func ConfigureVolumeAttributesClasses(nsc *v1alpha1.NFSStorageClass) []storagev1beta1.VolumeAttributesClass {
+	if nsc.DeletionTimestamp != nil {
+		return nil
+	}
+
+	vacs := make([]storagev1beta1.VolumeAttributesClass, 0, len(nsc.Spec.VolumeAttributesClasses))
+	for _, vac := range nsc.Spec.VolumeAttributesClasses {
+		vacs = append(vacs, storagev1beta1.VolumeAttributesClass{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: VolumeAttributesClassName(nsc, vac.Name),
+				Labels: map[string]string{
+					NFSStorageClassManagedLabelKey: NFSStorageClassManagedLabelValue,
+					NFSStorageClassNameLabelKey:    nsc.Name,
+				},
+			},
+			DriverName: NFSStorageClassProvisioner,
+			Parameters: map[string]string{
+				MountOptionsParamKey: strings.Join(vac.MountOptions, ","),
+			},
+		})
+	}
+
+	return vacs
+}
+
+// volumeAttributesClassGroupKind is served as storage.k8s.io/v1 since
+// Kubernetes 1.34 and as v1beta1 before, when the beta API is enabled.
+var volumeAttributesClassGroupKind = schema.GroupKind{Group: storagev1beta1.GroupName, Kind: "VolumeAttributesClass"}
+
+// vacClient reads and writes the VolumeAttributesClasses with the version of
+// the API served by the cluster. The objects of v1 and v1beta1 have the same
+// fields, so they are converted to and from the v1beta1 type.
+type vacClient struct {
+	cl      client.Client
+	version string
+}
+
+// newVACClient returns the client of the VolumeAttributesClasses preferring
+// v1. The error is a NoMatch error when the cluster serves neither.
+func newVACClient(cl client.Client) (*vacClient, error) {
+	mapping, err := cl.RESTMapper().RESTMapping(volumeAttributesClassGroupKind, "v1", "v1beta1")
+	if err != nil {
+		return nil, err
+	}
+	return &vacClient{cl: cl, version: mapping.GroupVersionKind.Version}, nil
+}
+
+func (c *vacClient) gvk(kind string) schema.GroupVersionKind {
+	return schema.GroupVersionKind{Group: volumeAttributesClassGroupKind.Group, Version: c.version, Kind: kind}
+}
+
+func (c *vacClient) toUnstructured(vac *storagev1beta1.VolumeAttributesClass) (*unstructured.Unstructured, error) {
+	object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(vac)
+	if err != nil {
+		return nil, err
+	}
+	u := &unstructured.Unstructured{Object: object}
+	u.SetGroupVersionKind(c.gvk(volumeAttributesClassGroupKind.Kind))
+	return u, nil
+}
+
+func (c *vacClient) List(ctx context.Context, vacList *storagev1beta1.VolumeAttributesClassList, opts ...client.ListOption) error {
+	u := &unstructured.UnstructuredList{}
+	u.SetGroupVersionKind(c.gvk(volumeAttributesClassGroupKind.Kind + "List"))
+	if err := c.cl.List(ctx, u, opts...); err != nil {
+		return err
+	}
+	vacList.Items = make([]storagev1beta1.VolumeAttributesClass, len(u.Items))
+	for i := range u.Items {
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Items[i].Object, &vacList.Items[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *vacClient) Get(ctx context.Context, name string, vac *storagev1beta1.VolumeAttributesClass) error {
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(c.gvk(volumeAttributesClassGroupKind.Kind))
+	if err := c.cl.Get(ctx, client.ObjectKey{Name: name}, u); err != nil {
+		return err
+	}
+	return runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, vac)
+}
+
+func (c *vacClient) Create(ctx context.Context, vac *storagev1beta1.VolumeAttributesClass) error {
+	u, err := c.toUnstructured(vac)
+	if err != nil {
+		return err
+	}
+	return c.cl.Create(ctx, u)
+}
+
+func (c *vacClient) Update(ctx context.Context, vac *storagev1beta1.VolumeAttributesClass) error {
+	u, err := c.toUnstructured(vac)
+	if err != nil {
+		return err
+	}
+	return c.cl.Update(ctx, u)
+}
+
+func (c *vacClient) Delete(ctx context.Context, vac *storagev1beta1.VolumeAttributesClass) error {
+	u, err := c.toUnstructured(vac)
+	if err != nil {
+		return err
+	}
+	return c.cl.Delete(ctx, u)
+}
+
+// reconcileVolumeAttributesClasses creates the VolumeAttributesClasses of
+// spec.volumeAttributesClasses and deletes the ones no longer listed. The
+// mount options of an element are immutable, but an element removed and
+// added again with other options replaces a VolumeAttributesClass which
+// Kubernetes keeps until no PVC refers to it: the new one is created once the
+// old one is gone and the NFSStorageClass is requeued until then.
+func reconcileVolumeAttributesClasses(ctx context.Context, cl client.Client, log logger.Logger, nsc *v1alpha1.NFSStorageClass) (bool, error) {
+	log.Debug(fmt.Sprintf("[reconcileVolumeAttributesClasses] starts for the NFSStorageClass %q", nsc.Name))
+
+	vacList := &storagev1beta1.VolumeAttributesClassList{}
+	vacs, err := newVACClient(cl)
+	if err == nil {
+		err = vacs.List(ctx, vacList, client.MatchingLabels{NFSStorageClassNameLabelKey: nsc.Name})
+	}
+	if err != nil {
+		if meta.IsNoMatchError(err) && (len(nsc.Spec.VolumeAttributesClasses) == 0 || nsc.DeletionTimestamp != nil) {
+			log.Debug("[reconcileVolumeAttributesClasses] the VolumeAttributesClass API is not available in the cluster")
+			return false, nil
+		}
+		err = fmt.Errorf("[reconcileVolumeAttributesClasses] unable to list VolumeAttributesClasses: %w", err)
+		return true, failNFSStorageClass(ctx, cl, nsc, err)
+	}
+
+	var (
+		errs          []error
+		shouldRequeue bool
+	)
+	newVACs := ConfigureVolumeAttributesClasses(nsc)
+	for i := range newVACs {
+		newVAC := &newVACs[i]
+		oldVAC := findVolumeAttributesClass(vacList, newVAC.Name)
+
+		if oldVAC == nil {
+			// the names of the VolumeAttributesClasses of the NFSStorageClasses
+			// may coincide, e.g. a-b + c and a + b-c, the first one keeps it
+			foreign := &storagev1beta1.VolumeAttributesClass{}
+			err = vacs.Get(ctx, newVAC.Name, foreign)
+			if err == nil {
+				errs = append(errs, fmt.Errorf("the VolumeAttributesClass %s already exists and belongs to %s, rename the element of spec.volumeAttributesClasses", newVAC.Name, volumeAttributesClassOwner(foreign)))
+				continue
+			}
+			if client.IgnoreNotFound(err) != nil {
+				errs = append(errs, fmt.Errorf("unable to get a VolumeAttributesClass %s: %w", newVAC.Name, err))
+				continue
+			}
+		}
+
+		switch {
+		case oldVAC == nil:
+			err = vacs.Create(ctx, newVAC)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("unable to create a VolumeAttributesClass %s: %w", newVAC.Name, err))
+				continue
+			}
+			log.Info(fmt.Sprintf("[reconcileVolumeAttributesClasses] successfully created a VolumeAttributesClass, name: %s", newVAC.Name))
+		case oldVAC.DeletionTimestamp != nil:
+			log.Info(fmt.Sprintf("[reconcileVolumeAttributesClasses] the VolumeAttributesClass %s is being deleted and will be created again when no PVC refers to it", oldVAC.Name))
+			shouldRequeue = true
+		case oldVAC.DriverName != newVAC.DriverName || !cmp.Equal(oldVAC.Parameters, newVAC.Parameters):
+			log.Debug(fmt.Sprintf("[reconcileVolumeAttributesClasses] a VolumeAttributesClass %s should be recreated. Parameters diff: %s", oldVAC.Name, cmp.Diff(oldVAC.Parameters, newVAC.Parameters)))
+			err = vacs.Delete(ctx, oldVAC)
+			if client.IgnoreNotFound(err) != nil {
+				errs = append(errs, fmt.Errorf("unable to delete a VolumeAttributesClass %s: %w", oldVAC.Name, err))
+				continue
+			}
+			log.Info(fmt.Sprintf("[reconcileVolumeAttributesClasses] successfully deleted a VolumeAttributesClass %s to create it with the new parameters", oldVAC.Name))
+			shouldRequeue = true
+		case !cmp.Equal(oldVAC.Labels, labels.Merge(oldVAC.Labels, newVAC.Labels)):
+			newVAC.Labels = labels.Merge(oldVAC.Labels, newVAC.Labels)
+			newVAC.Annotations = oldVAC.Annotations
+			newVAC.ResourceVersion = oldVAC.ResourceVersion
+			err = vacs.Update(ctx, newVAC)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("unable to update a VolumeAttributesClass %s: %w", newVAC.Name, err))
+				continue
+			}
+			log.Info(fmt.Sprintf("[reconcileVolumeAttributesClasses] successfully updated a VolumeAttributesClass, name: %s", newVAC.Name))
+		}
+	}
+
+	for i := range vacList.Items {
+		oldVAC := &vacList.Items[i]
+		if oldVAC.DeletionTimestamp != nil || !slices.Contains(allowedProvisioners, oldVAC.DriverName) ||
+			slices.ContainsFunc(newVACs, func(vac storagev1beta1.VolumeAttributesClass) bool { return vac.Name == oldVAC.Name }) {
+			continue
+		}
+		err = vacs.Delete(ctx, oldVAC)
+		if client.IgnoreNotFound(err) != nil {
+			errs = append(errs, fmt.Errorf("unable to delete a VolumeAttributesClass %s: %w", oldVAC.Name, err))
+			continue
+		}
+		log.Info(fmt.Sprintf("[reconcileVolumeAttributesClasses] successfully deleted a VolumeAttributesClass, name: %s", oldVAC.Name))
+	}
+
+	if len(errs) > 0 {
+		err = fmt.Errorf("[reconcileVolumeAttributesClasses] %w", errors.Join(errs...))
+		return true, failNFSStorageClass(ctx, cl, nsc, err)
+	}
+
+	log.Debug(fmt.Sprintf("[reconcileVolumeAttributesClasses] ends the reconciliation, shouldRequeue: %t", shouldRequeue))
+	return shouldRequeue, nil
+}
+
+// volumeAttributesClassOwner describes the owner of a VolumeAttributesClass
+// for the errors.
+func volumeAttributesClassOwner(vac *storagev1beta1.VolumeAttributesClass) string {
+	if name, ok := vac.Labels[NFSStorageClassNameLabelKey]; ok {
+		return fmt.Sprintf("the NFSStorageClass %s", name)
+	}
+	return fmt.Sprintf("the driver %s", vac.DriverName)
+}
+
+func findVolumeAttributesClass(vacList *storagev1beta1.VolumeAttributesClassList, name string) *storagev1beta1.VolumeAttributesClass {
+	for i := range vacList.Items {
+		if vacList.Items[i].Name == name {
+			return &vacList.Items[i]
+		}
+	}
+	return nil
+}
+
+// failNFSStorageClass sets the Failed phase of the NFSStorageClass with the
+// reconciliation error and returns it.
+func failNFSStorageClass(ctx context.Context, cl client.Client, nsc *v1alpha1.NFSStorageClass, err error) error {
+	if nsc.DeletionTimestamp != nil {
+		return err
+	}
+	upError := updateNFSStorageClassPhase(ctx, cl, nsc, FailedStatusPhase, err.Error())
+	if upError != nil {
+		upError = fmt.Errorf("unable to update the NFSStorageClass %s: %w", nsc.Name, upError)
+		err = errors.Join(err, upError)
+	}
+	return err
+}
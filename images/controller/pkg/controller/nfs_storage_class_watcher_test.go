@@ -18,6 +18,7 @@ package controller_test
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -118,6 +119,29 @@ var _ = Describe(controller.NFSStorageClassCtrlName, func() {
 
 	})
 
+	It("Check function ConfigureVSClass", func() {
+		nsc := generateNFSStorageClass(NFSStorageClassConfig{
+			Name:              nameForTestResource,
+			Host:              server,
+			Share:             share,
+			NFSVersion:        nfsVer,
+			MountMode:         mountMode,
+			ChmodPermissions:  chmodPermissions,
+			ReclaimPolicy:     string(corev1.PersistentVolumeReclaimDelete),
+			VolumeBindingMode: string(storagev1.VolumeBindingWaitForFirstConsumer),
+		})
+
+		vsClass := controller.ConfigureVSClass(nil, nsc, controllerNamespace)
+		Expect(vsClass.Parameters).To(HaveLen(4))
+		Expect(vsClass.Parameters).NotTo(HaveKey(controller.SnapshotCompressionParamKey))
+
+		nsc.Spec.SnapshotCompression = "zstd"
+		newVSClass := controller.ConfigureVSClass(vsClass, nsc, controllerNamespace)
+		Expect(newVSClass.Parameters).To(HaveLen(5))
+		Expect(newVSClass.Parameters).To(HaveKeyWithValue(controller.SnapshotCompressionParamKey, "zstd"))
+		Expect(controller.CompareVSClasses(vsClass, newVSClass)).NotTo(BeEmpty())
+	})
+
 	It("Create_nfs_sc_with_all_options", func() {
 		nfsSCtemplate := generateNFSStorageClass(NFSStorageClassConfig{
 			Name:              nameForTestResource,
@@ -443,6 +467,331 @@ var _ = Describe(controller.NFSStorageClassCtrlName, func() {
 		Expect(sc.Labels).To(HaveLen(0))
 	})
 
+	It("Create_nfs_sc_with_snapshot_export", func() {
+		const (
+			nscName         = "snapshot-export"
+			credentialsName = "s3-credentials"
+		)
+		nsc := generateNFSStorageClass(NFSStorageClassConfig{
+			Name:              nscName,
+			Host:              server,
+			Share:             share,
+			NFSVersion:        nfsVer,
+			ReclaimPolicy:     string(corev1.PersistentVolumeReclaimDelete),
+			VolumeBindingMode: string(storagev1.VolumeBindingWaitForFirstConsumer),
+		})
+		nsc.Spec.SnapshotExport = &v1alpha1.NFSStorageClassSnapshotExport{
+			S3: &v1alpha1.NFSStorageClassSnapshotExportS3{
+				Endpoint:              "http://minio.minio.svc:9000",
+				Bucket:                "snapshots",
+				Prefix:                "cluster",
+				CredentialsSecretName: credentialsName,
+			},
+		}
+		err := cl.Create(ctx, nsc)
+		Expect(err).NotTo(HaveOccurred())
+
+		scList := &storagev1.StorageClassList{}
+		err = cl.List(ctx, scList)
+		Expect(err).NotTo(HaveOccurred())
+
+		// the credentials Secret does not exist yet
+		err = cl.Get(ctx, client.ObjectKey{Name: nscName}, nsc)
+		Expect(err).NotTo(HaveOccurred())
+		shouldRequeue, err := controller.RunEventReconcile(ctx, cl, log, scList, nsc, controllerNamespace, nil)
+		Expect(err).To(HaveOccurred())
+		Expect(shouldRequeue).To(BeTrue())
+
+		err = cl.Get(ctx, client.ObjectKey{Name: nscName}, nsc)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(nsc.Status).NotTo(BeNil())
+		Expect(nsc.Status.Phase).To(Equal(controller.FailedStatusPhase))
+
+		credentials := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      credentialsName,
+				Namespace: controllerNamespace,
+			},
+			Data: map[string][]byte{
+				controller.SnapshotExportAccessKeyIDSecretKey:     []byte("access"),
+				controller.SnapshotExportSecretAccessKeySecretKey: []byte("secret"),
+			},
+		}
+		err = cl.Create(ctx, credentials)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = cl.List(ctx, scList)
+		Expect(err).NotTo(HaveOccurred())
+		shouldRequeue, err = controller.RunEventReconcile(ctx, cl, log, scList, nsc, controllerNamespace, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(shouldRequeue).To(BeFalse())
+
+		secret := &corev1.Secret{}
+		err = cl.Get(ctx, client.ObjectKey{Name: controller.SecretForMountOptionsPrefix + nscName, Namespace: controllerNamespace}, secret)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(secret.StringData).To(HaveKeyWithValue("snapshotExportS3Endpoint", "http://minio.minio.svc:9000"))
+		Expect(secret.StringData).To(HaveKeyWithValue("snapshotExportS3Bucket", "snapshots"))
+		Expect(secret.StringData).To(HaveKeyWithValue("snapshotExportS3Prefix", "cluster"))
+		Expect(secret.StringData).To(HaveKeyWithValue("snapshotExportS3AccessKeyID", "access"))
+		Expect(secret.StringData).To(HaveKeyWithValue("snapshotExportS3SecretAccessKey", "secret"))
+		Expect(secret.StringData).NotTo(HaveKey("snapshotExportS3Region"))
+
+		// rotated credentials are copied to the mount options Secret
+		credentials.Data[controller.SnapshotExportSecretAccessKeySecretKey] = []byte("rotated")
+		err = cl.Update(ctx, credentials)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = cl.Get(ctx, client.ObjectKey{Name: nscName}, nsc)
+		Expect(err).NotTo(HaveOccurred())
+		err = cl.List(ctx, scList)
+		Expect(err).NotTo(HaveOccurred())
+		shouldRequeue, err = controller.RunEventReconcile(ctx, cl, log, scList, nsc, controllerNamespace, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(shouldRequeue).To(BeFalse())
+
+		err = cl.Get(ctx, client.ObjectKey{Name: controller.SecretForMountOptionsPrefix + nscName, Namespace: controllerNamespace}, secret)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(secret.StringData).To(HaveKeyWithValue("snapshotExportS3SecretAccessKey", "rotated"))
+	})
+
+	It("Create_nfs_sc_with_project_quota", func() {
+		const (
+			nscName         = "project-quota"
+			credentialsName = "nfs-server-ssh"
+		)
+		credentials := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      credentialsName,
+				Namespace: controllerNamespace,
+			},
+			Data: map[string][]byte{
+				controller.ProjectQuotaSSHUserSecretKey:       []byte("quota"),
+				controller.ProjectQuotaSSHPrivateKeySecretKey: []byte("private key"),
+				controller.ProjectQuotaSSHKnownHostsSecretKey: []byte("server ssh-ed25519 AAAA"),
+			},
+		}
+		err := cl.Create(ctx, credentials)
+		Expect(err).NotTo(HaveOccurred())
+
+		nsc := generateNFSStorageClass(NFSStorageClassConfig{
+			Name:              nscName,
+			Host:              server,
+			Share:             share,
+			NFSVersion:        nfsVer,
+			ReclaimPolicy:     string(corev1.PersistentVolumeReclaimDelete),
+			VolumeBindingMode: string(storagev1.VolumeBindingWaitForFirstConsumer),
+		})
+		nsc.Spec.VolumeUsage = &v1alpha1.NFSStorageClassVolumeUsage{
+			Enforcement:  "Block",
+			ProjectQuota: &v1alpha1.NFSStorageClassProjectQuota{SSHSecretName: credentialsName},
+		}
+		err = cl.Create(ctx, nsc)
+		Expect(err).NotTo(HaveOccurred())
+
+		scList := &storagev1.StorageClassList{}
+		err = cl.List(ctx, scList)
+		Expect(err).NotTo(HaveOccurred())
+		shouldRequeue, err := controller.RunEventReconcile(ctx, cl, log, scList, nsc, controllerNamespace, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(shouldRequeue).To(BeFalse())
+
+		secret := &corev1.Secret{}
+		err = cl.Get(ctx, client.ObjectKey{Name: controller.SecretForMountOptionsPrefix + nscName, Namespace: controllerNamespace}, secret)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(secret.StringData).To(HaveKeyWithValue("projectQuotaExportPath", share))
+		Expect(secret.StringData).To(HaveKeyWithValue("projectQuotaSSHUser", "quota"))
+		Expect(secret.StringData).To(HaveKeyWithValue("projectQuotaSSHPrivateKey", "private key"))
+		Expect(secret.StringData).To(HaveKeyWithValue("projectQuotaSSHKnownHosts", "server ssh-ed25519 AAAA"))
+
+		// the quota of an expanded volume is changed with the same Secret
+		sc := &storagev1.StorageClass{}
+		err = cl.Get(ctx, client.ObjectKey{Name: nscName}, sc)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(sc.Annotations).To(HaveKeyWithValue(controller.VolumeUsageEnforcementAnnotationKey, "Block"))
+		Expect(sc.Parameters).To(HaveKeyWithValue(controller.ControllerExpandSecretNameKey, controller.SecretForMountOptionsPrefix+nscName))
+		Expect(sc.Parameters).To(HaveKeyWithValue(controller.ControllerExpandSecretNamespaceKey, controllerNamespace))
+	})
+
+	It("Create_nfs_sc_with_content_source", func() {
+		const (
+			nscName       = "content-source-new"
+			oldNscName    = "content-source-old"
+			tlsNscName    = "content-source-tls"
+			contentSource = "contentSourceMountOptions"
+		)
+		oldNsc := generateNFSStorageClass(NFSStorageClassConfig{
+			Name:              oldNscName,
+			Host:              "old-filer",
+			Share:             "/data",
+			NFSVersion:        "3",
+			MountMode:         "hard",
+			ReclaimPolicy:     string(corev1.PersistentVolumeReclaimDelete),
+			VolumeBindingMode: string(storagev1.VolumeBindingWaitForFirstConsumer),
+		})
+		err := cl.Create(ctx, oldNsc)
+		Expect(err).NotTo(HaveOccurred())
+
+		// volumes from a share mounted with TLS are not copied to a share mounted without it
+		tlsNsc := generateNFSStorageClass(NFSStorageClassConfig{
+			Name:              tlsNscName,
+			Host:              "tls-filer",
+			Share:             "/data",
+			NFSVersion:        "4.2",
+			ReclaimPolicy:     string(corev1.PersistentVolumeReclaimDelete),
+			VolumeBindingMode: string(storagev1.VolumeBindingWaitForFirstConsumer),
+		})
+		tlsNsc.Spec.Connection.Tls = true
+		err = cl.Create(ctx, tlsNsc)
+		Expect(err).NotTo(HaveOccurred())
+
+		nsc := generateNFSStorageClass(NFSStorageClassConfig{
+			Name:              nscName,
+			Host:              "new-filer",
+			Share:             "/data",
+			NFSVersion:        "4.1",
+			ReclaimPolicy:     string(corev1.PersistentVolumeReclaimDelete),
+			VolumeBindingMode: string(storagev1.VolumeBindingWaitForFirstConsumer),
+		})
+		err = cl.Create(ctx, nsc)
+		Expect(err).NotTo(HaveOccurred())
+
+		scList := &storagev1.StorageClassList{}
+		err = cl.List(ctx, scList)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = cl.Get(ctx, client.ObjectKey{Name: nscName}, nsc)
+		Expect(err).NotTo(HaveOccurred())
+		shouldRequeue, err := controller.RunEventReconcile(ctx, cl, log, scList, nsc, controllerNamespace, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(shouldRequeue).To(BeFalse())
+
+		secret := &corev1.Secret{}
+		err = cl.Get(ctx, client.ObjectKey{Name: controller.SecretForMountOptionsPrefix + nscName, Namespace: controllerNamespace}, secret)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(secret.StringData).To(HaveKey(contentSource))
+
+		shares := map[string]string{}
+		err = json.Unmarshal([]byte(secret.StringData[contentSource]), &shares)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(shares).To(HaveKeyWithValue("old-filer:/data", ContainSubstring("nfsvers=3")))
+		Expect(shares).NotTo(HaveKey("tls-filer:/data"))
+		Expect(shares).NotTo(HaveKey("new-filer:/data"))
+	})
+
+	It("Create_nfs_sc_with_volume_usage", func() {
+		const nscName = "volume-usage"
+		nsc := generateNFSStorageClass(NFSStorageClassConfig{
+			Name:              nscName,
+			Host:              server,
+			Share:             share,
+			NFSVersion:        "4.1",
+			ReclaimPolicy:     string(corev1.PersistentVolumeReclaimDelete),
+			VolumeBindingMode: string(storagev1.VolumeBindingWaitForFirstConsumer),
+		})
+		nsc.Spec.VolumeUsage = &v1alpha1.NFSStorageClassVolumeUsage{Enforcement: "ReadOnlyRemount"}
+		err := cl.Create(ctx, nsc)
+		Expect(err).NotTo(HaveOccurred())
+
+		scList := &storagev1.StorageClassList{}
+		err = cl.List(ctx, scList)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = cl.Get(ctx, client.ObjectKey{Name: nscName}, nsc)
+		Expect(err).NotTo(HaveOccurred())
+		shouldRequeue, err := controller.RunEventReconcile(ctx, cl, log, scList, nsc, controllerNamespace, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(shouldRequeue).To(BeFalse())
+
+		sc := &storagev1.StorageClass{}
+		err = cl.Get(ctx, client.ObjectKey{Name: nscName}, sc)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(sc.Annotations).To(HaveKeyWithValue(controller.VolumeUsageEnforcementAnnotationKey, "ReadOnlyRemount"))
+
+		// the usage is no longer measured once the section is removed
+		nsc.Spec.VolumeUsage = nil
+		err = cl.Update(ctx, nsc)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = cl.List(ctx, scList)
+		Expect(err).NotTo(HaveOccurred())
+		err = cl.Get(ctx, client.ObjectKey{Name: nscName}, nsc)
+		Expect(err).NotTo(HaveOccurred())
+		shouldRequeue, err = controller.RunEventReconcile(ctx, cl, log, scList, nsc, controllerNamespace, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(shouldRequeue).To(BeFalse())
+
+		err = cl.Get(ctx, client.ObjectKey{Name: nscName}, sc)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(sc.Annotations).NotTo(HaveKey(controller.VolumeUsageEnforcementAnnotationKey))
+		Expect(sc.Annotations).To(HaveKeyWithValue(controller.NFSStorageClassVolumeSnapshotClassAnnotationKey, nscName))
+	})
+
+	It("Create_nfs_sc_with_orphan_cleanup", func() {
+		const nscName = "orphan-cleanup"
+		nsc := generateNFSStorageClass(NFSStorageClassConfig{
+			Name:              nscName,
+			Host:              server,
+			Share:             share,
+			NFSVersion:        "4.1",
+			ReclaimPolicy:     string(corev1.PersistentVolumeReclaimDelete),
+			VolumeBindingMode: string(storagev1.VolumeBindingWaitForFirstConsumer),
+		})
+		nsc.Spec.OrphanCleanup = &v1alpha1.NFSStorageClassOrphanCleanup{DeleteOlderThan: "168h"}
+		err := cl.Create(ctx, nsc)
+		Expect(err).NotTo(HaveOccurred())
+
+		scList := &storagev1.StorageClassList{}
+		err = cl.List(ctx, scList)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = cl.Get(ctx, client.ObjectKey{Name: nscName}, nsc)
+		Expect(err).NotTo(HaveOccurred())
+		shouldRequeue, err := controller.RunEventReconcile(ctx, cl, log, scList, nsc, controllerNamespace, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(shouldRequeue).To(BeFalse())
+
+		secret := &corev1.Secret{}
+		err = cl.Get(ctx, client.ObjectKey{Name: controller.SecretForMountOptionsPrefix + nscName, Namespace: controllerNamespace}, secret)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(secret.StringData).To(HaveKeyWithValue("orphanDeleteOlderThan", "168h"))
+	})
+
+	It("Create_nfs_sc_with_archive_on_delete", func() {
+		const nscName = "archive-on-delete"
+		nsc := generateNFSStorageClass(NFSStorageClassConfig{
+			Name:              nscName,
+			Host:              server,
+			Share:             share,
+			NFSVersion:        "4.1",
+			ReclaimPolicy:     string(corev1.PersistentVolumeReclaimDelete),
+			VolumeBindingMode: string(storagev1.VolumeBindingWaitForFirstConsumer),
+		})
+		nsc.Spec.OnDelete = "Archive"
+		nsc.Spec.RetainArchivedFor = "720h"
+		err := cl.Create(ctx, nsc)
+		Expect(err).NotTo(HaveOccurred())
+
+		scList := &storagev1.StorageClassList{}
+		err = cl.List(ctx, scList)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = cl.Get(ctx, client.ObjectKey{Name: nscName}, nsc)
+		Expect(err).NotTo(HaveOccurred())
+		shouldRequeue, err := controller.RunEventReconcile(ctx, cl, log, scList, nsc, controllerNamespace, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(shouldRequeue).To(BeFalse())
+
+		sc := &storagev1.StorageClass{}
+		err = cl.Get(ctx, client.ObjectKey{Name: nscName}, sc)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(sc.Parameters).To(HaveKeyWithValue(controller.OnDeleteParamKey, "archive"))
+
+		secret := &corev1.Secret{}
+		err = cl.Get(ctx, client.ObjectKey{Name: controller.SecretForMountOptionsPrefix + nscName, Namespace: controllerNamespace}, secret)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(secret.StringData).To(HaveKeyWithValue("retainArchivedFor", "720h"))
+	})
+
 	// TODO: "Create_nfs_sc_when_sc_with_nfs_provisioner_exists_and_secret_does_not_exists", "Create_nfs_sc_when_sc_does_not_exists_and_secret_exists", "Create_nfs_sc_when_sc_with_nfs_provisioner_exists_and_secret_exists", "Update_nfs_sc_when_sc_with_nfs_provisioner_exists_and_secret_does_not_exists", "Remove_nfs_sc_when_sc_with_nfs_provisioner_exists_and_secret_does_not_exists", "Remove_nfs_sc_when_sc_does_not_exists_and_secret_exists"
 
 })
@@ -34,6 +34,7 @@ import (
 	v1alpha1 "github.com/deckhouse/csi-nfs/api/v1alpha1"
 	"github.com/deckhouse/csi-nfs/images/controller/pkg/config"
 	"github.com/deckhouse/csi-nfs/images/controller/pkg/logger"
+	"github.com/deckhouse/csi-nfs/lib/go/common/pkg/nodeselector"
 )
 
 const (
@@ -51,11 +52,7 @@ var (
 		CSIControllerLabel,
 		CSINodeLabel,
 	}
-	DefaultNodeSelector = &metav1.LabelSelector{
-		MatchLabels: map[string]string{
-			"kubernetes.io/os": "linux",
-		},
-	}
+	DefaultNodeSelector = nodeselector.DefaultNodeSelector
 )
 
 func RunNodeSelectorReconciler(ctx context.Context, mgr manager.Manager, cfg config.Options, log logger.Logger) {
@@ -101,7 +98,11 @@ func ReconcileNodeSelector(ctx context.Context, cl client.Client, clusterWideCli
 
 	log.Trace(fmt.Sprintf("[GetNodeSelectorFromNFSStorageClasses] Found %d NFSStorageClasses: %+v", len(nfsStorageClasses.Items), nfsStorageClasses.Items))
 
-	userNodeSelectorList := GetNodeSelectorFromNFSStorageClasses(log, nfsStorageClasses)
+	userNodeSelectorList, err := GetNodeSelectorFromNFSStorageClasses(log, nfsStorageClasses)
+	if err != nil {
+		err = fmt.Errorf("[reconcileNodeSelector] Failed get node selectors of NFSStorageClasses: %w", err)
+		return err
+	}
 	log.Debug(fmt.Sprintf("[reconcileNodeSelector] User node selector list: %+v", userNodeSelectorList))
 
 	selectedNodes, err := GetNodesBySelectorList(ctx, cl, log, userNodeSelectorList)
@@ -213,25 +214,14 @@ func ReconcileNodeSelector(ctx context.Context, cl client.Client, clusterWideCli
 	return nil
 }
 
-func GetNodeSelectorFromNFSStorageClasses(log logger.Logger, nfsStorageClasses *v1alpha1.NFSStorageClassList) []*metav1.LabelSelector {
-	if len(nfsStorageClasses.Items) == 0 {
-		log.Debug(fmt.Sprintf("[GetNodeSelectorFromNFSStorageClasses] No NFSStorageClasses found. Return default NodeSelector %+v.", DefaultNodeSelector))
-		return []*metav1.LabelSelector{DefaultNodeSelector}
-	}
-
-	nodeSelectorList := []*metav1.LabelSelector{}
+// GetNodeSelectorFromNFSStorageClasses returns the node selectors of the
+// NFSStorageClasses, the csi-nfs node plugin runs on the nodes matching any
+// of them.
+func GetNodeSelectorFromNFSStorageClasses(log logger.Logger, nfsStorageClasses *v1alpha1.NFSStorageClassList) ([]*metav1.LabelSelector, error) {
 	for _, nfsStorageClass := range nfsStorageClasses.Items {
-		log.Debug(fmt.Sprintf("[GetNodeSelectorFromNFSStorageClasses] Process NFSStorageClass %s.", nfsStorageClass.Name))
-		if nfsStorageClass.Spec.WorkloadNodes == nil || nfsStorageClass.Spec.WorkloadNodes.NodeSelector == nil {
-			log.Debug(fmt.Sprintf("[GetNodeSelectorFromNFSStorageClasses] NFSStorageClass %s has not NodeSelector. Return default NodeSelector %+v.", nfsStorageClass.Name, DefaultNodeSelector))
-			return []*metav1.LabelSelector{DefaultNodeSelector}
-		}
-		log.Debug(fmt.Sprintf("[GetNodeSelectorFromNFSStorageClasses] Add NodeSelector %+v from NFSStorageClass %s.", nfsStorageClass.Spec.WorkloadNodes.NodeSelector, nfsStorageClass.Name))
-		nodeSelectorList = append(nodeSelectorList, nfsStorageClass.Spec.WorkloadNodes.NodeSelector)
+		log.Debug(fmt.Sprintf("[GetNodeSelectorFromNFSStorageClasses] Process NFSStorageClass %s, NodeSelector %+v.", nfsStorageClass.Name, nodeselector.ForNFSStorageClass(&nfsStorageClass)))
 	}
-
-	// TODO: make unique nodeSelectorList
-	return nodeSelectorList
+	return nodeselector.ForNFSStorageClasses(nfsStorageClasses.Items)
 }
 
 func GetNodesBySelectorList(ctx context.Context, cl client.Client, log logger.Logger, nodeSelectorList []*metav1.LabelSelector) (*corev1.NodeList, error) {
@@ -240,7 +230,7 @@ func GetNodesBySelectorList(ctx context.Context, cl client.Client, log logger.Lo
 
 	for _, nodeSelector := range nodeSelectorList {
 		log.Trace(fmt.Sprintf("[GetNodesBySelectorList] Process node selector: %+v", nodeSelector))
-		selector, err := metav1.LabelSelectorAsSelector(nodeSelector)
+		selector, err := nodeselector.Selector(nodeSelector)
 		if err != nil {
 			err = fmt.Errorf("[GetNodesBySelectorList] Failed convert selector %+v to labels.Selector: %w", nodeSelector, err)
 			return nil, err
@@ -0,0 +1,159 @@
+/*
+Copyright 2026 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	v1alpha1 "github.com/deckhouse/csi-nfs/api/v1alpha1"
+	"github.com/deckhouse/csi-nfs/images/controller/pkg/logger"
+	commonfeature "github.com/deckhouse/csi-nfs/lib/go/common/pkg/feature"
+	commonvalidating "github.com/deckhouse/csi-nfs/lib/go/common/pkg/validating"
+	d8commonapi "github.com/deckhouse/sds-common-lib/api/v1alpha1"
+)
+
+const (
+	TLSCertificatesValidConditionType = "TLSCertificatesValid"
+
+	TLSCertificatesValidReason        = "Valid"
+	TLSCertificatesExpiringSoonReason = "ExpiringSoon"
+	TLSCertificatesExpiredReason      = "Expired"
+	TLSCertificatesInvalidReason      = "Invalid"
+
+	// the certificates are checked again while the tlsParameters setting is
+	// set, so that their expiry is reported without changes of the ModuleConfig
+	tlsCertificatesRecheckInterval = time.Hour
+)
+
+var tlsCertificateExpiration = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "csi_nfs_tls_certificate_expiration_timestamp_seconds",
+	Help: "Expiration time of the certificates of the tlsParameters setting of the csi-nfs ModuleConfig.",
+}, []string{"setting", "subject"})
+
+func init() {
+	metrics.Registry.MustRegister(tlsCertificateExpiration)
+}
+
+// tlsCertificatesCheck is the result of the check of the certificates of the
+// tlsParameters setting.
+type tlsCertificatesCheck struct {
+	now          time.Time
+	configured   bool
+	certificates []commonvalidating.TLSCertificate
+	errs         field.ErrorList
+	warnings     []string
+}
+
+func checkTLSCertificates(mc *d8commonapi.ModuleConfig, now time.Time) tlsCertificatesCheck {
+	_, configured := mc.Spec.Settings["tlsParameters"]
+	certificates, errs, warnings := commonvalidating.ValidateTLSParameters(mc, now)
+	return tlsCertificatesCheck{now: now, configured: configured, certificates: certificates, errs: errs, warnings: warnings}
+}
+
+// expired returns whether a certificate is expired or not valid yet.
+func (c tlsCertificatesCheck) expired() bool {
+	for _, certificate := range c.certificates {
+		if !certificate.ValidAt(c.now) {
+			return true
+		}
+	}
+	return false
+}
+
+// setTLSCertificatesCondition sets the TLSCertificatesValid condition of the
+// NFSStorageClass using RPC-with-TLS or removes it from the others. It returns
+// whether the status is changed.
+func setTLSCertificatesCondition(nsc *v1alpha1.NFSStorageClass, check tlsCertificatesCheck) bool {
+	usesTLS := commonfeature.TLSEnabled() && nsc.Spec.Connection != nil && (nsc.Spec.Connection.Tls || nsc.Spec.Connection.Mtls)
+	if !usesTLS {
+		if nsc.Status == nil {
+			return false
+		}
+		return meta.RemoveStatusCondition(&nsc.Status.Conditions, TLSCertificatesValidConditionType)
+	}
+
+	condition := metav1.Condition{
+		Type:               TLSCertificatesValidConditionType,
+		Status:             metav1.ConditionTrue,
+		Reason:             TLSCertificatesValidReason,
+		Message:            "The certificates of the tlsParameters setting are valid",
+		ObservedGeneration: nsc.Generation,
+	}
+	switch {
+	case !check.configured:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = TLSCertificatesInvalidReason
+		condition.Message = "The tlsParameters setting is missing"
+	case len(check.errs) > 0:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = TLSCertificatesInvalidReason
+		condition.Message = check.errs.ToAggregate().Error()
+	case check.expired():
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = TLSCertificatesExpiredReason
+		condition.Message = strings.Join(check.warnings, "; ")
+	case len(check.warnings) > 0:
+		condition.Reason = TLSCertificatesExpiringSoonReason
+		condition.Message = strings.Join(check.warnings, "; ")
+	}
+
+	if nsc.Status == nil {
+		nsc.Status = &v1alpha1.NFSStorageClassStatus{}
+	}
+	return meta.SetStatusCondition(&nsc.Status.Conditions, condition)
+}
+
+// RunTLSCertificatesReconcile exports the expiration time of the certificates
+// of the tlsParameters setting and updates the TLSCertificatesValid condition
+// of the NFSStorageClasses. It returns whether the certificates should be
+// checked again.
+func RunTLSCertificatesReconcile(ctx context.Context, cl client.Client, log logger.Logger, mc *d8commonapi.ModuleConfig, nscList *v1alpha1.NFSStorageClassList) (bool, error) {
+	check := checkTLSCertificates(mc, time.Now())
+
+	tlsCertificateExpiration.Reset()
+	for _, certificate := range check.certificates {
+		tlsCertificateExpiration.WithLabelValues(certificate.Setting, certificate.Subject).Set(float64(certificate.NotAfter.Unix()))
+	}
+	for _, warning := range check.warnings {
+		log.Warning(fmt.Sprintf("[RunTLSCertificatesReconcile] ModuleConfig %s: %s", mc.Name, warning))
+	}
+	if len(check.errs) > 0 {
+		log.Warning(fmt.Sprintf("[RunTLSCertificatesReconcile] ModuleConfig %s has invalid TLS certificates (%v)", mc.Name, check.errs.ToAggregate()))
+	}
+
+	for _, nsc := range nscList.Items {
+		if !setTLSCertificatesCondition(&nsc, check) {
+			continue
+		}
+		if err := cl.Status().Update(ctx, &nsc); err != nil {
+			return true, fmt.Errorf("[RunTLSCertificatesReconcile] unable to update the status of the NFSStorageClass %s: %w", nsc.Name, err)
+		}
+		log.Debug(fmt.Sprintf("[RunTLSCertificatesReconcile] successfully updated the %s condition of the NFSStorageClass %s", TLSCertificatesValidConditionType, nsc.Name))
+	}
+
+	return commonfeature.TLSEnabled() && check.configured, nil
+}
@@ -0,0 +1,160 @@
+/*
+Copyright 2026 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	v1alpha1 "github.com/deckhouse/csi-nfs/api/v1alpha1"
+	"github.com/deckhouse/csi-nfs/images/controller/pkg/controller"
+	"github.com/deckhouse/csi-nfs/images/controller/pkg/logger"
+	commonfeature "github.com/deckhouse/csi-nfs/lib/go/common/pkg/feature"
+	d8commonapi "github.com/deckhouse/sds-common-lib/api/v1alpha1"
+)
+
+var _ = Describe("NFSStorageClass TLSCertificatesValid condition", func() {
+	var (
+		ctx = context.Background()
+		cl  = NewFakeClient()
+		log = logger.Logger{}
+	)
+
+	// caValue returns a base64 encoded self-signed CA valid until notAfter
+	caValue := func(notAfter time.Time) string {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+		template := &x509.Certificate{
+			SerialNumber:          big.NewInt(1),
+			Subject:               pkix.Name{CommonName: "nfs-ca"},
+			NotBefore:             notAfter.Add(-365 * 24 * time.Hour),
+			NotAfter:              notAfter,
+			IsCA:                  true,
+			BasicConstraintsValid: true,
+			KeyUsage:              x509.KeyUsageCertSign,
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+		Expect(err).NotTo(HaveOccurred())
+		return base64.StdEncoding.EncodeToString(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	}
+
+	moduleConfig := func(ca string) *d8commonapi.ModuleConfig {
+		return &d8commonapi.ModuleConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: "csi-nfs"},
+			Spec: d8commonapi.ModuleConfigSpec{
+				Settings: d8commonapi.SettingsValues{"tlsParameters": map[string]any{"ca": ca}},
+			},
+		}
+	}
+
+	reconcile := func(mc *d8commonapi.ModuleConfig) bool {
+		nscList := &v1alpha1.NFSStorageClassList{}
+		Expect(cl.List(ctx, nscList)).To(Succeed())
+		shouldRecheck, err := controller.RunTLSCertificatesReconcile(ctx, cl, log, mc, nscList)
+		Expect(err).NotTo(HaveOccurred())
+		return shouldRecheck
+	}
+
+	condition := func(name string) *metav1.Condition {
+		nsc := &v1alpha1.NFSStorageClass{}
+		Expect(cl.Get(ctx, client.ObjectKey{Name: name}, nsc)).To(Succeed())
+		if nsc.Status == nil {
+			return nil
+		}
+		return meta.FindStatusCondition(nsc.Status.Conditions, controller.TLSCertificatesValidConditionType)
+	}
+
+	BeforeEach(func() {
+		if !commonfeature.TLSEnabled() {
+			Skip("RPC-with-TLS is not available in the edition")
+		}
+	})
+
+	It("Create_NFSStorageClasses", func() {
+		for name, tls := range map[string]bool{"nsc-tls": true, "nsc-plain": false} {
+			nsc := &v1alpha1.NFSStorageClass{
+				ObjectMeta: metav1.ObjectMeta{Name: name},
+				Spec: v1alpha1.NFSStorageClassSpec{
+					Connection: &v1alpha1.NFSStorageClassConnection{Host: "192.168.1.100", Share: "/data", NFSVersion: "4.2", Tls: tls},
+				},
+			}
+			Expect(cl.Create(ctx, nsc)).To(Succeed())
+		}
+	})
+
+	It("Sets_the_condition_of_valid_certificates", func() {
+		Expect(reconcile(moduleConfig(caValue(time.Now().Add(365 * 24 * time.Hour))))).To(BeTrue())
+
+		cond := condition("nsc-tls")
+		Expect(cond).NotTo(BeNil())
+		Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+		Expect(cond.Reason).To(Equal(controller.TLSCertificatesValidReason))
+		Expect(condition("nsc-plain")).To(BeNil())
+	})
+
+	It("Sets_the_condition_of_expiring_certificates", func() {
+		Expect(reconcile(moduleConfig(caValue(time.Now().Add(7 * 24 * time.Hour))))).To(BeTrue())
+
+		cond := condition("nsc-tls")
+		Expect(cond).NotTo(BeNil())
+		Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+		Expect(cond.Reason).To(Equal(controller.TLSCertificatesExpiringSoonReason))
+		Expect(cond.Message).To(ContainSubstring("nfs-ca"))
+	})
+
+	It("Sets_the_condition_of_expired_certificates", func() {
+		Expect(reconcile(moduleConfig(caValue(time.Now().Add(-time.Hour))))).To(BeTrue())
+
+		cond := condition("nsc-tls")
+		Expect(cond).NotTo(BeNil())
+		Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+		Expect(cond.Reason).To(Equal(controller.TLSCertificatesExpiredReason))
+		Expect(cond.Message).To(ContainSubstring("expired"))
+	})
+
+	It("Sets_the_condition_of_malformed_certificates", func() {
+		Expect(reconcile(moduleConfig(base64.StdEncoding.EncodeToString([]byte("Root certificate in PEM format"))))).To(BeTrue())
+
+		cond := condition("nsc-tls")
+		Expect(cond).NotTo(BeNil())
+		Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+		Expect(cond.Reason).To(Equal(controller.TLSCertificatesInvalidReason))
+	})
+
+	It("Sets_the_condition_without_tlsParameters", func() {
+		Expect(reconcile(&d8commonapi.ModuleConfig{ObjectMeta: metav1.ObjectMeta{Name: "csi-nfs"}})).To(BeFalse())
+
+		cond := condition("nsc-tls")
+		Expect(cond).NotTo(BeNil())
+		Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+		Expect(cond.Reason).To(Equal(controller.TLSCertificatesInvalidReason))
+	})
+})
@@ -28,15 +28,23 @@ import (
 	"time"
 
 	"github.com/spf13/cobra"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	coordinationv1 "k8s.io/api/coordination/v1"
 	corev1 "k8s.io/api/core/v1"
 	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 	"sigs.k8s.io/yaml"
 
 	"github.com/deckhouse/csi-nfs/api/v1alpha1"
+	"github.com/deckhouse/csi-nfs/images/csi-nfs-scheduler-extender/pkg/consts"
 	"github.com/deckhouse/csi-nfs/images/csi-nfs-scheduler-extender/pkg/kubutils"
 	"github.com/deckhouse/csi-nfs/images/csi-nfs-scheduler-extender/pkg/logger"
 	"github.com/deckhouse/csi-nfs/images/csi-nfs-scheduler-extender/pkg/scheduler"
@@ -46,32 +54,44 @@ const (
 	defaultDivisor                = 1
 	defaultListenAddr             = ":8000"
 	defaultHealthProbeBindAddress = ":8081"
-	defaultCacheSize              = 10
+	defaultCacheSize              = 1000
+	defaultPVCExpiredDurationSec  = 30
+	defaultMetricsBindAddress     = ":8080"
 	defaultcertFile               = "/etc/csi-nfs-scheduler-extender/certs/tls.crt"
 	defaultkeyFile                = "/etc/csi-nfs-scheduler-extender/certs/tls.key"
 )
 
 type Config struct {
-	ListenAddr             string  `json:"listen"`
-	DefaultDivisor         float64 `json:"default-divisor"`
-	LogLevel               string  `json:"log-level"`
-	CacheSize              int     `json:"cache-size"`
-	HealthProbeBindAddress string  `json:"health-probe-bind-address"`
-	CertFile               string  `json:"cert-file"`
-	KeyFile                string  `json:"key-file"`
-	PVCExpiredDurationSec  int     `json:"pvc-expired-duration-sec"`
+	ListenAddr             string                 `json:"listen"`
+	DefaultDivisor         float64                `json:"default-divisor"`
+	LogLevel               string                 `json:"log-level"`
+	CacheSize              int                    `json:"cache-size"`
+	HealthProbeBindAddress string                 `json:"health-probe-bind-address"`
+	CertFile               string                 `json:"cert-file"`
+	KeyFile                string                 `json:"key-file"`
+	PVCExpiredDurationSec  int                    `json:"pvc-expired-duration-sec"`
+	MetricsBindAddress     string                 `json:"metrics-bind-address"`
+	ScoreWeights           scheduler.ScoreWeights `json:"score-weights"`
 }
 
 var cfgFilePath string
 
 var resourcesSchemeFuncs = []func(*runtime.Scheme) error{
 	corev1.AddToScheme,
+	coordinationv1.AddToScheme,
 	storagev1.AddToScheme,
 	v1alpha1.AddToScheme,
+	authenticationv1.AddToScheme,
+	authorizationv1.AddToScheme,
 }
 
 var config = &Config{
 	ListenAddr:             defaultListenAddr,
+	DefaultDivisor:         defaultDivisor,
+	CacheSize:              defaultCacheSize,
+	PVCExpiredDurationSec:  defaultPVCExpiredDurationSec,
+	MetricsBindAddress:     defaultMetricsBindAddress,
+	ScoreWeights:           scheduler.DefaultScoreWeights,
 	HealthProbeBindAddress: defaultHealthProbeBindAddress,
 	LogLevel:               "2",
 	CertFile:               defaultcertFile,
@@ -83,9 +103,12 @@ var rootCmd = &cobra.Command{
 	Version: "development",
 	Short:   "a scheduler-extender for csi-nfs",
 	Long: `A scheduler-extender for csi-nfs.
-The extender implements filter verbs.
+The extender implements filter and prioritize verbs.
 The filter verb is "filter" and served at "/filter" via HTTP.
 It filters out nodes that not selected by user's selectors.
+The prioritize verb is "prioritize" and served at "/prioritize" via HTTP.
+It prefers nodes close to the NFS servers of the Pod volumes.
+The placement decision for a Pod is served at "/explain" via HTTP.
 `,
 	RunE: func(cmd *cobra.Command, _ []string) error {
 		// to avoid printing usage information when error is returned
@@ -151,7 +174,20 @@ func subMain(ctx context.Context) error {
 		Scheme:                 scheme,
 		Logger:                 log.GetLogger(),
 		HealthProbeBindAddress: config.HealthProbeBindAddress,
-		BaseContext:            func() context.Context { return ctx },
+		// only the csi-nfs-node Pods and the Leases with the NFS client
+		// statistics of the nodes are read by the extender
+		Cache: cache.Options{ByObject: map[client.Object]cache.ByObject{
+			&corev1.Pod{}: {
+				Namespaces: map[string]cache.Config{os.Getenv("NAMESPACE"): {}},
+				Label:      labels.SelectorFromSet(labels.Set{"app": consts.CSINodeAppLabelValue}),
+			},
+			&coordinationv1.Lease{}: {
+				Namespaces: map[string]cache.Config{os.Getenv("NAMESPACE"): {}},
+				Label:      labels.SelectorFromSet(labels.Set{consts.ClientStatsLabel: ""}),
+			},
+		}},
+		Metrics:     metricsserver.Options{BindAddress: config.MetricsBindAddress},
+		BaseContext: func() context.Context { return ctx },
 	}
 
 	mgr, err := manager.New(kConfig, managerOpts)
@@ -160,7 +196,16 @@ func subMain(ctx context.Context) error {
 		return err
 	}
 
-	httpHandler, err := scheduler.NewHandler(ctx, mgr.GetClient(), *log)
+	httpHandler, err := scheduler.NewHandler(ctx, mgr.GetClient(), *log, scheduler.Options{
+		DefaultDivisor:     config.DefaultDivisor,
+		ScoreWeights:       config.ScoreWeights,
+		APIReader:          mgr.GetAPIReader(),
+		Informers:          mgr.GetCache(),
+		CacheSize:          config.CacheSize,
+		PVCExpiredDuration: time.Duration(config.PVCExpiredDurationSec) * time.Second,
+		CSINodeNamespace:   os.Getenv("NAMESPACE"),
+		EventRecorder:      mgr.GetEventRecorderFor("csi-nfs-scheduler-extender"),
+	})
 	if err != nil {
 		log.Error(err, "[subMain] unable to create http.Handler of the scheduler extender")
 		return err
@@ -173,7 +218,13 @@ func subMain(ctx context.Context) error {
 	}
 	log.Info("[subMain] successfully AddHealthzCheck")
 
-	if err = mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
+	// the extender is ready when the informers of its caches are synced
+	if err = mgr.AddReadyzCheck("readyz", func(req *http.Request) error {
+		if !mgr.GetCache().WaitForCacheSync(req.Context()) {
+			return errors.New("informer caches are not synced")
+		}
+		return nil
+	}); err != nil {
 		log.Error(err, "[subMain] unable to mgr.AddReadyzCheck")
 		return err
 	}
@@ -19,4 +19,16 @@ package consts
 const (
 	CSINFSProvisioner = "nfs.csi.k8s.io"
 	ConfigSecretName  = "d8-csi-nfs-controller-config"
+
+	// CSINFSNodeLabelKey is the label of the nodes selected for the csi-nfs-node.
+	CSINFSNodeLabelKey = "storage.deckhouse.io/csi-nfs-node"
+	// CSINodeAppLabelValue is the app label of the csi-nfs-node Pods.
+	CSINodeAppLabelValue = "csi-node"
+
+	// ClientStatsLabel is the label of the Leases of the nodes with the NFS
+	// client statistics written by the csi-nfs-node.
+	ClientStatsLabel = "storage.deckhouse.io/nfs-client-stats"
+	// ClientStatsAnnotation is the annotation of the Lease of a node with
+	// the NFS servers of the mounted volumes and their RTT.
+	ClientStatsAnnotation = "storage.deckhouse.io/nfs-client-stats"
 )
@@ -59,3 +59,47 @@ type ExtenderFilterResult struct {
 
 // FailedNodesMap is copied from https://godoc.org/k8s.io/kubernetes/pkg/scheduler/api/v1#FailedNodesMap
 type FailedNodesMap map[string]string
+
+// ExplainResult is the placement decision of the scheduler extender for a Pod
+type ExplainResult struct {
+	// Namespace and name of the Pod
+	Pod string `json:"pod"`
+	// Volumes of the Pod provisioned by csi-nfs
+	Volumes []ExplainVolume `json:"volumes"`
+	// Nodes checked for the Pod
+	Nodes []ExplainNode `json:"nodes"`
+}
+
+// ExplainVolume is an NFS volume of the Pod and its NFSStorageClass
+type ExplainVolume struct {
+	// Name of the volume in the Pod
+	Name string `json:"name"`
+	// Source of the volume: persistentVolumeClaim, ephemeral or csi
+	Source string `json:"source"`
+	// NFSStorageClass resolved for the volume
+	NFSStorageClass string `json:"nfsStorageClass"`
+	// Node selector of the NFSStorageClass, or the default one if it has none
+	NodeSelector string `json:"nodeSelector"`
+}
+
+// ExplainNode is the decision of the scheduler extender for a node
+type ExplainNode struct {
+	// Name of the node
+	Name string `json:"name"`
+	// Whether the Pod can be scheduled to the node
+	Suitable bool `json:"suitable"`
+	// Why the Pod cannot be scheduled to the node
+	Reason string `json:"reason,omitempty"`
+	// Node selectors of the NFSStorageClasses of the Pod volumes
+	Selectors []ExplainSelector `json:"selectors,omitempty"`
+}
+
+// ExplainSelector is the node selector of an NFSStorageClass checked for a node
+type ExplainSelector struct {
+	// NFSStorageClass of the node selector
+	NFSStorageClass string `json:"nfsStorageClass"`
+	// Node selector of the NFSStorageClass, or the default one if it has none
+	NodeSelector string `json:"nodeSelector"`
+	// Whether the labels of the node match the node selector
+	Matched bool `json:"matched"`
+}
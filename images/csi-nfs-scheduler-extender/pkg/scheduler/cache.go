@@ -0,0 +1,377 @@
+/*
+Copyright 2026 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilcache "k8s.io/apimachinery/pkg/util/cache"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	v1alpha1 "github.com/deckhouse/csi-nfs/api/v1alpha1"
+	"github.com/deckhouse/csi-nfs/images/csi-nfs-scheduler-extender/pkg/logger"
+	"github.com/deckhouse/csi-nfs/lib/go/common/pkg/nodeselector"
+)
+
+// nodeSelectorIndex keeps the labels of all the nodes and the names of the
+// nodes selected by the recently used node selectors, updated on the Node
+// and NFSStorageClass events.
+type nodeSelectorIndex struct {
+	mu      sync.Mutex
+	size    int
+	nodes   map[string]labels.Set
+	entries map[string]*nodeSelectorEntry
+}
+
+type nodeSelectorEntry struct {
+	selector  labels.Selector
+	nodeNames map[string]struct{}
+	lastUsed  time.Time
+}
+
+func newNodeSelectorIndex(size int) *nodeSelectorIndex {
+	return &nodeSelectorIndex{
+		size:    max(size, 1),
+		nodes:   map[string]labels.Set{},
+		entries: map[string]*nodeSelectorEntry{},
+	}
+}
+
+// nodeSelectorKey returns the key of the node selector in the index, the
+// selectors matching the same nodes have the same key.
+func nodeSelectorKey(nodeSelector *metav1.LabelSelector) (string, labels.Selector, error) {
+	selector, err := nodeselector.Selector(nodeSelector)
+	if err != nil {
+		return "", nil, err
+	}
+	return selector.String(), selector, nil
+}
+
+func (idx *nodeSelectorIndex) upsertNode(node *corev1.Node) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	nodeLabels := labels.Set(node.Labels)
+	idx.nodes[node.Name] = nodeLabels
+	for _, entry := range idx.entries {
+		if entry.selector.Matches(nodeLabels) {
+			entry.nodeNames[node.Name] = struct{}{}
+		} else {
+			delete(entry.nodeNames, node.Name)
+		}
+	}
+}
+
+func (idx *nodeSelectorIndex) deleteNode(nodeName string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	delete(idx.nodes, nodeName)
+	for _, entry := range idx.entries {
+		delete(entry.nodeNames, nodeName)
+	}
+}
+
+// entry returns the entry of the node selector, computing it from the known
+// nodes and evicting the least recently used entry when the index is full.
+// It must be called with the lock held.
+func (idx *nodeSelectorIndex) entry(nodeSelector *metav1.LabelSelector) (*nodeSelectorEntry, bool, error) {
+	key, selector, err := nodeSelectorKey(nodeSelector)
+	if err != nil {
+		return nil, false, err
+	}
+	if entry, ok := idx.entries[key]; ok {
+		entry.lastUsed = time.Now()
+		return entry, true, nil
+	}
+
+	if len(idx.entries) >= idx.size {
+		var oldestKey string
+		var oldest time.Time
+		for k, e := range idx.entries {
+			if oldestKey == "" || e.lastUsed.Before(oldest) {
+				oldestKey, oldest = k, e.lastUsed
+			}
+		}
+		delete(idx.entries, oldestKey)
+	}
+
+	entry := &nodeSelectorEntry{selector: selector, nodeNames: map[string]struct{}{}, lastUsed: time.Now()}
+	for nodeName, nodeLabels := range idx.nodes {
+		if selector.Matches(nodeLabels) {
+			entry.nodeNames[nodeName] = struct{}{}
+		}
+	}
+	idx.entries[key] = entry
+	return entry, false, nil
+}
+
+// addNodeSelector precomputes the nodes of the node selector.
+func (idx *nodeSelectorIndex) addNodeSelector(nodeSelector *metav1.LabelSelector) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	_, _, err := idx.entry(nodeSelector)
+	return err
+}
+
+// commonNodeNames returns the sorted names of the nodes selected by all the
+// node selectors.
+func (idx *nodeSelectorIndex) commonNodeNames(nodeSelectorList []*metav1.LabelSelector) ([]string, error) {
+	if len(nodeSelectorList) == 0 {
+		return nil, fmt.Errorf("[commonNodeNames] Empty nodeSelectorList")
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	var common map[string]struct{}
+	for _, nodeSelector := range nodeSelectorList {
+		entry, hit, err := idx.entry(nodeSelector)
+		if err != nil {
+			return nil, fmt.Errorf("[commonNodeNames] invalid node selector %+v: %w", nodeSelector, err)
+		}
+		cacheLookups.WithLabelValues(cacheNodeSelectors, cacheResult(hit)).Inc()
+		if common == nil {
+			common = make(map[string]struct{}, len(entry.nodeNames))
+			for nodeName := range entry.nodeNames {
+				common[nodeName] = struct{}{}
+			}
+			continue
+		}
+		for nodeName := range common {
+			if _, ok := entry.nodeNames[nodeName]; !ok {
+				delete(common, nodeName)
+			}
+		}
+	}
+
+	nodeNames := make([]string, 0, len(common))
+	for nodeName := range common {
+		nodeNames = append(nodeNames, nodeName)
+	}
+	slices.Sort(nodeNames)
+	return nodeNames, nil
+}
+
+// podVolumes are the volumes of a Pod provisioned by the driver and their
+// NFSStorageClasses.
+type podVolumes struct {
+	shouldProcess     bool
+	volumes           []corev1.Volume
+	nfsStorageClasses *v1alpha1.NFSStorageClassList
+
+	// namespace and claims are the namespace and the names of all the PVCs
+	// of the Pod, whose events drop the cached volumes
+	namespace string
+	claims    []string
+}
+
+// usesClaim reports whether the Pod has a volume of the PVC.
+func (v *podVolumes) usesClaim(namespace, name string) bool {
+	return v.namespace == namespace && slices.Contains(v.claims, name)
+}
+
+// getPodVolumes returns the volumes of the Pod provisioned by the driver and
+// their NFSStorageClasses, cached for the filter and prioritize requests of
+// one scheduling cycle and the retries of an unschedulable Pod.
+func (s *scheduler) getPodVolumes(pod *corev1.Pod, targetProvisioner string) (*podVolumes, error) {
+	if s.podVolumesCache != nil {
+		if cached, ok := s.podVolumesCache.Get(pod.UID); ok {
+			cacheLookups.WithLabelValues(cachePodVolumes, cacheResult(true)).Inc()
+			return cached.(*podVolumes), nil
+		}
+		cacheLookups.WithLabelValues(cachePodVolumes, cacheResult(false)).Inc()
+	}
+
+	shouldProcess, volumes, err := shouldProcessPod(s.ctx, s.client, s.log, pod, targetProvisioner)
+	if err != nil {
+		return nil, err
+	}
+	result := &podVolumes{shouldProcess: shouldProcess, volumes: volumes, namespace: pod.Namespace}
+	for _, volume := range pod.Spec.Volumes {
+		if volume.PersistentVolumeClaim != nil {
+			result.claims = append(result.claims, volume.PersistentVolumeClaim.ClaimName)
+		}
+	}
+	if shouldProcess {
+		result.nfsStorageClasses, err = GetNFSStorageClassesFromVolumes(s.ctx, s.client, s.log, pod.Namespace, volumes)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if s.podVolumesCache != nil && pod.UID != "" {
+		s.podVolumesCache.Add(pod.UID, result, s.podVolumesTTL)
+	}
+	return result, nil
+}
+
+// commonNodeNames returns the names of the nodes selected by all the node
+// selectors, from the index when the handler is backed by informers.
+func (s *scheduler) commonNodeNames(nodeSelectorList []*metav1.LabelSelector) ([]string, error) {
+	if s.nodeIndex != nil {
+		return s.nodeIndex.commonNodeNames(nodeSelectorList)
+	}
+	return GetCommonNodesByNodeSelectorList(s.ctx, s.client, s.log, nodeSelectorList)
+}
+
+// watchInformers starts the informers of the objects read by the extender
+// and keeps the caches of the handler up to date with the Node,
+// NFSStorageClass, PersistentVolumeClaim and PersistentVolume events.
+func (s *scheduler) watchInformers(ctx context.Context, informers cache.Informers, cacheSize int, podVolumesTTL time.Duration) error {
+	objects := []client.Object{&corev1.PersistentVolumeClaim{}, &corev1.PersistentVolume{}, &storagev1.StorageClass{}}
+	if s.csiNodeNamespace != "" {
+		objects = append(objects, &corev1.Pod{})
+	}
+	for _, obj := range objects {
+		if _, err := informers.GetInformer(ctx, obj); err != nil {
+			return fmt.Errorf("unable to get an informer for %T: %w", obj, err)
+		}
+	}
+
+	s.podVolumesCache = utilcache.NewLRUExpireCache(max(cacheSize, 1))
+	s.podVolumesTTL = podVolumesTTL
+	s.nodeIndex = newNodeSelectorIndex(cacheSize)
+	if err := s.nodeIndex.addNodeSelector(DefaultNodeSelector); err != nil {
+		return fmt.Errorf("unable to index the default node selector: %w", err)
+	}
+	nodeInformer, err := informers.GetInformer(ctx, &corev1.Node{})
+	if err != nil {
+		return fmt.Errorf("unable to get an informer for nodes: %w", err)
+	}
+	_, err = nodeInformer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj any) {
+			if node, ok := obj.(*corev1.Node); ok {
+				s.nodeIndex.upsertNode(node)
+			}
+		},
+		UpdateFunc: func(_, obj any) {
+			if node, ok := obj.(*corev1.Node); ok {
+				s.nodeIndex.upsertNode(node)
+			}
+		},
+		DeleteFunc: func(obj any) {
+			if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if node, ok := obj.(*corev1.Node); ok {
+				s.nodeIndex.deleteNode(node.Name)
+			}
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("unable to watch nodes: %w", err)
+	}
+
+	nscInformer, err := informers.GetInformer(ctx, &v1alpha1.NFSStorageClass{})
+	if err != nil {
+		return fmt.Errorf("unable to get an informer for NFSStorageClasses: %w", err)
+	}
+	_, err = nscInformer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj any) {
+			s.onNFSStorageClassChange(s.log, obj)
+		},
+		UpdateFunc: func(_, obj any) {
+			s.onNFSStorageClassChange(s.log, obj)
+		},
+		DeleteFunc: func(any) {
+			s.onNFSStorageClassChange(s.log, nil)
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("unable to watch NFSStorageClasses: %w", err)
+	}
+
+	pvcInformer, err := informers.GetInformer(ctx, &corev1.PersistentVolumeClaim{})
+	if err != nil {
+		return fmt.Errorf("unable to get an informer for PVCs: %w", err)
+	}
+	onPVCChange := func(obj any) {
+		if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+			obj = tombstone.Obj
+		}
+		if pvc, ok := obj.(*corev1.PersistentVolumeClaim); ok {
+			s.onVolumeClaimChange(pvc.Namespace, pvc.Name)
+		}
+	}
+	_, err = pvcInformer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc:    onPVCChange,
+		UpdateFunc: func(_, obj any) { onPVCChange(obj) },
+		DeleteFunc: onPVCChange,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to watch PVCs: %w", err)
+	}
+
+	pvInformer, err := informers.GetInformer(ctx, &corev1.PersistentVolume{})
+	if err != nil {
+		return fmt.Errorf("unable to get an informer for PVs: %w", err)
+	}
+	onPVChange := func(obj any) {
+		if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+			obj = tombstone.Obj
+		}
+		if pv, ok := obj.(*corev1.PersistentVolume); ok && pv.Spec.ClaimRef != nil {
+			s.onVolumeClaimChange(pv.Spec.ClaimRef.Namespace, pv.Spec.ClaimRef.Name)
+		}
+	}
+	_, err = pvInformer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc:    onPVChange,
+		UpdateFunc: func(_, obj any) { onPVChange(obj) },
+		DeleteFunc: onPVChange,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to watch PVs: %w", err)
+	}
+	return nil
+}
+
+// onVolumeClaimChange drops the cached volumes of the Pods with the PVC, or
+// with the PVC the PersistentVolume is bound to, as the provisioner of the
+// PVC and its NFSStorageClass are read from both.
+func (s *scheduler) onVolumeClaimChange(namespace, name string) {
+	for _, key := range s.podVolumesCache.Keys() {
+		if cached, ok := s.podVolumesCache.Get(key); ok && cached.(*podVolumes).usesClaim(namespace, name) {
+			s.podVolumesCache.Remove(key)
+		}
+	}
+}
+
+// onNFSStorageClassChange drops the cached NFSStorageClasses of the Pods and
+// precomputes the nodes of the node selector of the changed NFSStorageClass.
+func (s *scheduler) onNFSStorageClassChange(log logger.Logger, obj any) {
+	s.podVolumesCache.RemoveAll(func(any) bool { return true })
+
+	nsc, ok := obj.(*v1alpha1.NFSStorageClass)
+	if !ok || nsc.Spec.WorkloadNodes == nil || nsc.Spec.WorkloadNodes.NodeSelector == nil {
+		return
+	}
+	log.Trace(fmt.Sprintf("[onNFSStorageClassChange] index the nodes of NFSStorageClass %s", nsc.Name))
+	if err := s.nodeIndex.addNodeSelector(nsc.Spec.WorkloadNodes.NodeSelector); err != nil {
+		log.Error(err, fmt.Sprintf("[onNFSStorageClassChange] unable to index the nodes of NFSStorageClass %s", nsc.Name))
+	}
+}
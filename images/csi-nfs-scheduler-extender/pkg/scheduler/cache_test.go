@@ -0,0 +1,158 @@
+/*
+Copyright 2026 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilcache "k8s.io/apimachinery/pkg/util/cache"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	v1alpha1 "github.com/deckhouse/csi-nfs/api/v1alpha1"
+	"github.com/deckhouse/csi-nfs/images/csi-nfs-scheduler-extender/pkg/logger"
+)
+
+func labeledNode(name string, nodeLabels map[string]string) *corev1.Node {
+	return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: nodeLabels}}
+}
+
+func TestNodeSelectorIndex(t *testing.T) {
+	nfsNodes := &metav1.LabelSelector{MatchLabels: map[string]string{"nfs": "true"}}
+	zoneA := &metav1.LabelSelector{MatchLabels: map[string]string{"zone": "a"}}
+
+	t.Run("test node events", func(t *testing.T) {
+		idx := newNodeSelectorIndex(10)
+		idx.upsertNode(labeledNode("node1", map[string]string{"nfs": "true", "zone": "a"}))
+		idx.upsertNode(labeledNode("node2", map[string]string{"nfs": "true", "zone": "b"}))
+		idx.upsertNode(labeledNode("node3", map[string]string{"zone": "a"}))
+
+		nodeNames, err := idx.commonNodeNames([]*metav1.LabelSelector{nfsNodes})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"node1", "node2"}, nodeNames)
+
+		nodeNames, err = idx.commonNodeNames([]*metav1.LabelSelector{nfsNodes, zoneA})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"node1"}, nodeNames)
+
+		// the cached entries follow the label changes and the deletions of the nodes
+		idx.upsertNode(labeledNode("node3", map[string]string{"nfs": "true", "zone": "a"}))
+		idx.upsertNode(labeledNode("node2", map[string]string{"zone": "b"}))
+		idx.deleteNode("node1")
+		nodeNames, err = idx.commonNodeNames([]*metav1.LabelSelector{nfsNodes, zoneA})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"node3"}, nodeNames)
+
+		_, err = idx.commonNodeNames(nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("test eviction", func(t *testing.T) {
+		idx := newNodeSelectorIndex(2)
+		idx.upsertNode(labeledNode("node1", map[string]string{"nfs": "true", "zone": "a"}))
+		idx.addNodeSelector(nfsNodes)
+		idx.addNodeSelector(zoneA)
+		idx.addNodeSelector(DefaultNodeSelector)
+		assert.Len(t, idx.entries, 2)
+
+		key, _, err := nodeSelectorKey(nfsNodes)
+		assert.NoError(t, err)
+		assert.NotContains(t, idx.entries, key)
+		nodeNames, err := idx.commonNodeNames([]*metav1.LabelSelector{nfsNodes})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"node1"}, nodeNames)
+	})
+}
+
+// countingClient counts the PVCs read by the client.
+type countingClient struct {
+	client.Client
+	pvcGets int
+}
+
+func (c *countingClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	if _, ok := obj.(*corev1.PersistentVolumeClaim); ok {
+		c.pvcGets++
+	}
+	return c.Client.Get(ctx, key, obj, opts...)
+}
+
+func TestGetPodVolumes(t *testing.T) {
+	storageClassName := "nfs"
+	scheme := runtime.NewScheme()
+	assert.NoError(t, clientgoscheme.AddToScheme(scheme))
+	assert.NoError(t, v1alpha1.AddToScheme(scheme))
+	cl := &countingClient{Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "pvc1",
+				Namespace:   "default",
+				Annotations: map[string]string{annotationStorageProvisioner: "nfs.csi.k8s.io"},
+			},
+			Spec: corev1.PersistentVolumeClaimSpec{StorageClassName: &storageClassName},
+		},
+		&v1alpha1.NFSStorageClass{ObjectMeta: metav1.ObjectMeta{Name: storageClassName}},
+	).Build()}
+	s := &scheduler{
+		ctx:             context.Background(),
+		client:          cl,
+		log:             logger.Logger{},
+		podVolumesCache: utilcache.NewLRUExpireCache(10),
+		podVolumesTTL:   time.Minute,
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "default", UID: types.UID("uid-1")},
+		Spec: corev1.PodSpec{Volumes: []corev1.Volume{{
+			Name:         "data",
+			VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "pvc1"}},
+		}}},
+	}
+
+	for range 2 {
+		volumes, err := s.getPodVolumes(pod, "nfs.csi.k8s.io")
+		assert.NoError(t, err)
+		assert.True(t, volumes.shouldProcess)
+		assert.Len(t, volumes.nfsStorageClasses.Items, 1)
+	}
+	// one read of shouldProcessPod and one of GetNFSStorageClassesFromVolumes
+	assert.Equal(t, 2, cl.pvcGets)
+
+	s.onNFSStorageClassChange(s.log, nil)
+	_, err := s.getPodVolumes(pod, "nfs.csi.k8s.io")
+	assert.NoError(t, err)
+	assert.Equal(t, 4, cl.pvcGets)
+
+	// only the events of the PVCs of the Pod drop its volumes
+	s.onVolumeClaimChange("default", "pvc2")
+	s.onVolumeClaimChange("other", "pvc1")
+	_, err = s.getPodVolumes(pod, "nfs.csi.k8s.io")
+	assert.NoError(t, err)
+	assert.Equal(t, 4, cl.pvcGets)
+
+	s.onVolumeClaimChange("default", "pvc1")
+	_, err = s.getPodVolumes(pod, "nfs.csi.k8s.io")
+	assert.NoError(t, err)
+	assert.Equal(t, 6, cl.pvcGets)
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2026 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	v1alpha1 "github.com/deckhouse/csi-nfs/api/v1alpha1"
+	"github.com/deckhouse/csi-nfs/images/csi-nfs-scheduler-extender/pkg/consts"
+)
+
+// The containers of the csi-nfs-node Pod preparing the node for NFSv3 and
+// RPC-with-TLS.
+const (
+	waitRpcbindContainer         = "wait-rpcbind"
+	ktlsEnablerContainer         = "ktls-enabler"
+	netHandshakeCheckerContainer = "net-handshake-checker"
+	tlshdContainer               = "tlshd"
+)
+
+// getCSINodePods returns the csi-nfs-node Pods by the names of their nodes,
+// preferring a Ready Pod when the DaemonSet is being updated.
+func getCSINodePods(ctx context.Context, cl client.Client, namespace string) (map[string]*corev1.Pod, error) {
+	pods := &corev1.PodList{}
+	err := cl.List(ctx, pods, client.InNamespace(namespace), client.MatchingLabels{"app": consts.CSINodeAppLabelValue})
+	if err != nil {
+		return nil, fmt.Errorf("error listing csi-nfs-node Pods in namespace %s: %w", namespace, err)
+	}
+
+	result := make(map[string]*corev1.Pod, len(pods.Items))
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Spec.NodeName == "" || pod.DeletionTimestamp != nil {
+			continue
+		}
+		if current, ok := result[pod.Spec.NodeName]; ok && (isPodReady(current) || !isPodReady(pod) && current.CreationTimestamp.After(pod.CreationTimestamp.Time)) {
+			continue
+		}
+		result[pod.Spec.NodeName] = pod
+	}
+	return result, nil
+}
+
+// checkNodeCapability returns why the node cannot mount the volumes of the
+// NFSStorageClasses, or an empty string when it can.
+func checkNodeCapability(node *corev1.Node, csiNodePod *corev1.Pod, nfsStorageClasses *v1alpha1.NFSStorageClassList) string {
+	if _, ok := node.Labels[consts.CSINFSNodeLabelKey]; !ok {
+		return fmt.Sprintf("node does not have the %s label", consts.CSINFSNodeLabelKey)
+	}
+	if csiNodePod == nil {
+		return "csi-nfs node plugin is not running on the node"
+	}
+
+	for _, nsc := range nfsStorageClasses.Items {
+		if nsc.Spec.Connection == nil {
+			continue
+		}
+		if nsc.Spec.Connection.NFSVersion == "3" {
+			if reason := checkInitContainer(csiNodePod, waitRpcbindContainer); reason != "" {
+				return fmt.Sprintf("NFSStorageClass %s uses NFSv3, but rpcbind is not available on the node: %s", nsc.Name, reason)
+			}
+		}
+		if nsc.Spec.Connection.Tls || nsc.Spec.Connection.Mtls {
+			if reason := checkInitContainer(csiNodePod, ktlsEnablerContainer); reason != "" {
+				return fmt.Sprintf("NFSStorageClass %s uses TLS, but kernel TLS is not enabled on the node: %s", nsc.Name, reason)
+			}
+			if reason := checkInitContainer(csiNodePod, netHandshakeCheckerContainer); reason != "" {
+				return fmt.Sprintf("NFSStorageClass %s uses TLS, but the kernel of the node does not support the TLS handshake: %s", nsc.Name, reason)
+			}
+			if reason := checkContainer(csiNodePod, tlshdContainer); reason != "" {
+				return fmt.Sprintf("NFSStorageClass %s uses TLS, but tlshd is not running on the node: %s", nsc.Name, reason)
+			}
+		}
+	}
+
+	if !isPodReady(csiNodePod) {
+		return "csi-nfs node plugin is not ready on the node"
+	}
+	return ""
+}
+
+// checkInitContainer returns why the init container of the Pod has not
+// completed successfully, or an empty string when it has.
+func checkInitContainer(pod *corev1.Pod, name string) string {
+	for _, status := range pod.Status.InitContainerStatuses {
+		if status.Name != name {
+			continue
+		}
+		switch {
+		case status.State.Terminated != nil && status.State.Terminated.ExitCode == 0:
+			return ""
+		case status.State.Terminated != nil:
+			return fmt.Sprintf("init container %s failed with exit code %d", name, status.State.Terminated.ExitCode)
+		case status.LastTerminationState.Terminated != nil:
+			return fmt.Sprintf("init container %s failed with exit code %d", name, status.LastTerminationState.Terminated.ExitCode)
+		default:
+			return fmt.Sprintf("init container %s has not completed", name)
+		}
+	}
+	return fmt.Sprintf("init container %s is disabled in the module settings", name)
+}
+
+// checkContainer returns why the container of the Pod is not ready, or an
+// empty string when it is.
+func checkContainer(pod *corev1.Pod, name string) string {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name != name {
+			continue
+		}
+		if status.Ready {
+			return ""
+		}
+		return fmt.Sprintf("container %s is not ready", name)
+	}
+	return fmt.Sprintf("container %s is disabled in the module settings", name)
+}
+
+func isPodReady(pod *corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// filterNodesByCapability moves the nodes which cannot mount the volumes of
+// the NFSStorageClasses from the suitable to the failed nodes of the result.
+func (s *scheduler) filterNodesByCapability(result *ExtenderFilterResult, nfsStorageClasses *v1alpha1.NFSStorageClassList) error {
+	csiNodePods, err := getCSINodePods(s.ctx, s.client, s.csiNodeNamespace)
+	if err != nil {
+		return err
+	}
+
+	suitable := make([]string, 0, len(*result.NodeNames))
+	for _, nodeName := range *result.NodeNames {
+		node := &corev1.Node{}
+		err := s.client.Get(s.ctx, client.ObjectKey{Name: nodeName}, node)
+		if err != nil {
+			if !k8serrors.IsNotFound(err) {
+				return fmt.Errorf("error getting node %s: %w", nodeName, err)
+			}
+			result.FailedNodes[nodeName] = "node is not found"
+			continue
+		}
+
+		if reason := checkNodeCapability(node, csiNodePods[nodeName], nfsStorageClasses); reason != "" {
+			result.FailedNodes[nodeName] = reason
+			continue
+		}
+		suitable = append(suitable, nodeName)
+	}
+	*result.NodeNames = suitable
+	return nil
+}
@@ -0,0 +1,312 @@
+/*
+Copyright 2026 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/deckhouse/csi-nfs/images/csi-nfs-scheduler-extender/pkg/consts"
+	"github.com/deckhouse/csi-nfs/lib/go/common/pkg/nodeselector"
+)
+
+const (
+	// filteredOutEventReason is the reason of the event written on a Pod when
+	// all the nodes are filtered out for its NFS volumes.
+	filteredOutEventReason = "NFSNodesFilteredOut"
+	// maxEventMessageLength is the limit of the message of an event.
+	maxEventMessageLength = 1024
+)
+
+// explain serves the placement decision for a Pod given in the body of a POST
+// request or by the namespace and name query parameters of a GET request. The
+// nodes are given in the comma-separated nodes query parameter, all the nodes
+// are checked by default. The requester must be allowed to get the Pods of the
+// namespace.
+func (s *scheduler) explain(w http.ResponseWriter, r *http.Request) {
+	s.log.Debug("[explain] starts the serving")
+	pod := &corev1.Pod{}
+	switch r.Method {
+	case http.MethodGet:
+		pod.Namespace, pod.Name = r.URL.Query().Get("namespace"), r.URL.Query().Get("name")
+		if pod.Namespace == "" || pod.Name == "" {
+			http.Error(w, "[explain] the namespace and name query parameters are required", http.StatusBadRequest)
+			return
+		}
+	case http.MethodPost:
+		reader := http.MaxBytesReader(w, r.Body, 10<<20)
+		if err := json.NewDecoder(reader).Decode(pod); err != nil {
+			s.log.Error(err, "[explain] unable to decode a request")
+			http.Error(w, fmt.Sprintf("[explain] unable to decode a request: %s", err), http.StatusBadRequest)
+			return
+		}
+		// the volumes of the Pod from the request must not be cached for the Pod being scheduled
+		pod.UID = ""
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if code, err := s.authorizeExplain(r, pod.Namespace); err != nil {
+		s.log.Warning(fmt.Sprintf("[explain] the request for Pod %s/%s is denied: %s", pod.Namespace, pod.Name, err))
+		http.Error(w, fmt.Sprintf("[explain] %s", err), code)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		// the cache of the client only has the Pods of the node plugin
+		if err := s.apiReader.Get(s.ctx, client.ObjectKeyFromObject(pod), pod); err != nil {
+			code := http.StatusInternalServerError
+			if k8serrors.IsNotFound(err) {
+				code = http.StatusNotFound
+			}
+			http.Error(w, fmt.Sprintf("[explain] unable to get Pod %s/%s: %s", pod.Namespace, pod.Name, err), code)
+			return
+		}
+	}
+
+	nodeNames, err := s.explainNodeNames(r.URL.Query().Get("nodes"))
+	if err != nil {
+		s.log.Error(err, "[explain] unable to get the nodes")
+		http.Error(w, fmt.Sprintf("[explain] unable to get the nodes: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	volumes, err := s.getPodVolumes(pod, consts.CSINFSProvisioner)
+	if err != nil {
+		s.log.Error(err, "[explain] unable to get the volumes of the Pod")
+		http.Error(w, fmt.Sprintf("[explain] unable to get the volumes of the Pod: %s", err), http.StatusBadRequest)
+		return
+	}
+	var filterResult *ExtenderFilterResult
+	if volumes.shouldProcess {
+		filterResult, err = s.filterNodes(&nodeNames, volumes.nfsStorageClasses)
+		if err != nil {
+			s.log.Error(err, "[explain] unable to filter the nodes")
+			http.Error(w, fmt.Sprintf("[explain] internal error: %s", err), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	result, err := s.explainPod(pod, nodeNames, volumes, filterResult)
+	if err != nil {
+		s.log.Error(err, "[explain] unable to explain the placement of the Pod")
+		http.Error(w, fmt.Sprintf("[explain] internal error: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("content-type", "application/json")
+	if err = json.NewEncoder(w).Encode(result); err != nil {
+		s.log.Error(err, "[explain] unable to encode a response")
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	s.log.Debug(fmt.Sprintf("[explain] ends the serving the request for a Pod %s/%s", pod.Namespace, pod.Name))
+}
+
+// authorizeExplain checks that the bearer token of the request belongs to a
+// user allowed to get the Pods of the namespace, and returns the HTTP status
+// code of the failed check.
+func (s *scheduler) authorizeExplain(r *http.Request, namespace string) (int, error) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return http.StatusUnauthorized, errors.New("no bearer token in the request")
+	}
+
+	tokenReview := &authenticationv1.TokenReview{Spec: authenticationv1.TokenReviewSpec{Token: token}}
+	if err := s.client.Create(s.ctx, tokenReview); err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("unable to review the token: %w", err)
+	}
+	if !tokenReview.Status.Authenticated {
+		return http.StatusUnauthorized, fmt.Errorf("the token is not authenticated: %s", tokenReview.Status.Error)
+	}
+
+	user := tokenReview.Status.User
+	extra := make(map[string]authorizationv1.ExtraValue, len(user.Extra))
+	for key, value := range user.Extra {
+		extra[key] = authorizationv1.ExtraValue(value)
+	}
+	accessReview := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: namespace,
+				Verb:      "get",
+				Resource:  "pods",
+			},
+			User:   user.Username,
+			Groups: user.Groups,
+			UID:    user.UID,
+			Extra:  extra,
+		},
+	}
+	if err := s.client.Create(s.ctx, accessReview); err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("unable to review the access: %w", err)
+	}
+	if !accessReview.Status.Allowed {
+		return http.StatusForbidden, fmt.Errorf("user %s is not allowed to get pods in namespace %s", user.Username, namespace)
+	}
+	return http.StatusOK, nil
+}
+
+// explainNodeNames returns the comma-separated node names, or the names of
+// all the nodes if there are none.
+func (s *scheduler) explainNodeNames(nodes string) ([]string, error) {
+	if nodes != "" {
+		return strings.Split(nodes, ","), nil
+	}
+
+	nodeList := &corev1.NodeList{}
+	if err := s.client.List(s.ctx, nodeList); err != nil {
+		return nil, err
+	}
+	nodeNames := make([]string, 0, len(nodeList.Items))
+	for _, node := range nodeList.Items {
+		nodeNames = append(nodeNames, node.Name)
+	}
+	slices.Sort(nodeNames)
+	return nodeNames, nil
+}
+
+// explainPod returns the NFS volumes of the Pod, their NFSStorageClasses and
+// for every node the result of the filter and whether the node matches the
+// node selector of each NFSStorageClass.
+func (s *scheduler) explainPod(pod *corev1.Pod, nodeNames []string, volumes *podVolumes, filterResult *ExtenderFilterResult) (*ExplainResult, error) {
+	result := &ExplainResult{
+		Pod:     fmt.Sprintf("%s/%s", pod.Namespace, pod.Name),
+		Volumes: []ExplainVolume{},
+		Nodes:   make([]ExplainNode, 0, len(nodeNames)),
+	}
+
+	selectors := make([]labels.Selector, 0, len(volumes.volumes))
+	if volumes.shouldProcess {
+		// the NFSStorageClasses are resolved in the order of the volumes
+		for i, volume := range volumes.volumes {
+			nsc := volumes.nfsStorageClasses.Items[i]
+			key, selector, err := nodeSelectorKey(nodeselector.ForNFSStorageClass(&nsc))
+			if err != nil {
+				return nil, fmt.Errorf("invalid node selector of NFSStorageClass %s: %w", nsc.Name, err)
+			}
+			selectors = append(selectors, selector)
+			result.Volumes = append(result.Volumes, ExplainVolume{
+				Name:            volume.Name,
+				Source:          volumeSource(volume),
+				NFSStorageClass: nsc.Name,
+				NodeSelector:    key,
+			})
+		}
+	}
+
+	for _, nodeName := range nodeNames {
+		explainNode := ExplainNode{Name: nodeName, Suitable: true}
+		if filterResult != nil {
+			explainNode.Suitable = slices.Contains(*filterResult.NodeNames, nodeName)
+			explainNode.Reason = filterResult.FailedNodes[nodeName]
+		}
+
+		if len(selectors) > 0 {
+			node := &corev1.Node{}
+			err := s.client.Get(s.ctx, client.ObjectKey{Name: nodeName}, node)
+			if err != nil && !k8serrors.IsNotFound(err) {
+				return nil, fmt.Errorf("error getting node %s: %w", nodeName, err)
+			}
+			for i, selector := range selectors {
+				explainNode.Selectors = append(explainNode.Selectors, ExplainSelector{
+					NFSStorageClass: result.Volumes[i].NFSStorageClass,
+					NodeSelector:    result.Volumes[i].NodeSelector,
+					Matched:         err == nil && selector.Matches(labels.Set(node.Labels)),
+				})
+			}
+		}
+		result.Nodes = append(result.Nodes, explainNode)
+	}
+	return result, nil
+}
+
+func volumeSource(volume corev1.Volume) string {
+	switch {
+	case volume.PersistentVolumeClaim != nil:
+		return "persistentVolumeClaim"
+	case volume.Ephemeral != nil:
+		return "ephemeral"
+	case volume.CSI != nil:
+		return "csi"
+	}
+	return ""
+}
+
+// explainSummary returns the NFSStorageClasses and the node selectors of the
+// volumes with the number of the nodes matching them, and the number of the
+// nodes filtered out for each reason.
+func explainSummary(result *ExplainResult) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "all %d nodes are filtered out for the NFS volumes of the pod:", len(result.Nodes))
+	for i, volume := range result.Volumes {
+		matched := 0
+		for _, node := range result.Nodes {
+			if node.Selectors[i].Matched {
+				matched++
+			}
+		}
+		fmt.Fprintf(&b, " volume %s: NFSStorageClass %s with nodeSelector %q matches %d nodes;", volume.Name, volume.NFSStorageClass, volume.NodeSelector, matched)
+	}
+
+	reasons := map[string]int{}
+	for _, node := range result.Nodes {
+		if !node.Suitable {
+			reasons[node.Reason]++
+		}
+	}
+	sortedReasons := make([]string, 0, len(reasons))
+	for reason := range reasons {
+		sortedReasons = append(sortedReasons, reason)
+	}
+	slices.Sort(sortedReasons)
+	for _, reason := range sortedReasons {
+		fmt.Fprintf(&b, " %d nodes: %s;", reasons[reason], reason)
+	}
+
+	summary := strings.TrimSuffix(b.String(), ";")
+	if len(summary) > maxEventMessageLength {
+		summary = summary[:maxEventMessageLength-3] + "..."
+	}
+	return summary
+}
+
+// recordFilteredOut writes the summary of the placement decision as an event
+// on the Pod when all the nodes are filtered out for its NFS volumes.
+func (s *scheduler) recordFilteredOut(pod *corev1.Pod, nodeNames []string, volumes *podVolumes, filterResult *ExtenderFilterResult) {
+	if s.recorder == nil {
+		return
+	}
+	result, err := s.explainPod(pod, nodeNames, volumes, filterResult)
+	if err != nil {
+		s.log.Error(err, fmt.Sprintf("[recordFilteredOut] unable to explain the placement of the Pod %s/%s", pod.Namespace, pod.Name))
+		return
+	}
+	s.recorder.Event(pod, corev1.EventTypeWarning, filteredOutEventReason, explainSummary(result))
+}
@@ -0,0 +1,240 @@
+/*
+Copyright 2026 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	v1alpha1 "github.com/deckhouse/csi-nfs/api/v1alpha1"
+	"github.com/deckhouse/csi-nfs/images/csi-nfs-scheduler-extender/pkg/consts"
+	"github.com/deckhouse/csi-nfs/images/csi-nfs-scheduler-extender/pkg/logger"
+)
+
+// newExplainClient returns a client with a Pod with an NFS volume whose
+// NFSStorageClass selects the nodes of project a. The tokens are the names of
+// the users and only alice may get the Pods.
+func newExplainClient(t *testing.T) client.Client {
+	storageClassName := "nfs"
+	scheme := runtime.NewScheme()
+	assert.NoError(t, clientgoscheme.AddToScheme(scheme))
+	assert.NoError(t, v1alpha1.AddToScheme(scheme))
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		labeledNode("node-a", map[string]string{"project": "a"}),
+		labeledNode("node-b", map[string]string{"project": "b"}),
+		&v1alpha1.NFSStorageClass{
+			ObjectMeta: metav1.ObjectMeta{Name: storageClassName},
+			Spec: v1alpha1.NFSStorageClassSpec{WorkloadNodes: &v1alpha1.NFSStorageClassWorkloadNodes{
+				NodeSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"project": "a"}},
+			}},
+		},
+		&corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "pvc1",
+				Namespace:   "default",
+				Annotations: map[string]string{annotationStorageProvisioner: consts.CSINFSProvisioner},
+			},
+			Spec: corev1.PersistentVolumeClaimSpec{StorageClassName: &storageClassName},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "default", UID: "uid-1"},
+			Spec: corev1.PodSpec{Volumes: []corev1.Volume{{
+				Name:         "data",
+				VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "pvc1"}},
+			}}},
+		},
+	).WithInterceptorFuncs(interceptor.Funcs{
+		Create: func(ctx context.Context, cl client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			switch review := obj.(type) {
+			case *authenticationv1.TokenReview:
+				review.Status.Authenticated = review.Spec.Token != "invalid"
+				review.Status.User.Username = review.Spec.Token
+				return nil
+			case *authorizationv1.SubjectAccessReview:
+				review.Status.Allowed = review.Spec.User == "alice" && review.Spec.ResourceAttributes.Namespace == "default"
+				return nil
+			}
+			return cl.Create(ctx, obj, opts...)
+		},
+	}).Build()
+}
+
+func TestExplain(t *testing.T) {
+	handler, err := NewHandler(context.Background(), newExplainClient(t), logger.Logger{}, Options{DefaultDivisor: 1, ScoreWeights: DefaultScoreWeights})
+	assert.NoError(t, err)
+
+	for _, tc := range []struct {
+		name         string
+		token        string
+		target       string
+		expectedCode int
+	}{
+		{name: "no token", target: "/scheduler/explain?namespace=default&name=pod1", expectedCode: http.StatusUnauthorized},
+		{name: "invalid token", token: "invalid", target: "/scheduler/explain?namespace=default&name=pod1", expectedCode: http.StatusUnauthorized},
+		{name: "forbidden user", token: "bob", target: "/scheduler/explain?namespace=default&name=pod1", expectedCode: http.StatusForbidden},
+		{name: "no pod", token: "alice", target: "/scheduler/explain?namespace=default&name=pod2", expectedCode: http.StatusNotFound},
+		{name: "no pod name", token: "alice", target: "/scheduler/explain?namespace=default", expectedCode: http.StatusBadRequest},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.target, nil)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			assert.Equal(t, tc.expectedCode, rr.Code, rr.Body.String())
+		})
+	}
+
+	t.Run("explain pod", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/scheduler/explain?namespace=default&name=pod1", nil)
+		req.Header.Set("Authorization", "Bearer alice")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+		var result ExplainResult
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &result))
+		assert.Equal(t, ExplainResult{
+			Pod:     "default/pod1",
+			Volumes: []ExplainVolume{{Name: "data", Source: "persistentVolumeClaim", NFSStorageClass: "nfs", NodeSelector: "project=a"}},
+			Nodes: []ExplainNode{
+				{Name: "node-a", Suitable: true, Selectors: []ExplainSelector{{NFSStorageClass: "nfs", NodeSelector: "project=a", Matched: true}}},
+				{Name: "node-b", Reason: "node is not selected by user selectors", Selectors: []ExplainSelector{{NFSStorageClass: "nfs", NodeSelector: "project=a"}}},
+			},
+		}, result)
+	})
+
+	t.Run("explain pod from the request", func(t *testing.T) {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "pod3", Namespace: "default"},
+			Spec: corev1.PodSpec{Volumes: []corev1.Volume{{
+				Name:         "config",
+				VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+			}}},
+		}
+		body, err := json.Marshal(pod)
+		assert.NoError(t, err)
+		req := httptest.NewRequest(http.MethodPost, "/scheduler/explain?nodes=node-b", bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer alice")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+		var result ExplainResult
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &result))
+		assert.Equal(t, ExplainResult{
+			Pod:     "default/pod3",
+			Volumes: []ExplainVolume{},
+			Nodes:   []ExplainNode{{Name: "node-b", Suitable: true}},
+		}, result)
+	})
+}
+
+// newRestrictedPodCache returns a client reading the Pods like the cache of
+// the manager in cmd/main.go: only the Pods of the node plugin in the namespace
+// of the module are cached.
+func newRestrictedPodCache(cl client.WithWatch, namespace string) client.Client {
+	return interceptor.NewClient(cl, interceptor.Funcs{
+		Get: func(ctx context.Context, cl client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+			if _, ok := obj.(*corev1.Pod); !ok {
+				return cl.Get(ctx, key, obj, opts...)
+			}
+			if key.Namespace != namespace {
+				return fmt.Errorf("unable to get: %v because of unknown namespace for the cache", key)
+			}
+			if err := cl.Get(ctx, key, obj, opts...); err != nil {
+				return err
+			}
+			if obj.GetLabels()["app"] != consts.CSINodeAppLabelValue {
+				return k8serrors.NewNotFound(corev1.Resource("pods"), key.Name)
+			}
+			return nil
+		},
+	})
+}
+
+func TestExplainReadsPodsFromAPI(t *testing.T) {
+	apiReader := newExplainClient(t)
+	cl := newRestrictedPodCache(apiReader.(client.WithWatch), "d8-csi-nfs")
+	request := func(handler http.Handler) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/scheduler/explain?namespace=default&name=pod1", nil)
+		req.Header.Set("Authorization", "Bearer alice")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("cache only", func(t *testing.T) {
+		handler, err := NewHandler(context.Background(), cl, logger.Logger{}, Options{DefaultDivisor: 1, ScoreWeights: DefaultScoreWeights})
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusInternalServerError, request(handler).Code)
+	})
+
+	t.Run("API reader", func(t *testing.T) {
+		handler, err := NewHandler(context.Background(), cl, logger.Logger{}, Options{DefaultDivisor: 1, ScoreWeights: DefaultScoreWeights, APIReader: apiReader})
+		assert.NoError(t, err)
+		rr := request(handler)
+		assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+		var result ExplainResult
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &result))
+		assert.Equal(t, "default/pod1", result.Pod)
+		assert.Len(t, result.Volumes, 1)
+	})
+}
+
+func TestFilterRecordsFilteredOut(t *testing.T) {
+	cl := newExplainClient(t)
+	recorder := record.NewFakeRecorder(10)
+	handler, err := NewHandler(context.Background(), cl, logger.Logger{}, Options{DefaultDivisor: 1, ScoreWeights: DefaultScoreWeights, EventRecorder: recorder})
+	assert.NoError(t, err)
+
+	pod := &corev1.Pod{}
+	assert.NoError(t, cl.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: "pod1"}, pod))
+	filter := func(nodeNames []string) {
+		body, err := json.Marshal(ExtenderArgs{Pod: pod, NodeNames: &nodeNames})
+		assert.NoError(t, err)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/scheduler/filter", bytes.NewReader(body)))
+		assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	filter([]string{"node-a", "node-b"})
+	assert.Empty(t, recorder.Events)
+
+	filter([]string{"node-b", "node-c"})
+	assert.Equal(t, `Warning NFSNodesFilteredOut all 2 nodes are filtered out for the NFS volumes of the pod: volume data: NFSStorageClass nfs with nodeSelector "project=a" matches 0 nodes; 2 nodes: node is not selected by user selectors`, <-recorder.Events)
+}
@@ -17,18 +17,14 @@ limitations under the License.
 package scheduler
 
 import (
-	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"slices"
 
-	corev1 "k8s.io/api/core/v1"
-	"sigs.k8s.io/controller-runtime/pkg/client"
-
+	v1alpha1 "github.com/deckhouse/csi-nfs/api/v1alpha1"
 	"github.com/deckhouse/csi-nfs/images/csi-nfs-scheduler-extender/pkg/consts"
-	"github.com/deckhouse/csi-nfs/images/csi-nfs-scheduler-extender/pkg/logger"
 )
 
 func (s *scheduler) Filter(w http.ResponseWriter, r *http.Request) {
@@ -61,13 +57,15 @@ func (s *scheduler) Filter(w http.ResponseWriter, r *http.Request) {
 	s.log.Trace(fmt.Sprintf("[filter] Node names from the request: %+v", nodeNames))
 
 	s.log.Debug(fmt.Sprintf("[filter] Find out if the Pod %s/%s should be processed", inputData.Pod.Namespace, inputData.Pod.Name))
-	shouldProcess, targetProvisionerVolumes, err := shouldProcessPod(s.ctx, s.client, s.log, inputData.Pod, consts.CSINFSProvisioner)
+	volumes, err := s.getPodVolumes(inputData.Pod, consts.CSINFSProvisioner)
 	if err != nil {
+		lookupErrors.WithLabelValues(verbFilter, lookupPodVolumes).Inc()
 		s.log.Error(err, "[filter] unable to check if the Pod should be processed")
 		http.Error(w, fmt.Sprintf("[filter] unable to check if the Pod should be processed: %s", err), http.StatusBadRequest)
 		return
 	}
-	if !shouldProcess {
+	podsTotal.WithLabelValues(verbFilter, podResult(volumes.shouldProcess)).Inc()
+	if !volumes.shouldProcess {
 		s.log.Debug(fmt.Sprintf("[filter] Pod %s/%s should not be processed. Return the same nodes", inputData.Pod.Namespace, inputData.Pod.Name))
 		filteredNodes := &ExtenderFilterResult{
 			NodeNames: &nodeNames,
@@ -86,13 +84,25 @@ func (s *scheduler) Filter(w http.ResponseWriter, r *http.Request) {
 	s.log.Debug(fmt.Sprintf("[filter] Pod %s/%s should be processed", inputData.Pod.Namespace, inputData.Pod.Name))
 
 	s.log.Debug(fmt.Sprintf("[filter] starts to filter the nodes from the request for a Pod %s/%s", inputData.Pod.Namespace, inputData.Pod.Name))
-	filteredNodes, err := filterNodes(s.ctx, s.client, s.log, &nodeNames, inputData.Pod.Namespace, targetProvisionerVolumes)
+	filteredNodes, err := s.filterNodes(&nodeNames, volumes.nfsStorageClasses)
 	if err != nil {
+		lookupErrors.WithLabelValues(verbFilter, lookupNodes).Inc()
 		s.log.Error(err, "[filter] unable to filter the nodes")
 		http.Error(w, fmt.Sprintf("[filter] internal error: %s", err), http.StatusInternalServerError)
 		return
 	}
 	s.log.Debug(fmt.Sprintf("[filter] successfully filtered the nodes from the request for a Pod %s/%s", inputData.Pod.Namespace, inputData.Pod.Name))
+	filterNodes.WithLabelValues(filterNodesInput).Observe(float64(len(nodeNames)))
+	filterNodes.WithLabelValues(filterNodesSuitable).Observe(float64(len(*filteredNodes.NodeNames)))
+	filterNodes.WithLabelValues(filterNodesFilteredOut).Observe(float64(len(filteredNodes.FailedNodes)))
+	s.log.Info("[filter] filtered the nodes for the NFS volumes of the Pod",
+		"pod", inputData.Pod.Namespace+"/"+inputData.Pod.Name,
+		"nodes", len(nodeNames),
+		"suitable", len(*filteredNodes.NodeNames),
+		"filteredOut", filteredOutNodesByReason(filteredNodes.FailedNodes))
+	if len(*filteredNodes.NodeNames) == 0 && len(nodeNames) > 0 {
+		s.recordFilteredOut(inputData.Pod, nodeNames, volumes, filteredNodes)
+	}
 
 	w.Header().Set("content-type", "application/json")
 	err = json.NewEncoder(w).Encode(filteredNodes)
@@ -104,14 +114,24 @@ func (s *scheduler) Filter(w http.ResponseWriter, r *http.Request) {
 	s.log.Debug(fmt.Sprintf("[filter] ends the serving the request for a Pod %s/%s", inputData.Pod.Namespace, inputData.Pod.Name))
 }
 
-func filterNodes(
-	ctx context.Context,
-	cl client.Client,
-	log logger.Logger,
+// filteredOutNodesByReason groups the sorted names of the filtered out nodes
+// by the reason, so the decision log stays short for the large clusters.
+func filteredOutNodesByReason(failedNodes FailedNodesMap) map[string][]string {
+	nodesByReason := make(map[string][]string)
+	for nodeName, reason := range failedNodes {
+		nodesByReason[reason] = append(nodesByReason[reason], nodeName)
+	}
+	for _, nodeNames := range nodesByReason {
+		slices.Sort(nodeNames)
+	}
+	return nodesByReason
+}
+
+func (s *scheduler) filterNodes(
 	nodeNames *[]string,
-	namespace string,
-	targetProvisionerVolumes []corev1.Volume,
+	nfsStorageClasses *v1alpha1.NFSStorageClassList,
 ) (*ExtenderFilterResult, error) {
+	log := s.log
 	if len(*nodeNames) == 0 {
 		log.Warning("[filterNodes] No nodes to filter. Return empty node list")
 		return &ExtenderFilterResult{
@@ -122,16 +142,14 @@ func filterNodes(
 
 	log.Debug("[filterNodes] Get user selectors")
 
-	nfsStorageClasses, err := GetNFSStorageClassesFromVolumes(ctx, cl, log, namespace, targetProvisionerVolumes)
+	userNodeSelectorList, err := GetNodeSelectorFromNFSStorageClasses(log, nfsStorageClasses)
 	if err != nil {
-		log.Error(err, "[filterNodes] Failed to get NFSStorageClasses from volumes")
+		log.Error(err, "[filterNodes] Failed get user selectors")
 		return nil, err
 	}
-
-	userNodeSelectorList := GetNodeSelectorFromNFSStorageClasses(log, nfsStorageClasses)
 	log.Trace(fmt.Sprintf("[filterNodes] user selector list: %+v", userNodeSelectorList))
 
-	commonNodeNames, err := GetCommonNodesByNodeSelectorList(ctx, cl, log, userNodeSelectorList)
+	commonNodeNames, err := s.commonNodeNames(userNodeSelectorList)
 	if err != nil {
 		log.Error(err, fmt.Sprintf("[filterNodes] Failed get common node names by user selectors: %+v", userNodeSelectorList))
 		return nil, err
@@ -151,6 +169,14 @@ func filterNodes(
 		}
 	}
 
+	if s.csiNodeNamespace != "" {
+		log.Debug("[filterNodes] Check the NFS capabilities of the nodes")
+		if err := s.filterNodesByCapability(result, nfsStorageClasses); err != nil {
+			log.Error(err, "[filterNodes] Failed to check the NFS capabilities of the nodes")
+			return nil, err
+		}
+	}
+
 	log.Trace(fmt.Sprintf("[filterNodes] suitable nodes: %+v", *result.NodeNames))
 	log.Trace(fmt.Sprintf("[filterNodes] failed nodes: %+v", result.FailedNodes))
 
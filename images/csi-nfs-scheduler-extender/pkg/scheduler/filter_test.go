@@ -181,6 +181,170 @@ var _ = Describe("Filter tests", func() {
 			checkFilter(ctx, cl, log, podWithoutVolumes, nodeNames, []string{"matching-sc1-node-0", "matching-sc1-node-1", "matching-sc2-node-0", "matching-sc2-node-1", "matching-sc1-and-sc2-node-0", "matching-sc1-and-sc2-node-1", "matching-sc3-node-0", "non-matching-node-0", "non-matching-node-1"}, []string{})
 		})
 
+		It("Scenario 4: NFSStorageClasses with NFSv3 and TLS; only nodes with the running node plugin able to mount them should be suitable", func() {
+			nfsSCConfig.Name = "test-nfs-sc-v3"
+			nfsSCConfig.NFSVersion = "3"
+			nscV3 := generateNFSStorageClass(nfsSCConfig)
+			Expect(cl.Create(ctx, nscV3)).To(Succeed())
+
+			nfsSCConfig.Name = "test-nfs-sc-tls"
+			nfsSCConfig.NFSVersion = "4.2"
+			nscTLS := generateNFSStorageClass(nfsSCConfig)
+			nscTLS.Spec.Connection.Tls = true
+			Expect(cl.Create(ctx, nscTLS)).To(Succeed())
+
+			nodeLabels := map[string]string{"kubernetes.io/os": "linux", nfsNodeSelectorKey: ""}
+			prepareNode(ctx, cl, "ready-node", nodeLabels)
+			prepareNode(ctx, cl, "unlabeled-node", map[string]string{"kubernetes.io/os": "linux"})
+			prepareNode(ctx, cl, "no-plugin-node", nodeLabels)
+			prepareNode(ctx, cl, "no-rpcbind-node", nodeLabels)
+			prepareNode(ctx, cl, "no-tlshd-node", nodeLabels)
+			prepareNode(ctx, cl, "not-ready-node", nodeLabels)
+			nodeNames := []string{"ready-node", "unlabeled-node", "no-plugin-node", "no-rpcbind-node", "no-tlshd-node", "not-ready-node"}
+
+			prepareCSINodePod(ctx, cl, controllerNamespace, "ready-node", 0, true, true)
+			prepareCSINodePod(ctx, cl, controllerNamespace, "unlabeled-node", 0, true, true)
+			prepareCSINodePod(ctx, cl, controllerNamespace, "no-rpcbind-node", 1, true, false)
+			prepareCSINodePod(ctx, cl, controllerNamespace, "no-tlshd-node", 0, false, false)
+			prepareCSINodePod(ctx, cl, controllerNamespace, "not-ready-node", 0, true, false)
+
+			preparePVC(ctx, cl, testNamespace, "pvc-v3", nscV3.Name, provisionerNFS)
+			preparePVC(ctx, cl, testNamespace, "pvc-tls", nscTLS.Name, provisionerNFS)
+			podWithV3 := preparePodWithVolumes(ctx, cl, testNamespace, "pod-with-nfs-v3-volumes", []string{"pvc-v3"})
+			podWithTLS := preparePodWithVolumes(ctx, cl, testNamespace, "pod-with-nfs-tls-volumes", []string{"pvc-tls"})
+
+			opts := scheduler.Options{DefaultDivisor: 1, ScoreWeights: scheduler.DefaultScoreWeights, CSINodeNamespace: controllerNamespace}
+			commonFailed := scheduler.FailedNodesMap{
+				"unlabeled-node": "node does not have the storage.deckhouse.io/csi-nfs-node label",
+				"no-plugin-node": "csi-nfs node plugin is not running on the node",
+				"not-ready-node": "csi-nfs node plugin is not ready on the node",
+			}
+
+			result, ok := runFilter(ctx, cl, log, opts, podWithV3, nodeNames)
+			Expect(ok).To(BeTrue())
+			Expect(*result.NodeNames).To(ConsistOf("ready-node"))
+			expectedFailed := scheduler.FailedNodesMap{
+				"no-rpcbind-node": "NFSStorageClass test-nfs-sc-v3 uses NFSv3, but rpcbind is not available on the node: init container wait-rpcbind failed with exit code 1",
+				"no-tlshd-node":   "csi-nfs node plugin is not ready on the node",
+			}
+			for nodeName, reason := range commonFailed {
+				expectedFailed[nodeName] = reason
+			}
+			Expect(result.FailedNodes).To(Equal(expectedFailed))
+
+			result, ok = runFilter(ctx, cl, log, opts, podWithTLS, nodeNames)
+			Expect(ok).To(BeTrue())
+			Expect(*result.NodeNames).To(ConsistOf("ready-node"))
+			expectedFailed = scheduler.FailedNodesMap{
+				"no-rpcbind-node": "csi-nfs node plugin is not ready on the node",
+				"no-tlshd-node":   "NFSStorageClass test-nfs-sc-tls uses TLS, but tlshd is not running on the node: container tlshd is not ready",
+			}
+			for nodeName, reason := range commonFailed {
+				expectedFailed[nodeName] = reason
+			}
+			Expect(result.FailedNodes).To(Equal(expectedFailed))
+		})
+
+		It("Scenario 5: Pods with generic ephemeral and inline CSI NFS volumes; only nodes matching the nodeSelector of their NFSStorageClass should be suitable", func() {
+			nfsSCConfig.nodeSelector = metav1.LabelSelector{
+				MatchLabels: map[string]string{"project": "test-1"},
+			}
+			nsc := generateNFSStorageClass(nfsSCConfig)
+			Expect(cl.Create(ctx, nsc)).To(Succeed())
+			Expect(cl.Create(ctx, &storagev1.StorageClass{
+				ObjectMeta:  metav1.ObjectMeta{Name: nfsSCConfig.Name},
+				Provisioner: provisionerNFS,
+			})).To(Succeed())
+
+			prepareNode(ctx, cl, "matching-node-0", map[string]string{"kubernetes.io/os": "linux", "project": "test-1"})
+			prepareNode(ctx, cl, "non-matching-node-0", map[string]string{"kubernetes.io/os": "linux", "project": "test-2"})
+			prepareNode(ctx, cl, "non-linux-node-0", nil)
+			nodeNames := []string{"matching-node-0", "non-matching-node-0", "non-linux-node-0"}
+
+			podWithEphemeral := generatePodWithVolumes(testNamespace, "pod-with-nfs-ephemeral-volumes", []corev1.Volume{{
+				Name: "test-vol-ephemeral",
+				VolumeSource: corev1.VolumeSource{
+					Ephemeral: &corev1.EphemeralVolumeSource{
+						VolumeClaimTemplate: &corev1.PersistentVolumeClaimTemplate{
+							Spec: corev1.PersistentVolumeClaimSpec{StorageClassName: &nfsSCConfig.Name},
+						},
+					},
+				},
+			}})
+			Expect(cl.Create(ctx, podWithEphemeral)).To(Succeed())
+			checkFilter(ctx, cl, log, podWithEphemeral, nodeNames, []string{"matching-node-0"}, []string{"non-matching-node-0", "non-linux-node-0"})
+
+			inlineVolume := func(server, share string) corev1.Volume {
+				return corev1.Volume{
+					Name: "test-vol-inline",
+					VolumeSource: corev1.VolumeSource{
+						CSI: &corev1.CSIVolumeSource{
+							Driver:           provisionerNFS,
+							VolumeAttributes: map[string]string{"server": server, "share": share},
+						},
+					},
+				}
+			}
+			// the inline volume of the export of the NFSStorageClass follows its nodeSelector
+			podWithInline := generatePodWithVolumes(testNamespace, "pod-with-nfs-inline-volumes", []corev1.Volume{inlineVolume(nfsSCConfig.Host, nfsSCConfig.Share+"/")})
+			Expect(cl.Create(ctx, podWithInline)).To(Succeed())
+			checkFilter(ctx, cl, log, podWithInline, nodeNames, []string{"matching-node-0"}, []string{"non-matching-node-0", "non-linux-node-0"})
+
+			// the inline volume of another export is skipped, so the pod may be placed on any Linux node
+			podWithOtherInline := generatePodWithVolumes(testNamespace, "pod-with-other-nfs-inline-volumes", []corev1.Volume{inlineVolume("other-server", "/share")})
+			Expect(cl.Create(ctx, podWithOtherInline)).To(Succeed())
+			checkFilter(ctx, cl, log, podWithOtherInline, nodeNames, []string{"matching-node-0", "non-matching-node-0"}, []string{"non-linux-node-0"})
+		})
+
+		It("Scenario 6: NFSStorageClasses with matchExpressions and without nodeSelector; pods should be scheduled on nodes matching all selectors at once for all their PVCs", func() {
+			nfsSCConfig.Name = "test-nfs-sc-zones"
+			nfsSCConfig.nodeSelector = metav1.LabelSelector{
+				MatchExpressions: []metav1.LabelSelectorRequirement{
+					{Key: "zone", Operator: metav1.LabelSelectorOpIn, Values: []string{"a", "b"}},
+				},
+			}
+			nscZones := generateNFSStorageClass(nfsSCConfig)
+			Expect(cl.Create(ctx, nscZones)).To(Succeed())
+
+			nfsSCConfig.Name = "test-nfs-sc-ssd"
+			nfsSCConfig.nodeSelector = metav1.LabelSelector{
+				MatchLabels: map[string]string{"disk": "ssd"},
+				MatchExpressions: []metav1.LabelSelectorRequirement{
+					{Key: "dedicated", Operator: metav1.LabelSelectorOpDoesNotExist},
+				},
+			}
+			nscSSD := generateNFSStorageClass(nfsSCConfig)
+			Expect(cl.Create(ctx, nscSSD)).To(Succeed())
+
+			nfsSCConfig.Name = "test-nfs-sc-default"
+			nfsSCConfig.nodeSelector = metav1.LabelSelector{}
+			nscDefault := generateNFSStorageClass(nfsSCConfig)
+			Expect(cl.Create(ctx, nscDefault)).To(Succeed())
+
+			prepareNode(ctx, cl, "linux-zone-a-ssd", map[string]string{"kubernetes.io/os": "linux", "zone": "a", "disk": "ssd"})
+			prepareNode(ctx, cl, "linux-zone-b-hdd", map[string]string{"kubernetes.io/os": "linux", "zone": "b", "disk": "hdd"})
+			prepareNode(ctx, cl, "linux-zone-c-ssd", map[string]string{"kubernetes.io/os": "linux", "zone": "c", "disk": "ssd"})
+			prepareNode(ctx, cl, "linux-zone-a-ssd-dedicated", map[string]string{"kubernetes.io/os": "linux", "zone": "a", "disk": "ssd", "dedicated": "db"})
+			prepareNode(ctx, cl, "non-linux-zone-b-ssd", map[string]string{"zone": "b", "disk": "ssd"})
+			nodeNames := []string{"linux-zone-a-ssd", "linux-zone-b-hdd", "linux-zone-c-ssd", "linux-zone-a-ssd-dedicated", "non-linux-zone-b-ssd"}
+
+			preparePVC(ctx, cl, testNamespace, "pvc-zones", nscZones.Name, provisionerNFS)
+			preparePVC(ctx, cl, testNamespace, "pvc-ssd", nscSSD.Name, provisionerNFS)
+			preparePVC(ctx, cl, testNamespace, "pvc-default", nscDefault.Name, provisionerNFS)
+
+			podWithNFSZones := preparePodWithVolumes(ctx, cl, testNamespace, "pod-with-nfs-volumes-zones", []string{"pvc-zones"})
+			podWithNFSSSD := preparePodWithVolumes(ctx, cl, testNamespace, "pod-with-nfs-volumes-ssd", []string{"pvc-ssd"})
+			podWithNFSZonesAndSSD := preparePodWithVolumes(ctx, cl, testNamespace, "pod-with-nfs-volumes-zones-and-ssd", []string{"pvc-zones", "pvc-ssd"})
+			podWithNFSZonesAndDefault := preparePodWithVolumes(ctx, cl, testNamespace, "pod-with-nfs-volumes-zones-and-default", []string{"pvc-zones", "pvc-default"})
+			podWithNFSAll := preparePodWithVolumes(ctx, cl, testNamespace, "pod-with-nfs-volumes-all", []string{"pvc-zones", "pvc-ssd", "pvc-default"})
+
+			checkFilter(ctx, cl, log, podWithNFSZones, nodeNames, []string{"linux-zone-a-ssd", "linux-zone-b-hdd", "linux-zone-a-ssd-dedicated", "non-linux-zone-b-ssd"}, []string{"linux-zone-c-ssd"})
+			checkFilter(ctx, cl, log, podWithNFSSSD, nodeNames, []string{"linux-zone-a-ssd", "linux-zone-c-ssd", "non-linux-zone-b-ssd"}, []string{"linux-zone-b-hdd", "linux-zone-a-ssd-dedicated"})
+			checkFilter(ctx, cl, log, podWithNFSZonesAndSSD, nodeNames, []string{"linux-zone-a-ssd", "non-linux-zone-b-ssd"}, []string{"linux-zone-b-hdd", "linux-zone-c-ssd", "linux-zone-a-ssd-dedicated"})
+			// the NFSStorageClass without nodeSelector restricts the pod to the Linux nodes and keeps the other selectors
+			checkFilter(ctx, cl, log, podWithNFSZonesAndDefault, nodeNames, []string{"linux-zone-a-ssd", "linux-zone-b-hdd", "linux-zone-a-ssd-dedicated"}, []string{"linux-zone-c-ssd", "non-linux-zone-b-ssd"})
+			checkFilter(ctx, cl, log, podWithNFSAll, nodeNames, []string{"linux-zone-a-ssd"}, []string{"linux-zone-b-hdd", "linux-zone-c-ssd", "linux-zone-a-ssd-dedicated", "non-linux-zone-b-ssd"})
+		})
 	})
 })
 
@@ -210,6 +374,39 @@ func prepareNode(ctx context.Context, cl client.Client, name string, labels map[
 	}
 }
 
+// prepareCSINodePod creates the csi-nfs-node Pod of the node with the exit
+// code of the wait-rpcbind init container and the readiness of tlshd.
+func prepareCSINodePod(ctx context.Context, cl client.Client, namespace, nodeName string, rpcbindExitCode int32, tlshdReady, ready bool) {
+	completed := func(name string, exitCode int32) corev1.ContainerStatus {
+		return corev1.ContainerStatus{Name: name, State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: exitCode}}}
+	}
+	podReady := corev1.ConditionFalse
+	if ready {
+		podReady = corev1.ConditionTrue
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "csi-node-" + nodeName,
+			Namespace: namespace,
+			Labels:    map[string]string{"app": consts.CSINodeAppLabelValue},
+		},
+		Spec: corev1.PodSpec{NodeName: nodeName},
+		Status: corev1.PodStatus{
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: podReady}},
+			InitContainerStatuses: []corev1.ContainerStatus{
+				completed("wait-rpcbind", rpcbindExitCode),
+				completed("ktls-enabler", 0),
+				completed("net-handshake-checker", 0),
+			},
+			ContainerStatuses: []corev1.ContainerStatus{
+				{Name: "node", Ready: ready},
+				{Name: "tlshd", Ready: tlshdReady},
+			},
+		},
+	}
+	Expect(cl.Create(ctx, pod)).To(Succeed())
+}
+
 func generatePodWithVolumes(namespace, name string, volumes []corev1.Volume) *corev1.Pod {
 	return &corev1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
@@ -321,7 +518,14 @@ func generateNFSStorageClass(cfg NFSStorageClassConfig) *v1alpha1.NFSStorageClas
 }
 
 func checkFilter(ctx context.Context, cl client.Client, log logger.Logger, pod *corev1.Pod, nodeNames []string, expectedSuitable, expectedFailed []string) {
-	schedulerExtender, err := scheduler.NewHandler(ctx, cl, log)
+	result, ok := runFilter(ctx, cl, log, scheduler.Options{DefaultDivisor: 1, ScoreWeights: scheduler.DefaultScoreWeights}, pod, nodeNames)
+	if ok {
+		checkResult(result, expectedSuitable, expectedFailed)
+	}
+}
+
+func runFilter(ctx context.Context, cl client.Client, log logger.Logger, opts scheduler.Options, pod *corev1.Pod, nodeNames []string) (scheduler.ExtenderFilterResult, bool) {
+	schedulerExtender, err := scheduler.NewHandler(ctx, cl, log, opts)
 	Expect(err).NotTo(HaveOccurred())
 
 	inputData := scheduler.ExtenderArgs{
@@ -339,13 +543,13 @@ func checkFilter(ctx context.Context, cl client.Client, log logger.Logger, pod *
 
 	if rr.Code != http.StatusOK {
 		Expect(rr.Body.String()).To(BeNil())
-		return
+		return scheduler.ExtenderFilterResult{}, false
 	}
 	Expect(rr.Code).To(Equal(http.StatusOK))
 	var result scheduler.ExtenderFilterResult
 	err = json.Unmarshal(rr.Body.Bytes(), &result)
 	Expect(err).NotTo(HaveOccurred())
-	checkResult(result, expectedSuitable, expectedFailed)
+	return result, true
 }
 
 func checkResult(result scheduler.ExtenderFilterResult, expectedSuitable, expectedFailed []string) {
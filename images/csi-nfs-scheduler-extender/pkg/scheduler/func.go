@@ -19,6 +19,7 @@ package scheduler
 import (
 	"context"
 	"fmt"
+	"path"
 
 	corev1 "k8s.io/api/core/v1"
 	storagev1 "k8s.io/api/storage/v1"
@@ -28,57 +29,36 @@ import (
 
 	v1alpha1 "github.com/deckhouse/csi-nfs/api/v1alpha1"
 	"github.com/deckhouse/csi-nfs/images/csi-nfs-scheduler-extender/pkg/logger"
+	"github.com/deckhouse/csi-nfs/lib/go/common/pkg/nodeselector"
 )
 
 const (
 	annotationBetaStorageProvisioner = "volume.beta.kubernetes.io/storage-provisioner"
 	annotationStorageProvisioner     = "volume.kubernetes.io/storage-provisioner"
+
+	annotationDefaultStorageClass     = "storageclass.kubernetes.io/is-default-class"
+	annotationBetaDefaultStorageClass = "storageclass.beta.kubernetes.io/is-default-class"
 )
 
 var (
-	DefaultNodeSelector = &metav1.LabelSelector{
-		MatchLabels: map[string]string{
-			"kubernetes.io/os": "linux",
-		},
-	}
+	DefaultNodeSelector = nodeselector.DefaultNodeSelector
 )
 
 func shouldProcessPod(ctx context.Context, cl client.Client, log logger.Logger, pod *corev1.Pod, targetProvisioner string) (bool, []corev1.Volume, error) {
 	log.Trace(fmt.Sprintf("[ShouldProcessPod] targetProvisioner=%s, pod: %+v", targetProvisioner, pod))
-	var discoveredProvisioner string
 	shouldProcessPod := false
 	targetProvisionerVolumes := make([]corev1.Volume, 0)
 
-	pvcs := &corev1.PersistentVolumeClaimList{}
-	err := cl.List(ctx, pvcs, client.InNamespace(pod.Namespace))
-	if err != nil {
-		return false, nil, fmt.Errorf("[ShouldProcessPod] error getting PVCs in namespace %s: %v", pod.Namespace, err)
-	}
-
-	pvcMap := make(map[string]*corev1.PersistentVolumeClaim, len(pvcs.Items))
-	for _, pvc := range pvcs.Items {
-		pvcMap[pvc.Name] = &pvc
-	}
-
 	for _, volume := range pod.Spec.Volumes {
-		if volume.PersistentVolumeClaim == nil {
-			log.Trace(fmt.Sprintf("[ShouldProcessPod] skip volume %s because it doesn't have PVC", volume.Name))
-			continue
+		discoveredProvisioner, err := getProvisionerFromVolume(ctx, cl, log, pod.Namespace, volume)
+		if err != nil {
+			return false, nil, fmt.Errorf("[ShouldProcessPod] error getting provisioner of volume %s: %w", volume.Name, err)
 		}
-
-		log.Trace(fmt.Sprintf("[ShouldProcessPod] process volume: %+v that has pvc: %+v", volume, volume.PersistentVolumeClaim))
-		pvcName := volume.PersistentVolumeClaim.ClaimName
-		pvc, found := pvcMap[pvcName]
-		if !found {
-			return false, nil, fmt.Errorf("[ShouldProcessPod] found no pvc %s in namespace %s", pvcName, pod.Namespace)
+		if discoveredProvisioner == "" {
+			log.Trace(fmt.Sprintf("[ShouldProcessPod] skip volume %s because it doesn't have PVC, ephemeral PVC template or CSI driver", volume.Name))
+			continue
 		}
 
-		log.Trace(fmt.Sprintf("[ShouldProcessPod] Successfully get PVC %s/%s: %+v", pod.Namespace, pvcName, pvc))
-
-		discoveredProvisioner, err = getProvisionerFromPVC(ctx, cl, log, pvc)
-		if err != nil {
-			return false, nil, fmt.Errorf("[ShouldProcessPod] error getting provisioner from PVC %s/%s: %v", pod.Namespace, pvcName, err)
-		}
 		log.Trace(fmt.Sprintf("[ShouldProcessPod] discovered provisioner: %s", discoveredProvisioner))
 		if discoveredProvisioner == targetProvisioner {
 			log.Trace(fmt.Sprintf("[ShouldProcessPod] provisioner matches targetProvisioner %s. Pod: %s/%s", pod.Namespace, pod.Name, targetProvisioner))
@@ -97,6 +77,93 @@ func shouldProcessPod(ctx context.Context, cl client.Client, log logger.Logger,
 	return false, nil, nil
 }
 
+// getProvisionerFromVolume returns the provisioner of the PVC, of the storage
+// class of the generic ephemeral volume or the driver of the inline CSI
+// volume, or an empty string for the other volumes.
+func getProvisionerFromVolume(ctx context.Context, cl client.Client, log logger.Logger, namespace string, volume corev1.Volume) (string, error) {
+	switch {
+	case volume.PersistentVolumeClaim != nil:
+		log.Trace(fmt.Sprintf("[getProvisionerFromVolume] process volume: %+v that has pvc: %+v", volume, volume.PersistentVolumeClaim))
+		pvcName := volume.PersistentVolumeClaim.ClaimName
+		pvc := &corev1.PersistentVolumeClaim{}
+		err := cl.Get(ctx, client.ObjectKey{Namespace: namespace, Name: pvcName}, pvc)
+		if err != nil {
+			if k8serrors.IsNotFound(err) {
+				return "", fmt.Errorf("found no pvc %s in namespace %s", pvcName, namespace)
+			}
+			return "", fmt.Errorf("error getting PVC %s/%s: %v", namespace, pvcName, err)
+		}
+		log.Trace(fmt.Sprintf("[getProvisionerFromVolume] Successfully get PVC %s/%s: %+v", namespace, pvcName, pvc))
+		return getProvisionerFromPVC(ctx, cl, log, pvc)
+
+	case volume.Ephemeral != nil && volume.Ephemeral.VolumeClaimTemplate != nil:
+		// the PVC of a generic ephemeral volume may not exist yet, its storage class is in the template
+		log.Trace(fmt.Sprintf("[getProvisionerFromVolume] process ephemeral volume: %+v", volume))
+		storageClass, err := getEphemeralVolumeStorageClass(ctx, cl, volume.Ephemeral)
+		if err != nil || storageClass == nil {
+			return "", err
+		}
+		return storageClass.Provisioner, nil
+
+	case volume.CSI != nil:
+		log.Trace(fmt.Sprintf("[getProvisionerFromVolume] process inline CSI volume: %+v", volume))
+		return volume.CSI.Driver, nil
+	}
+	return "", nil
+}
+
+// getEphemeralVolumeStorageClass returns the storage class of the PVC template
+// of the generic ephemeral volume, the default storage class if the template
+// has none, or nil if there is no such storage class.
+func getEphemeralVolumeStorageClass(ctx context.Context, cl client.Client, ephemeral *corev1.EphemeralVolumeSource) (*storagev1.StorageClass, error) {
+	storageClassName := ephemeral.VolumeClaimTemplate.Spec.StorageClassName
+	if storageClassName != nil {
+		if *storageClassName == "" {
+			return nil, nil
+		}
+		storageClass := &storagev1.StorageClass{}
+		err := cl.Get(ctx, client.ObjectKey{Name: *storageClassName}, storageClass)
+		if err != nil {
+			if k8serrors.IsNotFound(err) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("error getting StorageClass %s: %v", *storageClassName, err)
+		}
+		return storageClass, nil
+	}
+
+	storageClasses := &storagev1.StorageClassList{}
+	if err := cl.List(ctx, storageClasses); err != nil {
+		return nil, fmt.Errorf("error listing StorageClasses: %v", err)
+	}
+	for i := range storageClasses.Items {
+		annotations := storageClasses.Items[i].Annotations
+		if annotations[annotationDefaultStorageClass] == "true" || annotations[annotationBetaDefaultStorageClass] == "true" {
+			return &storageClasses.Items[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// getInlineVolumeNFSStorageClass returns the NFSStorageClass with the server
+// and the share of the inline CSI volume, or nil if there is none: such a
+// volume is not checked.
+func getInlineVolumeNFSStorageClass(ctx context.Context, cl client.Client, volume corev1.Volume) (*v1alpha1.NFSStorageClass, error) {
+	attributes := volume.CSI.VolumeAttributes
+	server, share := attributes["server"], path.Clean(attributes["share"])
+
+	nscList := &v1alpha1.NFSStorageClassList{}
+	if err := cl.List(ctx, nscList); err != nil {
+		return nil, fmt.Errorf("error listing NFSStorageClasses: %v", err)
+	}
+	for _, nsc := range nscList.Items {
+		if nsc.Spec.Connection != nil && nsc.Spec.Connection.Host == server && path.Clean(nsc.Spec.Connection.Share) == share {
+			return &nsc, nil
+		}
+	}
+	return nil, nil
+}
+
 func getNodeNames(inputData ExtenderArgs) ([]string, error) {
 	if inputData.NodeNames != nil && len(*inputData.NodeNames) > 0 {
 		return *inputData.NodeNames, nil
@@ -117,7 +184,9 @@ func GetNFSStorageClassesFromVolumes(ctx context.Context, cl client.Client, log
 	nfsStorageClasses := &v1alpha1.NFSStorageClassList{}
 	for _, volume := range volumes {
 		log.Trace(fmt.Sprintf("[GetNFSStorageClassesFromVolumes] process volume: %+v", volume))
-		if volume.PersistentVolumeClaim != nil {
+		var storageClassName string
+		switch {
+		case volume.PersistentVolumeClaim != nil:
 			pvcName := volume.PersistentVolumeClaim.ClaimName
 			pvc := &corev1.PersistentVolumeClaim{}
 			err := cl.Get(ctx, client.ObjectKey{Namespace: namespace, Name: pvcName}, pvc)
@@ -125,25 +194,51 @@ func GetNFSStorageClassesFromVolumes(ctx context.Context, cl client.Client, log
 				return nil, fmt.Errorf("error getting PVC %s: %v", pvcName, err)
 			}
 			log.Trace(fmt.Sprintf("[GetNFSStorageClassesFromVolumes] get pvc: %+v", pvc))
-			storageClassName := pvc.Spec.StorageClassName
-			if storageClassName == nil {
+			if pvc.Spec.StorageClassName == nil {
 				return nil, fmt.Errorf("PVC %s has no storage class", pvcName)
 			}
+			storageClassName = *pvc.Spec.StorageClassName
 
-			log.Trace(fmt.Sprintf("[GetNFSStorageClassesFromVolumes] get storage class name: %s", *storageClassName))
-			nsc := &v1alpha1.NFSStorageClass{}
-			err = cl.Get(ctx, client.ObjectKey{Name: *storageClassName}, nsc)
+		case volume.Ephemeral != nil && volume.Ephemeral.VolumeClaimTemplate != nil:
+			storageClass, err := getEphemeralVolumeStorageClass(ctx, cl, volume.Ephemeral)
 			if err != nil {
-				return nil, fmt.Errorf("error getting NFSStorageClass %s: %v", *storageClassName, err)
+				return nil, err
+			}
+			if storageClass == nil {
+				return nil, fmt.Errorf("ephemeral volume %s has no storage class", volume.Name)
 			}
-			log.Trace(fmt.Sprintf("[GetNFSStorageClassesFromVolumes] get NFSStorageClass: %+v", nsc))
+			storageClassName = storageClass.Name
+
+		case volume.CSI != nil:
+			nsc, err := getInlineVolumeNFSStorageClass(ctx, cl, volume)
+			if err != nil {
+				return nil, err
+			}
+			if nsc == nil {
+				log.Trace(fmt.Sprintf("[GetNFSStorageClassesFromVolumes] no NFSStorageClass of inline volume %s, skip it", volume.Name))
+				continue
+			}
+			log.Trace(fmt.Sprintf("[GetNFSStorageClassesFromVolumes] get NFSStorageClass of inline volume %s: %+v", volume.Name, nsc))
 			nfsStorageClasses.Items = append(nfsStorageClasses.Items, *nsc)
+			continue
+
+		default:
+			continue
+		}
+
+		log.Trace(fmt.Sprintf("[GetNFSStorageClassesFromVolumes] get storage class name: %s", storageClassName))
+		nsc := &v1alpha1.NFSStorageClass{}
+		err := cl.Get(ctx, client.ObjectKey{Name: storageClassName}, nsc)
+		if err != nil {
+			return nil, fmt.Errorf("error getting NFSStorageClass %s: %v", storageClassName, err)
 		}
+		log.Trace(fmt.Sprintf("[GetNFSStorageClassesFromVolumes] get NFSStorageClass: %+v", nsc))
+		nfsStorageClasses.Items = append(nfsStorageClasses.Items, *nsc)
 	}
 	return nfsStorageClasses, nil
 }
 
-func GetKubernetesNodeNamesBySelector(ctx context.Context, cl client.Client, nodeSelector map[string]string) ([]string, error) {
+func GetKubernetesNodeNamesBySelector(ctx context.Context, cl client.Client, nodeSelector *metav1.LabelSelector) ([]string, error) {
 	selectedK8sNodes, err := GetKubernetesNodesBySelector(ctx, cl, nodeSelector)
 	if err != nil {
 		return nil, err
@@ -155,26 +250,24 @@ func GetKubernetesNodeNamesBySelector(ctx context.Context, cl client.Client, nod
 	return nodeNames, nil
 }
 
-func GetKubernetesNodesBySelector(ctx context.Context, cl client.Client, nodeSelector map[string]string) (*corev1.NodeList, error) {
+func GetKubernetesNodesBySelector(ctx context.Context, cl client.Client, nodeSelector *metav1.LabelSelector) (*corev1.NodeList, error) {
+	selector, err := nodeselector.Selector(nodeSelector)
+	if err != nil {
+		return nil, err
+	}
 	selectedK8sNodes := &corev1.NodeList{}
-	err := cl.List(ctx, selectedK8sNodes, client.MatchingLabels(nodeSelector))
+	err = cl.List(ctx, selectedK8sNodes, client.MatchingLabelsSelector{Selector: selector})
 	return selectedK8sNodes, err
 }
 
-func GetNodeSelectorFromNFSStorageClasses(log logger.Logger, nfsStorageClasses *v1alpha1.NFSStorageClassList) []*metav1.LabelSelector {
-	nodeSelectorList := []*metav1.LabelSelector{}
+// GetNodeSelectorFromNFSStorageClasses returns the node selectors of the
+// NFSStorageClasses of the Pod volumes, the Pod may only run on the nodes
+// matching all of them.
+func GetNodeSelectorFromNFSStorageClasses(log logger.Logger, nfsStorageClasses *v1alpha1.NFSStorageClassList) ([]*metav1.LabelSelector, error) {
 	for _, nfsStorageClass := range nfsStorageClasses.Items {
-		log.Debug(fmt.Sprintf("[GetNodeSelectorFromNFSStorageClasses] Process NFSStorageClass %s.", nfsStorageClass.Name))
-		if nfsStorageClass.Spec.WorkloadNodes == nil || nfsStorageClass.Spec.WorkloadNodes.NodeSelector == nil {
-			log.Debug(fmt.Sprintf("[GetNodeSelectorFromNFSStorageClasses] NFSStorageClass %s has not NodeSelector. Return default NodeSelector %+v.", nfsStorageClass.Name, DefaultNodeSelector))
-			return []*metav1.LabelSelector{DefaultNodeSelector}
-		}
-		log.Debug(fmt.Sprintf("[GetNodeSelectorFromNFSStorageClasses] Add NodeSelector %+v from NFSStorageClass %s.", nfsStorageClass.Spec.WorkloadNodes.NodeSelector, nfsStorageClass.Name))
-		nodeSelectorList = append(nodeSelectorList, nfsStorageClass.Spec.WorkloadNodes.NodeSelector)
+		log.Debug(fmt.Sprintf("[GetNodeSelectorFromNFSStorageClasses] Process NFSStorageClass %s, NodeSelector %+v.", nfsStorageClass.Name, nodeselector.ForNFSStorageClass(&nfsStorageClass)))
 	}
-
-	// TODO: make unique nodeSelectorList
-	return nodeSelectorList
+	return nodeselector.ForNFSStorageClasses(nfsStorageClasses.Items)
 }
 
 func GetCommonNodesByNodeSelectorList(ctx context.Context, cl client.Client, log logger.Logger, nodeSelectorList []*metav1.LabelSelector) ([]string, error) {
@@ -185,7 +278,7 @@ func GetCommonNodesByNodeSelectorList(ctx context.Context, cl client.Client, log
 	commonNodeNames := make([]string, 0)
 	for i, nodeSelector := range nodeSelectorList {
 		log.Debug(fmt.Sprintf("[GetCommonNodesByNodeSelectorList] Process NodeSelector %d: %+v", i, nodeSelector))
-		selectedNodeNames, err := GetKubernetesNodeNamesBySelector(ctx, cl, nodeSelector.MatchLabels)
+		selectedNodeNames, err := GetKubernetesNodeNamesBySelector(ctx, cl, nodeSelector)
 		if err != nil {
 			return nil, fmt.Errorf("[GetCommonNodesByNodeSelectorList] Error getting nodes by selector: %v", err)
 		}
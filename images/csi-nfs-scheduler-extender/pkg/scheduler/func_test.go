@@ -218,6 +218,105 @@ func TestShouldProcessPod(t *testing.T) {
 			expectedShouldProcess: false,
 			expectedError:         true,
 		},
+		{
+			name: "Provisioner in StorageClass of ephemeral volume",
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "pod6",
+					Namespace: "default",
+				},
+				Spec: corev1.PodSpec{
+					Volumes: []corev1.Volume{
+						{
+							Name: "volume6",
+							VolumeSource: corev1.VolumeSource{
+								Ephemeral: &corev1.EphemeralVolumeSource{
+									VolumeClaimTemplate: &corev1.PersistentVolumeClaimTemplate{
+										Spec: corev1.PersistentVolumeClaimSpec{
+											StorageClassName: stringPtr("sc6"),
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			objects: []runtime.Object{
+				&storagev1.StorageClass{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "sc6",
+					},
+					Provisioner: "my-provisioner",
+				},
+			},
+			targetProvisioner:     "my-provisioner",
+			expectedShouldProcess: true,
+		},
+		{
+			name: "Provisioner in default StorageClass of ephemeral volume",
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "pod7",
+					Namespace: "default",
+				},
+				Spec: corev1.PodSpec{
+					Volumes: []corev1.Volume{
+						{
+							Name: "volume7",
+							VolumeSource: corev1.VolumeSource{
+								Ephemeral: &corev1.EphemeralVolumeSource{
+									VolumeClaimTemplate: &corev1.PersistentVolumeClaimTemplate{},
+								},
+							},
+						},
+					},
+				},
+			},
+			objects: []runtime.Object{
+				&storagev1.StorageClass{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "other",
+					},
+					Provisioner: "other-provisioner",
+				},
+				&storagev1.StorageClass{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "default",
+						Annotations: map[string]string{
+							"storageclass.kubernetes.io/is-default-class": "true",
+						},
+					},
+					Provisioner: "my-provisioner",
+				},
+			},
+			targetProvisioner:     "my-provisioner",
+			expectedShouldProcess: true,
+		},
+		{
+			name: "Provisioner in driver of inline CSI volume",
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "pod8",
+					Namespace: "default",
+				},
+				Spec: corev1.PodSpec{
+					Volumes: []corev1.Volume{
+						{
+							Name: "volume8",
+							VolumeSource: corev1.VolumeSource{
+								CSI: &corev1.CSIVolumeSource{
+									Driver: "my-provisioner",
+								},
+							},
+						},
+					},
+				},
+			},
+			objects:               []runtime.Object{},
+			targetProvisioner:     "my-provisioner",
+			expectedShouldProcess: true,
+		},
 	}
 
 	for _, tc := range tt {
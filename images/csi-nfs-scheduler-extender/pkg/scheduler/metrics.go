@@ -0,0 +1,117 @@
+/*
+Copyright 2026 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	cacheNodeSelectors = "node_selectors"
+	cachePodVolumes    = "pod_volumes"
+
+	verbFilter     = "filter"
+	verbPrioritize = "prioritize"
+
+	podProcessed = "processed"
+	podSkipped   = "skipped"
+
+	lookupPodVolumes = "pod_volumes"
+	lookupNodes      = "nodes"
+
+	filterNodesInput       = "input"
+	filterNodesSuitable    = "suitable"
+	filterNodesFilteredOut = "filtered_out"
+)
+
+var (
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "csi_nfs_scheduler_extender_request_duration_seconds",
+		Help:    "Duration of the requests of the scheduler to the extender.",
+		Buckets: []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5},
+	}, []string{"verb"})
+
+	requestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "csi_nfs_scheduler_extender_requests_total",
+		Help: "Number of the requests of the scheduler to the extender by the HTTP status code of the response.",
+	}, []string{"verb", "code"})
+
+	filterNodes = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "csi_nfs_scheduler_extender_filter_nodes",
+		Help:    "Number of the nodes in the filter requests and of the nodes left and filtered out by the extender for the Pods with NFS volumes.",
+		Buckets: prometheus.ExponentialBuckets(1, 4, 7),
+	}, []string{"nodes"})
+
+	podsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "csi_nfs_scheduler_extender_pods_total",
+		Help: "Number of the Pods processed by the extender and of the Pods without NFS volumes skipped by it.",
+	}, []string{"verb", "result"})
+
+	lookupErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "csi_nfs_scheduler_extender_lookup_errors_total",
+		Help: "Number of the requests failed on reading the objects from the API server or the caches.",
+	}, []string{"verb", "lookup"})
+
+	cacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "csi_nfs_scheduler_extender_cache_lookups_total",
+		Help: "Number of the lookups in the caches of the extender.",
+	}, []string{"cache", "result"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(requestDuration, requestsTotal, filterNodes, podsTotal, lookupErrors, cacheLookups)
+}
+
+// statusRecorder keeps the status code of the response for the metrics.
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.code = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// observeRequest serves the request of the verb and records its duration and
+// status code.
+func observeRequest(verb string, w http.ResponseWriter, r *http.Request, serve func(http.ResponseWriter, *http.Request)) {
+	start := time.Now()
+	recorder := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+	serve(recorder, r)
+	requestDuration.WithLabelValues(verb).Observe(time.Since(start).Seconds())
+	requestsTotal.WithLabelValues(verb, strconv.Itoa(recorder.code)).Inc()
+}
+
+func podResult(shouldProcess bool) string {
+	if shouldProcess {
+		return podProcessed
+	}
+	return podSkipped
+}
+
+func cacheResult(hit bool) string {
+	if hit {
+		return "hit"
+	}
+	return "miss"
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2026 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/deckhouse/csi-nfs/images/csi-nfs-scheduler-extender/pkg/logger"
+)
+
+func TestFilterMetrics(t *testing.T) {
+	cl := newExplainClient(t)
+	handler, err := NewHandler(context.Background(), cl, logger.Logger{}, Options{DefaultDivisor: 1, ScoreWeights: DefaultScoreWeights})
+	assert.NoError(t, err)
+
+	filter := func(pod *corev1.Pod, nodeNames []string) int {
+		body, err := json.Marshal(ExtenderArgs{Pod: pod, NodeNames: &nodeNames})
+		assert.NoError(t, err)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/scheduler/filter", bytes.NewReader(body)))
+		return rr.Code
+	}
+
+	requestsOK := testutil.ToFloat64(requestsTotal.WithLabelValues(verbFilter, "200"))
+	requestsBadRequest := testutil.ToFloat64(requestsTotal.WithLabelValues(verbFilter, "400"))
+	processed := testutil.ToFloat64(podsTotal.WithLabelValues(verbFilter, podProcessed))
+	skipped := testutil.ToFloat64(podsTotal.WithLabelValues(verbFilter, podSkipped))
+	podVolumesErrors := testutil.ToFloat64(lookupErrors.WithLabelValues(verbFilter, lookupPodVolumes))
+
+	pod := &corev1.Pod{}
+	assert.NoError(t, cl.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: "pod1"}, pod))
+	assert.Equal(t, http.StatusOK, filter(pod, []string{"node-a", "node-b"}))
+	assert.Equal(t, http.StatusOK, filter(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod2", Namespace: "default"}}, []string{"node-a"}))
+
+	// the PVC of the Pod can not be read
+	podWithoutPVC := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod3", Namespace: "default"},
+		Spec: corev1.PodSpec{Volumes: []corev1.Volume{{
+			Name:         "data",
+			VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "absent"}},
+		}}},
+	}
+	assert.Equal(t, http.StatusBadRequest, filter(podWithoutPVC, []string{"node-a"}))
+
+	assert.Equal(t, requestsOK+2, testutil.ToFloat64(requestsTotal.WithLabelValues(verbFilter, "200")))
+	assert.Equal(t, requestsBadRequest+1, testutil.ToFloat64(requestsTotal.WithLabelValues(verbFilter, "400")))
+	assert.Equal(t, processed+1, testutil.ToFloat64(podsTotal.WithLabelValues(verbFilter, podProcessed)))
+	assert.Equal(t, skipped+1, testutil.ToFloat64(podsTotal.WithLabelValues(verbFilter, podSkipped)))
+	assert.Equal(t, podVolumesErrors+1, testutil.ToFloat64(lookupErrors.WithLabelValues(verbFilter, lookupPodVolumes)))
+}
+
+func TestFilteredOutNodesByReason(t *testing.T) {
+	assert.Equal(t, map[string][]string{
+		"node is not selected by user selectors": {"node-a", "node-c"},
+		"node plugin is not ready":               {"node-b"},
+	}, filteredOutNodesByReason(FailedNodesMap{
+		"node-c": "node is not selected by user selectors",
+		"node-b": "node plugin is not ready",
+		"node-a": "node is not selected by user selectors",
+	}))
+}
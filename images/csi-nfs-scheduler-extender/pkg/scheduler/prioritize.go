@@ -17,16 +17,56 @@ limitations under the License.
 package scheduler
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"path"
+	"slices"
 
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	v1alpha1 "github.com/deckhouse/csi-nfs/api/v1alpha1"
+	"github.com/deckhouse/csi-nfs/images/csi-nfs-scheduler-extender/pkg/consts"
 	"github.com/deckhouse/csi-nfs/images/csi-nfs-scheduler-extender/pkg/logger"
 )
 
+const (
+	// maxScore is the highest score of a node returned by the extender.
+	maxScore = 10
+	// minRTTMilliseconds is the lowest RTT bucket reported by the nodes.
+	minRTTMilliseconds = 0.5
+)
+
+// ScoreWeights are the weights of the signals combined into the score of a node.
+// Every signal scores a node from 0 to 10 for each NFSStorageClass of the Pod.
+type ScoreWeights struct {
+	// Zone is the weight of the node being in the zone of the NFS server.
+	Zone float64 `json:"zone"`
+	// MountedExport is the weight of the node already mounting the share.
+	MountedExport float64 `json:"mounted-export"`
+	// RTT is the weight of the round-trip time from the node to the NFS server
+	// relative to the fastest node.
+	RTT float64 `json:"rtt"`
+}
+
+// DefaultScoreWeights weigh all the signals equally.
+var DefaultScoreWeights = ScoreWeights{Zone: 1, MountedExport: 1, RTT: 1}
+
+// nodeServerClientStats is the value of an NFS server in the client
+// statistics annotation of the Lease of a node written by the csi-nfs-node.
+type nodeServerClientStats struct {
+	Exports         []string `json:"exports"`
+	RTTMilliseconds *float64 `json:"rttMs,omitempty"`
+}
+
 func (s *scheduler) prioritize(w http.ResponseWriter, r *http.Request) {
-	s.log.Debug("[prioritize] starts serving. WARNING: this scheduler does not support prioritizing! It will return the same nodes with 0 score")
+	s.log.Debug("[prioritize] starts serving")
 
 	var inputData ExtenderArgs
 	reader := http.MaxBytesReader(w, r.Body, 10<<20)
@@ -51,7 +91,35 @@ func (s *scheduler) prioritize(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result := scoreNodes(s.log, &nodeNames)
+	volumes, err := s.getPodVolumes(inputData.Pod, consts.CSINFSProvisioner)
+	if err != nil {
+		lookupErrors.WithLabelValues(verbPrioritize, lookupPodVolumes).Inc()
+		s.log.Error(err, "[prioritize] unable to check if the Pod should be processed")
+		http.Error(w, fmt.Sprintf("[prioritize] unable to check if the Pod should be processed: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	podsTotal.WithLabelValues(verbPrioritize, podResult(volumes.shouldProcess)).Inc()
+	var result []HostPriority
+	if !volumes.shouldProcess {
+		s.log.Debug(fmt.Sprintf("[prioritize] Pod %s/%s should not be processed. Return the same nodes with 0 score", inputData.Pod.Namespace, inputData.Pod.Name))
+		result = zeroScores(nodeNames)
+	} else {
+		nodes, err := getNodes(s.ctx, s.client, inputData, nodeNames)
+		if err != nil {
+			lookupErrors.WithLabelValues(verbPrioritize, lookupNodes).Inc()
+			s.log.Error(err, "[prioritize] unable to get the nodes from the request")
+			http.Error(w, fmt.Sprintf("[prioritize] internal error: %s", err), http.StatusInternalServerError)
+			return
+		}
+
+		clientStats, err := getClientStats(s.ctx, s.client, s.csiNodeNamespace)
+		if err != nil {
+			// the nodes are scored by the zones only
+			s.log.Error(err, "[prioritize] unable to get the NFS client statistics of the nodes")
+		}
+		result = scoreNodes(s.log, nodes, clientStats, volumes.nfsStorageClasses, s.scoreWeights, s.defaultDivisor)
+	}
 	s.log.Debug(fmt.Sprintf("[prioritize] successfully scored the nodes for Pod %s/%s", inputData.Pod.Namespace, inputData.Pod.Name))
 
 	w.Header().Set("content-type", "application/json")
@@ -63,24 +131,147 @@ func (s *scheduler) prioritize(w http.ResponseWriter, r *http.Request) {
 	s.log.Debug("[prioritize] ends serving")
 }
 
+func zeroScores(nodeNames []string) []HostPriority {
+	result := make([]HostPriority, 0, len(nodeNames))
+	for _, nodeName := range nodeNames {
+		result = append(result, HostPriority{Host: nodeName, Score: 0})
+	}
+	return result
+}
+
+// getNodes returns the nodes of the request in its order. A node which is not
+// found is returned without labels and annotations and gets no score.
+func getNodes(ctx context.Context, cl client.Client, inputData ExtenderArgs, nodeNames []string) ([]corev1.Node, error) {
+	if inputData.Nodes != nil && len(inputData.Nodes.Items) > 0 {
+		return inputData.Nodes.Items, nil
+	}
+
+	nodes := make([]corev1.Node, 0, len(nodeNames))
+	for _, nodeName := range nodeNames {
+		node := corev1.Node{}
+		err := cl.Get(ctx, client.ObjectKey{Name: nodeName}, &node)
+		if err != nil {
+			if !k8serrors.IsNotFound(err) {
+				return nil, fmt.Errorf("error getting node %s: %w", nodeName, err)
+			}
+			node.Name = nodeName
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// getClientStats returns the NFS client statistics annotations of the Leases
+// of the nodes in the namespace of the csi-nfs-node by the names of the nodes.
+func getClientStats(ctx context.Context, cl client.Client, namespace string) (map[string]string, error) {
+	if namespace == "" {
+		return nil, nil
+	}
+	leases := &coordinationv1.LeaseList{}
+	err := cl.List(ctx, leases, client.InNamespace(namespace), client.HasLabels{consts.ClientStatsLabel})
+	if err != nil {
+		return nil, fmt.Errorf("error listing the Leases with the NFS client statistics in namespace %s: %w", namespace, err)
+	}
+
+	result := make(map[string]string, len(leases.Items))
+	for _, lease := range leases.Items {
+		value, ok := lease.Annotations[consts.ClientStatsAnnotation]
+		if !ok || lease.Spec.HolderIdentity == nil {
+			continue
+		}
+		result[*lease.Spec.HolderIdentity] = value
+	}
+	return result, nil
+}
+
+// scoreNodes scores the nodes for the NFSStorageClasses of the volumes of a
+// Pod with the NFS client statistics of the nodes by their names. The score of
+// a node is the weighted average of its signals, averaged over the
+// NFSStorageClasses and divided by the divisor.
 func scoreNodes(
 	log logger.Logger,
-	nodeNames *[]string,
+	nodes []corev1.Node,
+	nodeClientStats map[string]string,
+	nfsStorageClasses *v1alpha1.NFSStorageClassList,
+	weights ScoreWeights,
+	divisor float64,
 ) []HostPriority {
-	result := make([]HostPriority, 0, len(*nodeNames))
-	if len(*nodeNames) == 0 {
+	result := make([]HostPriority, 0, len(nodes))
+	if len(nodes) == 0 {
 		log.Warning("[scoreNodes] no nodes to score. Return empty result")
 		return result
 	}
+	if divisor <= 0 {
+		divisor = 1
+	}
+
+	clientStats := make([]map[string]nodeServerClientStats, len(nodes))
+	for i, node := range nodes {
+		value, ok := nodeClientStats[node.Name]
+		if !ok {
+			continue
+		}
+		if err := json.Unmarshal([]byte(value), &clientStats[i]); err != nil {
+			log.Warning(fmt.Sprintf("[scoreNodes] unable to parse the NFS client statistics of node %s: %v", node.Name, err))
+		}
+	}
+
+	scores := make([]float64, len(nodes))
+	for _, nsc := range nfsStorageClasses.Items {
+		if nsc.Spec.Connection == nil {
+			continue
+		}
+		server := nsc.Spec.Connection.Host
+		share := path.Clean(nsc.Spec.Connection.Share)
+
+		var minRTT float64
+		for i := range nodes {
+			if stats, ok := clientStats[i][server]; ok && stats.RTTMilliseconds != nil {
+				rtt := math.Max(*stats.RTTMilliseconds, minRTTMilliseconds)
+				if minRTT == 0 || rtt < minRTT {
+					minRTT = rtt
+				}
+			}
+		}
+
+		for i, node := range nodes {
+			var sum, weightSum float64
+			if zone := nsc.Spec.Connection.Zone; zone != "" {
+				weightSum += weights.Zone
+				if node.Labels[corev1.LabelTopologyZone] == zone {
+					sum += weights.Zone * maxScore
+				}
+			}
+
+			stats, ok := clientStats[i][server]
+			weightSum += weights.MountedExport
+			if ok && slices.Contains(stats.Exports, share) {
+				sum += weights.MountedExport * maxScore
+			}
+			weightSum += weights.RTT
+			if ok && stats.RTTMilliseconds != nil {
+				sum += weights.RTT * maxScore * minRTT / math.Max(*stats.RTTMilliseconds, minRTTMilliseconds)
+			}
+
+			if weightSum > 0 {
+				scores[i] += sum / weightSum
+			}
+		}
+	}
 
-	for _, nodeName := range *nodeNames {
-		log.Trace(fmt.Sprintf("[scoreNodes] node: %s", nodeName))
+	for i, node := range nodes {
+		score := 0
+		if len(nfsStorageClasses.Items) > 0 {
+			score = int(math.Round(scores[i] / float64(len(nfsStorageClasses.Items)) / divisor))
+		}
+		score = max(0, min(score, maxScore))
+		log.Trace(fmt.Sprintf("[scoreNodes] node: %s, score: %d", node.Name, score))
 		result = append(result, HostPriority{
-			Host:  nodeName,
-			Score: 0,
+			Host:  node.Name,
+			Score: score,
 		})
 	}
 
-	log.Trace("[scoreNodes] final result: %+v", result)
+	log.Trace(fmt.Sprintf("[scoreNodes] final result: %+v", result))
 	return result
 }
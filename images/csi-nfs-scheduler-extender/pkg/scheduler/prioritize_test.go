@@ -17,28 +17,110 @@ limitations under the License.
 package scheduler
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
+	v1alpha1 "github.com/deckhouse/csi-nfs/api/v1alpha1"
+	"github.com/deckhouse/csi-nfs/images/csi-nfs-scheduler-extender/pkg/consts"
 	"github.com/deckhouse/csi-nfs/images/csi-nfs-scheduler-extender/pkg/logger"
 )
 
+func testNode(name, zone string) corev1.Node {
+	node := corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{}}}
+	if zone != "" {
+		node.Labels[corev1.LabelTopologyZone] = zone
+	}
+	return node
+}
+
+func testNFSStorageClasses(zone string) *v1alpha1.NFSStorageClassList {
+	return &v1alpha1.NFSStorageClassList{Items: []v1alpha1.NFSStorageClass{{
+		ObjectMeta: metav1.ObjectMeta{Name: "nfs"},
+		Spec: v1alpha1.NFSStorageClassSpec{
+			Connection: &v1alpha1.NFSStorageClassConnection{Host: "10.0.0.1", Share: "/share/", Zone: zone},
+		},
+	}}}
+}
+
 func TestPrioritize(t *testing.T) {
-	t.Run("test prioritize", func(t *testing.T) {
-		nodeNames := []string{"node1", "node2", "node3"}
-		log, err := logger.NewLogger(logger.InfoLevel)
-		if err != nil {
-			t.Errorf("failed to create logger: %v", err)
-		}
+	log, err := logger.NewLogger(logger.InfoLevel)
+	if err != nil {
+		t.Errorf("failed to create logger: %v", err)
+	}
+
+	nodes := []corev1.Node{
+		testNode("node1", "zone-a"),
+		testNode("node2", "zone-a"),
+		testNode("node3", "zone-b"),
+		testNode("node4", ""),
+	}
+	clientStats := map[string]string{
+		"node1": `{"10.0.0.1":{"exports":["/share"],"rttMs":0.5}}`,
+		"node2": `{"10.0.0.1":{"exports":["/other"],"rttMs":2}}`,
+		"node3": `{"10.0.0.2":{"exports":["/share"],"rttMs":0.5}}`,
+		"node4": "not json",
+	}
+
+	t.Run("test zero scores", func(t *testing.T) {
+		result := zeroScores([]string{"node1", "node2", "node3"})
+		assert.Equal(t, []HostPriority{{Host: "node1"}, {Host: "node2"}, {Host: "node3"}}, result)
+	})
+
+	t.Run("test all signals", func(t *testing.T) {
+		result := scoreNodes(*log, nodes, clientStats, testNFSStorageClasses("zone-a"), DefaultScoreWeights, 1)
+		// node1: zone 10, mounted 10, rtt 10; node2: zone 10, rtt 2.5
+		assert.Equal(t, []HostPriority{{Host: "node1", Score: 10}, {Host: "node2", Score: 4}, {Host: "node3", Score: 0}, {Host: "node4", Score: 0}}, result)
+	})
 
-		result := scoreNodes(*log, &nodeNames)
-		assert.Equal(t, 3, len(result))
-		assert.Equal(t, "node1", result[0].Host)
-		assert.Equal(t, "node2", result[1].Host)
-		assert.Equal(t, "node3", result[2].Host)
-		assert.Equal(t, 0, result[0].Score)
-		assert.Equal(t, 0, result[1].Score)
-		assert.Equal(t, 0, result[2].Score)
+	t.Run("test without zone", func(t *testing.T) {
+		result := scoreNodes(*log, nodes, clientStats, testNFSStorageClasses(""), DefaultScoreWeights, 1)
+		assert.Equal(t, []HostPriority{{Host: "node1", Score: 10}, {Host: "node2", Score: 1}, {Host: "node3", Score: 0}, {Host: "node4", Score: 0}}, result)
 	})
+
+	t.Run("test weights and divisor", func(t *testing.T) {
+		result := scoreNodes(*log, nodes, clientStats, testNFSStorageClasses("zone-b"), ScoreWeights{Zone: 1}, 1)
+		assert.Equal(t, []HostPriority{{Host: "node1", Score: 0}, {Host: "node2", Score: 0}, {Host: "node3", Score: 10}, {Host: "node4", Score: 0}}, result)
+
+		result = scoreNodes(*log, nodes, clientStats, testNFSStorageClasses("zone-a"), DefaultScoreWeights, 2)
+		assert.Equal(t, 5, result[0].Score)
+		assert.Equal(t, 2, result[1].Score)
+	})
+
+	t.Run("test no nodes", func(t *testing.T) {
+		result := scoreNodes(*log, nil, nil, testNFSStorageClasses("zone-a"), DefaultScoreWeights, 1)
+		assert.Empty(t, result)
+	})
+}
+
+func TestGetClientStats(t *testing.T) {
+	lease := func(name, holder string, labels, annotations map[string]string) *coordinationv1.Lease {
+		return &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "d8-csi-nfs", Labels: labels, Annotations: annotations},
+			Spec:       coordinationv1.LeaseSpec{HolderIdentity: &holder},
+		}
+	}
+	stats := map[string]string{consts.ClientStatsAnnotation: `{"10.0.0.1":{"exports":["/share"]}}`}
+	scheme := runtime.NewScheme()
+	assert.NoError(t, clientgoscheme.AddToScheme(scheme))
+	cl := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		lease("nfs-client-stats-node1", "node1", map[string]string{consts.ClientStatsLabel: ""}, stats),
+		// the leader election Lease of the controller
+		lease("csi-nfs-controller", "controller-0", nil, stats),
+	).Build()
+
+	result, err := getClientStats(context.Background(), cl, "d8-csi-nfs")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"node1": stats[consts.ClientStatsAnnotation]}, result)
+
+	result, err = getClientStats(context.Background(), cl, "")
+	assert.NoError(t, err)
+	assert.Empty(t, result)
 }
@@ -20,29 +20,70 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"time"
 
+	utilcache "k8s.io/apimachinery/pkg/util/cache"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/deckhouse/csi-nfs/images/csi-nfs-scheduler-extender/pkg/logger"
 )
 
 type scheduler struct {
-	defaultDivisor float64
-	log            logger.Logger
-	client         client.Client
-	ctx            context.Context
+	defaultDivisor  float64
+	scoreWeights    ScoreWeights
+	log             logger.Logger
+	client          client.Client
+	apiReader       client.Reader
+	ctx             context.Context
+	nodeIndex       *nodeSelectorIndex
+	podVolumesCache *utilcache.LRUExpireCache
+	podVolumesTTL   time.Duration
+	// csiNodeNamespace is the namespace of the csi-nfs-node Pods
+	csiNodeNamespace string
+	recorder         record.EventRecorder
+}
+
+// Options are the settings of the scheduler extender handler.
+type Options struct {
+	DefaultDivisor float64
+	ScoreWeights   ScoreWeights
+	// APIReader reads the objects which are not in the caches of the client,
+	// e.g. the Pods of the explain requests. If nil, the client is used.
+	APIReader client.Reader
+	// Informers keep the caches of the handler up to date. Without them
+	// nothing is cached and every request reads the nodes with the client.
+	Informers cache.Informers
+	// CacheSize is the number of the node selectors and of the Pods whose
+	// nodes and NFSStorageClasses are cached.
+	CacheSize int
+	// PVCExpiredDuration is how long the NFSStorageClasses of the PVCs of a
+	// Pod are cached.
+	PVCExpiredDuration time.Duration
+	// CSINodeNamespace is the namespace of the csi-nfs-node Pods. If empty,
+	// the nodes are not checked for the NFS capabilities of the
+	// NFSStorageClasses and the running node plugin.
+	CSINodeNamespace string
+	// EventRecorder writes the events on the Pods for which all the nodes
+	// are filtered out. If nil, no events are written.
+	EventRecorder record.EventRecorder
 }
 
 func (s *scheduler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	switch r.URL.Path {
 	case "/scheduler/filter":
 		s.log.Debug("[ServeHTTP] filter route starts handling the request")
-		s.Filter(w, r)
+		observeRequest(verbFilter, w, r, s.Filter)
 		s.log.Debug("[ServeHTTP] filter route ends handling the request")
 	case "/scheduler/prioritize":
 		s.log.Debug("[ServeHTTP] prioritize route starts handling the request")
-		s.prioritize(w, r)
+		observeRequest(verbPrioritize, w, r, s.prioritize)
 		s.log.Debug("[ServeHTTP] prioritize route ends handling the request")
+	case "/scheduler/explain":
+		s.log.Debug("[ServeHTTP] explain route starts handling the request")
+		s.explain(w, r)
+		s.log.Debug("[ServeHTTP] explain route ends handling the request")
 	case "/status":
 		s.log.Debug("[ServeHTTP] status route starts handling the request")
 		status(w, r)
@@ -53,13 +94,33 @@ func (s *scheduler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 // NewHandler return new http.Handler of the scheduler extender
-func NewHandler(ctx context.Context, cl client.Client, log logger.Logger) (http.Handler, error) {
-	return &scheduler{
-		// defaultDivisor // TODO: add defaultDivisor from the config
-		log:    log,
-		client: cl,
-		ctx:    ctx,
-	}, nil
+func NewHandler(ctx context.Context, cl client.Client, log logger.Logger, opts Options) (http.Handler, error) {
+	if opts.DefaultDivisor <= 0 {
+		return nil, fmt.Errorf("default divisor must be positive, got %v", opts.DefaultDivisor)
+	}
+	if opts.ScoreWeights.Zone < 0 || opts.ScoreWeights.MountedExport < 0 || opts.ScoreWeights.RTT < 0 {
+		return nil, fmt.Errorf("score weights must not be negative, got %+v", opts.ScoreWeights)
+	}
+	s := &scheduler{
+		defaultDivisor: opts.DefaultDivisor,
+		scoreWeights:   opts.ScoreWeights,
+		log:            log,
+		client:         cl,
+		apiReader:      opts.APIReader,
+		ctx:            ctx,
+
+		csiNodeNamespace: opts.CSINodeNamespace,
+		recorder:         opts.EventRecorder,
+	}
+	if s.apiReader == nil {
+		s.apiReader = cl
+	}
+	if opts.Informers != nil {
+		if err := s.watchInformers(ctx, opts.Informers, opts.CacheSize, opts.PVCExpiredDuration); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
 }
 
 func status(w http.ResponseWriter, _ *http.Request) {
@@ -0,0 +1,163 @@
+/*
+Copyright 2026 Flant JSC
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nfs
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// retainArchivedForKey is set in the provisioner secret by the controller
+	// from spec.retainArchivedFor of the NFSStorageClass; without it the
+	// archived volumes are kept until deleted by hand
+	retainArchivedForKey = "retainArchivedFor"
+
+	archivedVolumeTimeFormat = "20060102T150405Z"
+
+	archivedVolumePurgedReason      = "ArchivedVolumePurged"
+	archivedVolumePurgeFailedReason = "ArchivedVolumePurgeFailed"
+)
+
+var (
+	// archivedVolumeNameRe matches the directories renamed by DeleteVolume
+	// with the archive policy. The archives of the volumes in nested
+	// subdirectories and the ones without a timestamp are never purged.
+	archivedVolumeNameRe = regexp.MustCompile(`^archived-([^/]+)-([0-9]{8}T[0-9]{6}Z)$`)
+
+	archivedVolumesGauge = newMetricVec(metricTypeGauge, "csi_nfs_archived_volumes",
+		"Number of volume directories archived on deletion and kept on the share of the storage class.", "storage_class")
+	archivedVolumesPurgedTotal = newMetricVec(metricTypeCounter, "csi_nfs_archived_volumes_purged_total",
+		"Number of archived volume directories deleted after the retention period of the storage class.", "storage_class")
+)
+
+type archivedVolume struct {
+	name       string
+	volume     string
+	archivedAt time.Time
+}
+
+// archivedVolumeName returns the name DeleteVolume renames the directory of
+// a volume with the archive policy to. The time of the deletion keeps the
+// archives of the volumes recreated with the same name apart and is used to
+// purge them.
+func archivedVolumeName(subDir string) string {
+	return "archived-" + subDir + "-" + time.Now().UTC().Format(archivedVolumeTimeFormat)
+}
+
+// volumeDirExists reports whether the directory of the volume is still to be
+// archived: a retried DeleteVolume finds it already renamed. The other errors
+// are returned, so that a directory which cannot be checked is not skipped.
+func volumeDirExists(path string) (bool, error) {
+	_, err := os.Stat(path)
+	if err == nil {
+		return true, nil
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	return false, err
+}
+
+// findArchivedVolumes returns the archived volume directories in the share
+// root, the oldest first.
+func findArchivedVolumes(root string) ([]archivedVolume, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+	var archived []archivedVolume
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		match := archivedVolumeNameRe.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		archivedAt, err := time.Parse(archivedVolumeTimeFormat, match[2])
+		if err != nil {
+			klog.Warningf("orphan scan: unexpected archived volume %s: %v", entry.Name(), err)
+			continue
+		}
+		archived = append(archived, archivedVolume{name: entry.Name(), volume: match[1], archivedAt: archivedAt})
+	}
+	sort.Slice(archived, func(i, j int) bool {
+		return archived[i].archivedAt.Before(archived[j].archivedAt)
+	})
+	return archived, nil
+}
+
+// expiredArchivedVolumes returns the archived volumes older than retainFor;
+// none if retainFor is zero.
+func expiredArchivedVolumes(archived []archivedVolume, retainFor time.Duration, now time.Time) []archivedVolume {
+	if retainFor <= 0 {
+		return nil
+	}
+	var expired []archivedVolume
+	for _, a := range archived {
+		if now.Sub(a.archivedAt) >= retainFor {
+			expired = append(expired, a)
+		}
+	}
+	return expired
+}
+
+// purgeArchivedVolumes deletes the expired archived volumes with the cleanup
+// method of the class and returns the number of the remaining ones.
+func (s *orphanScanner) purgeArchivedVolumes(ctx context.Context, target string, share *orphanShare, archived []archivedVolume) int {
+	expired := expiredArchivedVolumes(archived, share.retainArchivedFor, s.now())
+	if len(expired) == 0 {
+		return len(archived)
+	}
+	if err := s.mount(ctx, target, share, false); err != nil {
+		klog.Errorf("orphan scan: storage class %s: failed to mount share read-write: %v", share.storageClass, err)
+		return len(archived)
+	}
+	defer s.unmount(ctx, target, share)
+
+	remaining := len(archived)
+	for _, a := range expired {
+		path := filepath.Join(target, a.name)
+		err := func() error {
+			if share.cleanupMethod != "" {
+				if err := cleanupVolume(path, share.cleanupMethod); err != nil {
+					return err
+				}
+			}
+			return os.RemoveAll(path)
+		}()
+		if err != nil {
+			klog.Errorf("orphan scan: failed to purge archived volume %s: %v", path, err)
+			s.recorder.Eventf(share.involvedObject(), corev1.EventTypeWarning, archivedVolumePurgeFailedReason,
+				"Failed to delete archived volume %s on share %s:%s: %v", a.name, share.server, share.share, err)
+			continue
+		}
+		klog.Infof("orphan scan: purged archived volume %s of storage class %s, archived at %s", a.name, share.storageClass, a.archivedAt.Format(time.RFC3339))
+		s.recorder.Eventf(share.involvedObject(), corev1.EventTypeNormal, archivedVolumePurgedReason,
+			"Deleted volume %s archived at %s on share %s:%s", a.volume, a.archivedAt.Format(time.RFC3339), share.server, share.share)
+		archivedVolumesPurgedTotal.inc(share.storageClass)
+		remaining--
+	}
+	return remaining
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2026 Flant JSC
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nfs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestArchivedVolumeName(t *testing.T) {
+	name := archivedVolumeName(testUsedVolume)
+	match := archivedVolumeNameRe.FindStringSubmatch(name)
+	if match == nil || match[1] != testUsedVolume {
+		t.Fatalf("unexpected archived volume name %s", name)
+	}
+	archivedAt, err := time.Parse(archivedVolumeTimeFormat, match[2])
+	if err != nil {
+		t.Fatalf("failed to parse the time of %s: %v", name, err)
+	}
+	if d := time.Since(archivedAt); d < 0 || d > time.Minute {
+		t.Errorf("unexpected time of %s: %s", name, archivedAt)
+	}
+}
+
+func TestVolumeDirExists(t *testing.T) {
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, testUsedVolume), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if exists, err := volumeDirExists(filepath.Join(root, testUsedVolume)); !exists || err != nil {
+		t.Errorf("expected the volume directory to exist, got %t, %v", exists, err)
+	}
+	if exists, err := volumeDirExists(filepath.Join(root, testOldVolume)); exists || err != nil {
+		t.Errorf("expected the volume directory to be missing, got %t, %v", exists, err)
+	}
+	// a file in the path is not a missing directory
+	if err := os.WriteFile(filepath.Join(root, "file"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := volumeDirExists(filepath.Join(root, "file", testOldVolume)); err == nil {
+		t.Errorf("expected an error for a path through a file")
+	}
+}
+
+func TestFindArchivedVolumes(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{
+		"archived-" + testOldVolume + "-20260301T100000Z",
+		"archived-" + testNewVolume + "-20260101T100000Z",
+		"archived-" + testUsedVolume + "-20260201T100000Z",
+		// archived by the upstream driver, without the time
+		"archived-" + testUsedVolume,
+		"archived-nested",
+		testUsedVolume,
+	} {
+		if err := os.Mkdir(filepath.Join(root, name), 0755); err != nil {
+			t.Fatalf("failed to create %s: %v", name, err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, "archived-file-20260101T100000Z"), nil, 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	archived, err := findArchivedVolumes(root)
+	if err != nil {
+		t.Fatalf("findArchivedVolumes failed: %v", err)
+	}
+	expected := []string{testNewVolume, testUsedVolume, testOldVolume}
+	if len(archived) != len(expected) {
+		t.Fatalf("expected %d archived volumes, got %+v", len(expected), archived)
+	}
+	for i, a := range archived {
+		if a.volume != expected[i] {
+			t.Errorf("expected archived volume %s at %d, got %s", expected[i], i, a.volume)
+		}
+	}
+
+	now := time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)
+	if expired := expiredArchivedVolumes(archived, 0, now); len(expired) != 0 {
+		t.Errorf("archived volumes expired without a retention period: %+v", expired)
+	}
+	expired := expiredArchivedVolumes(archived, 30*24*time.Hour, now)
+	if len(expired) != 2 || expired[0].volume != testNewVolume || expired[1].volume != testUsedVolume {
+		t.Errorf("unexpected expired archived volumes %+v", expired)
+	}
+}
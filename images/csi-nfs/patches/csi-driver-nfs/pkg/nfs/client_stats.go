@@ -0,0 +1,301 @@
+/*
+Copyright 2026 Flant JSC
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nfs
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// ClientStatsLeasePrefix is the prefix of the name of the Lease of a node
+	// in the namespace of the driver with the NFS client statistics of the
+	// node. The Lease is held by the node and owned by the Node object, so it
+	// is removed with the node.
+	ClientStatsLeasePrefix = "nfs-client-stats-"
+	// ClientStatsLabel is the label of the Leases with the NFS client
+	// statistics.
+	ClientStatsLabel = "storage.deckhouse.io/nfs-client-stats"
+	// ClientStatsAnnotation is the annotation of the Lease with the NFS
+	// servers of the volumes mounted on the node, read by the scheduler
+	// extender to prefer the nodes close to the server of a volume.
+	ClientStatsAnnotation = "storage.deckhouse.io/nfs-client-stats"
+)
+
+// clientStatsRTTBuckets are the upper bounds in milliseconds the RTT is
+// reported with, so the Lease is only updated when the RTT changes notably.
+var clientStatsRTTBuckets = []float64{0.5, 1, 2, 5, 10, 20, 50, 100, 200, 500, 1000}
+
+// NFSServerClientStats is the value of an NFS server in the
+// ClientStatsAnnotation.
+type NFSServerClientStats struct {
+	// Exports are the exported directories with volumes mounted on the node,
+	// the parents of the volume directories.
+	Exports []string `json:"exports"`
+	// RTTMilliseconds is the upper bound of the bucket of the average
+	// round-trip time of the NFS requests to the server between two reports,
+	// unset until the first requests.
+	RTTMilliseconds *float64 `json:"rttMs,omitempty"`
+}
+
+// clientStatsTotals are the counters of the NFS requests to a server.
+type clientStatsTotals struct {
+	ops   uint64
+	rttMs uint64
+}
+
+// clientStatsReporter writes the NFS servers of the volumes mounted on the
+// node with their recent RTT to the Lease of the node.
+type clientStatsReporter struct {
+	driverName     string
+	nodeID         string
+	namespace      string
+	mountStatsPath string
+	kubeClient     kubernetes.Interface
+
+	previous map[string]clientStatsTotals
+	rtt      map[string]float64 // the last RTT bucket of the servers
+	reported *string            // the value of the annotation, nil before the first report
+}
+
+// nodeServerWithClientStats starts reporting the NFS client statistics of
+// the node every interval and returns ns.
+func (n *Driver) nodeServerWithClientStats(ns csi.NodeServer) csi.NodeServer {
+	if n.clientStatsInterval <= 0 {
+		return ns
+	}
+	_, kubeClient, _, err := newInClusterClient(n.name, n.nodeID)
+	if err != nil {
+		klog.Errorf("NFS client statistics of the node are not reported: %v", err)
+		return ns
+	}
+	namespace, err := os.ReadFile(serviceAccountNamespace)
+	if err != nil {
+		klog.Errorf("NFS client statistics of the node are not reported: failed to get the namespace of the pod: %v", err)
+		return ns
+	}
+	r := &clientStatsReporter{
+		driverName:     n.name,
+		nodeID:         n.nodeID,
+		namespace:      strings.TrimSpace(string(namespace)),
+		mountStatsPath: filepath.Join(procPath, "self", "mountstats"),
+		kubeClient:     kubeClient,
+	}
+	go r.run(context.Background(), n.clientStatsInterval)
+	return ns
+}
+
+func (r *clientStatsReporter) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := r.report(ctx); err != nil {
+			klog.Errorf("failed to report the NFS client statistics of node %s: %v", r.nodeID, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// collect returns the statistics of the NFS servers of the volumes of the
+// driver mounted on the node and the totals of their requests.
+func (r *clientStatsReporter) collect() (map[string]*NFSServerClientStats, map[string]clientStatsTotals, error) {
+	mounts, err := readMountStats(r.mountStatsPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	servers := map[string]*NFSServerClientStats{}
+	totals := map[string]clientStatsTotals{}
+	seen := map[string]bool{}
+	for _, m := range mounts {
+		if !publishPathRe.MatchString(m.mountPoint) || seen[m.device] {
+			continue
+		}
+		data, err := readKubeletVolumeData(m.mountPoint)
+		if err != nil || data.DriverName != r.driverName {
+			continue
+		}
+		seen[m.device] = true
+
+		i := strings.LastIndex(m.device, ":/")
+		if i < 0 {
+			continue
+		}
+		export := path.Dir(m.device[i+1:])
+		server := mountStatsServer(m.device)
+		stats := servers[server]
+		if stats == nil {
+			stats = &NFSServerClientStats{}
+			servers[server] = stats
+		}
+		if !slices.Contains(stats.Exports, export) {
+			stats.Exports = append(stats.Exports, export)
+		}
+
+		t := totals[server]
+		for _, op := range m.operations {
+			t.ops += op.ops
+			t.rttMs += op.rttMs
+		}
+		totals[server] = t
+	}
+	for _, stats := range servers {
+		slices.Sort(stats.Exports)
+	}
+	return servers, totals, nil
+}
+
+// rttBucket returns the upper bound of the bucket of the RTT.
+func rttBucket(rtt float64) float64 {
+	for _, bound := range clientStatsRTTBuckets {
+		if rtt <= bound {
+			return bound
+		}
+	}
+	return clientStatsRTTBuckets[len(clientStatsRTTBuckets)-1]
+}
+
+// report writes the statistics to the Lease of the node when they changed and
+// removes them from the Lease when no volumes of the driver are mounted on the
+// node.
+func (r *clientStatsReporter) report(ctx context.Context) error {
+	servers, totals, err := r.collect()
+	if err != nil {
+		return err
+	}
+	rtts := map[string]float64{}
+	for server, stats := range servers {
+		previous, ok := r.previous[server]
+		current := totals[server]
+		// the counters are reset when the last mount of a server is unmounted
+		if ok && current.ops > previous.ops && current.rttMs >= previous.rttMs {
+			rtts[server] = rttBucket(float64(current.rttMs-previous.rttMs) / float64(current.ops-previous.ops))
+		} else if rtt, ok := r.rtt[server]; ok {
+			// the RTT is kept while there are no requests to the server
+			rtts[server] = rtt
+		} else {
+			continue
+		}
+		rtt := rtts[server]
+		stats.RTTMilliseconds = &rtt
+	}
+	r.previous = totals
+	r.rtt = rtts
+
+	reported := ""
+	if len(servers) > 0 {
+		b, err := json.Marshal(servers)
+		if err != nil {
+			return err
+		}
+		reported = string(b)
+	}
+	if r.reported != nil && *r.reported == reported {
+		return nil
+	}
+
+	if reported == "" {
+		if err := r.clearLease(ctx); err != nil {
+			return err
+		}
+	} else if err := r.writeLease(ctx, reported); err != nil {
+		return err
+	}
+	r.reported = &reported
+	return nil
+}
+
+// writeLease creates or updates the Lease of the node with the statistics.
+func (r *clientStatsReporter) writeLease(ctx context.Context, value string) error {
+	leases := r.kubeClient.CoordinationV1().Leases(r.namespace)
+	now := metav1.NewMicroTime(time.Now())
+	lease, err := leases.Get(ctx, ClientStatsLeasePrefix+r.nodeID, metav1.GetOptions{})
+	if err == nil {
+		if lease.Annotations == nil {
+			lease.Annotations = map[string]string{}
+		}
+		lease.Annotations[ClientStatsAnnotation] = value
+		lease.Spec.RenewTime = &now
+		_, err = leases.Update(ctx, lease, metav1.UpdateOptions{})
+		return err
+	}
+	if !apierrors.IsNotFound(err) {
+		return err
+	}
+
+	node, err := r.kubeClient.CoreV1().Nodes().Get(ctx, r.nodeID, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	lease = &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        ClientStatsLeasePrefix + r.nodeID,
+			Namespace:   r.namespace,
+			Labels:      map[string]string{ClientStatsLabel: ""},
+			Annotations: map[string]string{ClientStatsAnnotation: value},
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: "v1",
+				Kind:       "Node",
+				Name:       node.Name,
+				UID:        node.UID,
+			}},
+		},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity: &r.nodeID,
+			AcquireTime:    &now,
+			RenewTime:      &now,
+		},
+	}
+	_, err = leases.Create(ctx, lease, metav1.CreateOptions{})
+	return err
+}
+
+// clearLease removes the statistics from the Lease of the node. The Lease is
+// kept, so the node needs no permission to delete the Leases, and is removed
+// with the Node.
+func (r *clientStatsReporter) clearLease(ctx context.Context) error {
+	leases := r.kubeClient.CoordinationV1().Leases(r.namespace)
+	lease, err := leases.Get(ctx, ClientStatsLeasePrefix+r.nodeID, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, ok := lease.Annotations[ClientStatsAnnotation]; !ok {
+		return nil
+	}
+	delete(lease.Annotations, ClientStatsAnnotation)
+	now := metav1.NewMicroTime(time.Now())
+	lease.Spec.RenewTime = &now
+	_, err = leases.Update(ctx, lease, metav1.UpdateOptions{})
+	return err
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2026 Flant JSC
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nfs
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const testClientStats = `device 10.0.0.1:/share/pvc-1 mounted on %[1]s with fstype nfs4 statvers=1.1
+	per-op statistics
+	        READ: %[3]s %[3]s 0 0 0 0 %[4]s 0 0
+	     GETATTR: 100 100 0 0 0 0 100 0 0
+
+device 10.0.0.1:/other/pvc-2 mounted on %[2]s with fstype nfs4 statvers=1.1
+	per-op statistics
+	        READ: 0 0 0 0 0 0 0 0 0
+`
+
+func TestClientStatsReporter(t *testing.T) {
+	ctx := context.Background()
+	kubeletDir := t.TempDir()
+	pvc1 := createTestPublishedVolume(t, kubeletDir, "uid-1", "pvc-1", testWatchdogDriver)
+	pvc2 := createTestPublishedVolume(t, kubeletDir, "uid-1", "pvc-2", testWatchdogDriver)
+	path := filepath.Join(kubeletDir, "mountstats")
+	writeStats := func(ops, rtt string) {
+		content := strings.NewReplacer("%[1]s", pvc1, "%[2]s", pvc2, "%[3]s", ops, "%[4]s", rtt).Replace(testClientStats)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	kubeClient := fake.NewSimpleClientset(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1", UID: "node-uid"}})
+	r := &clientStatsReporter{driverName: testWatchdogDriver, nodeID: "node-1", namespace: "d8-csi-nfs", mountStatsPath: path, kubeClient: kubeClient}
+	lease := func() map[string]NFSServerClientStats {
+		lease, err := kubeClient.CoordinationV1().Leases("d8-csi-nfs").Get(ctx, ClientStatsLeasePrefix+"node-1", metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(lease.OwnerReferences) != 1 || lease.OwnerReferences[0].UID != "node-uid" {
+			t.Errorf("unexpected owners of the lease %+v", lease.OwnerReferences)
+		}
+		value, ok := lease.Annotations[ClientStatsAnnotation]
+		if !ok {
+			return nil
+		}
+		var servers map[string]NFSServerClientStats
+		if err := json.Unmarshal([]byte(value), &servers); err != nil {
+			t.Fatal(err)
+		}
+		return servers
+	}
+	report := func() {
+		if err := r.report(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeStats("100", "500")
+	report()
+	servers := lease()
+	if stats, ok := servers["10.0.0.1"]; !ok || strings.Join(stats.Exports, ",") != "/other,/share" || stats.RTTMilliseconds != nil {
+		t.Errorf("unexpected statistics %+v", servers)
+	}
+
+	// 100 READ and 0 GETATTR requests with 1400ms of RTT since the first report
+	writeStats("200", "1900")
+	report()
+	servers = lease()
+	if rtt := servers["10.0.0.1"].RTTMilliseconds; rtt == nil || *rtt != 20 {
+		t.Errorf("unexpected statistics %+v", servers)
+	}
+
+	// the RTT stays in its bucket and is kept without requests
+	kubeClient.ClearActions()
+	writeStats("300", "3500")
+	report()
+	report()
+	if actions := kubeClient.Actions(); len(actions) != 0 {
+		t.Errorf("the lease was written without changes of the statistics: %v", actions)
+	}
+
+	if err := os.WriteFile(path, []byte("device /dev/sda1 mounted on / with fstype ext4\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	kubeClient.ClearActions()
+	report()
+	if servers = lease(); servers != nil {
+		t.Errorf("the statistics of the node without NFS mounts were not removed: %+v", servers)
+	}
+	for _, action := range kubeClient.Actions() {
+		if action.GetVerb() == "delete" {
+			t.Errorf("the lease was deleted: %v", action)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+/*
+Copyright 2026 Flant JSC
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nfs
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"golang.org/x/sys/unix"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
+)
+
+// Methods a volume is cloned with, reported in the method label of
+// csi_nfs_volume_clones_total.
+const (
+	// every file is cloned on the server with the NFSv4.2 CLONE operation
+	cloneMethodReflink = "reflink"
+	// the files are copied on the server with the NFSv4.2 COPY operation
+	// (some may be reflinked)
+	cloneMethodCopyFileRange = "copy_file_range"
+	// the data is read and written back by the provisioner with 'cp -a'
+	cloneMethodCopy = "copy"
+)
+
+// Reasons of falling back to cloneMethodCopy, reported in the reason label of
+// csi_nfs_volume_clone_fallbacks_total.
+const (
+	cloneFallbackNFSVersion  = "nfs_version"
+	cloneFallbackUnsupported = "unsupported"
+)
+
+var (
+	volumeClonesTotal = newMetricVec(metricTypeCounter, "csi_nfs_volume_clones_total",
+		"Number of volumes cloned from another volume by the method used to copy the data.", "method")
+	volumeCloneBytesTotal = newMetricVec(metricTypeCounter, "csi_nfs_volume_clone_bytes_total",
+		"Bytes of regular files cloned from another volume by the method used to copy the data.", "method")
+	volumeCloneFallbacksTotal = newMetricVec(metricTypeCounter, "csi_nfs_volume_clone_fallbacks_total",
+		"Number of volume clones that fell back to copying the data through the provisioner.", "reason")
+)
+
+// errServerSideCopyUnsupported is returned by the server-side copy when
+// neither the mount nor the server can copy the data without the client.
+var errServerSideCopyUnsupported = errors.New("server-side copy is not supported")
+
+var procMountsPath = "/proc/self/mounts"
+
+// cloneVolume copies the content of the source volume srcPath to stagingPath.
+// On NFSv4.2 the files are copied with copy_file_range, which the kernel
+// offloads to the server, or reflinked with FICLONE when the exported file
+// system supports it, so the data does not travel through the provisioner.
+// Otherwise, or when the server rejects the copy, false is returned and the
+// caller copies the volume with 'cp -a' as before.
+func cloneVolume(srcPath, stagingPath string) (bool, error) {
+	src := filepath.Clean(srcPath)
+
+	reason := cloneFallbackNFSVersion
+	if version, err := nfsMountVersion(src); err != nil {
+		klog.Warningf("failed to get the NFS version of %s, copying the volume through the provisioner: %v", src, err)
+	} else if version == "4.2" {
+		method, size, err := copyTreeServerSide(src, stagingPath)
+		if err == nil {
+			klog.V(2).Infof("cloned %s -> %s with %s (%d bytes)", src, stagingPath, method, size)
+			volumeClonesTotal.inc(method)
+			volumeCloneBytesTotal.add(float64(size), method)
+			return true, nil
+		}
+		if !errors.Is(err, errServerSideCopyUnsupported) {
+			return false, status.Errorf(codes.Internal, "failed to clone volume %s: %v", src, err)
+		}
+		klog.Infof("server-side copy of %s is not supported, copying the volume through the provisioner: %v", src, err)
+		if err := removeDirContent(stagingPath); err != nil {
+			return false, status.Errorf(codes.Internal, "failed to clean up staging directory %s: %v", stagingPath, err)
+		}
+		reason = cloneFallbackUnsupported
+	} else {
+		klog.V(4).Infof("%s is mounted with NFS version %q, server-side copy requires 4.2", src, version)
+	}
+	volumeCloneFallbacksTotal.inc(reason)
+	return false, nil
+}
+
+// nfsMountVersion returns the negotiated NFS version ("vers=" option) of the
+// mount path belongs to.
+func nfsMountVersion(path string) (string, error) {
+	f, err := os.Open(procMountsPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var mountPoint, options string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		// the mount points are octal-escaped, e.g. "\040" for a space
+		mp := unescapeMountPath(fields[1])
+		if (path == mp || strings.HasPrefix(path, strings.TrimSuffix(mp, "/")+"/")) && len(mp) >= len(mountPoint) {
+			// the last mount on the longest matching mount point wins
+			mountPoint = mp
+			options = ""
+			if strings.HasPrefix(fields[2], "nfs") {
+				options = fields[3]
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	if mountPoint == "" {
+		return "", fmt.Errorf("no mount found for %s", path)
+	}
+	for _, option := range strings.Split(options, ",") {
+		if version, ok := strings.CutPrefix(option, "vers="); ok {
+			return version, nil
+		}
+	}
+	return "", nil
+}
+
+func unescapeMountPath(path string) string {
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		if path[i] == '\\' && i+3 < len(path) {
+			var c byte
+			if _, err := fmt.Sscanf(path[i+1:i+4], "%03o", &c); err == nil {
+				b.WriteByte(c)
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(path[i])
+	}
+	return b.String()
+}
+
+// serverSideCopier copies files without reading their data into the
+// provisioner. Reflinks are tried first and given up after the first file the
+// server cannot clone.
+type serverSideCopier struct {
+	noReflink  bool
+	reflinked  int64
+	rangeCopy  int64
+	copiedSize atomic.Int64
+}
+
+func copyTreeServerSide(srcPath, dstPath string) (method string, size int64, err error) {
+	c := &serverSideCopier{}
+	progress := startCopyProgress("server-side copy of volume "+srcPath, 0, c.copiedSize.Load)
+	defer progress.stop()
+
+	err = copyTree(srcPath, dstPath, srcPath, func(src, dst string, info fs.FileInfo) error {
+		return c.copyFile(src, dst, info)
+	})
+	if err != nil {
+		return "", 0, err
+	}
+	method = cloneMethodCopyFileRange
+	if c.reflinked > 0 && c.rangeCopy == 0 {
+		method = cloneMethodReflink
+	}
+	return method, c.copiedSize.Load(), nil
+}
+
+func (c *serverSideCopier) copyFile(srcPath, dstPath string, info fs.FileInfo) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(dstPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	err = c.copyData(src, dst, info.Size())
+	if err == nil {
+		// an NFS write error may only be reported on close
+		err = dst.Close()
+	} else {
+		dst.Close()
+	}
+	return err
+}
+
+func (c *serverSideCopier) copyData(src, dst *os.File, size int64) error {
+	if size == 0 {
+		return nil
+	}
+
+	if !c.noReflink {
+		err := unix.IoctlFileClone(int(dst.Fd()), int(src.Fd()))
+		if err == nil {
+			c.reflinked++
+			c.copiedSize.Add(size)
+			return nil
+		}
+		if !isServerSideCopyUnsupported(err) {
+			return fmt.Errorf("failed to clone %s: %w", src.Name(), err)
+		}
+		klog.V(4).Infof("reflinks are not supported for %s, using copy_file_range: %v", src.Name(), err)
+		c.noReflink = true
+	}
+
+	var copied int64
+	for copied < size {
+		n, err := unix.CopyFileRange(int(src.Fd()), nil, int(dst.Fd()), nil, int(size-copied), 0)
+		if err != nil {
+			if isServerSideCopyUnsupported(err) {
+				return fmt.Errorf("%w: copy_file_range %s: %v", errServerSideCopyUnsupported, src.Name(), err)
+			}
+			return fmt.Errorf("failed to copy %s: %w", src.Name(), err)
+		}
+		if n == 0 {
+			// the file was truncated while it was copied
+			break
+		}
+		copied += int64(n)
+		c.copiedSize.Add(int64(n))
+	}
+	c.rangeCopy++
+	return nil
+}
+
+func isServerSideCopyUnsupported(err error) bool {
+	return errors.Is(err, unix.EXDEV) || errors.Is(err, unix.EOPNOTSUPP) || errors.Is(err, unix.ENOSYS) ||
+		errors.Is(err, unix.EINVAL) || errors.Is(err, unix.ENOTTY)
+}
+
+// removeDirContent removes everything inside path, keeping path itself.
+func removeDirContent(path string) error {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := os.RemoveAll(filepath.Join(path, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2026 Flant JSC
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nfs
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNFSMountVersion(t *testing.T) {
+	mounts := filepath.Join(t.TempDir(), "mounts")
+	content := `/dev/sda1 / ext4 rw,relatime 0 0
+server:/export /tmp/pvc-1 nfs4 rw,relatime,vers=4.2,rsize=1048576 0 0
+server:/export /tmp/pvc\0402 nfs rw,relatime,vers=3,hard 0 0
+server:/export /tmp/pvc-1 nfs4 rw,relatime,vers=4.1 0 0
+`
+	if err := os.WriteFile(mounts, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write mounts: %v", err)
+	}
+	defer func(path string) { procMountsPath = path }(procMountsPath)
+	procMountsPath = mounts
+
+	tests := []struct {
+		path    string
+		version string
+	}{
+		{path: "/tmp/pvc-1/pvc-1", version: "4.1"},
+		{path: "/tmp/pvc 2/pvc-2", version: "3"},
+		{path: "/tmp/pvc-10", version: ""},
+		{path: "/", version: ""},
+	}
+	for _, test := range tests {
+		version, err := nfsMountVersion(test.path)
+		if err != nil {
+			t.Errorf("[%s] unexpected error: %v", test.path, err)
+		}
+		if version != test.version {
+			t.Errorf("[%s] got version %q, want %q", test.path, version, test.version)
+		}
+	}
+}
+
+func TestCopyTreeServerSide(t *testing.T) {
+	src := t.TempDir()
+	data := bytes.Repeat([]byte("0123456789"), 100000)
+	if err := os.MkdirAll(filepath.Join(src, "dir"), 0750); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "dir", "file"), data, 0640); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "empty"), nil, 0600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	dst := t.TempDir()
+	method, size, err := copyTreeServerSide(src, dst)
+	if err != nil {
+		// copy_file_range is available on local file systems since Linux 4.5
+		t.Fatalf("copyTreeServerSide failed: %v", err)
+	}
+	if method != cloneMethodReflink && method != cloneMethodCopyFileRange {
+		t.Errorf("unexpected method %q", method)
+	}
+	if size != int64(len(data)) {
+		t.Errorf("got size %d, want %d", size, len(data))
+	}
+	if copied, _ := os.ReadFile(filepath.Join(dst, "dir", "file")); !bytes.Equal(copied, data) {
+		t.Errorf("file content differs")
+	}
+	if fi, err := os.Stat(filepath.Join(dst, "empty")); err != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("empty file not copied: %v", err)
+	}
+	if fi, err := os.Stat(filepath.Join(dst, "dir")); err != nil || fi.Mode().Perm() != 0750 {
+		t.Errorf("directory mode not preserved: %v", err)
+	}
+}
+
+func TestCloneVolumeFallback(t *testing.T) {
+	mounts := filepath.Join(t.TempDir(), "mounts")
+	if err := os.WriteFile(mounts, []byte("server:/export /tmp nfs4 rw,vers=4.1 0 0\n"), 0644); err != nil {
+		t.Fatalf("failed to write mounts: %v", err)
+	}
+	defer func(path string) { procMountsPath = path }(procMountsPath)
+	procMountsPath = mounts
+
+	before := volumeCloneFallbacksTotal.get(cloneFallbackNFSVersion)
+	cloned, err := cloneVolume("/tmp/src/.", t.TempDir())
+	if err != nil || cloned {
+		t.Errorf("expected fallback to cp, got %v, %v", cloned, err)
+	}
+	if volumeCloneFallbacksTotal.get(cloneFallbackNFSVersion) != before+1 {
+		t.Errorf("fallback is not counted")
+	}
+}
+
+func TestWriteMetrics(t *testing.T) {
+	m := &metricVec{name: "test_total", help: "Test.", typ: metricTypeCounter, labels: []string{"a", "b"}, values: map[string]float64{}}
+	m.inc("x", `y"z`)
+	m.add(2, "x", `y"z`)
+	m.set(1.5, "w", "v")
+
+	var buf bytes.Buffer
+	m.write(&buf)
+	expected := `# HELP test_total Test.
+# TYPE test_total counter
+test_total{a="w",b="v"} 1.5
+test_total{a="x",b="y\"z"} 3
+`
+	if buf.String() != expected {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), expected)
+	}
+
+	m.delete("w", "v")
+	buf.Reset()
+	m.write(&buf)
+	if strings.Contains(buf.String(), `a="w"`) {
+		t.Errorf("deleted series is written: %s", buf.String())
+	}
+}
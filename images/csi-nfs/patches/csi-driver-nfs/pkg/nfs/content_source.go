@@ -0,0 +1,403 @@
+/*
+Copyright 2026 Flant JSC
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nfs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
+)
+
+// contentSourceMountOptionsKey is the provisioner secret key holding the mount
+// options of the shares a volume of the class may be restored or cloned from:
+// a JSON object keyed by "<server>:<share>". The controller lists there the
+// shares of the other NFSStorageClasses compatible with the class.
+const contentSourceMountOptionsKey = "contentSourceMountOptions"
+
+// copyProgressInterval is how often the progress of a cross-server copy is logged.
+var copyProgressInterval = 30 * time.Second
+
+func contentSourceShareKey(server, baseDir string) string {
+	return server + ":" + filepath.Join(string(filepath.Separator), baseDir)
+}
+
+// getContentSourceMountOptions returns the mount options of the share of
+// srcVol if it is not the share of dstVol. ok is false if the content source
+// is on the same share, or the secret lists no other shares (the source is
+// then mounted with the options of the destination as before).
+func getContentSourceMountOptions(secrets map[string]string, srcVol, dstVol *nfsVolume) (mountOptions string, ok bool, err error) {
+	srcKey := contentSourceShareKey(srcVol.server, srcVol.baseDir)
+	if srcKey == contentSourceShareKey(dstVol.server, dstVol.baseDir) {
+		return "", false, nil
+	}
+	value, found := secrets[contentSourceMountOptionsKey]
+	if !found {
+		return "", false, nil
+	}
+
+	shares := map[string]string{}
+	if err := json.Unmarshal([]byte(value), &shares); err != nil {
+		return "", false, status.Errorf(codes.Internal, "failed to parse %s: %v", contentSourceMountOptionsKey, err)
+	}
+	mountOptions, found = shares[srcKey]
+	if !found {
+		return "", false, status.Errorf(codes.FailedPrecondition, "content source share %s does not belong to an NFSStorageClass compatible with the destination class", srcKey)
+	}
+	return mountOptions, true, nil
+}
+
+// copyAcrossServers copies the content source of the request to dstPath if it
+// is on another server or share than dstVol. Both shares are mounted with the
+// options of their own classes, the data is streamed and verified and the
+// progress is logged. It returns false if the content source is on the share
+// of dstVol and has to be copied by copyFromSnapshot or copyFromVolume.
+func (cs *ControllerServer) copyAcrossServers(ctx context.Context, req *csi.CreateVolumeRequest, dstVol *nfsVolume, dstPath string, mountPermissions uint64) (bool, error) {
+	var srcVol *nfsVolume
+	var snap *nfsSnapshot
+	switch source := req.GetVolumeContentSource().GetType().(type) {
+	case *csi.VolumeContentSource_Snapshot:
+		s, err := getNfsSnapFromID(source.Snapshot.GetSnapshotId())
+		if err != nil {
+			// reported by copyFromSnapshot
+			return false, nil
+		}
+		snap = s
+		srcVol = volumeFromSnapshot(s)
+	case *csi.VolumeContentSource_Volume:
+		v, err := getNfsVolFromID(source.Volume.GetVolumeId())
+		if err != nil {
+			// reported by copyFromVolume
+			return false, nil
+		}
+		srcVol = v
+	default:
+		return false, nil
+	}
+
+	mountOptions, ok, err := getContentSourceMountOptions(req.GetSecrets(), srcVol, dstVol)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	srcVolCap := &csi.VolumeCapability{
+		AccessType: &csi.VolumeCapability_Mount{
+			Mount: &csi.VolumeCapability_MountVolume{},
+		},
+	}
+	if mountOptions != "" {
+		srcVolCap.AccessType = &csi.VolumeCapability_Mount{
+			Mount: &csi.VolumeCapability_MountVolume{MountFlags: []string{mountOptions}},
+		}
+	}
+	var dstVolCap *csi.VolumeCapability
+	if len(req.GetVolumeCapabilities()) > 0 {
+		dstVolCap = req.GetVolumeCapabilities()[0]
+	}
+
+	klog.V(2).Infof("copying volume content from %s to %s across servers", contentSourceShareKey(srcVol.server, srcVol.baseDir), contentSourceShareKey(dstVol.server, dstVol.baseDir))
+	if err := cs.internalMount(ctx, srcVol, nil, srcVolCap); err != nil {
+		return true, status.Errorf(codes.Internal, "failed to mount src nfs server %s: %v", srcVol.server, err)
+	}
+	defer func() {
+		if err := cs.internalUnmount(ctx, srcVol); err != nil {
+			klog.Warningf("failed to unmount src nfs server %s after cross-server copy: %v", srcVol.server, err)
+		}
+	}()
+	if err := cs.internalMount(ctx, dstVol, nil, dstVolCap); err != nil {
+		return true, status.Errorf(codes.Internal, "failed to mount dst nfs server %s: %v", dstVol.server, err)
+	}
+	defer func() {
+		if err := cs.internalUnmount(ctx, dstVol); err != nil {
+			klog.Warningf("failed to unmount dst nfs server %s after cross-server copy: %v", dstVol.server, err)
+		}
+	}()
+
+	// copy to a staging path and atomically rename it into place; this must
+	// happen while the destination mount above is held
+	stagingPath, err := prepareStagingDir(dstPath, mountPermissions)
+	if err != nil {
+		return true, err
+	}
+	srcPath := getInternalVolumePath(cs.Driver.workingMountDir, srcVol)
+	if snap != nil {
+		err = cs.restoreSnapshotArchive(ctx, req.GetSecrets(), snap, srcPath, stagingPath)
+	} else {
+		err = copyTreeVerified(srcPath, stagingPath, srcVol.id)
+	}
+	if err != nil {
+		return true, err
+	}
+	if err := finalizeStagingDir(stagingPath, dstPath); err != nil {
+		return true, err
+	}
+	klog.V(2).Infof("volume content copied from %s to %s", contentSourceShareKey(srcVol.server, srcVol.baseDir), dstPath)
+	return true, nil
+}
+
+// restoreSnapshotArchive extracts the snapshot archive in snapDir to
+// stagingPath. An archive missing in snapDir is first imported from the
+// object store if the secrets enable the snapshot export, as for a restore on
+// the same server. The archive checksum is verified before the extraction and
+// the extracted files are checked against the file count and size in the
+// manifest afterwards.
+func (cs *ControllerServer) restoreSnapshotArchive(ctx context.Context, secrets map[string]string, snap *nfsSnapshot, snapDir, stagingPath string) error {
+	if err := importSnapshot(ctx, secrets, snapDir, snap); err != nil {
+		return status.Errorf(codes.Internal, "failed to import snapshot archive: %v", err)
+	}
+	snapPath, manifest, err := resolveSnapshotArchive(snapDir, snap)
+	if err != nil {
+		return err
+	}
+
+	progress := startCopyProgress("restore of snapshot "+snap.id, manifest.UncompressedSizeBytes, func() int64 {
+		_, size, _ := dirUsage(stagingPath)
+		return size
+	})
+	err = unpackSnapshotArchive(snapPath, stagingPath, manifest, cs.Driver.useTarCommandInSnapshot)
+	progress.stop()
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to copy volume for snapshot: %v", err)
+	}
+
+	if manifest.SHA256 == "" {
+		// archives without a manifest carry no file count and size
+		return nil
+	}
+	files, size, err := dirUsage(stagingPath)
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to scan restored volume %s: %v", stagingPath, err)
+	}
+	if files != manifest.FileCount || size != manifest.UncompressedSizeBytes {
+		return status.Errorf(codes.DataLoss, "restored volume does not match snapshot %s: %d files of %d bytes, manifest expects %d files of %d bytes", snap.id, files, size, manifest.FileCount, manifest.UncompressedSizeBytes)
+	}
+	return nil
+}
+
+// copyTreeVerified copies the directory tree srcPath to dstPath preserving
+// modes, ownership and modification times. Every regular file is hashed while
+// it is streamed and read back from the destination to compare the checksums.
+// Hard links are copied as separate files; special files are skipped.
+func copyTreeVerified(srcPath, dstPath, what string) error {
+	_, total, err := dirUsage(srcPath)
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to scan source volume %s: %v", srcPath, err)
+	}
+	var copied atomic.Int64
+	progress := startCopyProgress("copy of volume "+what, total, copied.Load)
+	defer progress.stop()
+
+	err = copyTree(srcPath, dstPath, what, func(src, dst string, info fs.FileInfo) error {
+		return copyFileVerified(src, dst, info.Mode().Perm(), &copied)
+	})
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to copy volume %s: %v", what, err)
+	}
+	return nil
+}
+
+// copyTree copies the directory tree srcPath to dstPath preserving modes,
+// ownership and modification times, using copyFile for the regular files.
+func copyTree(srcPath, dstPath, what string, copyFile func(src, dst string, info fs.FileInfo) error) error {
+	var dirs []string
+	err := filepath.WalkDir(srcPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(srcPath, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dstPath, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case d.IsDir():
+			if rel != "." {
+				if err := os.Mkdir(target, 0o700); err != nil {
+					return err
+				}
+			}
+			// times and modes of directories are restored once their content
+			// is copied
+			dirs = append(dirs, rel)
+			return nil
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			if err := os.Symlink(link, target); err != nil {
+				return err
+			}
+		case d.Type().IsRegular():
+			if err := copyFile(path, target, info); err != nil {
+				return err
+			}
+		default:
+			klog.Warningf("skipping special file %s while copying volume %s", path, what)
+			return nil
+		}
+		return copyMetadata(target, info)
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		info, err := os.Lstat(filepath.Join(srcPath, dirs[i]))
+		if err != nil {
+			return err
+		}
+		if err := copyMetadata(filepath.Join(dstPath, dirs[i]), info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFileVerified(srcPath, dstPath string, perm fs.FileMode, copied *atomic.Int64) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(dstPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+
+	h := sha256.New()
+	_, err = io.Copy(dst, &countingReader{r: io.TeeReader(src, h), n: copied})
+	if err == nil {
+		// an NFS write error may only be reported on close
+		err = dst.Close()
+	} else {
+		dst.Close()
+	}
+	if err != nil {
+		return err
+	}
+
+	checksum, err := fileSHA256(dstPath)
+	if err != nil {
+		return err
+	}
+	if expected := hex.EncodeToString(h.Sum(nil)); checksum != expected {
+		return fmt.Errorf("checksum mismatch for %s: sha256 is %s, source has %s", dstPath, checksum, expected)
+	}
+	return nil
+}
+
+// copyMetadata applies the ownership, mode and modification time of info to path.
+func copyMetadata(path string, info fs.FileInfo) error {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		if err := os.Lchown(path, int(st.Uid), int(st.Gid)); err != nil && !errors.Is(err, fs.ErrPermission) {
+			return err
+		}
+	}
+	if info.Mode()&fs.ModeSymlink != 0 {
+		return nil
+	}
+	if err := os.Chmod(path, info.Mode()&(fs.ModePerm|fs.ModeSetuid|fs.ModeSetgid|fs.ModeSticky)); err != nil {
+		return err
+	}
+	return os.Chtimes(path, info.ModTime(), info.ModTime())
+}
+
+type countingReader struct {
+	r io.Reader
+	n *atomic.Int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+// dirUsage returns the number and the total size of the regular files under path.
+func dirUsage(path string) (files, size int64, err error) {
+	err = filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files++
+		size += info.Size()
+		return nil
+	})
+	return files, size, err
+}
+
+// copyProgress periodically logs the progress of a long copy.
+type copyProgress struct {
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+func startCopyProgress(what string, total int64, current func() int64) *copyProgress {
+	p := &copyProgress{done: make(chan struct{}), stopped: make(chan struct{})}
+	start := time.Now()
+	go func() {
+		defer close(p.stopped)
+		ticker := time.NewTicker(copyProgressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.done:
+				klog.V(2).Infof("%s: completed in %s", what, time.Since(start).Round(time.Second))
+				return
+			case <-ticker.C:
+				n := current()
+				if total <= 0 {
+					// the size is not known in advance
+					klog.V(2).Infof("%s: %d bytes copied in %s", what, n, time.Since(start).Round(time.Second))
+					continue
+				}
+				percent := float64(n) * 100 / float64(total)
+				klog.V(2).Infof("%s: %d of %d bytes copied (%.1f%%) in %s", what, n, total, percent, time.Since(start).Round(time.Second))
+			}
+		}
+	}()
+	return p
+}
+
+func (p *copyProgress) stop() {
+	close(p.done)
+	<-p.stopped
+}
@@ -0,0 +1,195 @@
+/*
+Copyright 2026 Flant JSC
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nfs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestGetContentSourceMountOptions(t *testing.T) {
+	dstVol := &nfsVolume{server: "new-filer", baseDir: "export"}
+	secrets := map[string]string{
+		contentSourceMountOptionsKey: `{"old-filer:/data":"nfsvers=3,hard"}`,
+	}
+
+	tests := []struct {
+		desc         string
+		secrets      map[string]string
+		srcVol       *nfsVolume
+		mountOptions string
+		ok           bool
+		code         codes.Code
+	}{
+		{
+			desc:    "same share",
+			secrets: secrets,
+			srcVol:  &nfsVolume{server: "new-filer", baseDir: "/export"},
+		},
+		{
+			desc:   "no shares listed",
+			srcVol: &nfsVolume{server: "old-filer", baseDir: "data"},
+		},
+		{
+			desc:         "compatible share",
+			secrets:      secrets,
+			srcVol:       &nfsVolume{server: "old-filer", baseDir: "data"},
+			mountOptions: "nfsvers=3,hard",
+			ok:           true,
+		},
+		{
+			desc:    "unknown share",
+			secrets: secrets,
+			srcVol:  &nfsVolume{server: "old-filer", baseDir: "other"},
+			code:    codes.FailedPrecondition,
+		},
+	}
+
+	for _, test := range tests {
+		mountOptions, ok, err := getContentSourceMountOptions(test.secrets, test.srcVol, dstVol)
+		if status.Code(err) != test.code {
+			t.Errorf("[%s] unexpected error: %v", test.desc, err)
+		}
+		if mountOptions != test.mountOptions || ok != test.ok {
+			t.Errorf("[%s] got %q, %v, want %q, %v", test.desc, mountOptions, ok, test.mountOptions, test.ok)
+		}
+	}
+}
+
+func TestCopyTreeVerified(t *testing.T) {
+	src := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(src, "dir", "nested"), 0755); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "dir", "nested", "file"), []byte("data"), 0640); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if err := os.Symlink("dir/nested/file", filepath.Join(src, "link")); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+	mtime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(src, "dir", "nested", "file"), mtime, mtime); err != nil {
+		t.Fatalf("failed to set times: %v", err)
+	}
+	if err := os.Chmod(filepath.Join(src, "dir"), 0555); err != nil {
+		t.Fatalf("failed to chmod: %v", err)
+	}
+	defer os.Chmod(filepath.Join(src, "dir"), 0755)
+
+	dst := filepath.Join(t.TempDir(), "dst")
+	if err := os.Mkdir(dst, 0777); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+	defer os.Chmod(filepath.Join(dst, "dir"), 0755)
+	if err := copyTreeVerified(src, dst, "test"); err != nil {
+		t.Fatalf("copyTreeVerified failed: %v", err)
+	}
+
+	fi, err := os.Stat(filepath.Join(dst, "dir", "nested", "file"))
+	if err != nil {
+		t.Fatalf("file not copied: %v", err)
+	}
+	if fi.Mode().Perm() != 0640 || !fi.ModTime().Equal(mtime) {
+		t.Errorf("file metadata not preserved: %v %v", fi.Mode(), fi.ModTime())
+	}
+	if data, _ := os.ReadFile(filepath.Join(dst, "dir", "nested", "file")); string(data) != "data" {
+		t.Errorf("unexpected file content %q", data)
+	}
+	if link, err := os.Readlink(filepath.Join(dst, "link")); err != nil || link != "dir/nested/file" {
+		t.Errorf("symlink not copied: %q, %v", link, err)
+	}
+	if di, err := os.Stat(filepath.Join(dst, "dir")); err != nil || di.Mode().Perm() != 0555 {
+		t.Errorf("directory mode not preserved: %v", err)
+	}
+}
+
+func TestRestoreSnapshotArchiveVerifiesContent(t *testing.T) {
+	src := t.TempDir()
+	if err := os.WriteFile(filepath.Join(src, "file"), []byte("snapshot-data"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	snapDir := t.TempDir()
+	snap := &nfsSnapshot{id: "snap-id", uuid: "snap-uuid", src: "src-pv"}
+	archivePath := filepath.Join(snapDir, snapshotArchiveName(snap, snapshotCompressionGzip))
+	if err := createSnapshotArchive(src, snapDir, archivePath, snap, snapshotCompressionGzip, false); err != nil {
+		t.Fatalf("createSnapshotArchive failed: %v", err)
+	}
+	cs := &ControllerServer{Driver: &Driver{}}
+
+	staging := t.TempDir()
+	if err := cs.restoreSnapshotArchive(context.Background(), nil, snap, snapDir, staging); err != nil {
+		t.Fatalf("restoreSnapshotArchive failed: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(staging, "file")); string(data) != "snapshot-data" {
+		t.Errorf("unexpected file content %q", data)
+	}
+
+	// a leftover file in the staging directory makes the restored volume differ
+	staging = t.TempDir()
+	if err := os.WriteFile(filepath.Join(staging, "leftover"), []byte("x"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if err := cs.restoreSnapshotArchive(context.Background(), nil, snap, snapDir, staging); status.Code(err) != codes.DataLoss {
+		t.Errorf("expected DataLoss, got %v", err)
+	}
+}
+
+func TestRestoreSnapshotArchiveImportsMissingArchive(t *testing.T) {
+	ctx := context.Background()
+	_, srv := newFakeS3(t)
+	secrets := map[string]string{
+		snapshotExportS3EndpointKey:        srv.URL,
+		snapshotExportS3BucketKey:          "bucket",
+		snapshotExportS3AccessKeyIDKey:     "access",
+		snapshotExportS3SecretAccessKeyKey: "secret",
+	}
+	src := t.TempDir()
+	if err := os.WriteFile(filepath.Join(src, "file"), []byte("snapshot-data"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	snapDir := filepath.Join(t.TempDir(), "snap-uuid")
+	if err := os.MkdirAll(snapDir, 0777); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+	snap := &nfsSnapshot{id: "snap-id", server: "nfs-server", baseDir: "share", uuid: "snap-uuid", src: "src-pv"}
+	archivePath := filepath.Join(snapDir, snapshotArchiveName(snap, snapshotCompressionGzip))
+	if err := createSnapshotArchive(src, snapDir, archivePath, snap, snapshotCompressionGzip, false); err != nil {
+		t.Fatalf("createSnapshotArchive failed: %v", err)
+	}
+	if err := exportSnapshot(ctx, secrets, snapDir, snap); err != nil {
+		t.Fatalf("exportSnapshot failed: %v", err)
+	}
+	cs := &ControllerServer{Driver: &Driver{}}
+
+	// the snapshot share of the other server is lost
+	if err := os.RemoveAll(snapDir); err != nil {
+		t.Fatalf("failed to remove snapshot dir: %v", err)
+	}
+	if err := cs.restoreSnapshotArchive(ctx, nil, snap, snapDir, t.TempDir()); err == nil {
+		t.Fatal("expected the restore of a missing archive to fail without the snapshot export")
+	}
+	staging := t.TempDir()
+	if err := cs.restoreSnapshotArchive(ctx, secrets, snap, snapDir, staging); err != nil {
+		t.Fatalf("restoreSnapshotArchive failed: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(staging, "file")); string(data) != "snapshot-data" {
+		t.Errorf("unexpected file content %q", data)
+	}
+}
@@ -0,0 +1,631 @@
+/*
+Copyright 2026 Flant JSC
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// listCacheTTL is how long a listing of the shares is reused: listing
+	// mounts every share, and the sidecars page through it in a burst
+	listCacheTTL = time.Minute
+
+	// listScanDir is the directory under the working mount dir where the
+	// shares are mounted for the listing
+	listScanDir = ".list"
+)
+
+// listingControllerServer implements ListVolumes, ListSnapshots and
+// ControllerGetVolume by listing the volume directories and snapshot archives
+// on the shares of the storage classes of the driver. The volumes are
+// reported with their condition for the external-health-monitor.
+type listingControllerServer struct {
+	csi.ControllerServer
+	driver *Driver
+
+	mu     sync.Mutex
+	lister *shareLister
+}
+
+// shareLister lists the shares and caches the result for listCacheTTL.
+type shareLister struct {
+	driverName string
+	kubeClient kubernetes.Interface
+	mountRoot  string
+	mount      func(ctx context.Context, target string, share *orphanShare, readOnly bool) error
+	unmount    func(ctx context.Context, target string, share *orphanShare)
+	now        func() time.Time
+
+	mu         sync.Mutex
+	generation uint64
+	listing    *shareListing
+}
+
+// shareListing is the content of the shares at a point in time. The page
+// tokens refer to its generation, so a listing refreshed in the middle of a
+// pagination aborts it instead of skipping or repeating entries.
+type shareListing struct {
+	generation uint64
+	created    time.Time
+	volumes    []*csi.ListVolumesResponse_Entry
+	snapshots  []listedSnapshot
+	byID       map[string]*csi.ListVolumesResponse_Entry
+}
+
+// listedSnapshot is a snapshot archive with the fields the ListSnapshots
+// filters match.
+type listedSnapshot struct {
+	shareKey string
+	uuid     string
+	src      string
+	entry    *csi.ListSnapshotsResponse_Entry
+}
+
+// listedPV is a PersistentVolume of the driver on a share.
+type listedPV struct {
+	handle   string
+	subDir   string
+	uuid     string
+	capacity int64
+}
+
+// controllerServerWithListing returns the controller server advertising
+// LIST_VOLUMES, LIST_SNAPSHOTS, GET_VOLUME and VOLUME_CONDITION. The client is created on the first call,
+// so the node plugins, which never list, do not need it.
+func (n *Driver) controllerServerWithListing(cs csi.ControllerServer) csi.ControllerServer {
+	return &listingControllerServer{ControllerServer: cs, driver: n}
+}
+
+func newShareLister(driverName string, kubeClient kubernetes.Interface, mountRoot string) *shareLister {
+	return &shareLister{
+		driverName: driverName,
+		kubeClient: kubeClient,
+		mountRoot:  mountRoot,
+		now:        time.Now,
+	}
+}
+
+func (cs *listingControllerServer) getLister() (*shareLister, error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if cs.lister != nil {
+		return cs.lister, nil
+	}
+	_, kubeClient, _, err := newInClusterClient(cs.driver.name, cs.driver.nodeID)
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to list the shares: %v", err)
+	}
+	cs.lister = newShareLister(cs.driver.name, kubeClient, filepath.Join(cs.driver.workingMountDir, listScanDir))
+	cs.lister.mount = cs.driver.mountShare
+	cs.lister.unmount = cs.driver.unmountShare
+	return cs.lister, nil
+}
+
+func (cs *listingControllerServer) ControllerGetCapabilities(ctx context.Context, req *csi.ControllerGetCapabilitiesRequest) (*csi.ControllerGetCapabilitiesResponse, error) {
+	resp, err := cs.ControllerServer.ControllerGetCapabilities(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+	// the response may share the capabilities of the driver, so they are copied
+	capabilities := append([]*csi.ControllerServiceCapability{}, resp.GetCapabilities()...)
+	for _, rpc := range []csi.ControllerServiceCapability_RPC_Type{
+		csi.ControllerServiceCapability_RPC_LIST_VOLUMES,
+		csi.ControllerServiceCapability_RPC_LIST_SNAPSHOTS,
+		csi.ControllerServiceCapability_RPC_GET_VOLUME,
+		csi.ControllerServiceCapability_RPC_VOLUME_CONDITION,
+	} {
+		if !hasControllerCapability(capabilities, rpc) {
+			capabilities = append(capabilities, &csi.ControllerServiceCapability{
+				Type: &csi.ControllerServiceCapability_Rpc{Rpc: &csi.ControllerServiceCapability_RPC{Type: rpc}},
+			})
+		}
+	}
+	return &csi.ControllerGetCapabilitiesResponse{Capabilities: capabilities}, nil
+}
+
+func hasControllerCapability(capabilities []*csi.ControllerServiceCapability, rpc csi.ControllerServiceCapability_RPC_Type) bool {
+	for _, c := range capabilities {
+		if c.GetRpc().GetType() == rpc {
+			return true
+		}
+	}
+	return false
+}
+
+// ListVolumes lists the volume directories on the shares: the ones of the
+// PersistentVolumes of the driver with their volume handles, and the other
+// pvc-<uuid> directories with the IDs CreateVolume would have given them.
+// The directory of a PersistentVolume missing or not accessible on the share
+// is listed with an abnormal condition.
+func (cs *listingControllerServer) ListVolumes(ctx context.Context, req *csi.ListVolumesRequest) (*csi.ListVolumesResponse, error) {
+	lister, err := cs.getLister()
+	if err != nil {
+		return nil, err
+	}
+	listing, offset, err := lister.get(ctx, req.GetStartingToken())
+	if err != nil {
+		return nil, err
+	}
+	start, end, next, err := listingPage(listing, offset, len(listing.volumes), req.GetMaxEntries())
+	if err != nil {
+		return nil, err
+	}
+	return &csi.ListVolumesResponse{Entries: listing.volumes[start:end], NextToken: next}, nil
+}
+
+// ControllerGetVolume returns the volume with its condition from the listing.
+// A volume missing in the listing, e.g. created after it, is looked up on its
+// share.
+func (cs *listingControllerServer) ControllerGetVolume(ctx context.Context, req *csi.ControllerGetVolumeRequest) (*csi.ControllerGetVolumeResponse, error) {
+	if req.GetVolumeId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "Volume ID missing in request")
+	}
+	lister, err := cs.getLister()
+	if err != nil {
+		return nil, err
+	}
+	listing, _, err := lister.get(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	entry, ok := listing.byID[req.GetVolumeId()]
+	if !ok {
+		if entry, err = lister.lookup(ctx, req.GetVolumeId()); err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to look up volume %s: %v", req.GetVolumeId(), err)
+		}
+	}
+	if entry == nil {
+		return nil, status.Errorf(codes.NotFound, "volume %s not found on the shares of the storage classes", req.GetVolumeId())
+	}
+	return &csi.ControllerGetVolumeResponse{
+		Volume: entry.GetVolume(),
+		Status: &csi.ControllerGetVolumeResponse_VolumeStatus{VolumeCondition: entry.GetStatus().GetVolumeCondition()},
+	}, nil
+}
+
+// ListSnapshots lists the snapshot archives on the shares. A snapshot ID or
+// source volume ID of another format than the ones of the driver matches no
+// snapshot.
+func (cs *listingControllerServer) ListSnapshots(ctx context.Context, req *csi.ListSnapshotsRequest) (*csi.ListSnapshotsResponse, error) {
+	lister, err := cs.getLister()
+	if err != nil {
+		return nil, err
+	}
+	listing, offset, err := lister.get(ctx, req.GetStartingToken())
+	if err != nil {
+		return nil, err
+	}
+
+	match := func(listedSnapshot) bool { return true }
+	if id := req.GetSnapshotId(); id != "" {
+		snap, err := getNfsSnapFromID(id)
+		if err != nil {
+			return &csi.ListSnapshotsResponse{}, nil
+		}
+		key := contentSourceShareKey(snap.server, snap.baseDir)
+		match = func(s listedSnapshot) bool { return s.shareKey == key && s.uuid == snap.uuid }
+	} else if id := req.GetSourceVolumeId(); id != "" {
+		vol, err := getNfsVolFromID(id)
+		if err != nil {
+			return &csi.ListSnapshotsResponse{}, nil
+		}
+		key, src := contentSourceShareKey(vol.server, vol.baseDir), snapshotSource(vol.subDir, vol.uuid)
+		match = func(s listedSnapshot) bool { return s.shareKey == key && s.src == src }
+	}
+	var entries []*csi.ListSnapshotsResponse_Entry
+	for _, s := range listing.snapshots {
+		if match(s) {
+			entries = append(entries, s.entry)
+		}
+	}
+
+	start, end, next, err := listingPage(listing, offset, len(entries), req.GetMaxEntries())
+	if err != nil {
+		return nil, err
+	}
+	return &csi.ListSnapshotsResponse{Entries: entries[start:end], NextToken: next}, nil
+}
+
+// listingPage returns the bounds of the page starting at offset and the
+// token of the next page.
+func listingPage(listing *shareListing, offset, total int, maxEntries int32) (int, int, string, error) {
+	if maxEntries < 0 {
+		return 0, 0, "", status.Errorf(codes.InvalidArgument, "max_entries must not be negative: %d", maxEntries)
+	}
+	if offset > total {
+		return 0, 0, "", status.Errorf(codes.Aborted, "starting_token %d is beyond the %d entries", offset, total)
+	}
+	end := total
+	if maxEntries > 0 && offset+int(maxEntries) < total {
+		end = offset + int(maxEntries)
+	}
+	next := ""
+	if end < total {
+		next = fmt.Sprintf("%d.%d", listing.generation, end)
+	}
+	return offset, end, next, nil
+}
+
+// get returns the listing the token refers to and the offset in it. A
+// listing older than listCacheTTL is refreshed unless a pagination is
+// continued.
+func (l *shareLister) get(ctx context.Context, token string) (*shareListing, int, error) {
+	var generation uint64
+	var offset int
+	if token != "" {
+		parts := strings.SplitN(token, ".", 2)
+		var err error
+		if len(parts) == 2 {
+			if generation, err = strconv.ParseUint(parts[0], 10, 64); err == nil {
+				offset, err = strconv.Atoi(parts[1])
+			}
+		}
+		if len(parts) != 2 || err != nil || offset < 0 {
+			return nil, 0, status.Errorf(codes.Aborted, "invalid starting_token %q", token)
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.listing == nil || (token == "" && l.now().Sub(l.listing.created) >= listCacheTTL) {
+		listing, err := l.list(ctx)
+		if err != nil {
+			return nil, 0, status.Errorf(codes.Internal, "failed to list the shares: %v", err)
+		}
+		l.generation++
+		listing.generation = l.generation
+		l.listing = listing
+	}
+	if token != "" && generation != l.listing.generation {
+		return nil, 0, status.Errorf(codes.Aborted, "starting_token %q refers to an outdated listing", token)
+	}
+	return l.listing, offset, nil
+}
+
+// list mounts the share of every storage class of the driver read-only and
+// lists it. The volumes of the PersistentVolumes on a share which cannot be
+// mounted are listed as abnormal, the ones on a share of no storage class
+// of the driver are listed without a check.
+func (l *shareLister) list(ctx context.Context) (*shareListing, error) {
+	scs, err := l.kubeClient.StorageV1().StorageClasses().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list storage classes: %v", err)
+	}
+	pvs, err := l.listPVs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	listing := &shareListing{created: l.now()}
+	listed := map[string]bool{}
+	for i := range scs.Items {
+		sc := &scs.Items[i]
+		if sc.Provisioner != l.driverName {
+			continue
+		}
+		share, err := getStorageClassShare(ctx, l.kubeClient, sc)
+		if err != nil {
+			klog.Errorf("listing: storage class %s: %v", sc.Name, err)
+			continue
+		}
+		key := contentSourceShareKey(share.server, share.share)
+		if listed[key] {
+			continue
+		}
+		listed[key] = true
+
+		volumes, snapshots, err := l.listShare(ctx, share, pvs[key])
+		if err != nil {
+			klog.Errorf("listing: storage class %s: %v", sc.Name, err)
+			condition := abnormalVolumeCondition("share %s:%s is not accessible: %v", share.server, share.share, err)
+			for _, pv := range pvs[key] {
+				volumes = append(volumes, pv.entry(condition))
+			}
+		}
+		listing.volumes = append(listing.volumes, volumes...)
+		listing.snapshots = append(listing.snapshots, snapshots...)
+	}
+	for key, sharePVs := range pvs {
+		if listed[key] {
+			continue
+		}
+		condition := normalVolumeCondition("not checked: no storage class of the driver uses the share")
+		for _, pv := range sharePVs {
+			listing.volumes = append(listing.volumes, pv.entry(condition))
+		}
+	}
+	sort.Slice(listing.volumes, func(i, j int) bool {
+		return listing.volumes[i].GetVolume().GetVolumeId() < listing.volumes[j].GetVolume().GetVolumeId()
+	})
+	sort.Slice(listing.snapshots, func(i, j int) bool {
+		return listing.snapshots[i].entry.GetSnapshot().GetSnapshotId() < listing.snapshots[j].entry.GetSnapshot().GetSnapshotId()
+	})
+	listing.byID = make(map[string]*csi.ListVolumesResponse_Entry, len(listing.volumes))
+	for _, v := range listing.volumes {
+		listing.byID[v.GetVolume().GetVolumeId()] = v
+	}
+	return listing, nil
+}
+
+// lookup returns the volume as the listing would report it, checking only
+// its own directory, or nil if the listing would not report it.
+func (l *shareLister) lookup(ctx context.Context, volumeID string) (*csi.ListVolumesResponse_Entry, error) {
+	vol, err := getNfsVolFromID(volumeID)
+	if err != nil {
+		return nil, nil
+	}
+	key := contentSourceShareKey(vol.server, vol.baseDir)
+	pvs, err := l.listPVs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var pv *listedPV
+	for i := range pvs[key] {
+		if pvs[key][i].handle == volumeID {
+			pv = &pvs[key][i]
+			break
+		}
+	}
+	subDir := strings.Trim(vol.subDir, "/")
+	if pv == nil && (!orphanVolumeName.MatchString(subDir) || volumeID != listedVolumeID(vol.server, vol.baseDir, subDir)) {
+		return nil, nil
+	}
+
+	scs, err := l.kubeClient.StorageV1().StorageClasses().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list storage classes: %v", err)
+	}
+	var share *orphanShare
+	for i := range scs.Items {
+		sc := &scs.Items[i]
+		if sc.Provisioner != l.driverName {
+			continue
+		}
+		if s, err := getStorageClassShare(ctx, l.kubeClient, sc); err == nil && contentSourceShareKey(s.server, s.share) == key {
+			share = s
+			break
+		}
+	}
+	if share == nil {
+		if pv == nil {
+			return nil, nil
+		}
+		return pv.entry(normalVolumeCondition("not checked: no storage class of the driver uses the share")), nil
+	}
+
+	// the share is mounted at the target of the listing
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	target := filepath.Join(l.mountRoot, share.storageClass)
+	if err := l.mount(ctx, target, share, true); err != nil {
+		if pv == nil {
+			return nil, fmt.Errorf("failed to mount share: %v", err)
+		}
+		return pv.entry(abnormalVolumeCondition("share %s:%s is not accessible: %v", share.server, share.share, err)), nil
+	}
+	defer l.unmount(ctx, target, share)
+	if pv != nil {
+		return pv.entry(volumeDirCondition(filepath.Join(target, pv.subDir))), nil
+	}
+	info, err := os.Stat(filepath.Join(target, subDir))
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && !info.IsDir()) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &csi.ListVolumesResponse_Entry{
+		Volume: &csi.Volume{VolumeId: volumeID},
+		Status: &csi.ListVolumesResponse_VolumeStatus{VolumeCondition: normalVolumeCondition("volume directory is not used by any persistent volume")},
+	}, nil
+}
+
+// listPVs returns the PersistentVolumes of the driver keyed by the share.
+func (l *shareLister) listPVs(ctx context.Context) (map[string][]listedPV, error) {
+	pvs, err := l.kubeClient.CoreV1().PersistentVolumes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list persistent volumes: %v", err)
+	}
+	byShare := map[string][]listedPV{}
+	for _, pv := range pvs.Items {
+		if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != l.driverName {
+			continue
+		}
+		vol, err := getNfsVolFromID(pv.Spec.CSI.VolumeHandle)
+		if err != nil {
+			klog.Warningf("listing: persistent volume %s: %v", pv.Name, err)
+			continue
+		}
+		key := contentSourceShareKey(vol.server, vol.baseDir)
+		capacity := pv.Spec.Capacity[corev1.ResourceStorage]
+		byShare[key] = append(byShare[key], listedPV{
+			handle:   pv.Spec.CSI.VolumeHandle,
+			subDir:   strings.Trim(vol.subDir, "/"),
+			uuid:     vol.uuid,
+			capacity: capacity.Value(),
+		})
+	}
+	return byShare, nil
+}
+
+func (l *shareLister) listShare(ctx context.Context, share *orphanShare, pvs []listedPV) ([]*csi.ListVolumesResponse_Entry, []listedSnapshot, error) {
+	target := filepath.Join(l.mountRoot, share.storageClass)
+	if err := l.mount(ctx, target, share, true); err != nil {
+		return nil, nil, fmt.Errorf("failed to mount share: %v", err)
+	}
+	defer l.unmount(ctx, target, share)
+	return listShare(target, share.server, share.share, pvs)
+}
+
+func (pv listedPV) entry(condition *csi.VolumeCondition) *csi.ListVolumesResponse_Entry {
+	return &csi.ListVolumesResponse_Entry{
+		Volume: &csi.Volume{VolumeId: pv.handle, CapacityBytes: pv.capacity},
+		Status: &csi.ListVolumesResponse_VolumeStatus{VolumeCondition: condition},
+	}
+}
+
+// snapshotSource is the name of the archive of the snapshots of a volume,
+// see newNFSSnapshot.
+func snapshotSource(subDir, uuid string) string {
+	if uuid != "" {
+		return uuid
+	}
+	return subDir
+}
+
+// listedVolumeID and listedSnapshotID return the IDs CreateVolume and
+// CreateSnapshot give to the directories and archives on the share.
+func listedVolumeID(server, baseDir, subDir string) string {
+	return strings.Join([]string{strings.Trim(server, "/"), strings.Trim(baseDir, "/"), subDir, "", ""}, separator)
+}
+
+func listedSnapshotID(server, baseDir, uuid, src string) string {
+	return strings.Join([]string{strings.Trim(server, "/"), strings.Trim(baseDir, "/"), uuid, uuid, src}, separator)
+}
+
+// listShare lists the volume directories and snapshot archives in the share
+// root: the directories of the PersistentVolumes of the share with their
+// condition, the other pvc-<uuid> directories and the snapshot-<uuid>
+// archives.
+func listShare(root, server, baseDir string, pvs []listedPV) ([]*csi.ListVolumesResponse_Entry, []listedSnapshot, error) {
+	var volumes []*csi.ListVolumesResponse_Entry
+	sources := map[string]string{}
+	referenced := map[string]bool{}
+	for _, pv := range pvs {
+		sources[snapshotSource(pv.subDir, pv.uuid)] = pv.handle
+		referenced[pv.subDir] = true
+		volumes = append(volumes, pv.entry(volumeDirCondition(filepath.Join(root, pv.subDir))))
+	}
+
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, nil, err
+	}
+	var snapshots []listedSnapshot
+	for _, entry := range entries {
+		name := entry.Name()
+		switch {
+		case !entry.IsDir() || referenced[name]:
+		case orphanVolumeName.MatchString(name):
+			volumes = append(volumes, &csi.ListVolumesResponse_Entry{
+				Volume: &csi.Volume{VolumeId: listedVolumeID(server, baseDir, name)},
+				Status: &csi.ListVolumesResponse_VolumeStatus{VolumeCondition: normalVolumeCondition("volume directory is not used by any persistent volume")},
+			})
+		case orphanSnapshotName.MatchString(name):
+			snapshot, err := readListedSnapshot(filepath.Join(root, name), name)
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			} else if err != nil {
+				return nil, nil, fmt.Errorf("failed to read snapshot %s: %v", name, err)
+			}
+			if snapshot == nil {
+				continue
+			}
+			sourceVolumeID, ok := sources[snapshot.src]
+			if !ok {
+				sourceVolumeID = listedVolumeID(server, baseDir, snapshot.src)
+			}
+			snapshot.shareKey = contentSourceShareKey(server, baseDir)
+			snapshot.entry.Snapshot.SnapshotId = listedSnapshotID(server, baseDir, name, snapshot.src)
+			snapshot.entry.Snapshot.SourceVolumeId = sourceVolumeID
+			snapshots = append(snapshots, *snapshot)
+		}
+	}
+	return volumes, snapshots, nil
+}
+
+// readListedSnapshot reads the snapshot directory: the source volume is
+// named by the manifest or the archive. The snapshot is ready once the
+// archive is renamed into place and its background job is done. A directory
+// without an archive is not listed.
+func readListedSnapshot(dir, uuid string) (*listedSnapshot, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	src, archiveName := "", ""
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), populatingSuffix)
+		if base, ok := strings.CutSuffix(name, snapshotManifestSuffix); ok {
+			src = base
+			continue
+		}
+		for _, suffix := range []string{gzipArchiveSuffix, ".tar.zst", ".tar"} {
+			if base, ok := strings.CutSuffix(name, suffix); ok && archiveName == "" {
+				src, archiveName = base, name
+				break
+			}
+		}
+	}
+	if src == "" {
+		return nil, nil
+	}
+
+	snap := &nfsSnapshot{uuid: uuid, src: src}
+	manifest, err := readSnapshotManifest(dir, snap)
+	if err != nil {
+		return nil, err
+	}
+	// the manifest of an archive in another format than gzip may not be
+	// written yet
+	if _, err := os.Stat(filepath.Join(dir, snapshotManifestName(snap))); errors.Is(err, fs.ErrNotExist) && archiveName != "" {
+		manifest.Archive = archiveName
+	}
+	ready := true
+	archive, err := os.Stat(filepath.Join(dir, manifest.Archive))
+	if errors.Is(err, fs.ErrNotExist) {
+		ready = false
+		if archive, err = os.Stat(filepath.Join(dir, manifest.Archive+populatingSuffix)); errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	creationTime := manifest.CreationTime
+	if creationTime.IsZero() {
+		creationTime = archive.ModTime()
+	}
+	return &listedSnapshot{
+		uuid: uuid,
+		src:  src,
+		entry: &csi.ListSnapshotsResponse_Entry{
+			Snapshot: &csi.Snapshot{
+				SizeBytes:    archive.Size(),
+				CreationTime: timestamppb.New(creationTime),
+				ReadyToUse:   ready && !isSnapshotJobRunning(uuid),
+			},
+		},
+	}, nil
+}
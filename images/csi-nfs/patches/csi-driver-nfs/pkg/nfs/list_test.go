@@ -0,0 +1,331 @@
+/*
+Copyright 2026 Flant JSC
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nfs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const testNestedVolume = "ns/pvc-00000000-0000-0000-0000-000000000008"
+
+// createTestSnapshot creates a snapshot directory with the archive of src,
+// with its manifest if complete.
+func createTestSnapshot(t *testing.T, root, uuid, archive string, complete bool, created time.Time) {
+	dir := filepath.Join(root, uuid)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("failed to create %s: %v", dir, err)
+	}
+	name := archive
+	if !complete {
+		name += populatingSuffix
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), make([]byte, 1024), 0644); err != nil {
+		t.Fatalf("failed to write archive: %v", err)
+	}
+	if complete {
+		src := strings.TrimSuffix(archive, filepath.Ext(archive))
+		src = strings.TrimSuffix(src, ".tar")
+		manifestPath := filepath.Join(dir, snapshotManifestName(&nfsSnapshot{uuid: uuid, src: src}))
+		if err := writeSnapshotManifest(manifestPath, &snapshotManifest{Archive: archive, CreationTime: created}); err != nil {
+			t.Fatalf("failed to write manifest: %v", err)
+		}
+	}
+}
+
+// testControllerServer is the controller server the listing wraps.
+type testControllerServer struct {
+	csi.ControllerServer
+}
+
+func (testControllerServer) ControllerGetCapabilities(context.Context, *csi.ControllerGetCapabilitiesRequest) (*csi.ControllerGetCapabilitiesResponse, error) {
+	return &csi.ControllerGetCapabilitiesResponse{Capabilities: []*csi.ControllerServiceCapability{{
+		Type: &csi.ControllerServiceCapability_Rpc{Rpc: &csi.ControllerServiceCapability_RPC{Type: csi.ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME}},
+	}}}, nil
+}
+
+func testPV(name, handle string, capacity string) *corev1.PersistentVolume {
+	return &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: corev1.PersistentVolumeSpec{
+			Capacity: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(capacity)},
+			PersistentVolumeSource: corev1.PersistentVolumeSource{
+				CSI: &corev1.CSIPersistentVolumeSource{Driver: "nfs.csi.k8s.io", VolumeHandle: handle},
+			},
+		},
+	}
+}
+
+func TestListShare(t *testing.T) {
+	root := t.TempDir()
+	now := time.Now()
+	createTestShare(t, root, now, map[string]time.Duration{
+		testUsedVolume:      time.Hour,
+		testOldVolume:       time.Hour,
+		testNestedVolume:    time.Hour,
+		testPopulatingClone: time.Hour,
+		"backup":            time.Hour,
+		"archived-" + testOldVolume + "-20260101T000000Z": time.Hour,
+	})
+	created := now.Add(-time.Hour).UTC().Truncate(time.Second)
+	createTestSnapshot(t, root, testUsedSnapshot, testUsedVolume+gzipArchiveSuffix, true, created)
+	createTestSnapshot(t, root, testOldSnapshot, testOldVolume+".tar.zst", false, time.Time{})
+	if err := os.MkdirAll(filepath.Join(root, "snapshot-00000000-0000-0000-0000-000000000009"), 0755); err != nil {
+		t.Fatalf("failed to create empty snapshot: %v", err)
+	}
+
+	pvs := []listedPV{
+		{handle: "server#share#" + testUsedVolume + "##archive", subDir: testUsedVolume, capacity: 1 << 30},
+		{handle: "server#share#" + testNestedVolume + "#" + testNewVolume + "#", subDir: testNestedVolume, uuid: testNewVolume, capacity: 2 << 30},
+		// deleted from the share by hand
+		{handle: "server#share#pvc-00000000-0000-0000-0000-00000000000a##", subDir: "pvc-00000000-0000-0000-0000-00000000000a"},
+	}
+	volumes, snapshots, err := listShare(root, "server", "/share", pvs)
+	if err != nil {
+		t.Fatalf("listShare failed: %v", err)
+	}
+
+	var got []string
+	for _, v := range volumes {
+		got = append(got, fmt.Sprintf("%s=%s:%t", v.GetVolume().GetVolumeId(),
+			resource.NewQuantity(v.GetVolume().GetCapacityBytes(), resource.BinarySI), v.GetStatus().GetVolumeCondition().GetAbnormal()))
+	}
+	expected := []string{
+		"server#share#" + testUsedVolume + "##archive=1Gi:false",
+		"server#share#" + testNestedVolume + "#" + testNewVolume + "#=2Gi:false",
+		"server#share#pvc-00000000-0000-0000-0000-00000000000a##=0:true",
+		"server#share#" + testOldVolume + "##=0:false",
+	}
+	if strings.Join(got, " ") != strings.Join(expected, " ") {
+		t.Errorf("expected volumes %v, got %v", expected, got)
+	}
+
+	if len(snapshots) != 2 {
+		t.Fatalf("expected 2 snapshots, got %d", len(snapshots))
+	}
+	byUUID := map[string]*csi.Snapshot{}
+	for _, s := range snapshots {
+		byUUID[s.uuid] = s.entry.GetSnapshot()
+	}
+	used := byUUID[testUsedSnapshot]
+	if used.GetSnapshotId() != "server#share#"+testUsedSnapshot+"#"+testUsedSnapshot+"#"+testUsedVolume ||
+		used.GetSourceVolumeId() != pvs[0].handle || used.GetSizeBytes() != 1024 || !used.GetReadyToUse() ||
+		!used.GetCreationTime().AsTime().Equal(created) {
+		t.Errorf("unexpected snapshot %v", used)
+	}
+	old := byUUID[testOldSnapshot]
+	if old.GetSnapshotId() != "server#share#"+testOldSnapshot+"#"+testOldSnapshot+"#"+testOldVolume ||
+		old.GetSourceVolumeId() != "server#share#"+testOldVolume+"##" || old.GetReadyToUse() {
+		t.Errorf("unexpected snapshot %v", old)
+	}
+}
+
+func TestListingControllerServer(t *testing.T) {
+	const driverName = "nfs.csi.k8s.io"
+	now := time.Now()
+	mountRoot := t.TempDir()
+	createTestShare(t, filepath.Join(mountRoot, "nfs"), now, map[string]time.Duration{
+		testUsedVolume: time.Hour,
+		testOldVolume:  time.Hour,
+		testNewVolume:  time.Hour,
+	})
+	createTestSnapshot(t, filepath.Join(mountRoot, "nfs"), testUsedSnapshot, testUsedVolume+gzipArchiveSuffix, true, now)
+	createTestSnapshot(t, filepath.Join(mountRoot, "nfs"), testOldSnapshot, testOldVolume+gzipArchiveSuffix, true, now)
+
+	sc := func(name string) *storagev1.StorageClass {
+		return &storagev1.StorageClass{
+			ObjectMeta:  metav1.ObjectMeta{Name: name},
+			Provisioner: driverName,
+			Parameters:  map[string]string{paramServer: "server", paramShare: "/share"},
+		}
+	}
+	const (
+		missingVolumeID = "server#share#pvc-00000000-0000-0000-0000-00000000000b##"
+		// the storage class of the share was deleted
+		otherVolumeID = "other#share#pvc-00000000-0000-0000-0000-00000000000c##"
+	)
+	kubeClient := fake.NewSimpleClientset(sc("nfs"), sc("nfs-copy"),
+		testPV(testUsedVolume, "server#share#"+testUsedVolume+"##", "1Gi"),
+		testPV("missing", missingVolumeID, "1Gi"),
+		testPV("other", otherVolumeID, "1Gi"))
+
+	lister := newShareLister(driverName, kubeClient, mountRoot)
+	lister.now = func() time.Time { return now }
+	var mounts []string
+	lister.mount = func(_ context.Context, target string, share *orphanShare, readOnly bool) error {
+		mounts = append(mounts, filepath.Base(target)+":"+map[bool]string{true: "ro", false: "rw"}[readOnly])
+		return nil
+	}
+	lister.unmount = func(context.Context, string, *orphanShare) {}
+	cs := &listingControllerServer{ControllerServer: testControllerServer{}, lister: lister}
+	ctx := context.Background()
+
+	caps, err := cs.ControllerGetCapabilities(ctx, &csi.ControllerGetCapabilitiesRequest{})
+	if err != nil {
+		t.Fatalf("ControllerGetCapabilities failed: %v", err)
+	}
+	for _, rpc := range []csi.ControllerServiceCapability_RPC_Type{
+		csi.ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME,
+		csi.ControllerServiceCapability_RPC_LIST_VOLUMES,
+		csi.ControllerServiceCapability_RPC_LIST_SNAPSHOTS,
+		csi.ControllerServiceCapability_RPC_GET_VOLUME,
+		csi.ControllerServiceCapability_RPC_VOLUME_CONDITION,
+	} {
+		if !hasControllerCapability(caps.GetCapabilities(), rpc) {
+			t.Errorf("capability %v not advertised", rpc)
+		}
+	}
+
+	// the classes on the same share are listed once
+	var ids []string
+	token := ""
+	for pages := 0; ; pages++ {
+		resp, err := cs.ListVolumes(ctx, &csi.ListVolumesRequest{MaxEntries: 2, StartingToken: token})
+		if err != nil {
+			t.Fatalf("ListVolumes failed: %v", err)
+		}
+		if len(resp.GetEntries()) > 2 || pages > 2 {
+			t.Fatalf("unexpected page %v", resp)
+		}
+		for _, e := range resp.GetEntries() {
+			ids = append(ids, e.GetVolume().GetVolumeId())
+		}
+		if token = resp.GetNextToken(); token == "" {
+			break
+		}
+	}
+	if len(ids) != 5 || len(mounts) != 1 || mounts[0] != "nfs:ro" {
+		t.Errorf("unexpected volumes %v listed with mounts %v", ids, mounts)
+	}
+
+	if _, err := cs.ListVolumes(ctx, &csi.ListVolumesRequest{StartingToken: "invalid"}); status.Code(err) != codes.Aborted {
+		t.Errorf("expected Aborted for an invalid token, got %v", err)
+	}
+	if _, err := cs.ListVolumes(ctx, &csi.ListVolumesRequest{MaxEntries: -1}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument for negative max_entries, got %v", err)
+	}
+
+	for id, abnormal := range map[string]bool{"server#share#" + testUsedVolume + "##": false, missingVolumeID: true, otherVolumeID: false} {
+		resp, err := cs.ControllerGetVolume(ctx, &csi.ControllerGetVolumeRequest{VolumeId: id})
+		if err != nil {
+			t.Fatalf("ControllerGetVolume failed: %v", err)
+		}
+		if resp.GetVolume().GetVolumeId() != id || resp.GetStatus().GetVolumeCondition().GetAbnormal() != abnormal {
+			t.Errorf("unexpected volume %v", resp)
+		}
+	}
+	if _, err := cs.ControllerGetVolume(ctx, &csi.ControllerGetVolumeRequest{VolumeId: "server#share#pvc-unknown##"}); status.Code(err) != codes.NotFound {
+		t.Errorf("expected NotFound for an unknown volume, got %v", err)
+	}
+
+	// the volumes created after the listing are looked up on the share
+	const (
+		createdVolume   = "pvc-00000000-0000-0000-0000-00000000000d"
+		createdVolumeID = "server#share#" + createdVolume + "#" + createdVolume + "#"
+		newDirVolume    = "pvc-00000000-0000-0000-0000-00000000000e"
+	)
+	for _, dir := range []string{createdVolume, newDirVolume} {
+		if err := os.Mkdir(filepath.Join(mountRoot, "nfs", dir), 0o777); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := kubeClient.CoreV1().PersistentVolumes().Create(ctx, testPV("created", createdVolumeID, "2Gi"), metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for id, capacity := range map[string]int64{createdVolumeID: 2 << 30, "server#share#" + newDirVolume + "##": 0} {
+		resp, err := cs.ControllerGetVolume(ctx, &csi.ControllerGetVolumeRequest{VolumeId: id})
+		if err != nil {
+			t.Fatalf("ControllerGetVolume of a volume created after the listing failed: %v", err)
+		}
+		if resp.GetVolume().GetVolumeId() != id || resp.GetVolume().GetCapacityBytes() != capacity || resp.GetStatus().GetVolumeCondition().GetAbnormal() {
+			t.Errorf("unexpected volume %v", resp)
+		}
+	}
+	if _, err := cs.ControllerGetVolume(ctx, &csi.ControllerGetVolumeRequest{VolumeId: "server#share#pvc-00000000-0000-0000-0000-00000000000f##"}); status.Code(err) != codes.NotFound {
+		t.Errorf("expected NotFound for a volume missing on the share, got %v", err)
+	}
+
+	// a token of an outdated listing
+	resp, err := cs.ListVolumes(ctx, &csi.ListVolumesRequest{MaxEntries: 1})
+	if err != nil {
+		t.Fatalf("ListVolumes failed: %v", err)
+	}
+	lister.now = func() time.Time { return now.Add(2 * listCacheTTL) }
+	if _, err := cs.ListVolumes(ctx, &csi.ListVolumesRequest{}); err != nil {
+		t.Fatalf("ListVolumes failed: %v", err)
+	}
+	if _, err := cs.ListVolumes(ctx, &csi.ListVolumesRequest{StartingToken: resp.GetNextToken()}); status.Code(err) != codes.Aborted {
+		t.Errorf("expected Aborted for an outdated token, got %v", err)
+	}
+
+	for _, test := range []struct {
+		name     string
+		req      *csi.ListSnapshotsRequest
+		expected []string
+	}{
+		{
+			name:     "all",
+			req:      &csi.ListSnapshotsRequest{},
+			expected: []string{testUsedSnapshot, testOldSnapshot},
+		},
+		{
+			name:     "by snapshot id",
+			req:      &csi.ListSnapshotsRequest{SnapshotId: "server#share#" + testOldSnapshot + "#" + testOldSnapshot + "#" + testOldVolume},
+			expected: []string{testOldSnapshot},
+		},
+		{
+			name:     "by snapshot id of another share",
+			req:      &csi.ListSnapshotsRequest{SnapshotId: "server#other#" + testOldSnapshot + "#" + testOldSnapshot + "#" + testOldVolume},
+			expected: nil,
+		},
+		{
+			name:     "by invalid snapshot id",
+			req:      &csi.ListSnapshotsRequest{SnapshotId: "invalid"},
+			expected: nil,
+		},
+		{
+			name:     "by source volume id",
+			req:      &csi.ListSnapshotsRequest{SourceVolumeId: "server#share#" + testUsedVolume + "##"},
+			expected: []string{testUsedSnapshot},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			resp, err := cs.ListSnapshots(ctx, test.req)
+			if err != nil {
+				t.Fatalf("ListSnapshots failed: %v", err)
+			}
+			var got []string
+			for _, e := range resp.GetEntries() {
+				got = append(got, strings.Split(e.GetSnapshot().GetSnapshotId(), separator)[2])
+			}
+			if strings.Join(got, " ") != strings.Join(test.expected, " ") {
+				t.Errorf("expected snapshots %v, got %v", test.expected, got)
+			}
+		})
+	}
+}
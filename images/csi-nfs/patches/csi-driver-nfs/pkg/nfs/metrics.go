@@ -0,0 +1,167 @@
+/*
+Copyright 2026 Flant JSC
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nfs
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"k8s.io/klog/v2"
+)
+
+// The driver exposes a handful of metrics in the Prometheus text format. The
+// upstream module does not depend on a Prometheus client, so a minimal
+// registry is kept here instead of adding one.
+
+const (
+	metricTypeCounter = "counter"
+	metricTypeGauge   = "gauge"
+)
+
+var metricsRegistry = struct {
+	sync.Mutex
+	metrics    []*metricVec
+	collectors []func() // update the metrics read from the system before a scrape
+}{}
+
+// metricVec is a counter or a gauge partitioned by labels.
+type metricVec struct {
+	name, help, typ string
+	labels          []string
+
+	mu     sync.Mutex
+	values map[string]float64 // keyed by the label values joined with "\xff"
+}
+
+func newMetricVec(typ, name, help string, labels ...string) *metricVec {
+	m := &metricVec{name: name, help: help, typ: typ, labels: labels, values: map[string]float64{}}
+	metricsRegistry.Lock()
+	defer metricsRegistry.Unlock()
+	metricsRegistry.metrics = append(metricsRegistry.metrics, m)
+	return m
+}
+
+func (m *metricVec) key(labelValues []string) string {
+	if len(labelValues) != len(m.labels) {
+		panic(fmt.Sprintf("metric %s expects %d label values, got %d", m.name, len(m.labels), len(labelValues)))
+	}
+	return strings.Join(labelValues, "\xff")
+}
+
+// add increases the value of the series by v.
+func (m *metricVec) add(v float64, labelValues ...string) {
+	k := m.key(labelValues)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values[k] += v
+}
+
+func (m *metricVec) inc(labelValues ...string) {
+	m.add(1, labelValues...)
+}
+
+// set sets the value of the series; used for gauges and for the counters
+// read from the kernel.
+func (m *metricVec) set(v float64, labelValues ...string) {
+	k := m.key(labelValues)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values[k] = v
+}
+
+// delete removes the series, e.g. of a volume that no longer exists.
+func (m *metricVec) delete(labelValues ...string) {
+	k := m.key(labelValues)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.values, k)
+}
+
+// reset removes all the series.
+func (m *metricVec) reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values = map[string]float64{}
+}
+
+func (m *metricVec) get(labelValues ...string) float64 {
+	k := m.key(labelValues)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.values[k]
+}
+
+func (m *metricVec) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.values) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.typ)
+	keys := make([]string, 0, len(m.values))
+	for k := range m.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprint(w, m.name)
+		if len(m.labels) > 0 {
+			pairs := make([]string, len(m.labels))
+			for i, value := range strings.Split(k, "\xff") {
+				pairs[i] = m.labels[i] + "=" + strconv.Quote(value)
+			}
+			fmt.Fprintf(w, "{%s}", strings.Join(pairs, ","))
+		}
+		fmt.Fprintf(w, " %s\n", strconv.FormatFloat(m.values[k], 'g', -1, 64))
+	}
+}
+
+// registerMetricsCollector registers collect to be called before the
+// metrics are written.
+func registerMetricsCollector(collect func()) {
+	metricsRegistry.Lock()
+	defer metricsRegistry.Unlock()
+	metricsRegistry.collectors = append(metricsRegistry.collectors, collect)
+}
+
+func writeMetrics(w io.Writer) {
+	metricsRegistry.Lock()
+	defer metricsRegistry.Unlock()
+	for _, collect := range metricsRegistry.collectors {
+		collect()
+	}
+	for _, m := range metricsRegistry.metrics {
+		m.write(w)
+	}
+}
+
+// serveMetrics serves the metrics on address in the background.
+func serveMetrics(address string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		writeMetrics(w)
+	})
+	go func() {
+		klog.Infof("serving metrics on %s", address)
+		if err := http.ListenAndServe(address, mux); err != nil {
+			klog.Errorf("failed to serve metrics on %s: %v", address, err)
+		}
+	}()
+}
@@ -0,0 +1,227 @@
+/*
+Copyright 2026 Flant JSC
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nfs
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"k8s.io/klog/v2"
+)
+
+var (
+	mountOperationsTotal = newMetricVec(metricTypeCounter, "csi_nfs_mount_operations_total",
+		"Number of NFS operations of the volume mount by operation.", "pv", "server", "operation")
+	mountRetransmissionsTotal = newMetricVec(metricTypeCounter, "csi_nfs_mount_operation_retransmissions_total",
+		"Number of retransmissions of the NFS operations of the volume mount by operation.", "pv", "server", "operation")
+	mountTimeoutsTotal = newMetricVec(metricTypeCounter, "csi_nfs_mount_operation_timeouts_total",
+		"Number of major timeouts of the NFS operations of the volume mount by operation.", "pv", "server", "operation")
+	mountErrorsTotal = newMetricVec(metricTypeCounter, "csi_nfs_mount_operation_errors_total",
+		"Number of NFS operations of the volume mount that completed with an error status by operation.", "pv", "server", "operation")
+	mountRTTSecondsTotal = newMetricVec(metricTypeCounter, "csi_nfs_mount_operation_rtt_seconds_total",
+		"Total time between sending the NFS requests of the volume mount and receiving the replies by operation.", "pv", "server", "operation")
+	mountExecuteSecondsTotal = newMetricVec(metricTypeCounter, "csi_nfs_mount_operation_execute_seconds_total",
+		"Total time of the NFS operations of the volume mount from their start to their completion, including the queueing, by operation.", "pv", "server", "operation")
+	mountReadBytesTotal = newMetricVec(metricTypeCounter, "csi_nfs_mount_read_bytes_total",
+		"Number of bytes read from the NFS server by the volume mount.", "pv", "server")
+	mountWriteBytesTotal = newMetricVec(metricTypeCounter, "csi_nfs_mount_write_bytes_total",
+		"Number of bytes written to the NFS server by the volume mount.", "pv", "server")
+	mountReconnectsTotal = newMetricVec(metricTypeCounter, "csi_nfs_mount_transport_reconnects_total",
+		"Number of times the transport of the volume mount reconnected to the NFS server.", "pv", "server")
+
+	mountStatsMetrics = []*metricVec{mountOperationsTotal, mountRetransmissionsTotal, mountTimeoutsTotal, mountErrorsTotal,
+		mountRTTSecondsTotal, mountExecuteSecondsTotal, mountReadBytesTotal, mountWriteBytesTotal, mountReconnectsTotal}
+)
+
+// nfsMountStats is the entry of an NFS mount in /proc/<pid>/mountstats.
+type nfsMountStats struct {
+	device     string // server:/export
+	mountPoint string
+	readBytes  uint64
+	writeBytes uint64
+	reconnects uint64 // of all the transports, 0 for UDP
+	operations []nfsOperationStats
+}
+
+// nfsOperationStats is a line of the per-op statistics of an NFS mount.
+type nfsOperationStats struct {
+	name      string
+	ops       uint64
+	trans     uint64
+	timeouts  uint64
+	rttMs     uint64
+	executeMs uint64
+	errors    uint64 // since statvers 1.1
+}
+
+// readMountStats reads the NFS mounts from a mountstats file. The format is
+// described in fs/nfs/super.c and net/sunrpc/stats.c of the kernel.
+func readMountStats(path string) ([]nfsMountStats, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var mounts []nfsMountStats
+	var current *nfsMountStats
+	perOp := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		// device server:/export mounted on /mnt with fstype nfs4 statvers=1.1
+		if fields[0] == "device" {
+			current, perOp = nil, false
+			if len(fields) >= 8 && fields[2] == "mounted" && fields[3] == "on" && fields[5] == "with" && fields[6] == "fstype" &&
+				(fields[7] == "nfs" || fields[7] == "nfs4") {
+				mounts = append(mounts, nfsMountStats{device: fields[1], mountPoint: unescapeMountPath(fields[4])})
+				current = &mounts[len(mounts)-1]
+			}
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		if fields[0] == "per-op" {
+			perOp = true
+			continue
+		}
+		values := parseMountStatsValues(fields[1:])
+		switch {
+		case fields[0] == "bytes:":
+			// normalread normalwrite directread directwrite serverread serverwrite readpages writepages
+			if len(values) >= 6 {
+				current.readBytes, current.writeBytes = values[4], values[5]
+			}
+		case fields[0] == "xprt:":
+			// tcp port bind_count connect_count ...; udp has no connections. A
+			// mount with nconnect has a line per transport.
+			if len(fields) >= 5 && (fields[1] == "tcp" || fields[1] == "rdma") {
+				// the first connection is not a reconnect
+				if connects, err := strconv.ParseUint(fields[4], 10, 64); err == nil && connects > 1 {
+					current.reconnects += connects - 1
+				}
+			}
+		case perOp && strings.HasSuffix(fields[0], ":") && len(values) >= 8:
+			// READ: ops trans timeouts bytes_sent bytes_recv queue_ms rtt_ms execute_ms [errors]
+			op := nfsOperationStats{
+				name:      strings.TrimSuffix(fields[0], ":"),
+				ops:       values[0],
+				trans:     values[1],
+				timeouts:  values[2],
+				rttMs:     values[6],
+				executeMs: values[7],
+			}
+			if len(values) >= 9 {
+				op.errors = values[8]
+			}
+			current.operations = append(current.operations, op)
+		}
+	}
+	return mounts, scanner.Err()
+}
+
+// parseMountStatsValues parses the numeric fields, stopping at the first
+// field that is not a number.
+func parseMountStatsValues(fields []string) []uint64 {
+	values := make([]uint64, 0, len(fields))
+	for _, field := range fields {
+		v, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			break
+		}
+		values = append(values, v)
+	}
+	return values
+}
+
+// mountStatsServer returns the server of the device of an NFS mount,
+// server:/export or [ipv6]:/export.
+func mountStatsServer(device string) string {
+	i := strings.LastIndex(device, ":/")
+	if i < 0 {
+		return device
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(device[:i], "["), "]")
+}
+
+// mountStatsCollector exports the statistics of the NFS client of the
+// mounts of the volumes of the driver published on the node.
+type mountStatsCollector struct {
+	driverName     string
+	mountStatsPath string
+}
+
+// nodeServerWithMountStats registers the collector of the NFS client
+// statistics of the published volumes and returns ns.
+func (n *Driver) nodeServerWithMountStats(ns csi.NodeServer) csi.NodeServer {
+	c := &mountStatsCollector{driverName: n.name, mountStatsPath: filepath.Join(procPath, "self", "mountstats")}
+	registerMetricsCollector(c.collect)
+	return ns
+}
+
+// collect replaces the series with the statistics of the mounts published
+// now. The mounts of the same export with the same options share the NFS
+// client of the kernel and report the same statistics, so every volume is
+// counted once, but the volumes of a server mounted with the same options
+// report the statistics of the whole server.
+func (c *mountStatsCollector) collect() {
+	mounts, err := readMountStats(c.mountStatsPath)
+	if err != nil {
+		klog.Errorf("failed to read the NFS mount statistics: %v", err)
+		return
+	}
+	for _, m := range mountStatsMetrics {
+		m.reset()
+	}
+
+	seen := map[string]bool{}
+	for _, m := range mounts {
+		if !publishPathRe.MatchString(m.mountPoint) {
+			continue
+		}
+		data, err := readKubeletVolumeData(m.mountPoint)
+		if err != nil || data.DriverName != c.driverName || data.SpecVolID == "" || seen[data.SpecVolID] {
+			continue
+		}
+		seen[data.SpecVolID] = true
+
+		pv, server := data.SpecVolID, mountStatsServer(m.device)
+		mountReadBytesTotal.set(float64(m.readBytes), pv, server)
+		mountWriteBytesTotal.set(float64(m.writeBytes), pv, server)
+		mountReconnectsTotal.set(float64(m.reconnects), pv, server)
+		for _, op := range m.operations {
+			if op.ops == 0 {
+				continue
+			}
+			retransmissions := uint64(0)
+			if op.trans > op.ops {
+				retransmissions = op.trans - op.ops
+			}
+			mountOperationsTotal.set(float64(op.ops), pv, server, op.name)
+			mountRetransmissionsTotal.set(float64(retransmissions), pv, server, op.name)
+			mountTimeoutsTotal.set(float64(op.timeouts), pv, server, op.name)
+			mountErrorsTotal.set(float64(op.errors), pv, server, op.name)
+			mountRTTSecondsTotal.set(float64(op.rttMs)/1000, pv, server, op.name)
+			mountExecuteSecondsTotal.set(float64(op.executeMs)/1000, pv, server, op.name)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2026 Flant JSC
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nfs
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testMountStats = `device rootfs mounted on / with fstype rootfs
+device /dev/sda1 mounted on /var/lib/kubelet with fstype ext4
+device 10.0.0.1:/share/pvc-1 mounted on %[1]s with fstype nfs4 statvers=1.1
+	opts:	rw,vers=4.1,rsize=1048576,wsize=1048576,namlen=255,acregmin=3,acregmax=60,acdirmin=30,acdirmax=60,hard,proto=tcp,nconnect=2,timeo=600,retrans=2,sec=sys
+	age:	3600
+	caps:	caps=0x3ffbffff,wtmult=512,dtsize=1048576,bsize=0,namlen=255
+	sec:	flavor=1,pseudoflavor=1
+	events:	10 20 30 40 50 60 70 80 90 100 110 120 130 140 150 160 170 180 190 200 210 220 230 240 250 260 270
+	bytes:	1000 2000 300 400 5000 6000 7 8
+	RPC iostats version: 1.1  p/v: 100003/4 (nfs)
+	xprt:	tcp 0 1 3 0 11 1234 1234 0 2345 0 2 0 0
+	xprt:	tcp 0 1 2 0 11 1234 1234 0 2345 0 2 0 0
+	per-op statistics
+	        NULL: 0 0 0 0 0 0 0 0 0
+	        READ: 100 105 1 12000 500000 20 1500 1800 2
+	       WRITE: 50 50 0 300000 8000 10 2500 3000 0
+	     GETATTR: 400 400 0 60000 90000 5 200 260 0
+
+device [fd00::1]:/share/pvc-1 mounted on %[2]s with fstype nfs4 statvers=1.1
+	bytes:	1 1 1 1 1 1 1 1
+	per-op statistics
+	        READ: 1 1 0 0 0 0 0 0 0
+
+device 10.0.0.2:/other/pvc-2 mounted on %[3]s with fstype nfs statvers=1.1
+	bytes:	1 1 1 1 1 1 1 1
+	RPC iostats version: 1.0  p/v: 100003/3 (nfs)
+	xprt:	udp 0 0 11 11 0 0 0 0 0
+	per-op statistics
+	        READ: 5 7 2 0 0 0 10 20
+`
+
+func TestMountStatsCollector(t *testing.T) {
+	kubeletDir := t.TempDir()
+	pvc1 := createTestPublishedVolume(t, kubeletDir, "uid-1", "pvc-1", testWatchdogDriver)
+	pvc1Again := createTestPublishedVolume(t, kubeletDir, "uid-2", "pvc-1", testWatchdogDriver)
+	pvc2 := createTestPublishedVolume(t, kubeletDir, "uid-3", "pvc-2", "other.csi.k8s.io")
+	path := filepath.Join(kubeletDir, "mountstats")
+	content := strings.NewReplacer("%[1]s", pvc1, "%[2]s", pvc1Again, "%[3]s", pvc2).Replace(testMountStats)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	mounts, err := readMountStats(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mounts) != 3 {
+		t.Fatalf("expected three NFS mounts, got %+v", mounts)
+	}
+	if m := mounts[0]; m.readBytes != 5000 || m.writeBytes != 6000 || m.reconnects != 3 || len(m.operations) != 4 {
+		t.Errorf("unexpected statistics %+v", m)
+	}
+	if m := mounts[2]; m.reconnects != 0 || m.operations[0].errors != 0 || m.operations[0].trans != 7 {
+		t.Errorf("unexpected statistics of the NFSv3 mount %+v", m)
+	}
+
+	c := &mountStatsCollector{driverName: testWatchdogDriver, mountStatsPath: path}
+	mountOperationsTotal.set(1, "pvc-gone", "10.0.0.1", "READ")
+	c.collect()
+
+	for _, test := range []struct {
+		metric *metricVec
+		labels []string
+		value  float64
+	}{
+		{metric: mountOperationsTotal, labels: []string{"pvc-1", "10.0.0.1", "READ"}, value: 100},
+		{metric: mountRetransmissionsTotal, labels: []string{"pvc-1", "10.0.0.1", "READ"}, value: 5},
+		{metric: mountTimeoutsTotal, labels: []string{"pvc-1", "10.0.0.1", "READ"}, value: 1},
+		{metric: mountErrorsTotal, labels: []string{"pvc-1", "10.0.0.1", "READ"}, value: 2},
+		{metric: mountRTTSecondsTotal, labels: []string{"pvc-1", "10.0.0.1", "WRITE"}, value: 2.5},
+		{metric: mountExecuteSecondsTotal, labels: []string{"pvc-1", "10.0.0.1", "GETATTR"}, value: 0.26},
+		{metric: mountReadBytesTotal, labels: []string{"pvc-1", "10.0.0.1"}, value: 5000},
+		{metric: mountWriteBytesTotal, labels: []string{"pvc-1", "10.0.0.1"}, value: 6000},
+		{metric: mountReconnectsTotal, labels: []string{"pvc-1", "10.0.0.1"}, value: 3},
+	} {
+		if v := test.metric.get(test.labels...); v != test.value {
+			t.Errorf("%s%v = %v, expected %v", test.metric.name, test.labels, v, test.value)
+		}
+	}
+
+	var out bytes.Buffer
+	mountOperationsTotal.write(&out)
+	// the second mount of the volume is not counted again, the operations
+	// without calls, the unmounted volumes and the volumes of other drivers
+	// are not exported
+	if strings.Count(out.String(), "\ncsi_nfs_mount_operations_total{") != 3 || strings.Contains(out.String(), "NULL") ||
+		strings.Contains(out.String(), "pvc-gone") || strings.Contains(out.String(), "pvc-2") {
+		t.Errorf("unexpected series:\n%s", out.String())
+	}
+}
+
+func TestMountStatsServer(t *testing.T) {
+	for device, server := range map[string]string{
+		"10.0.0.1:/share":       "10.0.0.1",
+		"nfs.example.com:/":     "nfs.example.com",
+		"[fd00::1]:/share/pv-1": "fd00::1",
+		"server":                "server",
+	} {
+		if s := mountStatsServer(device); s != server {
+			t.Errorf("mountStatsServer(%q) = %q, expected %q", device, s, server)
+		}
+	}
+}
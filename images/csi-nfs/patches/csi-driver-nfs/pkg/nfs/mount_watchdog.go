@@ -0,0 +1,419 @@
+/*
+Copyright 2026 Flant JSC
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nfs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"golang.org/x/sys/unix"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// MountWatchdogReport annotates the pods using a stale or hung mount and
+	// creates events; MountWatchdogRemount also unmounts it lazily and
+	// mounts the volume again; MountWatchdogEvict also evicts the pods, whose
+	// containers keep the stale mount until they are recreated.
+	MountWatchdogReport  = "report"
+	MountWatchdogRemount = "remount"
+	MountWatchdogEvict   = "evict"
+
+	// staleMountAnnotation lists the PersistentVolumes whose mounts in the
+	// pod are stale or hung on the node
+	staleMountAnnotation = "storage.deckhouse.io/nfs-stale-volumes"
+
+	staleMountReason          = "NFSMountStale"
+	staleMountRecoveredReason = "NFSMountRecovered"
+	remountedReason           = "NFSMountRemounted"
+	remountFailedReason       = "NFSMountRemountFailed"
+	staleMountEvictedReason   = "NFSMountEvicted"
+
+	unmountTimeout = 30 * time.Second
+)
+
+var (
+	// publishPathRe matches the target paths of the volumes published by the
+	// kubelet: <kubelet dir>/pods/<pod uid>/volumes/kubernetes.io~csi/<pv>/mount
+	publishPathRe = regexp.MustCompile(`/pods/([^/]+)/volumes/kubernetes\.io~csi/([^/]+)/mount$`)
+	// stagingPathRe matches the staging paths of the kubelet. The driver does
+	// not stage volumes, the mounts there are only reported.
+	stagingPathRe = regexp.MustCompile(`/plugins/kubernetes\.io/csi/.+/globalmount$`)
+
+	staleMountsGauge = newMetricVec(metricTypeGauge, "csi_nfs_stale_mounts",
+		"Number of NFS mounts of the volumes on the node that are stale or do not respond.", "node")
+	mountWatchdogActionsTotal = newMetricVec(metricTypeCounter, "csi_nfs_mount_watchdog_actions_total",
+		"Number of actions taken by the mount watchdog on stale or hung NFS mounts.", "node", "action", "result")
+)
+
+// MountWatchdogOptions configures the watchdog of the NFS mounts on the node.
+type MountWatchdogOptions struct {
+	// Interval of probing the mounts. If 0, the mounts are not probed.
+	Interval time.Duration
+	// Policy is MountWatchdogReport, MountWatchdogRemount or MountWatchdogEvict.
+	Policy string
+	// ProbeTimeout is how long a mount point may take to answer.
+	ProbeTimeout time.Duration
+	// FailureThreshold is the number of failed probes in a row before the
+	// mount is acted on.
+	FailureThreshold int
+}
+
+// mountWatchdog probes the NFS mounts of the volumes of the driver on the
+// node. The mounts are read from /proc/self/mountinfo and unmounted with
+// umount2, so neither the mount table nor the mount binaries are involved
+// until the volume is mounted again by the node server.
+type mountWatchdog struct {
+	driverName string
+	nodeID     string
+	options    MountWatchdogOptions
+	kubeClient kubernetes.Interface
+	recorder   record.EventRecorder
+	prober     *volumeProber
+
+	mountInfoPath string
+	publish       func(ctx context.Context, req *csi.NodePublishVolumeRequest) (*csi.NodePublishVolumeResponse, error)
+	unmount       func(target string) error
+
+	mounts map[string]*watchedMount
+}
+
+// watchedMount is the state of a mount point across the probes.
+type watchedMount struct {
+	failures int
+	stale    bool
+	message  string
+}
+
+// watchdogMount is a mount of a volume of the driver found on the node.
+type watchdogMount struct {
+	mountPoint string
+	pvName     string
+	podUID     types.UID // empty for the staging paths
+	readOnly   bool
+}
+
+// nodeServerWithMountWatchdog starts the mount watchdog, which mounts the
+// stale volumes again through ns, and returns ns.
+func (n *Driver) nodeServerWithMountWatchdog(ns csi.NodeServer) csi.NodeServer {
+	if n.mountWatchdog.Interval <= 0 {
+		return ns
+	}
+	_, kubeClient, recorder, err := newInClusterClient(n.name, n.nodeID)
+	if err != nil {
+		klog.Errorf("mount watchdog is disabled: %v", err)
+		return ns
+	}
+	w := newMountWatchdog(n.name, n.nodeID, n.mountWatchdog, kubeClient, recorder)
+	w.publish = ns.NodePublishVolume
+	go w.run(context.Background())
+	return ns
+}
+
+func newMountWatchdog(driverName, nodeID string, options MountWatchdogOptions, kubeClient kubernetes.Interface, recorder record.EventRecorder) *mountWatchdog {
+	if options.ProbeTimeout <= 0 {
+		options.ProbeTimeout = volumeProbeTimeout
+	}
+	if options.FailureThreshold <= 0 {
+		options.FailureThreshold = 1
+	}
+	return &mountWatchdog{
+		driverName:    driverName,
+		nodeID:        nodeID,
+		options:       options,
+		kubeClient:    kubeClient,
+		recorder:      recorder,
+		prober:        newVolumeProber(options.ProbeTimeout),
+		mountInfoPath: filepath.Join(procPath, "self", "mountinfo"),
+		unmount:       forceLazyUnmount,
+		mounts:        map[string]*watchedMount{},
+	}
+}
+
+func (w *mountWatchdog) run(ctx context.Context) {
+	klog.Infof("mount watchdog: probing the NFS mounts every %s, policy %s", w.options.Interval, w.options.Policy)
+	ticker := time.NewTicker(w.options.Interval)
+	defer ticker.Stop()
+	for {
+		w.checkAll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// findMounts returns the NFS mounts of the volumes of the driver in the
+// publish and staging paths of the kubelet.
+func (w *mountWatchdog) findMounts() ([]watchdogMount, error) {
+	infos, err := readMountInfo(w.mountInfoPath)
+	if err != nil {
+		return nil, err
+	}
+	var mounts []watchdogMount
+	for _, info := range infos {
+		if info.fsType != "nfs" && info.fsType != "nfs4" {
+			continue
+		}
+		m := watchdogMount{mountPoint: info.mountPoint, readOnly: slices.Contains(info.options, "ro")}
+		if match := publishPathRe.FindStringSubmatch(info.mountPoint); match != nil {
+			data, err := readKubeletVolumeData(info.mountPoint)
+			if err != nil || data.DriverName != w.driverName {
+				continue
+			}
+			m.podUID, m.pvName = types.UID(match[1]), data.SpecVolID
+			if m.pvName == "" {
+				m.pvName = match[2]
+			}
+		} else if stagingPathRe.MatchString(info.mountPoint) {
+			data, err := readKubeletVolumeData(info.mountPoint)
+			if err != nil || data.DriverName != w.driverName {
+				continue
+			}
+			m.pvName = data.SpecVolID
+		} else {
+			continue
+		}
+		mounts = append(mounts, m)
+	}
+	return mounts, nil
+}
+
+// checkAll probes every mount and acts on the ones failing
+// FailureThreshold probes in a row, then again every FailureThreshold
+// failures while they stay stale.
+func (w *mountWatchdog) checkAll(ctx context.Context) {
+	mounts, err := w.findMounts()
+	if err != nil {
+		klog.Errorf("mount watchdog: failed to read the mounts: %v", err)
+		return
+	}
+
+	seen := map[string]bool{}
+	stale := 0
+	for _, m := range mounts {
+		seen[m.mountPoint] = true
+		state, ok := w.mounts[m.mountPoint]
+		if !ok {
+			state = &watchedMount{}
+			w.mounts[m.mountPoint] = state
+		}
+
+		condition := w.prober.probe(ctx, m.mountPoint)
+		if !condition.GetAbnormal() {
+			if state.stale {
+				klog.Infof("mount watchdog: mount of volume %s at %s recovered", m.pvName, m.mountPoint)
+				w.podEvent(ctx, m, corev1.EventTypeNormal, staleMountRecoveredReason, "NFS mount of volume %s on node %s recovered", m.pvName, w.nodeID)
+				w.annotatePod(ctx, m, false)
+			}
+			*state = watchedMount{}
+			continue
+		}
+
+		state.failures++
+		state.message = condition.GetMessage()
+		klog.Warningf("mount watchdog: volume %s at %s failed probe %d/%d: %s", m.pvName, m.mountPoint, state.failures, w.options.FailureThreshold, state.message)
+		if state.failures < w.options.FailureThreshold {
+			continue
+		}
+		stale++
+		if state.failures%w.options.FailureThreshold == 0 {
+			w.handleStale(ctx, m, state)
+		}
+	}
+	for mountPoint := range w.mounts {
+		if !seen[mountPoint] {
+			delete(w.mounts, mountPoint)
+		}
+	}
+	staleMountsGauge.set(float64(stale), w.nodeID)
+}
+
+// handleStale reports the stale mount and applies the policy to it.
+func (w *mountWatchdog) handleStale(ctx context.Context, m watchdogMount, state *watchedMount) {
+	if !state.stale {
+		state.stale = true
+		w.annotatePod(ctx, m, true)
+	}
+	w.podEvent(ctx, m, corev1.EventTypeWarning, staleMountReason, "NFS mount of volume %s on node %s is stale: %s", m.pvName, w.nodeID, state.message)
+	mountWatchdogActionsTotal.inc(w.nodeID, MountWatchdogReport, "success")
+
+	if m.podUID == "" || (w.options.Policy != MountWatchdogRemount && w.options.Policy != MountWatchdogEvict) {
+		return
+	}
+	if err := w.remount(ctx, m); err != nil {
+		klog.Errorf("mount watchdog: failed to remount volume %s at %s: %v", m.pvName, m.mountPoint, err)
+		w.podEvent(ctx, m, corev1.EventTypeWarning, remountFailedReason, "Failed to mount volume %s again on node %s: %v", m.pvName, w.nodeID, err)
+		mountWatchdogActionsTotal.inc(w.nodeID, MountWatchdogRemount, "failure")
+	} else {
+		klog.Infof("mount watchdog: remounted volume %s at %s", m.pvName, m.mountPoint)
+		w.podEvent(ctx, m, corev1.EventTypeNormal, remountedReason, "Volume %s was mounted again on node %s, the running containers keep the stale mount until they are restarted", m.pvName, w.nodeID)
+		mountWatchdogActionsTotal.inc(w.nodeID, MountWatchdogRemount, "success")
+	}
+
+	if w.options.Policy == MountWatchdogEvict {
+		if err := w.evictPod(ctx, m); err != nil {
+			klog.Errorf("mount watchdog: failed to evict the pod %s using volume %s: %v", m.podUID, m.pvName, err)
+			mountWatchdogActionsTotal.inc(w.nodeID, MountWatchdogEvict, "failure")
+		} else {
+			mountWatchdogActionsTotal.inc(w.nodeID, MountWatchdogEvict, "success")
+		}
+	}
+}
+
+// remount unmounts the stale mount lazily and publishes the volume again
+// with the request the kubelet would send, built from the PersistentVolume.
+func (w *mountWatchdog) remount(ctx context.Context, m watchdogMount) error {
+	pv, err := w.kubeClient.CoreV1().PersistentVolumes().Get(ctx, m.pvName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get PersistentVolume: %v", err)
+	}
+	if pv.Spec.CSI == nil {
+		return fmt.Errorf("PersistentVolume %s is not a CSI volume", pv.Name)
+	}
+	if err := w.unmount(m.mountPoint); err != nil {
+		return fmt.Errorf("failed to unmount: %v", err)
+	}
+	_, err = w.publish(ctx, &csi.NodePublishVolumeRequest{
+		VolumeId:   pv.Spec.CSI.VolumeHandle,
+		TargetPath: m.mountPoint,
+		VolumeCapability: &csi.VolumeCapability{
+			AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{MountFlags: pv.Spec.MountOptions}},
+			AccessMode: &csi.VolumeCapability_AccessMode{Mode: publishAccessMode(pv)},
+		},
+		Readonly:      m.readOnly,
+		VolumeContext: pv.Spec.CSI.VolumeAttributes,
+	})
+	return err
+}
+
+func publishAccessMode(pv *corev1.PersistentVolume) csi.VolumeCapability_AccessMode_Mode {
+	switch {
+	case slices.Contains(pv.Spec.AccessModes, corev1.ReadWriteMany):
+		return csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER
+	case slices.Contains(pv.Spec.AccessModes, corev1.ReadOnlyMany):
+		return csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY
+	default:
+		return csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER
+	}
+}
+
+// forceLazyUnmount aborts the pending requests of the NFS mount and detaches
+// it, as umount -f -l does. The call may block on a hung server, so it is
+// given unmountTimeout.
+func forceLazyUnmount(target string) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- unix.Unmount(target, unix.MNT_FORCE|unix.MNT_DETACH)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(unmountTimeout):
+		return fmt.Errorf("unmount of %s did not finish in %s", target, unmountTimeout)
+	}
+}
+
+func (w *mountWatchdog) getPod(ctx context.Context, uid types.UID) (*corev1.Pod, error) {
+	pods, err := w.kubeClient.CoreV1().Pods("").List(ctx, metav1.ListOptions{FieldSelector: "spec.nodeName=" + w.nodeID})
+	if err != nil {
+		return nil, err
+	}
+	for i := range pods.Items {
+		if pods.Items[i].UID == uid {
+			return &pods.Items[i], nil
+		}
+	}
+	return nil, fmt.Errorf("pod %s not found on node %s", uid, w.nodeID)
+}
+
+// podEvent creates the event on the pod of a published volume, or on the
+// PersistentVolume of a staged one.
+func (w *mountWatchdog) podEvent(ctx context.Context, m watchdogMount, eventType, reason, messageFmt string, args ...interface{}) {
+	if m.podUID == "" {
+		pv := &corev1.PersistentVolume{ObjectMeta: metav1.ObjectMeta{Name: m.pvName}}
+		w.recorder.Eventf(pv, eventType, reason, messageFmt, args...)
+		return
+	}
+	pod, err := w.getPod(ctx, m.podUID)
+	if err != nil {
+		klog.Warningf("mount watchdog: %v", err)
+		return
+	}
+	w.recorder.Eventf(pod, eventType, reason, messageFmt, args...)
+}
+
+// annotatePod adds the volume to or removes it from staleMountAnnotation.
+func (w *mountWatchdog) annotatePod(ctx context.Context, m watchdogMount, stale bool) {
+	if m.podUID == "" {
+		return
+	}
+	pod, err := w.getPod(ctx, m.podUID)
+	if err != nil {
+		klog.Warningf("mount watchdog: %v", err)
+		return
+	}
+	var volumes []string
+	if value := pod.Annotations[staleMountAnnotation]; value != "" {
+		volumes = strings.Split(value, ",")
+	}
+	volumes = slices.DeleteFunc(volumes, func(v string) bool { return v == m.pvName })
+	if stale {
+		volumes = append(volumes, m.pvName)
+		slices.Sort(volumes)
+	}
+
+	var value interface{}
+	if len(volumes) > 0 {
+		value = strings.Join(volumes, ",")
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"annotations": map[string]interface{}{staleMountAnnotation: value}},
+	})
+	if err != nil {
+		klog.Errorf("mount watchdog: %v", err)
+		return
+	}
+	if _, err := w.kubeClient.CoreV1().Pods(pod.Namespace).Patch(ctx, pod.Name, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		klog.Errorf("mount watchdog: failed to annotate pod %s/%s: %v", pod.Namespace, pod.Name, err)
+	}
+}
+
+// evictPod evicts the pod through the eviction API, so the disruption
+// budgets are respected and the controller of the pod recreates it with a
+// fresh mount.
+func (w *mountWatchdog) evictPod(ctx context.Context, m watchdogMount) error {
+	pod, err := w.getPod(ctx, m.podUID)
+	if err != nil {
+		return err
+	}
+	w.recorder.Eventf(pod, corev1.EventTypeWarning, staleMountEvictedReason, "Evicting the pod: NFS mount of volume %s on node %s is stale", m.pvName, w.nodeID)
+	return w.kubeClient.PolicyV1().Evictions(pod.Namespace).Evict(ctx, &policyv1.Eviction{
+		ObjectMeta: metav1.ObjectMeta{Name: pod.Name, Namespace: pod.Namespace},
+	})
+}
@@ -0,0 +1,251 @@
+/*
+Copyright 2026 Flant JSC
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nfs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+)
+
+const testWatchdogDriver = "nfs.csi.k8s.io"
+
+// createTestPublishedVolume creates the kubelet directories of a volume
+// published to the pod and returns the target path.
+func createTestPublishedVolume(t *testing.T, kubeletDir, podUID, pvName, driverName string) string {
+	t.Helper()
+	target := filepath.Join(kubeletDir, "pods", podUID, "volumes", "kubernetes.io~csi", pvName, "mount")
+	if err := os.MkdirAll(target, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	data := fmt.Sprintf(`{"driverName":%q,"specVolID":%q,"volumeHandle":"server#share#%s##"}`, driverName, pvName, pvName)
+	if err := os.WriteFile(filepath.Join(filepath.Dir(target), kubeletVolumeDataFile), []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return target
+}
+
+func testWatchdogPod(name string, uid types.UID) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: uid},
+		Spec:       corev1.PodSpec{NodeName: "node-1"},
+	}
+}
+
+func testWatchdogPV(name string) *corev1.PersistentVolume {
+	return &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: corev1.PersistentVolumeSpec{
+			AccessModes:  []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany},
+			MountOptions: []string{"nfsvers=4.1"},
+			PersistentVolumeSource: corev1.PersistentVolumeSource{CSI: &corev1.CSIPersistentVolumeSource{
+				Driver:           testWatchdogDriver,
+				VolumeHandle:     "server#share#" + name + "##",
+				VolumeAttributes: map[string]string{"server": "server", "share": "share"},
+			}},
+		},
+	}
+}
+
+type testMountWatchdog struct {
+	*mountWatchdog
+	kubeClient *fake.Clientset
+	recorder   *record.FakeRecorder
+	stale      map[string]bool
+	unmounted  []string
+	published  []*csi.NodePublishVolumeRequest
+}
+
+func newTestMountWatchdog(t *testing.T, policy string, objects ...runtime.Object) (*testMountWatchdog, string) {
+	t.Helper()
+	kubeletDir := t.TempDir()
+	healthy := createTestPublishedVolume(t, kubeletDir, "uid-1", "pv-healthy", testWatchdogDriver)
+	stale := createTestPublishedVolume(t, kubeletDir, "uid-1", "pv-stale", testWatchdogDriver)
+	other := createTestPublishedVolume(t, kubeletDir, "uid-2", "pv-other", "other.csi.k8s.io")
+	mountInfo := strings.Join([]string{
+		"22 1 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw",
+		"40 22 0:50 /share/pv-healthy " + healthy + " rw,relatime shared:20 - nfs4 server:/share/pv-healthy rw,vers=4.1",
+		"41 22 0:51 /share/pv-stale " + stale + " ro,relatime shared:21 - nfs server:/share/pv-stale ro,vers=3",
+		"42 22 0:52 /share/pv-other " + other + " rw,relatime shared:22 - nfs4 server:/share/pv-other rw,vers=4.1",
+		"43 22 8:1 /data " + filepath.Join(kubeletDir, "pods", "uid-3", "volumes", "kubernetes.io~csi", "pv-local", "mount") + " rw shared:23 - ext4 /dev/sda1 rw",
+	}, "\n") + "\n"
+	mountInfoPath := filepath.Join(kubeletDir, "mountinfo")
+	if err := os.WriteFile(mountInfoPath, []byte(mountInfo), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	kubeClient := fake.NewSimpleClientset(objects...)
+	recorder := record.NewFakeRecorder(100)
+	w := &testMountWatchdog{kubeClient: kubeClient, recorder: recorder, stale: map[string]bool{stale: true}}
+	w.mountWatchdog = newMountWatchdog(testWatchdogDriver, "node-1",
+		MountWatchdogOptions{Interval: time.Minute, Policy: policy, ProbeTimeout: time.Second, FailureThreshold: 2},
+		kubeClient, recorder)
+	w.mountInfoPath = mountInfoPath
+	w.prober.access = func(path string) error {
+		if w.stale[path] {
+			return syscall.ESTALE
+		}
+		return nil
+	}
+	w.unmount = func(target string) error {
+		w.unmounted = append(w.unmounted, target)
+		return nil
+	}
+	w.publish = func(_ context.Context, req *csi.NodePublishVolumeRequest) (*csi.NodePublishVolumeResponse, error) {
+		w.published = append(w.published, req)
+		return &csi.NodePublishVolumeResponse{}, nil
+	}
+	return w, stale
+}
+
+func (w *testMountWatchdog) events() []string {
+	var events []string
+	for {
+		select {
+		case e := <-w.recorder.Events:
+			events = append(events, e)
+		default:
+			return events
+		}
+	}
+}
+
+func (w *testMountWatchdog) annotation(t *testing.T) string {
+	t.Helper()
+	pod, err := w.kubeClient.CoreV1().Pods("default").Get(context.Background(), "pod-1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pod.Annotations[staleMountAnnotation]
+}
+
+func TestMountWatchdogFindMounts(t *testing.T) {
+	w, stale := newTestMountWatchdog(t, MountWatchdogReport)
+	mounts, err := w.findMounts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mounts) != 2 {
+		t.Fatalf("expected the two mounts of the driver, got %+v", mounts)
+	}
+	if m := mounts[1]; m.mountPoint != stale || m.pvName != "pv-stale" || m.podUID != "uid-1" || !m.readOnly {
+		t.Errorf("unexpected mount %+v", m)
+	}
+}
+
+func TestMountWatchdogReport(t *testing.T) {
+	ctx := context.Background()
+	w, stale := newTestMountWatchdog(t, MountWatchdogReport, testWatchdogPod("pod-1", "uid-1"), testWatchdogPV("pv-stale"))
+
+	// the first failure is below the threshold
+	w.checkAll(ctx)
+	if events := w.events(); len(events) != 0 || w.annotation(t) != "" {
+		t.Errorf("acted on a single failed probe: %v", events)
+	}
+
+	w.checkAll(ctx)
+	events := w.events()
+	if len(events) != 1 || !strings.Contains(events[0], staleMountReason) || !strings.Contains(events[0], "stale NFS file handle") {
+		t.Errorf("unexpected events %v", events)
+	}
+	if a := w.annotation(t); a != "pv-stale" {
+		t.Errorf("unexpected annotation %q", a)
+	}
+	if len(w.unmounted) != 0 || len(w.published) != 0 {
+		t.Errorf("report policy changed the mounts")
+	}
+	if v := staleMountsGauge.get("node-1"); v != 1 {
+		t.Errorf("unexpected stale mounts gauge %v", v)
+	}
+
+	delete(w.stale, stale)
+	w.checkAll(ctx)
+	events = w.events()
+	if len(events) != 1 || !strings.Contains(events[0], staleMountRecoveredReason) {
+		t.Errorf("unexpected events %v", events)
+	}
+	if a := w.annotation(t); a != "" {
+		t.Errorf("annotation %q is not removed", a)
+	}
+	if v := staleMountsGauge.get("node-1"); v != 0 {
+		t.Errorf("unexpected stale mounts gauge %v", v)
+	}
+}
+
+func TestMountWatchdogRemount(t *testing.T) {
+	ctx := context.Background()
+	w, stale := newTestMountWatchdog(t, MountWatchdogRemount, testWatchdogPod("pod-1", "uid-1"), testWatchdogPV("pv-stale"))
+
+	w.checkAll(ctx)
+	w.checkAll(ctx)
+	if len(w.unmounted) != 1 || w.unmounted[0] != stale {
+		t.Fatalf("unexpected unmounts %v", w.unmounted)
+	}
+	if len(w.published) != 1 {
+		t.Fatalf("expected the volume to be published again, got %d requests", len(w.published))
+	}
+	req := w.published[0]
+	if req.GetVolumeId() != "server#share#pv-stale##" || req.GetTargetPath() != stale || !req.GetReadonly() ||
+		req.VolumeContext["share"] != "share" ||
+		req.VolumeCapability.AccessMode.Mode != csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER {
+		t.Errorf("unexpected publish request %+v", req)
+	}
+	events := w.events()
+	if len(events) != 2 || !strings.Contains(events[1], remountedReason) {
+		t.Errorf("unexpected events %v", events)
+	}
+
+	// the next attempt waits for another FailureThreshold failures
+	w.checkAll(ctx)
+	if len(w.published) != 1 {
+		t.Errorf("remounted on every failed probe")
+	}
+}
+
+func TestMountWatchdogEvict(t *testing.T) {
+	ctx := context.Background()
+	w, _ := newTestMountWatchdog(t, MountWatchdogEvict, testWatchdogPod("pod-1", "uid-1"), testWatchdogPV("pv-stale"))
+	evicted := ""
+	w.kubeClient.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "eviction" {
+			return false, nil, nil
+		}
+		evicted = action.GetNamespace() + "/" + action.(k8stesting.CreateAction).GetObject().(metav1.Object).GetName()
+		return true, nil, nil
+	})
+
+	w.checkAll(ctx)
+	w.checkAll(ctx)
+	if evicted != "default/pod-1" {
+		t.Errorf("pod is not evicted: %q", evicted)
+	}
+	events := w.events()
+	if len(events) != 3 || !strings.Contains(events[2], staleMountEvictedReason) {
+		t.Errorf("unexpected events %v", events)
+	}
+}
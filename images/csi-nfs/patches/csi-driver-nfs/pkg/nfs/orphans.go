@@ -0,0 +1,630 @@
+/*
+Copyright 2026 Flant JSC
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nfs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// orphanDeleteOlderThanKey is set in the provisioner secret by the
+	// controller from spec.orphanCleanup.deleteOlderThan of the
+	// NFSStorageClass; without it the orphans are only reported
+	orphanDeleteOlderThanKey = "orphanDeleteOlderThan"
+
+	orphanKindVolume   = "Volume"
+	orphanKindSnapshot = "Snapshot"
+
+	// orphanMinAge protects the directories of the volumes and snapshots
+	// being created, whose objects may not exist yet
+	orphanMinAge = time.Hour
+	// orphanPopulatingMinAge protects the volumes being copied from a
+	// snapshot or a volume: the copy of a large volume may take hours and
+	// its PersistentVolume only exists once it is complete
+	orphanPopulatingMinAge = 24 * time.Hour
+	// orphanReportLimit is the number of orphans listed in the status, the
+	// count and the total size cover all of them
+	orphanReportLimit = 100
+
+	// orphanScanDir is the directory under the working mount dir where the
+	// shares are mounted for the scan
+	orphanScanDir = ".orphan-scan"
+
+	// the replicas of the controller elect the one scanning the shares with
+	// a lease in their namespace
+	orphanScanLeaseDuration = 137 * time.Second
+	orphanScanRenewDeadline = 107 * time.Second
+	orphanScanRetryPeriod   = 26 * time.Second
+	serviceAccountNamespace = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+	orphanDeletedReason        = "OrphanDeleted"
+	orphanDeletionFailedReason = "OrphanDeletionFailed"
+	orphanScanFailedReason     = "OrphanScanFailed"
+
+	provisionerSecretNameParam      = "csi.storage.k8s.io/provisioner-secret-name"
+	provisionerSecretNamespaceParam = "csi.storage.k8s.io/provisioner-secret-namespace"
+
+	// the storage classes created by the controller for the
+	// NFSStorageClasses of the same name
+	managedByLabel              = "storage.deckhouse.io/managed-by"
+	managedByNFSStorageClassCtl = "nfs-storage-class-controller"
+)
+
+var (
+	nfsStorageClassResource       = schema.GroupVersionResource{Group: "storage.deckhouse.io", Version: "v1alpha1", Resource: "nfsstorageclasses"}
+	volumeSnapshotContentResource = schema.GroupVersionResource{Group: "snapshot.storage.k8s.io", Version: "v1", Resource: "volumesnapshotcontents"}
+
+	// only the directories named like the ones the driver creates are
+	// considered, the share may hold other data
+	orphanVolumeName   = regexp.MustCompile(`^pvc-[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+	orphanSnapshotName = regexp.MustCompile(`^snapshot-[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+	orphansGauge = newMetricVec(metricTypeGauge, "csi_nfs_orphans",
+		"Number of volume directories and snapshot archives on the share of the storage class not referenced by any PersistentVolume or VolumeSnapshotContent.", "storage_class", "kind")
+	orphanBytesGauge = newMetricVec(metricTypeGauge, "csi_nfs_orphan_bytes",
+		"Space used by the orphans on the share of the storage class.", "storage_class")
+	orphansDeletedTotal = newMetricVec(metricTypeCounter, "csi_nfs_orphans_deleted_total",
+		"Number of orphans deleted by the orphan cleanup policy of the storage class.", "storage_class")
+)
+
+// orphanScanner periodically lists the volume directories and snapshot
+// archives on the shares of the storage classes of the driver and reports
+// those not referenced by any PersistentVolume or VolumeSnapshotContent.
+type orphanScanner struct {
+	driverName    string
+	interval      time.Duration
+	kubeClient    kubernetes.Interface
+	dynamicClient dynamic.Interface
+	recorder      record.EventRecorder
+	// mountRoot is where the shares are mounted
+	mountRoot string
+	mount     func(ctx context.Context, target string, share *orphanShare, readOnly bool) error
+	unmount   func(ctx context.Context, target string, share *orphanShare)
+	now       func() time.Time
+}
+
+// orphanShare is the share of a storage class and its orphan settings.
+type orphanShare struct {
+	storageClass string
+	// managed is set for the storage classes of the NFSStorageClasses, the
+	// report is written to their status
+	managed       bool
+	server        string
+	share         string
+	mountOptions  []string
+	cleanupMethod string
+	// deleteOlderThan is zero if the orphans are not deleted
+	deleteOlderThan time.Duration
+	// retainArchivedFor is zero if the archived volumes are not deleted
+	retainArchivedFor time.Duration
+}
+
+type orphan struct {
+	name      string
+	kind      string
+	sizeBytes int64
+	modTime   time.Time
+}
+
+// orphanReport is written to status.orphans of the NFSStorageClass. The
+// fields are never omitted, so the merge patch removes the stale values; a
+// failed scan only sets the error and keeps the last result.
+type orphanReport struct {
+	LastScanTime   metav1.Time        `json:"lastScanTime"`
+	Count          int                `json:"count"`
+	TotalSizeBytes int64              `json:"totalSizeBytes"`
+	Deleted        int                `json:"deleted"`
+	Items          []orphanReportItem `json:"items"`
+	Error          *string            `json:"error"`
+}
+
+type orphanReportItem struct {
+	Name             string      `json:"name"`
+	Kind             string      `json:"kind"`
+	SizeBytes        int64       `json:"sizeBytes"`
+	ModificationTime metav1.Time `json:"modificationTime"`
+}
+
+// controllerServerWithOrphanScan returns the controller server to register
+// and starts the orphan scan when it is enabled with --orphan-scan-interval.
+func (n *Driver) controllerServerWithOrphanScan() csi.ControllerServer {
+	cs := NewControllerServer(n)
+	if n.orphanScanInterval <= 0 {
+		return cs
+	}
+	config, kubeClient, recorder, err := newInClusterClient(n.name, n.nodeID)
+	if err != nil {
+		klog.Errorf("orphan scan is disabled: %v", err)
+		return cs
+	}
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		klog.Errorf("orphan scan is disabled: failed to create dynamic client: %v", err)
+		return cs
+	}
+	namespace, err := os.ReadFile(serviceAccountNamespace)
+	if err != nil {
+		klog.Errorf("orphan scan is disabled: failed to get the namespace of the pod: %v", err)
+		return cs
+	}
+	identity, err := os.Hostname()
+	if err != nil {
+		klog.Errorf("orphan scan is disabled: failed to get the hostname: %v", err)
+		return cs
+	}
+
+	s := newOrphanScanner(n.name, n.orphanScanInterval, kubeClient, dynamicClient, recorder, filepath.Join(n.workingMountDir, orphanScanDir))
+	s.mount = n.mountShare
+	s.unmount = n.unmountShare
+	go s.runLeader(context.Background(), strings.TrimSpace(string(namespace)), identity)
+	return cs
+}
+
+// mountShare mounts the root of the share of a storage class at target with
+// the mount options of the class.
+func (n *Driver) mountShare(ctx context.Context, target string, share *orphanShare, readOnly bool) error {
+	klog.V(2).Infof("mounting %s:%s at %s (read-only: %t)", share.server, share.share, target, readOnly)
+	_, err := n.ns.NodePublishVolume(ctx, &csi.NodePublishVolumeRequest{
+		TargetPath: target,
+		VolumeCapability: &csi.VolumeCapability{
+			AccessType: &csi.VolumeCapability_Mount{
+				Mount: &csi.VolumeCapability_MountVolume{MountFlags: share.mountOptions},
+			},
+		},
+		VolumeContext: map[string]string{
+			paramServer: share.server,
+			paramShare:  share.share,
+		},
+		VolumeId: share.volumeID(),
+		Readonly: readOnly,
+	})
+	return err
+}
+
+func (n *Driver) unmountShare(ctx context.Context, target string, share *orphanShare) {
+	if _, err := n.ns.NodeUnpublishVolume(ctx, &csi.NodeUnpublishVolumeRequest{
+		VolumeId:   share.volumeID(),
+		TargetPath: target,
+	}); err != nil {
+		klog.Warningf("failed to unmount %s: %v", target, err)
+	}
+}
+
+func newOrphanScanner(driverName string, interval time.Duration, kubeClient kubernetes.Interface, dynamicClient dynamic.Interface, recorder record.EventRecorder, mountRoot string) *orphanScanner {
+	return &orphanScanner{
+		driverName:    driverName,
+		interval:      interval,
+		kubeClient:    kubeClient,
+		dynamicClient: dynamicClient,
+		recorder:      recorder,
+		mountRoot:     mountRoot,
+		now:           time.Now,
+	}
+}
+
+func (share *orphanShare) volumeID() string {
+	return strings.Join([]string{share.server, share.share, orphanScanDir}, separator)
+}
+
+// involvedObject is the object the events of the share are created for.
+func (share *orphanShare) involvedObject() *corev1.ObjectReference {
+	if share.managed {
+		return &corev1.ObjectReference{APIVersion: "storage.deckhouse.io/v1alpha1", Kind: "NFSStorageClass", Name: share.storageClass}
+	}
+	return &corev1.ObjectReference{APIVersion: "storage.k8s.io/v1", Kind: "StorageClass", Name: share.storageClass}
+}
+
+// runLeader scans the shares while the replica holds the lease.
+func (s *orphanScanner) runLeader(ctx context.Context, namespace, identity string) {
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Name:      strings.ReplaceAll(s.driverName, ".", "-") + "-orphan-scan",
+			Namespace: namespace,
+		},
+		Client:     s.kubeClient.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{Identity: identity},
+	}
+	for ctx.Err() == nil {
+		leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+			Lock:            lock,
+			LeaseDuration:   orphanScanLeaseDuration,
+			RenewDeadline:   orphanScanRenewDeadline,
+			RetryPeriod:     orphanScanRetryPeriod,
+			ReleaseOnCancel: true,
+			Callbacks: leaderelection.LeaderCallbacks{
+				OnStartedLeading: s.run,
+				OnStoppedLeading: func() {
+					klog.Infof("%s stopped scanning the shares for orphans", identity)
+				},
+			},
+		})
+	}
+}
+
+func (s *orphanScanner) run(ctx context.Context) {
+	klog.Infof("scanning the shares for orphaned volumes and snapshots every %s", s.interval)
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		s.scanAll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *orphanScanner) scanAll(ctx context.Context) {
+	scs, err := s.kubeClient.StorageV1().StorageClasses().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		klog.Errorf("orphan scan: failed to list storage classes: %v", err)
+		return
+	}
+	inUse, err := s.listInUse(ctx)
+	if err != nil {
+		klog.Errorf("orphan scan: %v", err)
+		return
+	}
+	for i := range scs.Items {
+		sc := &scs.Items[i]
+		if sc.Provisioner != s.driverName || sc.DeletionTimestamp != nil {
+			continue
+		}
+		share, err := getStorageClassShare(ctx, s.kubeClient, sc)
+		if err != nil {
+			klog.Errorf("orphan scan: storage class %s: %v", sc.Name, err)
+			continue
+		}
+		s.scanShare(ctx, share, inUse[contentSourceShareKey(share.server, share.share)])
+	}
+}
+
+// listInUse returns the names of the volume and snapshot directories
+// referenced by the PersistentVolumes and VolumeSnapshotContents of the
+// driver, keyed by the share.
+func (s *orphanScanner) listInUse(ctx context.Context) (map[string]map[string]bool, error) {
+	inUse := map[string]map[string]bool{}
+	add := func(server, baseDir, dir string) {
+		key := contentSourceShareKey(server, baseDir)
+		if inUse[key] == nil {
+			inUse[key] = map[string]bool{}
+		}
+		// a volume in a nested subdirectory keeps the whole top directory
+		inUse[key][strings.Split(strings.Trim(dir, "/"), "/")[0]] = true
+	}
+
+	pvs, err := s.kubeClient.CoreV1().PersistentVolumes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list persistent volumes: %v", err)
+	}
+	for _, pv := range pvs.Items {
+		if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != s.driverName {
+			continue
+		}
+		vol, err := getNfsVolFromID(pv.Spec.CSI.VolumeHandle)
+		if err != nil {
+			klog.Warningf("orphan scan: persistent volume %s: %v", pv.Name, err)
+			continue
+		}
+		add(vol.server, vol.baseDir, vol.subDir)
+	}
+
+	contents, err := s.dynamicClient.Resource(volumeSnapshotContentResource).List(ctx, metav1.ListOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to list volume snapshot contents: %v", err)
+	}
+	if contents != nil {
+		for _, content := range contents.Items {
+			if driver, _, _ := unstructured.NestedString(content.Object, "spec", "driver"); driver != s.driverName {
+				continue
+			}
+			for _, fields := range [][]string{{"status", "snapshotHandle"}, {"spec", "source", "snapshotHandle"}} {
+				handle, _, _ := unstructured.NestedString(content.Object, fields...)
+				if handle == "" {
+					continue
+				}
+				snap, err := getNfsSnapFromID(handle)
+				if err != nil {
+					klog.Warningf("orphan scan: volume snapshot content %s: %v", content.GetName(), err)
+					continue
+				}
+				add(snap.server, snap.baseDir, snap.uuid)
+			}
+		}
+	}
+	return inUse, nil
+}
+
+// getStorageClassShare returns the share of the storage class with the
+// settings from its provisioner secret.
+func getStorageClassShare(ctx context.Context, kubeClient kubernetes.Interface, sc *storagev1.StorageClass) (*orphanShare, error) {
+	share := &orphanShare{
+		storageClass: sc.Name,
+		managed:      sc.Labels[managedByLabel] == managedByNFSStorageClassCtl,
+		mountOptions: sc.MountOptions,
+	}
+	for k, v := range sc.Parameters {
+		switch strings.ToLower(k) {
+		case paramServer:
+			share.server = v
+		case paramShare:
+			share.share = v
+		}
+	}
+	if share.server == "" || share.share == "" {
+		return nil, fmt.Errorf("server or share is not set")
+	}
+
+	secretName, secretNamespace := sc.Parameters[provisionerSecretNameParam], sc.Parameters[provisionerSecretNamespaceParam]
+	if secretName == "" {
+		return share, nil
+	}
+	secret, err := kubeClient.CoreV1().Secrets(secretNamespace).Get(ctx, secretName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get provisioner secret %s/%s: %v", secretNamespace, secretName, err)
+	}
+	data := make(map[string]string, len(secret.Data))
+	for k, v := range secret.Data {
+		data[k] = string(v)
+	}
+	if share.cleanupMethod, _, err = getVolumeCleanupMethod(data); err != nil {
+		return nil, err
+	}
+	if value := data[orphanDeleteOlderThanKey]; value != "" {
+		if share.deleteOlderThan, err = time.ParseDuration(value); err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", orphanDeleteOlderThanKey, err)
+		}
+	}
+	if value := data[retainArchivedForKey]; value != "" {
+		if share.retainArchivedFor, err = time.ParseDuration(value); err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", retainArchivedForKey, err)
+		}
+	}
+	return share, nil
+}
+
+// scanShare mounts the share read-only, finds the orphans and the archived
+// volumes and, if the policy of the class allows it, remounts the share
+// read-write and deletes the expired ones. The result is reported in the
+// metrics and the status of the NFSStorageClass.
+func (s *orphanScanner) scanShare(ctx context.Context, share *orphanShare, inUse map[string]bool) {
+	target := filepath.Join(s.mountRoot, share.storageClass)
+	orphans, archived, err := s.findShareOrphans(ctx, target, share, inUse)
+	if err != nil {
+		klog.Errorf("orphan scan: storage class %s: %v", share.storageClass, err)
+		s.recorder.Eventf(share.involvedObject(), corev1.EventTypeWarning, orphanScanFailedReason,
+			"Failed to scan share %s:%s for orphaned volumes and snapshots: %v", share.server, share.share, err)
+		s.patchStatus(ctx, share, map[string]interface{}{
+			"lastScanTime": metav1.NewTime(s.now()),
+			"error":        err.Error(),
+		})
+		return
+	}
+
+	deleted := 0
+	if expired := expiredOrphans(orphans, share.deleteOlderThan, s.now()); len(expired) > 0 {
+		deletedNames, err := s.deleteOrphans(ctx, target, share, expired)
+		if err != nil {
+			klog.Errorf("orphan scan: storage class %s: %v", share.storageClass, err)
+		}
+		remaining := orphans[:0]
+		for _, o := range orphans {
+			if !deletedNames[o.name] {
+				remaining = append(remaining, o)
+			}
+		}
+		orphans, deleted = remaining, len(deletedNames)
+	}
+	archivedVolumesGauge.set(float64(s.purgeArchivedVolumes(ctx, target, share, archived)), share.storageClass)
+	s.report(ctx, share, orphans, deleted)
+}
+
+func (s *orphanScanner) findShareOrphans(ctx context.Context, target string, share *orphanShare, inUse map[string]bool) ([]orphan, []archivedVolume, error) {
+	if err := s.mount(ctx, target, share, true); err != nil {
+		return nil, nil, fmt.Errorf("failed to mount share: %v", err)
+	}
+	defer s.unmount(ctx, target, share)
+	orphans, err := findOrphans(target, inUse)
+	if err != nil {
+		return nil, nil, err
+	}
+	archived, err := findArchivedVolumes(target)
+	if err != nil {
+		return nil, nil, err
+	}
+	return orphans, archived, nil
+}
+
+// deleteOrphans deletes the orphans with the cleanup method of the class.
+// The volumes and snapshots are listed again first: an object created since
+// the scan keeps its directory.
+func (s *orphanScanner) deleteOrphans(ctx context.Context, target string, share *orphanShare, expired []orphan) (map[string]bool, error) {
+	inUse, err := s.listInUse(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shareInUse := inUse[contentSourceShareKey(share.server, share.share)]
+	if err := s.mount(ctx, target, share, false); err != nil {
+		return nil, fmt.Errorf("failed to mount share read-write: %v", err)
+	}
+	defer s.unmount(ctx, target, share)
+
+	deleted := map[string]bool{}
+	for _, o := range expired {
+		if shareInUse[strings.TrimSuffix(o.name, populatingSuffix)] {
+			continue
+		}
+		path := filepath.Join(target, o.name)
+		err := func() error {
+			if share.cleanupMethod != "" {
+				if err := cleanupVolume(path, share.cleanupMethod); err != nil {
+					return err
+				}
+			}
+			return os.RemoveAll(path)
+		}()
+		if err != nil {
+			klog.Errorf("orphan scan: failed to delete %s: %v", path, err)
+			s.recorder.Eventf(share.involvedObject(), corev1.EventTypeWarning, orphanDeletionFailedReason,
+				"Failed to delete orphaned %s %s on share %s:%s: %v", strings.ToLower(o.kind), o.name, share.server, share.share, err)
+			continue
+		}
+		klog.Infof("orphan scan: deleted orphaned %s %s of storage class %s, last modified at %s", strings.ToLower(o.kind), o.name, share.storageClass, o.modTime.Format(time.RFC3339))
+		s.recorder.Eventf(share.involvedObject(), corev1.EventTypeNormal, orphanDeletedReason,
+			"Deleted orphaned %s %s (%d bytes, last modified at %s) on share %s:%s", strings.ToLower(o.kind), o.name, o.sizeBytes, o.modTime.Format(time.RFC3339), share.server, share.share)
+		orphansDeletedTotal.inc(share.storageClass)
+		deleted[o.name] = true
+	}
+	return deleted, nil
+}
+
+func (s *orphanScanner) report(ctx context.Context, share *orphanShare, orphans []orphan, deleted int) {
+	report := orphanReport{
+		LastScanTime: metav1.NewTime(s.now()),
+		Count:        len(orphans),
+		Deleted:      deleted,
+	}
+	counts := map[string]int{orphanKindVolume: 0, orphanKindSnapshot: 0}
+	for i, o := range orphans {
+		counts[o.kind]++
+		report.TotalSizeBytes += o.sizeBytes
+		if i < orphanReportLimit {
+			report.Items = append(report.Items, orphanReportItem{
+				Name:             o.name,
+				Kind:             o.kind,
+				SizeBytes:        o.sizeBytes,
+				ModificationTime: metav1.NewTime(o.modTime),
+			})
+		}
+	}
+	for kind, count := range counts {
+		orphansGauge.set(float64(count), share.storageClass, kind)
+	}
+	orphanBytesGauge.set(float64(report.TotalSizeBytes), share.storageClass)
+	s.patchStatus(ctx, share, report)
+}
+
+// patchStatus merges orphans into status.orphans of the NFSStorageClass of
+// the share.
+func (s *orphanScanner) patchStatus(ctx context.Context, share *orphanShare, orphans interface{}) {
+	if !share.managed {
+		return
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"status": map[string]interface{}{"orphans": orphans},
+	})
+	if err != nil {
+		klog.Errorf("orphan scan: %v", err)
+		return
+	}
+	_, err = s.dynamicClient.Resource(nfsStorageClassResource).Patch(ctx, share.storageClass, types.MergePatchType, patch, metav1.PatchOptions{}, "status")
+	if err != nil && !apierrors.IsNotFound(err) {
+		klog.Errorf("orphan scan: failed to update the status of NFSStorageClass %s: %v", share.storageClass, err)
+	}
+}
+
+// findOrphans returns the volume and snapshot directories in the share root
+// not in inUse, the oldest first. Interrupted copies of volumes (with the
+// populating suffix) are orphans unless the volume exists; their age is the
+// one of the latest change in the tree, a copy in flight keeps it recent.
+func findOrphans(root string, inUse map[string]bool) ([]orphan, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+	var orphans []orphan
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() || inUse[strings.TrimSuffix(name, populatingSuffix)] {
+			continue
+		}
+		var kind string
+		switch base := strings.TrimSuffix(name, populatingSuffix); {
+		case orphanVolumeName.MatchString(base):
+			kind = orphanKindVolume
+		case orphanSnapshotName.MatchString(base):
+			kind = orphanKindSnapshot
+		default:
+			continue
+		}
+
+		info, err := entry.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		usage, err := newUsageScanner().scan(filepath.Join(root, name))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to measure %s: %v", name, err)
+		}
+		modTime := info.ModTime()
+		if strings.HasSuffix(name, populatingSuffix) && usage.ChangeTime.After(modTime) {
+			modTime = usage.ChangeTime
+		}
+		orphans = append(orphans, orphan{name: name, kind: kind, sizeBytes: usage.UsedBytes, modTime: modTime})
+	}
+	sort.Slice(orphans, func(i, j int) bool {
+		return orphans[i].modTime.Before(orphans[j].modTime)
+	})
+	return orphans, nil
+}
+
+// expiredOrphans returns the orphans not modified for olderThan, but at least
+// orphanMinAge, or orphanPopulatingMinAge for the copies of the volumes;
+// none if olderThan is zero.
+func expiredOrphans(orphans []orphan, olderThan time.Duration, now time.Time) []orphan {
+	if olderThan <= 0 {
+		return nil
+	}
+	var expired []orphan
+	for _, o := range orphans {
+		minAge := orphanMinAge
+		if strings.HasSuffix(o.name, populatingSuffix) {
+			minAge = orphanPopulatingMinAge
+		}
+		if now.Sub(o.modTime) >= max(olderThan, minAge) {
+			expired = append(expired, o)
+		}
+	}
+	return expired
+}
@@ -0,0 +1,266 @@
+/*
+Copyright 2026 Flant JSC
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nfs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	testUsedVolume      = "pvc-00000000-0000-0000-0000-000000000001"
+	testOldVolume       = "pvc-00000000-0000-0000-0000-000000000002"
+	testNewVolume       = "pvc-00000000-0000-0000-0000-000000000003"
+	testUsedSnapshot    = "snapshot-00000000-0000-0000-0000-000000000004"
+	testOldSnapshot     = "snapshot-00000000-0000-0000-0000-000000000005"
+	testPopulatingClone = "pvc-00000000-0000-0000-0000-000000000006" + populatingSuffix
+)
+
+// createTestShare creates the share content: the directories are keyed by the
+// time since their modification.
+func createTestShare(t *testing.T, root string, now time.Time, dirs map[string]time.Duration) {
+	for name, age := range dirs {
+		dir := filepath.Join(root, name)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("failed to create %s: %v", dir, err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "data"), make([]byte, 4096), 0644); err != nil {
+			t.Fatalf("failed to write data: %v", err)
+		}
+		if err := os.Chtimes(dir, now.Add(-age), now.Add(-age)); err != nil {
+			t.Fatalf("failed to set times of %s: %v", dir, err)
+		}
+	}
+}
+
+func TestFindOrphans(t *testing.T) {
+	root := t.TempDir()
+	now := time.Now()
+	createTestShare(t, root, now, map[string]time.Duration{
+		testUsedVolume:      100 * time.Hour,
+		testOldVolume:       48 * time.Hour,
+		testNewVolume:       2 * time.Hour,
+		testUsedSnapshot:    100 * time.Hour,
+		testOldSnapshot:     72 * time.Hour,
+		testPopulatingClone: 24 * time.Hour,
+		// not created by the driver
+		"backup":                    100 * time.Hour,
+		"archived-" + testOldVolume: 100 * time.Hour,
+	})
+	if err := os.WriteFile(filepath.Join(root, "pvc-00000000-0000-0000-0000-000000000007"), nil, 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	orphans, err := findOrphans(root, map[string]bool{testUsedVolume: true, testUsedSnapshot: true})
+	if err != nil {
+		t.Fatalf("findOrphans failed: %v", err)
+	}
+	var got []string
+	for _, o := range orphans {
+		got = append(got, o.kind+":"+o.name)
+		if o.sizeBytes < 4096 {
+			t.Errorf("unexpected size of %s: %d", o.name, o.sizeBytes)
+		}
+	}
+	// the copy was just written: its age is the one of its data
+	expected := []string{
+		orphanKindSnapshot + ":" + testOldSnapshot,
+		orphanKindVolume + ":" + testOldVolume,
+		orphanKindVolume + ":" + testNewVolume,
+		orphanKindVolume + ":" + testPopulatingClone,
+	}
+	if strings.Join(got, " ") != strings.Join(expected, " ") {
+		t.Errorf("expected orphans %v, got %v", expected, got)
+	}
+
+	// the copy of an existing volume is still in progress
+	orphans, err = findOrphans(root, map[string]bool{strings.TrimSuffix(testPopulatingClone, populatingSuffix): true})
+	if err != nil {
+		t.Fatalf("findOrphans failed: %v", err)
+	}
+	for _, o := range orphans {
+		if o.name == testPopulatingClone {
+			t.Errorf("copy of an existing volume reported as orphan")
+		}
+	}
+}
+
+func TestFindOrphansPopulatingInFlight(t *testing.T) {
+	root := t.TempDir()
+	now := time.Now()
+	// the directory of a copy keeps its age while a large file is written
+	// and the copied files keep the times of the source
+	createTestShare(t, root, now, map[string]time.Duration{testPopulatingClone: 48 * time.Hour})
+	file := filepath.Join(root, testPopulatingClone, "data")
+	if err := os.Chtimes(file, now.Add(-100*time.Hour), now.Add(-100*time.Hour)); err != nil {
+		t.Fatalf("failed to set times of %s: %v", file, err)
+	}
+
+	orphans, err := findOrphans(root, nil)
+	if err != nil {
+		t.Fatalf("findOrphans failed: %v", err)
+	}
+	if len(orphans) != 1 || now.Sub(orphans[0].modTime) > time.Hour {
+		t.Fatalf("expected the copy in flight with a recent change, got %v", orphans)
+	}
+	if expired := expiredOrphans(orphans, 2*time.Hour, now); len(expired) != 0 {
+		t.Errorf("copy in flight expired: %v", expired)
+	}
+}
+
+func TestExpiredOrphans(t *testing.T) {
+	now := time.Now()
+	orphans := []orphan{
+		{name: "a", modTime: now.Add(-48 * time.Hour)},
+		{name: "b", modTime: now.Add(-30 * time.Minute)},
+	}
+	if expired := expiredOrphans(orphans, 0, now); len(expired) != 0 {
+		t.Errorf("orphans expired without a policy: %v", expired)
+	}
+	if expired := expiredOrphans(orphans, 24*time.Hour, now); len(expired) != 1 || expired[0].name != "a" {
+		t.Errorf("unexpected expired orphans %v", expired)
+	}
+	// never younger than orphanMinAge
+	if expired := expiredOrphans(orphans, time.Minute, now); len(expired) != 1 || expired[0].name != "a" {
+		t.Errorf("unexpected expired orphans %v", expired)
+	}
+	// the copies of the volumes never younger than orphanPopulatingMinAge
+	populating := []orphan{
+		{name: "c" + populatingSuffix, modTime: now.Add(-30 * time.Hour)},
+		{name: "d" + populatingSuffix, modTime: now.Add(-2 * time.Hour)},
+	}
+	if expired := expiredOrphans(populating, time.Hour, now); len(expired) != 1 || expired[0].name != "c"+populatingSuffix {
+		t.Errorf("unexpected expired copies %v", expired)
+	}
+}
+
+func TestOrphanScannerScan(t *testing.T) {
+	const driverName = "nfs.csi.k8s.io"
+	now := time.Now()
+	mountRoot := t.TempDir()
+	oldArchive := "archived-" + testUsedVolume + "-" + now.Add(-31*24*time.Hour).UTC().Format(archivedVolumeTimeFormat)
+	newArchive := "archived-" + testUsedVolume + "-" + now.Add(-24*time.Hour).UTC().Format(archivedVolumeTimeFormat)
+	createTestShare(t, filepath.Join(mountRoot, "nfs"), now, map[string]time.Duration{
+		testUsedVolume:   100 * time.Hour,
+		testOldVolume:    48 * time.Hour,
+		testNewVolume:    2 * time.Hour,
+		testUsedSnapshot: 100 * time.Hour,
+		oldArchive:       time.Hour,
+		newArchive:       time.Hour,
+	})
+
+	sc := &storagev1.StorageClass{
+		ObjectMeta:  metav1.ObjectMeta{Name: "nfs", Labels: map[string]string{managedByLabel: managedByNFSStorageClassCtl}},
+		Provisioner: driverName,
+		Parameters: map[string]string{
+			paramServer:                     "server",
+			paramShare:                      "/share",
+			provisionerSecretNameParam:      "mount-options-nfs",
+			provisionerSecretNamespaceParam: "d8-csi-nfs",
+		},
+		MountOptions: []string{"nfsvers=4.1"},
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "mount-options-nfs", Namespace: "d8-csi-nfs"},
+		Data: map[string][]byte{
+			orphanDeleteOlderThanKey: []byte("24h"),
+			retainArchivedForKey:     []byte("720h"),
+			volumeCleanupMethodKey:   []byte(volumeCleanupMethodSinglePass),
+		},
+	}
+	pv := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: testUsedVolume},
+		Spec: corev1.PersistentVolumeSpec{
+			PersistentVolumeSource: corev1.PersistentVolumeSource{
+				CSI: &corev1.CSIPersistentVolumeSource{Driver: driverName, VolumeHandle: "server#share#" + testUsedVolume + "##"},
+			},
+		},
+	}
+	content := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "snapshot.storage.k8s.io/v1",
+		"kind":       "VolumeSnapshotContent",
+		"metadata":   map[string]interface{}{"name": "snapcontent-1"},
+		"spec":       map[string]interface{}{"driver": driverName},
+		"status":     map[string]interface{}{"snapshotHandle": "server#share#" + testUsedSnapshot + "#" + testUsedSnapshot + "#" + testUsedVolume},
+	}}
+	nsc := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "storage.deckhouse.io/v1alpha1",
+		"kind":       "NFSStorageClass",
+		"metadata":   map[string]interface{}{"name": "nfs"},
+	}}
+
+	kubeClient := fake.NewSimpleClientset(sc, secret, pv)
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		volumeSnapshotContentResource: "VolumeSnapshotContentList",
+		nfsStorageClassResource:       "NFSStorageClassList",
+	}, content, nsc)
+	recorder := record.NewFakeRecorder(10)
+
+	s := newOrphanScanner(driverName, time.Hour, kubeClient, dynamicClient, recorder, mountRoot)
+	s.now = func() time.Time { return now }
+	var mounts []string
+	s.mount = func(_ context.Context, target string, share *orphanShare, readOnly bool) error {
+		mounts = append(mounts, filepath.Base(target)+":"+share.server+":"+share.share+":"+strings.Join(share.mountOptions, ",")+":"+map[bool]string{true: "ro", false: "rw"}[readOnly])
+		return nil
+	}
+	s.unmount = func(context.Context, string, *orphanShare) {}
+
+	ctx := context.Background()
+	s.scanAll(ctx)
+
+	expectedMounts := []string{"nfs:server:/share:nfsvers=4.1:ro", "nfs:server:/share:nfsvers=4.1:rw", "nfs:server:/share:nfsvers=4.1:rw"}
+	if strings.Join(mounts, " ") != strings.Join(expectedMounts, " ") {
+		t.Errorf("expected mounts %v, got %v", expectedMounts, mounts)
+	}
+	for name, exists := range map[string]bool{testUsedVolume: true, testOldVolume: false, testNewVolume: true, testUsedSnapshot: true, oldArchive: false, newArchive: true} {
+		if _, err := os.Stat(filepath.Join(mountRoot, "nfs", name)); (err == nil) != exists {
+			t.Errorf("%s: expected to exist %t, got %v", name, exists, err)
+		}
+	}
+	if event := <-recorder.Events; !strings.HasPrefix(event, "Normal "+orphanDeletedReason) || !strings.Contains(event, testOldVolume) {
+		t.Errorf("unexpected event %q", event)
+	}
+	if event := <-recorder.Events; !strings.HasPrefix(event, "Normal "+archivedVolumePurgedReason) || !strings.Contains(event, testUsedVolume) {
+		t.Errorf("unexpected event %q", event)
+	}
+
+	got, err := dynamicClient.Resource(nfsStorageClassResource).Get(ctx, "nfs", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get NFSStorageClass: %v", err)
+	}
+	count, _, _ := unstructured.NestedInt64(got.Object, "status", "orphans", "count")
+	deleted, _, _ := unstructured.NestedInt64(got.Object, "status", "orphans", "deleted")
+	items, _, _ := unstructured.NestedSlice(got.Object, "status", "orphans", "items")
+	if count != 1 || deleted != 1 || len(items) != 1 || items[0].(map[string]interface{})["name"] != testNewVolume {
+		t.Errorf("unexpected status %v", got.Object["status"])
+	}
+	if orphansGauge.get("nfs", orphanKindVolume) != 1 || orphansGauge.get("nfs", orphanKindSnapshot) != 0 || orphansDeletedTotal.get("nfs") != 1 ||
+		archivedVolumesGauge.get("nfs") != 1 || archivedVolumesPurgedTotal.get("nfs") != 1 {
+		t.Errorf("unexpected metrics")
+	}
+}
@@ -0,0 +1,261 @@
+/*
+Copyright 2026 Flant JSC
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nfs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"golang.org/x/crypto/ssh"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// the project quota settings are set in the provisioner secret by the
+	// controller from spec.volumeUsage.projectQuota of the NFSStorageClass
+	// with the Block enforcement; without them no quota is set
+	projectQuotaExportPathKey    = "projectQuotaExportPath"
+	projectQuotaSSHUserKey       = "projectQuotaSSHUser"
+	projectQuotaSSHPrivateKeyKey = "projectQuotaSSHPrivateKey"
+	projectQuotaSSHKnownHostsKey = "projectQuotaSSHKnownHosts"
+
+	projectQuotaSSHPort    = "22"
+	projectQuotaSSHTimeout = 30 * time.Second
+)
+
+// projectQuotaSettings is how the driver reaches the NFS server to manage
+// the XFS project quotas of the volume directories.
+type projectQuotaSettings struct {
+	// exportPath is the path of the share on the server
+	exportPath string
+	user       string
+	privateKey []byte
+	knownHosts []byte
+}
+
+// projectQuotaControllerServer limits the volume directories to the
+// requested size with an XFS project quota on the NFS server, so the server
+// refuses the writes beyond it. The quota is set by CreateVolume, changed by
+// ControllerExpandVolume and removed by DeleteVolume, over SSH with
+// xfs_quota, so the server must export an XFS file system mounted with the
+// prjquota option.
+type projectQuotaControllerServer struct {
+	csi.ControllerServer
+	// run runs a shell script on the server
+	run func(ctx context.Context, server string, settings *projectQuotaSettings, script string) error
+}
+
+// controllerServerWithProjectQuota returns the controller server managing
+// the project quotas of the volumes of the classes with the settings.
+func (n *Driver) controllerServerWithProjectQuota(cs csi.ControllerServer) csi.ControllerServer {
+	return &projectQuotaControllerServer{ControllerServer: cs, run: runSSHScript}
+}
+
+func (cs *projectQuotaControllerServer) CreateVolume(ctx context.Context, req *csi.CreateVolumeRequest) (*csi.CreateVolumeResponse, error) {
+	resp, err := cs.ControllerServer.CreateVolume(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+	settings, err := getProjectQuotaSettings(req.GetSecrets())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	sizeBytes := req.GetCapacityRange().GetRequiredBytes()
+	if settings == nil || sizeBytes <= 0 {
+		return resp, nil
+	}
+	// a failed CreateVolume is retried and finds the volume directory
+	if err := cs.setQuota(ctx, resp.GetVolume().GetVolumeId(), settings, sizeBytes); err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to set the project quota of volume %s: %v", resp.GetVolume().GetVolumeId(), err)
+	}
+	return resp, nil
+}
+
+func (cs *projectQuotaControllerServer) ControllerExpandVolume(ctx context.Context, req *csi.ControllerExpandVolumeRequest) (*csi.ControllerExpandVolumeResponse, error) {
+	resp, err := cs.ControllerServer.ControllerExpandVolume(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+	settings, err := getProjectQuotaSettings(req.GetSecrets())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	sizeBytes := req.GetCapacityRange().GetRequiredBytes()
+	if settings == nil || sizeBytes <= 0 {
+		return resp, nil
+	}
+	if err := cs.setQuota(ctx, req.GetVolumeId(), settings, sizeBytes); err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to change the project quota of volume %s: %v", req.GetVolumeId(), err)
+	}
+	return resp, nil
+}
+
+func (cs *projectQuotaControllerServer) DeleteVolume(ctx context.Context, req *csi.DeleteVolumeRequest) (*csi.DeleteVolumeResponse, error) {
+	settings, err := getProjectQuotaSettings(req.GetSecrets())
+	if err != nil {
+		klog.Warningf("the project quota of volume %s is not removed: %v", req.GetVolumeId(), err)
+	} else if settings != nil {
+		// an archived or retained directory keeps its project without a limit
+		if err := cs.setQuota(ctx, req.GetVolumeId(), settings, 0); err != nil {
+			klog.Warningf("failed to remove the project quota of volume %s: %v", req.GetVolumeId(), err)
+		}
+	}
+	return cs.ControllerServer.DeleteVolume(ctx, req)
+}
+
+// setQuota limits the directory of the volume to sizeBytes, 0 removes the
+// limit.
+func (cs *projectQuotaControllerServer) setQuota(ctx context.Context, volumeID string, settings *projectQuotaSettings, sizeBytes int64) error {
+	vol, err := getNfsVolFromID(volumeID)
+	if err != nil {
+		return err
+	}
+	script, err := projectQuotaScript(settings, vol, sizeBytes)
+	if err != nil {
+		return err
+	}
+	klog.V(2).Infof("setting the project quota of volume %s to %d bytes", volumeID, sizeBytes)
+	return cs.run(ctx, vol.server, settings, script)
+}
+
+// getProjectQuotaSettings returns the project quota settings from the
+// provisioner secret, nil if they are not set.
+func getProjectQuotaSettings(secrets map[string]string) (*projectQuotaSettings, error) {
+	settings := &projectQuotaSettings{
+		exportPath: secrets[projectQuotaExportPathKey],
+		user:       secrets[projectQuotaSSHUserKey],
+		privateKey: []byte(secrets[projectQuotaSSHPrivateKeyKey]),
+		knownHosts: []byte(secrets[projectQuotaSSHKnownHostsKey]),
+	}
+	if settings.exportPath == "" && settings.user == "" {
+		return nil, nil
+	}
+	if settings.exportPath == "" || settings.user == "" || len(settings.privateKey) == 0 || len(settings.knownHosts) == 0 {
+		return nil, fmt.Errorf("%s, %s, %s and %s must be set together", projectQuotaExportPathKey, projectQuotaSSHUserKey, projectQuotaSSHPrivateKeyKey, projectQuotaSSHKnownHostsKey)
+	}
+	return settings, nil
+}
+
+// projectQuotaID returns the project of a volume. The ID is derived from the
+// volume name, so it does not need to be stored.
+func projectQuotaID(vol *nfsVolume) uint32 {
+	name := vol.uuid
+	if name == "" {
+		name = vol.subDir
+	}
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	// the project 0 is the default one of the file system
+	return max(h.Sum32(), 1)
+}
+
+// projectQuotaScript returns the shell script assigning the directory of the
+// volume on the server to its project and setting the hard block limit of
+// the project.
+func projectQuotaScript(settings *projectQuotaSettings, vol *nfsVolume, sizeBytes int64) (string, error) {
+	dir := path.Join(settings.exportPath, vol.subDir)
+	// the path is passed inside the commands of xfs_quota
+	if strings.ContainsAny(dir, " \t\n'\"\\$`") {
+		return "", fmt.Errorf("unsupported characters in the path %q of the volume on the server", dir)
+	}
+	sudo := ""
+	if settings.user != "root" {
+		sudo = "sudo -n "
+	}
+	id := projectQuotaID(vol)
+	var script strings.Builder
+	fmt.Fprintf(&script, "set -e\n")
+	fmt.Fprintf(&script, "fs=$(df -P '%s' | awk 'NR==2 {print $6}')\n", dir)
+	if sizeBytes > 0 {
+		fmt.Fprintf(&script, "%sxfs_quota -x -c 'project -s -p %s %d' \"$fs\" >/dev/null\n", sudo, dir, id)
+	}
+	fmt.Fprintf(&script, "%sxfs_quota -x -c 'limit -p bhard=%d %d' \"$fs\"\n", sudo, sizeBytes, id)
+	return script.String(), nil
+}
+
+// runSSHScript runs a shell script on the server as the user of the
+// settings. The host key of the server must be in the known hosts.
+func runSSHScript(ctx context.Context, server string, settings *projectQuotaSettings, script string) error {
+	signer, err := ssh.ParsePrivateKey(settings.privateKey)
+	if err != nil {
+		return fmt.Errorf("failed to parse the SSH private key: %v", err)
+	}
+	hostKeys, err := parseKnownHostKeys(settings.knownHosts)
+	if err != nil {
+		return err
+	}
+	config := &ssh.ClientConfig{
+		User: settings.user,
+		Auth: []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		// the known hosts are those of the server of the class
+		HostKeyCallback: func(hostname string, _ net.Addr, key ssh.PublicKey) error {
+			for _, hostKey := range hostKeys {
+				if bytes.Equal(hostKey.Marshal(), key.Marshal()) {
+					return nil
+				}
+			}
+			return fmt.Errorf("the %s host key of %s is not in the known hosts", key.Type(), hostname)
+		},
+		Timeout: projectQuotaSSHTimeout,
+	}
+	client, err := ssh.Dial("tcp", net.JoinHostPort(strings.Trim(server, "[]"), projectQuotaSSHPort), config)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s over SSH: %v", server, err)
+	}
+	defer client.Close()
+	stop := context.AfterFunc(ctx, func() { client.Close() })
+	defer stop()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return fmt.Errorf("failed to open an SSH session on %s: %v", server, err)
+	}
+	defer session.Close()
+	if out, err := session.CombinedOutput(script); err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// parseKnownHostKeys returns the keys of the known_hosts lines, e.g. the
+// output of ssh-keyscan.
+func parseKnownHostKeys(knownHosts []byte) ([]ssh.PublicKey, error) {
+	var keys []ssh.PublicKey
+	for rest := knownHosts; len(bytes.TrimSpace(rest)) > 0; {
+		_, _, key, _, next, err := ssh.ParseKnownHosts(rest)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the SSH known hosts: %v", err)
+		}
+		keys = append(keys, key)
+		rest = next
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no host keys in the SSH known hosts")
+	}
+	return keys, nil
+}
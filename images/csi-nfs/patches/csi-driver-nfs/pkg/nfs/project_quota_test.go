@@ -0,0 +1,153 @@
+/*
+Copyright 2026 Flant JSC
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nfs
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const testQuotaVolumeID = "server#/share#" + testOldVolume + "##"
+
+// testQuotaControllerServer is the controller server the project quota
+// wraps.
+type testQuotaControllerServer struct {
+	csi.ControllerServer
+	deleted bool
+}
+
+func (testQuotaControllerServer) CreateVolume(context.Context, *csi.CreateVolumeRequest) (*csi.CreateVolumeResponse, error) {
+	return &csi.CreateVolumeResponse{Volume: &csi.Volume{VolumeId: testQuotaVolumeID}}, nil
+}
+
+func (testQuotaControllerServer) ControllerExpandVolume(_ context.Context, req *csi.ControllerExpandVolumeRequest) (*csi.ControllerExpandVolumeResponse, error) {
+	return &csi.ControllerExpandVolumeResponse{CapacityBytes: req.GetCapacityRange().GetRequiredBytes()}, nil
+}
+
+func (cs *testQuotaControllerServer) DeleteVolume(context.Context, *csi.DeleteVolumeRequest) (*csi.DeleteVolumeResponse, error) {
+	cs.deleted = true
+	return &csi.DeleteVolumeResponse{}, nil
+}
+
+func testQuotaSecrets() map[string]string {
+	return map[string]string{
+		projectQuotaExportPathKey:    "/srv/nfs",
+		projectQuotaSSHUserKey:       "quota",
+		projectQuotaSSHPrivateKeyKey: "key",
+		projectQuotaSSHKnownHostsKey: "server ssh-ed25519 AAAA",
+	}
+}
+
+func TestGetProjectQuotaSettings(t *testing.T) {
+	if settings, err := getProjectQuotaSettings(map[string]string{"mountOptions": "nfsvers=4.1"}); settings != nil || err != nil {
+		t.Errorf("expected no settings, got %v, %v", settings, err)
+	}
+	settings, err := getProjectQuotaSettings(testQuotaSecrets())
+	if err != nil || settings.exportPath != "/srv/nfs" || settings.user != "quota" {
+		t.Errorf("unexpected settings %v, %v", settings, err)
+	}
+	secrets := testQuotaSecrets()
+	delete(secrets, projectQuotaSSHKnownHostsKey)
+	if _, err := getProjectQuotaSettings(secrets); err == nil {
+		t.Errorf("expected an error without the known hosts")
+	}
+}
+
+func TestProjectQuotaScript(t *testing.T) {
+	settings, _ := getProjectQuotaSettings(testQuotaSecrets())
+	vol := &nfsVolume{server: "server", baseDir: "/share", subDir: testOldVolume}
+	id := projectQuotaID(vol)
+	if id == 0 || id != projectQuotaID(&nfsVolume{subDir: testOldVolume}) {
+		t.Errorf("unexpected project %d", id)
+	}
+
+	script, err := projectQuotaScript(settings, vol, 1<<30)
+	if err != nil {
+		t.Fatalf("projectQuotaScript failed: %v", err)
+	}
+	for _, expected := range []string{
+		"df -P '/srv/nfs/" + testOldVolume + "'",
+		"sudo -n xfs_quota -x -c 'project -s -p /srv/nfs/" + testOldVolume + " ",
+		"sudo -n xfs_quota -x -c 'limit -p bhard=1073741824 ",
+	} {
+		if !strings.Contains(script, expected) {
+			t.Errorf("expected %q in the script:\n%s", expected, script)
+		}
+	}
+
+	// the limit is removed without touching the project
+	settings.user = "root"
+	script, err = projectQuotaScript(settings, vol, 0)
+	if err != nil {
+		t.Fatalf("projectQuotaScript failed: %v", err)
+	}
+	if strings.Contains(script, "project -s") || strings.Contains(script, "sudo") || !strings.Contains(script, "limit -p bhard=0 ") {
+		t.Errorf("unexpected script:\n%s", script)
+	}
+
+	if _, err := projectQuotaScript(settings, &nfsVolume{subDir: "pvc-1'; reboot; '"}, 1<<30); err == nil {
+		t.Errorf("expected an error for a path with quotes")
+	}
+}
+
+func TestProjectQuotaControllerServer(t *testing.T) {
+	ctx := context.Background()
+	wrapped := &testQuotaControllerServer{}
+	var scripts []string
+	runErr := error(nil)
+	cs := &projectQuotaControllerServer{
+		ControllerServer: wrapped,
+		run: func(_ context.Context, server string, _ *projectQuotaSettings, script string) error {
+			if server != "server" {
+				t.Errorf("unexpected server %s", server)
+			}
+			scripts = append(scripts, script)
+			return runErr
+		},
+	}
+
+	// without the settings nothing is run
+	if _, err := cs.CreateVolume(ctx, &csi.CreateVolumeRequest{CapacityRange: &csi.CapacityRange{RequiredBytes: 1 << 30}}); err != nil || len(scripts) != 0 {
+		t.Fatalf("unexpected result %v, scripts %v", err, scripts)
+	}
+
+	if _, err := cs.CreateVolume(ctx, &csi.CreateVolumeRequest{CapacityRange: &csi.CapacityRange{RequiredBytes: 1 << 30}, Secrets: testQuotaSecrets()}); err != nil {
+		t.Fatalf("CreateVolume failed: %v", err)
+	}
+	if _, err := cs.ControllerExpandVolume(ctx, &csi.ControllerExpandVolumeRequest{VolumeId: testQuotaVolumeID, CapacityRange: &csi.CapacityRange{RequiredBytes: 2 << 30}, Secrets: testQuotaSecrets()}); err != nil {
+		t.Fatalf("ControllerExpandVolume failed: %v", err)
+	}
+	if len(scripts) != 2 || !strings.Contains(scripts[0], "bhard=1073741824 ") || !strings.Contains(scripts[1], "bhard=2147483648 ") {
+		t.Errorf("unexpected scripts %v", scripts)
+	}
+
+	// a failed quota fails the request, which is retried
+	runErr = status.Error(codes.Unknown, "xfs_quota: cannot find mount point")
+	if _, err := cs.ControllerExpandVolume(ctx, &csi.ControllerExpandVolumeRequest{VolumeId: testQuotaVolumeID, CapacityRange: &csi.CapacityRange{RequiredBytes: 3 << 30}, Secrets: testQuotaSecrets()}); status.Code(err) != codes.Unavailable {
+		t.Errorf("expected Unavailable, got %v", err)
+	}
+
+	// the volume is deleted even if the limit is not removed
+	if _, err := cs.DeleteVolume(ctx, &csi.DeleteVolumeRequest{VolumeId: testQuotaVolumeID, Secrets: testQuotaSecrets()}); err != nil || !wrapped.deleted {
+		t.Errorf("DeleteVolume failed: %v, deleted: %t", err, wrapped.deleted)
+	}
+	if last := scripts[len(scripts)-1]; !strings.Contains(last, "bhard=0 ") {
+		t.Errorf("the limit is not removed: %s", last)
+	}
+}
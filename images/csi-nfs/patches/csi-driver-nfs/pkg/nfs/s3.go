@@ -0,0 +1,399 @@
+/*
+Copyright 2026 Flant JSC
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nfs
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	s3DefaultRegion   = "us-east-1"
+	s3DefaultPartSize = 64 << 20
+	// S3 allows at most 10000 parts per upload
+	s3MaxParts = 10000
+
+	s3TimeFormat     = "20060102T150405Z"
+	s3DateFormat     = "20060102"
+	s3EmptyBodyHash  = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	s3MetaHeaderBase = "X-Amz-Meta-"
+)
+
+var errS3ObjectNotFound = errors.New("object not found")
+
+// s3Client is a minimal client of the S3 API: it supports exactly the calls
+// needed to export and import snapshot archives. Requests use path-style
+// addressing, which works with AWS as well as with MinIO and other
+// S3-compatible stores, and are signed with AWS Signature Version 4.
+type s3Client struct {
+	endpoint  *url.URL
+	region    string
+	bucket    string
+	accessKey string
+	secretKey string
+	partSize  int64
+
+	httpClient *http.Client
+	now        func() time.Time
+}
+
+func newS3Client(endpoint, region, bucket, accessKey, secretKey string) (*s3Client, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid s3 endpoint %q: %w", endpoint, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid s3 endpoint %q: must be an http or https URL", endpoint)
+	}
+	if bucket == "" {
+		return nil, fmt.Errorf("s3 bucket is not set")
+	}
+	if region == "" {
+		region = s3DefaultRegion
+	}
+	return &s3Client{
+		endpoint:   u,
+		region:     region,
+		bucket:     bucket,
+		accessKey:  accessKey,
+		secretKey:  secretKey,
+		partSize:   s3DefaultPartSize,
+		httpClient: &http.Client{},
+		now:        time.Now,
+	}, nil
+}
+
+// headObject returns the user metadata of the object.
+func (c *s3Client) headObject(ctx context.Context, key string) (map[string]string, error) {
+	resp, err := c.do(ctx, http.MethodHead, key, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	metadata := make(map[string]string)
+	for name, values := range resp.Header {
+		if strings.HasPrefix(name, s3MetaHeaderBase) && len(values) > 0 {
+			metadata[strings.ToLower(strings.TrimPrefix(name, s3MetaHeaderBase))] = values[0]
+		}
+	}
+	return metadata, nil
+}
+
+// putObject uploads a small object in a single request.
+func (c *s3Client) putObject(ctx context.Context, key string, data []byte) error {
+	resp, err := c.do(ctx, http.MethodPut, key, nil, nil, data)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// getObject streams the object to w.
+func (c *s3Client) getObject(ctx context.Context, key string, w io.Writer) error {
+	resp, err := c.do(ctx, http.MethodGet, key, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return fmt.Errorf("failed to download s3 object %s: %w", key, err)
+	}
+	return nil
+}
+
+func (c *s3Client) deleteObject(ctx context.Context, key string) error {
+	resp, err := c.do(ctx, http.MethodDelete, key, nil, nil, nil)
+	if errors.Is(err, errS3ObjectNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// uploadFile uploads the file with a multipart upload, so that archives of
+// any size can be exported and every part is checked by the store against
+// its signed SHA-256. An unfinished upload is aborted on error.
+func (c *s3Client) uploadFile(ctx context.Context, key, path string, metadata map[string]string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	partSize := c.partSize
+	if fi.Size() > partSize*s3MaxParts {
+		partSize = (fi.Size() + s3MaxParts - 1) / s3MaxParts
+	}
+
+	header := http.Header{}
+	for k, v := range metadata {
+		header.Set(s3MetaHeaderBase+k, v)
+	}
+	resp, err := c.do(ctx, http.MethodPost, key, url.Values{"uploads": {""}}, header, nil)
+	if err != nil {
+		return err
+	}
+	var initiated struct {
+		UploadID string `xml:"UploadId"`
+	}
+	err = decodeS3Response(resp, &initiated)
+	if err != nil {
+		return err
+	}
+	if initiated.UploadID == "" {
+		return fmt.Errorf("s3 returned no upload id for %s", key)
+	}
+
+	completed, err := c.uploadParts(ctx, key, initiated.UploadID, f, partSize)
+	if err != nil {
+		c.abortUpload(ctx, key, initiated.UploadID)
+		return err
+	}
+
+	body, err := xml.Marshal(completed)
+	if err != nil {
+		c.abortUpload(ctx, key, initiated.UploadID)
+		return err
+	}
+	resp, err = c.do(ctx, http.MethodPost, key, url.Values{"uploadId": {initiated.UploadID}}, nil, body)
+	if err != nil {
+		c.abortUpload(ctx, key, initiated.UploadID)
+		return err
+	}
+	// CompleteMultipartUpload may fail after the 200 status line was sent
+	var result struct {
+		XMLName xml.Name
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	err = decodeS3Response(resp, &result)
+	if err != nil {
+		c.abortUpload(ctx, key, initiated.UploadID)
+		return err
+	}
+	if result.XMLName.Local == "Error" {
+		c.abortUpload(ctx, key, initiated.UploadID)
+		return fmt.Errorf("failed to complete multipart upload of %s: %s: %s", key, result.Code, result.Message)
+	}
+	return nil
+}
+
+type s3CompletedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+type s3CompleteMultipartUpload struct {
+	XMLName xml.Name          `xml:"CompleteMultipartUpload"`
+	Parts   []s3CompletedPart `xml:"Part"`
+}
+
+func (c *s3Client) uploadParts(ctx context.Context, key, uploadID string, r io.Reader, partSize int64) (*s3CompleteMultipartUpload, error) {
+	completed := &s3CompleteMultipartUpload{}
+	buf := make([]byte, partSize)
+	for partNumber := 1; ; partNumber++ {
+		n, err := io.ReadFull(r, buf)
+		if err == io.EOF && partNumber > 1 {
+			break
+		}
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return nil, err
+		}
+
+		query := url.Values{
+			"partNumber": {strconv.Itoa(partNumber)},
+			"uploadId":   {uploadID},
+		}
+		resp, doErr := c.do(ctx, http.MethodPut, key, query, nil, buf[:n])
+		if doErr != nil {
+			return nil, fmt.Errorf("failed to upload part %d of %s: %w", partNumber, key, doErr)
+		}
+		resp.Body.Close()
+		completed.Parts = append(completed.Parts, s3CompletedPart{PartNumber: partNumber, ETag: resp.Header.Get("ETag")})
+
+		if err != nil {
+			// the last, possibly short or empty, part has been uploaded
+			break
+		}
+	}
+	return completed, nil
+}
+
+func (c *s3Client) abortUpload(ctx context.Context, key, uploadID string) {
+	resp, err := c.do(ctx, http.MethodDelete, key, url.Values{"uploadId": {uploadID}}, nil, nil)
+	if err == nil {
+		resp.Body.Close()
+	}
+}
+
+// do sends a signed request for the object key and returns the response if
+// its status is 2xx.
+func (c *s3Client) do(ctx context.Context, method, key string, query url.Values, header http.Header, body []byte) (*http.Response, error) {
+	u := *c.endpoint
+	u.Path = strings.TrimSuffix(c.endpoint.Path, "/") + "/" + c.bucket + "/" + key
+	u.RawPath = ""
+	u.RawQuery = s3CanonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	c.sign(req, body)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("s3 %s %s failed: %w", method, key, err)
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound && method != http.MethodPost {
+		return nil, fmt.Errorf("s3 %s %s: %w", method, key, errS3ObjectNotFound)
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return nil, fmt.Errorf("s3 %s %s failed with status %s: %s", method, key, resp.Status, strings.TrimSpace(string(msg)))
+}
+
+func decodeS3Response(resp *http.Response, v interface{}) error {
+	defer resp.Body.Close()
+	if err := xml.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode s3 response: %w", err)
+	}
+	return nil
+}
+
+// sign adds the AWS Signature Version 4 authorization to the request.
+func (c *s3Client) sign(req *http.Request, body []byte) {
+	now := c.now().UTC()
+	payloadHash := s3EmptyBodyHash
+	if len(body) > 0 {
+		sum := sha256.Sum256(body)
+		payloadHash = hex.EncodeToString(sum[:])
+	}
+	req.Header.Set("X-Amz-Date", now.Format(s3TimeFormat))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	req.Header.Set("Authorization", s3Authorization(req, payloadHash, c.region, c.accessKey, c.secretKey, now))
+}
+
+func s3Authorization(req *http.Request, payloadHash, region, accessKey, secretKey string, now time.Time) string {
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "host" || lower == "range" || lower == "content-type" || lower == "content-md5" || strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		s3EscapePath(req.URL.Path),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := now.Format(s3DateFormat) + "/" + region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + now.Format(s3TimeFormat) + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := s3HMAC([]byte("AWS4"+secretKey), now.Format(s3DateFormat))
+	key = s3HMAC(key, region)
+	key = s3HMAC(key, "s3")
+	key = s3HMAC(key, "aws4_request")
+	signature := hex.EncodeToString(s3HMAC(key, stringToSign))
+
+	return fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", accessKey, scope, signedHeaders, signature)
+}
+
+func s3HMAC(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// s3EscapePath URI-encodes every path segment as SigV4 requires.
+func s3EscapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = s3Escape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// s3CanonicalQuery encodes the query with the keys sorted and every key and
+// value URI-encoded, so the same string is sent and signed.
+func s3CanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, s3Escape(k)+"="+s3Escape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func s3Escape(s string) string {
+	var b strings.Builder
+	for _, c := range []byte(s) {
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,296 @@
+/*
+Copyright 2026 Flant JSC
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nfs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
+)
+
+const (
+	snapshotCompressionField = "snapshotcompression"
+
+	snapshotCompressionNone = "none"
+	snapshotCompressionGzip = "gzip"
+	snapshotCompressionZstd = "zstd"
+
+	// gzip is the format upstream always used, so archives without a manifest
+	// are treated as gzip-compressed
+	defaultSnapshotCompression = snapshotCompressionGzip
+
+	gzipArchiveSuffix      = ".tar.gz"
+	snapshotManifestSuffix = ".manifest.json"
+)
+
+// snapshotManifest is stored next to the snapshot archive and describes it.
+// It is written before the archive is renamed into place, so a complete
+// archive always has a manifest (archives created before the manifest was
+// introduced are the only exception).
+type snapshotManifest struct {
+	Format                string    `json:"format"`
+	Archive               string    `json:"archive"`
+	FileCount             int64     `json:"fileCount"`
+	UncompressedSizeBytes int64     `json:"uncompressedSizeBytes"`
+	SHA256                string    `json:"sha256"`
+	CreationTime          time.Time `json:"creationTime"`
+}
+
+// getSnapshotCompression Convert VolumeSnapshot parameters to a snapshot archive compression format
+func getSnapshotCompression(params map[string]string) (string, error) {
+	compression := defaultSnapshotCompression
+	for k, v := range params {
+		if strings.ToLower(k) != snapshotCompressionField || v == "" {
+			continue
+		}
+		switch strings.ToLower(v) {
+		case snapshotCompressionNone, snapshotCompressionGzip, snapshotCompressionZstd:
+			compression = strings.ToLower(v)
+		default:
+			return "", status.Errorf(codes.InvalidArgument, "invalid snapshotCompression %s in snapshot class", v)
+		}
+	}
+	return compression, nil
+}
+
+// snapshotArchiveBaseName returns the archive name without the format specific extension.
+func snapshotArchiveBaseName(snap *nfsSnapshot) string {
+	return strings.TrimSuffix(snap.archiveName(), gzipArchiveSuffix)
+}
+
+// snapshotArchiveName returns the archive file name for the compression format.
+// gzip archives keep the upstream name, so existing snapshots stay valid.
+func snapshotArchiveName(snap *nfsSnapshot, compression string) string {
+	switch compression {
+	case snapshotCompressionNone:
+		return snapshotArchiveBaseName(snap) + ".tar"
+	case snapshotCompressionZstd:
+		return snapshotArchiveBaseName(snap) + ".tar.zst"
+	default:
+		return snap.archiveName()
+	}
+}
+
+func snapshotManifestName(snap *nfsSnapshot) string {
+	return snapshotArchiveBaseName(snap) + snapshotManifestSuffix
+}
+
+// isSnapshotFile reports whether name is one of the files CreateSnapshot may
+// leave in the snapshot directory: the archive in any format, its manifest, or
+// their staging leftovers.
+func isSnapshotFile(snap *nfsSnapshot, name string) bool {
+	name = strings.TrimSuffix(name, populatingSuffix)
+	if name == snapshotManifestName(snap) {
+		return true
+	}
+	for _, compression := range []string{snapshotCompressionNone, snapshotCompressionGzip, snapshotCompressionZstd} {
+		if name == snapshotArchiveName(snap, compression) {
+			return true
+		}
+	}
+	return false
+}
+
+// packSnapshotArchive archives srcPath to dstPath using the compression format
+// and returns the manifest describing the archive.
+func packSnapshotArchive(srcPath, dstPath, compression string, useTarCommand bool) (*snapshotManifest, error) {
+	manifest := &snapshotManifest{
+		Format:       compression,
+		Archive:      filepath.Base(strings.TrimSuffix(dstPath, populatingSuffix)),
+		CreationTime: time.Now().UTC(),
+	}
+
+	err := filepath.WalkDir(srcPath, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		manifest.FileCount++
+		manifest.UncompressedSizeBytes += info.Size()
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", srcPath, err)
+	}
+
+	switch compression {
+	case snapshotCompressionNone, snapshotCompressionGzip:
+		if err := packTar(srcPath, dstPath, compression == snapshotCompressionGzip, useTarCommand); err != nil {
+			return nil, err
+		}
+	case snapshotCompressionZstd:
+		// tar is written uncompressed first and then compressed by the zstd
+		// utility, which is noticeably faster than any in-process encoder.
+		// The intermediate tar is named as a staging plain archive, so
+		// leftovers of an interrupted attempt are recognized and replaced.
+		tarPath := filepath.Join(filepath.Dir(dstPath), strings.TrimSuffix(manifest.Archive, ".zst")+populatingSuffix)
+		defer os.Remove(tarPath)
+		if err := packTar(srcPath, tarPath, false, useTarCommand); err != nil {
+			return nil, err
+		}
+		if out, err := exec.Command("zstd", "-q", "-f", "-T0", tarPath, "-o", dstPath).CombinedOutput(); err != nil {
+			return nil, fmt.Errorf("zstd failed: %v: %s", err, string(out))
+		}
+	default:
+		return nil, fmt.Errorf("unsupported snapshot compression %q", compression)
+	}
+
+	manifest.SHA256, err = fileSHA256(dstPath)
+	if err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+func packTar(srcPath, dstPath string, compress, useTarCommand bool) error {
+	if useTarCommand {
+		flags := "-cvf"
+		if compress {
+			flags = "-czvf"
+		}
+		if out, err := exec.Command("tar", "-C", srcPath, flags, dstPath, ".").CombinedOutput(); err != nil {
+			return fmt.Errorf("%v: %s", err, string(out))
+		}
+		return nil
+	}
+	return TarPack(srcPath, dstPath, compress)
+}
+
+// unpackSnapshotArchive extracts the archive described by the manifest to dstPath.
+func unpackSnapshotArchive(archivePath, dstPath string, manifest *snapshotManifest, useTarCommand bool) error {
+	switch manifest.Format {
+	case snapshotCompressionNone, snapshotCompressionGzip:
+		return unpackTar(archivePath, dstPath, manifest.Format == snapshotCompressionGzip, useTarCommand)
+	case snapshotCompressionZstd:
+		tarPath := dstPath + ".tar"
+		defer os.Remove(tarPath)
+		if out, err := exec.Command("zstd", "-q", "-d", "-f", archivePath, "-o", tarPath).CombinedOutput(); err != nil {
+			return fmt.Errorf("zstd failed: %v: %s", err, string(out))
+		}
+		return unpackTar(tarPath, dstPath, false, useTarCommand)
+	default:
+		return fmt.Errorf("unsupported snapshot compression %q", manifest.Format)
+	}
+}
+
+func unpackTar(archivePath, dstPath string, compressed, useTarCommand bool) error {
+	if useTarCommand {
+		flags := "-xvf"
+		if compressed {
+			flags = "-xzvf"
+		}
+		if out, err := exec.Command("tar", flags, archivePath, "-C", dstPath).CombinedOutput(); err != nil {
+			return fmt.Errorf("%v: %s", err, string(out))
+		}
+		return nil
+	}
+	return TarUnpack(archivePath, dstPath, compressed)
+}
+
+// writeSnapshotManifest atomically writes the manifest to manifestPath.
+func writeSnapshotManifest(manifestPath string, manifest *snapshotManifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	stagingPath := manifestPath + populatingSuffix
+	if err := os.WriteFile(stagingPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(stagingPath, manifestPath)
+}
+
+// readSnapshotManifest returns the manifest stored in snapDir, or a manifest
+// describing the legacy gzip archive (without a checksum) if there is none.
+func readSnapshotManifest(snapDir string, snap *nfsSnapshot) (*snapshotManifest, error) {
+	data, err := os.ReadFile(filepath.Join(snapDir, snapshotManifestName(snap)))
+	if errors.Is(err, os.ErrNotExist) {
+		return &snapshotManifest{Format: defaultSnapshotCompression, Archive: snap.archiveName()}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	manifest := &snapshotManifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot manifest: %w", err)
+	}
+	if manifest.Archive == "" || manifest.Archive != filepath.Base(manifest.Archive) {
+		return nil, fmt.Errorf("invalid archive name %q in snapshot manifest", manifest.Archive)
+	}
+	return manifest, nil
+}
+
+// resolveSnapshotArchive finds the archive of the snapshot in snapDir and
+// verifies its checksum against the manifest. A corrupted archive is never
+// restored.
+func resolveSnapshotArchive(snapDir string, snap *nfsSnapshot) (string, *snapshotManifest, error) {
+	manifest, err := readSnapshotManifest(snapDir, snap)
+	if err != nil {
+		return "", nil, status.Errorf(codes.Internal, "failed to read manifest of snapshot archive %s: %v", snap.archiveName(), err)
+	}
+	archivePath := filepath.Join(snapDir, manifest.Archive)
+	if err := verifySnapshotArchive(archivePath, manifest); err != nil {
+		return "", nil, err
+	}
+	return archivePath, manifest, nil
+}
+
+func verifySnapshotArchive(archivePath string, manifest *snapshotManifest) error {
+	if manifest.SHA256 == "" {
+		klog.V(2).Infof("snapshot archive %s has no manifest, skipping checksum verification", archivePath)
+		return nil
+	}
+	checksum, err := fileSHA256(archivePath)
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to calculate checksum of snapshot archive %s: %v", archivePath, err)
+	}
+	if checksum != manifest.SHA256 {
+		return status.Errorf(codes.DataLoss, "snapshot archive %s is corrupted: sha256 is %s, manifest expects %s", archivePath, checksum, manifest.SHA256)
+	}
+	klog.V(2).Infof("snapshot archive %s checksum verified", archivePath)
+	return nil
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2026 Flant JSC
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nfs
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestGetSnapshotCompression(t *testing.T) {
+	tests := []struct {
+		params    map[string]string
+		expected  string
+		expectErr bool
+	}{
+		{params: nil, expected: snapshotCompressionGzip},
+		{params: map[string]string{"snapshotCompression": ""}, expected: snapshotCompressionGzip},
+		{params: map[string]string{"snapshotCompression": "none"}, expected: snapshotCompressionNone},
+		{params: map[string]string{"snapshotcompression": "ZSTD"}, expected: snapshotCompressionZstd},
+		{params: map[string]string{"snapshotCompression": "lz4"}, expectErr: true},
+	}
+	for _, test := range tests {
+		compression, err := getSnapshotCompression(test.params)
+		if (err != nil) != test.expectErr {
+			t.Errorf("params %v: unexpected error: %v", test.params, err)
+		}
+		if compression != test.expected {
+			t.Errorf("params %v: got %q, want %q", test.params, compression, test.expected)
+		}
+	}
+}
+
+func TestSnapshotArchiveRoundTrip(t *testing.T) {
+	formats := []string{snapshotCompressionNone, snapshotCompressionGzip}
+	if _, err := exec.LookPath("zstd"); err == nil {
+		formats = append(formats, snapshotCompressionZstd)
+	}
+
+	for _, compression := range formats {
+		t.Run(compression, func(t *testing.T) {
+			srcPath := t.TempDir()
+			if err := os.WriteFile(filepath.Join(srcPath, "disk.img"), []byte("snapshot-data"), 0644); err != nil {
+				t.Fatalf("failed to write source content: %v", err)
+			}
+			if err := os.MkdirAll(filepath.Join(srcPath, "dir"), 0777); err != nil {
+				t.Fatalf("failed to create source dir: %v", err)
+			}
+			if err := os.WriteFile(filepath.Join(srcPath, "dir", "file"), []byte("data"), 0644); err != nil {
+				t.Fatalf("failed to write source content: %v", err)
+			}
+
+			snap := &nfsSnapshot{src: "src-pv"}
+			snapDir := t.TempDir()
+			archivePath := filepath.Join(snapDir, snapshotArchiveName(snap, compression))
+			manifest, err := packSnapshotArchive(srcPath, archivePath, compression, false)
+			if err != nil {
+				t.Fatalf("packSnapshotArchive failed: %v", err)
+			}
+			if manifest.FileCount != 2 || manifest.UncompressedSizeBytes != int64(len("snapshot-data")+len("data")) {
+				t.Fatalf("unexpected manifest: %+v", manifest)
+			}
+			if err := writeSnapshotManifest(filepath.Join(snapDir, snapshotManifestName(snap)), manifest); err != nil {
+				t.Fatalf("writeSnapshotManifest failed: %v", err)
+			}
+
+			resolvedPath, resolvedManifest, err := resolveSnapshotArchive(snapDir, snap)
+			if err != nil {
+				t.Fatalf("resolveSnapshotArchive failed: %v", err)
+			}
+			if resolvedPath != archivePath || resolvedManifest.Format != compression {
+				t.Fatalf("resolved %s (%s), want %s (%s)", resolvedPath, resolvedManifest.Format, archivePath, compression)
+			}
+
+			dstPath := t.TempDir()
+			if err := unpackSnapshotArchive(resolvedPath, dstPath, resolvedManifest, false); err != nil {
+				t.Fatalf("unpackSnapshotArchive failed: %v", err)
+			}
+			data, err := os.ReadFile(filepath.Join(dstPath, "dir", "file"))
+			if err != nil || string(data) != "data" {
+				t.Fatalf("restored content mismatch: %q, %v", data, err)
+			}
+		})
+	}
+}
+
+func TestResolveSnapshotArchiveChecksumMismatch(t *testing.T) {
+	srcPath := t.TempDir()
+	if err := os.WriteFile(filepath.Join(srcPath, "disk.img"), []byte("snapshot-data"), 0644); err != nil {
+		t.Fatalf("failed to write source content: %v", err)
+	}
+
+	snap := &nfsSnapshot{src: "src-pv"}
+	snapDir := t.TempDir()
+	archivePath := filepath.Join(snapDir, snapshotArchiveName(snap, snapshotCompressionNone))
+	manifest, err := packSnapshotArchive(srcPath, archivePath, snapshotCompressionNone, false)
+	if err != nil {
+		t.Fatalf("packSnapshotArchive failed: %v", err)
+	}
+	if err := writeSnapshotManifest(filepath.Join(snapDir, snapshotManifestName(snap)), manifest); err != nil {
+		t.Fatalf("writeSnapshotManifest failed: %v", err)
+	}
+
+	f, err := os.OpenFile(archivePath, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("failed to open archive: %v", err)
+	}
+	if _, err := f.Write([]byte("garbage")); err != nil {
+		t.Fatalf("failed to corrupt archive: %v", err)
+	}
+	f.Close()
+
+	_, _, err = resolveSnapshotArchive(snapDir, snap)
+	if status.Code(err) != codes.DataLoss {
+		t.Fatalf("expected DataLoss for a corrupted archive, got: %v", err)
+	}
+}
+
+func TestResolveSnapshotArchiveWithoutManifest(t *testing.T) {
+	snap := &nfsSnapshot{src: "src-pv"}
+	snapDir := t.TempDir()
+
+	archivePath, manifest, err := resolveSnapshotArchive(snapDir, snap)
+	if err != nil {
+		t.Fatalf("resolveSnapshotArchive failed: %v", err)
+	}
+	if archivePath != filepath.Join(snapDir, snap.archiveName()) || manifest.Format != snapshotCompressionGzip {
+		t.Fatalf("legacy archive resolved to %s (%s)", archivePath, manifest.Format)
+	}
+}
+
+func TestIsSnapshotFile(t *testing.T) {
+	snap := &nfsSnapshot{src: "src-pv"}
+	for _, name := range []string{"src-pv.tar.gz", "src-pv.tar", "src-pv.tar.zst", "src-pv.tar.zst.populating", "src-pv.manifest.json", "src-pv.manifest.json.populating"} {
+		if !isSnapshotFile(snap, name) {
+			t.Errorf("%s must be recognized as a snapshot file", name)
+		}
+	}
+	for _, name := range []string{"other-pv.tar.gz", "src-pv.tar.bz2"} {
+		if isSnapshotFile(snap, name) {
+			t.Errorf("%s must not be recognized as a snapshot file", name)
+		}
+	}
+}
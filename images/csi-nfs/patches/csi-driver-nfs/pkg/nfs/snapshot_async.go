@@ -0,0 +1,303 @@
+/*
+Copyright 2026 Flant JSC
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"k8s.io/klog/v2"
+)
+
+// snapshotJobsDir is the directory under the working mount dir where
+// background snapshot jobs mount the source and snapshot shares. The jobs
+// outlive the CreateSnapshot call, so they can not share its internal mounts.
+const snapshotJobsDir = ".snapshot-jobs"
+
+// snapshotJob is an archive being created in the background. Source volumes
+// larger than the async snapshot threshold are archived by a snapshot job:
+// CreateSnapshot returns ReadyToUse=false right away and the CO polls it by
+// calling CreateSnapshot again until the archive is complete.
+type snapshotJob struct {
+	dstPath   string
+	startTime time.Time
+
+	mu   sync.Mutex
+	done bool
+	err  error
+}
+
+var snapshotJobs = struct {
+	sync.Mutex
+	jobs map[string]*snapshotJob
+}{jobs: map[string]*snapshotJob{}}
+
+func (j *snapshotJob) result() (bool, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.done, j.err
+}
+
+func (j *snapshotJob) finish(err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.done = true
+	j.err = err
+}
+
+// response reports the state of the job. While the archive is being created,
+// the snapshot is not ready and SizeBytes is the size of the archive written
+// so far.
+func (j *snapshotJob) response(snapshot *nfsSnapshot, srcVol *nfsVolume) *csi.CreateSnapshotResponse {
+	done, _ := j.result()
+	path := j.dstPath
+	if !done {
+		path += populatingSuffix
+	}
+	var size int64
+	if fi, err := os.Stat(path); err == nil {
+		size = fi.Size()
+	}
+	if !done {
+		klog.V(2).Infof("CreateSnapshot: archive %s is being created in the background: %d bytes written in %s", j.dstPath, size, time.Since(j.startTime).Round(time.Second))
+	}
+
+	return &csi.CreateSnapshotResponse{
+		Snapshot: &csi.Snapshot{
+			SnapshotId:     snapshot.id,
+			SourceVolumeId: srcVol.id,
+			SizeBytes:      size,
+			CreationTime:   timestamppb.New(j.startTime),
+			ReadyToUse:     done,
+		},
+	}
+}
+
+// lookupSnapshotJob returns the background job creating the snapshot archive,
+// if there is one. A finished job is forgotten once its result has been
+// reported, so a failed job is restarted by the next CreateSnapshot call.
+// Must be called with snapshotJobs locked.
+func lookupSnapshotJob(uuid string) (*snapshotJob, error) {
+	job, ok := snapshotJobs.jobs[uuid]
+	if !ok {
+		return nil, nil
+	}
+	done, err := job.result()
+	if !done {
+		return job, nil
+	}
+	delete(snapshotJobs.jobs, uuid)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to create archive for snapshot: %v", err)
+	}
+	return job, nil
+}
+
+// isSnapshotJobRunning reports whether the archive of the snapshot is still being created.
+func isSnapshotJobRunning(uuid string) bool {
+	snapshotJobs.Lock()
+	defer snapshotJobs.Unlock()
+
+	job, ok := snapshotJobs.jobs[uuid]
+	if !ok {
+		return false
+	}
+	done, _ := job.result()
+	return !done
+}
+
+// getSnapshotJob returns the background job creating the snapshot archive.
+// A new job is started if the source volume is larger than the async snapshot
+// threshold. It returns nil if the snapshot has to be created synchronously.
+// The job exports the archive once it is created if the secrets enable the
+// snapshot export.
+func (cs *ControllerServer) getSnapshotJob(snapshot *nfsSnapshot, srcVol *nfsVolume, srcPath, dstPath, compression string, params, secrets map[string]string) (*snapshotJob, error) {
+	snapshotJobs.Lock()
+	job, err := lookupSnapshotJob(snapshot.uuid)
+	snapshotJobs.Unlock()
+	if job != nil || err != nil {
+		return job, err
+	}
+
+	if cs.Driver.asyncSnapshotThreshold <= 0 {
+		return nil, nil
+	}
+	large, err := isDirLargerThan(srcPath, cs.Driver.asyncSnapshotThreshold)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to determine size of source volume %s: %v", srcPath, err)
+	}
+	if !large {
+		return nil, nil
+	}
+
+	snapshotJobs.Lock()
+	defer snapshotJobs.Unlock()
+	if job, ok := snapshotJobs.jobs[snapshot.uuid]; ok {
+		return job, nil
+	}
+
+	job = &snapshotJob{dstPath: dstPath, startTime: time.Now()}
+	snapshotJobs.jobs[snapshot.uuid] = job
+
+	mountParams := make(map[string]string, len(params))
+	for k, v := range params {
+		mountParams[k] = v
+	}
+	exportSecrets := make(map[string]string, len(secrets))
+	for k, v := range secrets {
+		exportSecrets[k] = v
+	}
+	klog.V(2).Infof("CreateSnapshot: source volume %s is larger than %d bytes, creating archive %s in the background", srcPath, cs.Driver.asyncSnapshotThreshold, dstPath)
+	go func() {
+		err := cs.runSnapshotJob(snapshot, srcVol, filepath.Base(dstPath), compression, mountParams, exportSecrets)
+		if err != nil {
+			klog.Errorf("background snapshot %s failed: %v", snapshot.uuid, err)
+		} else {
+			klog.V(2).Infof("background snapshot %s completed in %s", snapshot.uuid, time.Since(job.startTime).Round(time.Second))
+		}
+		job.finish(err)
+	}()
+	return job, nil
+}
+
+// runSnapshotJob mounts the source and the snapshot shares under a job specific
+// directory, creates the snapshot archive there and exports it.
+func (cs *ControllerServer) runSnapshotJob(snapshot *nfsSnapshot, srcVol *nfsVolume, archiveName, compression string, params, secrets map[string]string) error {
+	ctx := context.Background()
+	snapVol := volumeFromSnapshot(snapshot)
+	jobRoot := filepath.Join(cs.Driver.workingMountDir, snapshotJobsDir, snapshot.uuid)
+
+	// the mount points are removed on unmount, only the empty job directory
+	// is left; never remove it recursively, the shares may still be mounted
+	defer os.Remove(jobRoot)
+	for _, vol := range []*nfsVolume{srcVol, snapVol} {
+		if err := cs.jobMount(ctx, jobRoot, vol, params); err != nil {
+			return fmt.Errorf("failed to mount nfs server: %w", err)
+		}
+		defer cs.jobUnmount(ctx, jobRoot, vol)
+	}
+
+	srcPath := getInternalVolumePath(jobRoot, srcVol)
+	snapPath := getInternalVolumePath(jobRoot, snapVol)
+	if err := createSnapshotArchive(srcPath, snapPath, filepath.Join(snapPath, archiveName), snapshot, compression, cs.Driver.useTarCommandInSnapshot); err != nil {
+		return err
+	}
+	return exportSnapshot(ctx, secrets, snapPath, snapshot)
+}
+
+// jobMount mounts the share of vol under jobRoot the same way internalMount
+// mounts it under the working mount dir.
+func (cs *ControllerServer) jobMount(ctx context.Context, jobRoot string, vol *nfsVolume, params map[string]string) error {
+	sharePath := filepath.Join(string(filepath.Separator) + vol.baseDir)
+	targetPath := getInternalMountPath(jobRoot, vol)
+
+	volContext := map[string]string{
+		paramServer: vol.server,
+		paramShare:  sharePath,
+	}
+	for k, v := range params {
+		// only nfs-server:/share is mounted, as in internalMount
+		if strings.ToLower(k) != paramSubDir {
+			volContext[k] = v
+		}
+	}
+
+	klog.V(2).Infof("mounting %s:%s at %s for background snapshot", vol.server, sharePath, targetPath)
+	_, err := cs.Driver.ns.NodePublishVolume(ctx, &csi.NodePublishVolumeRequest{
+		TargetPath: targetPath,
+		VolumeCapability: &csi.VolumeCapability{
+			AccessType: &csi.VolumeCapability_Mount{
+				Mount: &csi.VolumeCapability_MountVolume{},
+			},
+		},
+		VolumeContext: volContext,
+		VolumeId:      vol.id,
+	})
+	return err
+}
+
+func (cs *ControllerServer) jobUnmount(ctx context.Context, jobRoot string, vol *nfsVolume) {
+	targetPath := getInternalMountPath(jobRoot, vol)
+	klog.V(2).Infof("unmounting %s after background snapshot", targetPath)
+	if _, err := cs.Driver.ns.NodeUnpublishVolume(ctx, &csi.NodeUnpublishVolumeRequest{
+		VolumeId:   vol.id,
+		TargetPath: targetPath,
+	}); err != nil {
+		klog.Warningf("failed to unmount %s: %v", targetPath, err)
+	}
+}
+
+// createSnapshotArchive archives srcPath to dstPath through a staging file,
+// writing the manifest before the archive is renamed into place: a complete
+// archive must always be verifiable on restore.
+func createSnapshotArchive(srcPath, snapPath, dstPath string, snapshot *nfsSnapshot, compression string, useTarCommand bool) error {
+	stagingPath := dstPath + populatingSuffix
+	// remove leftovers of a previously interrupted copy
+	if err := os.RemoveAll(stagingPath); err != nil {
+		return fmt.Errorf("failed to remove stale staging archive %s: %w", stagingPath, err)
+	}
+	klog.V(2).Infof("tar %v -> %v (compression: %s)", srcPath, dstPath, compression)
+	manifest, err := packSnapshotArchive(srcPath, stagingPath, compression, useTarCommand)
+	if err != nil {
+		return err
+	}
+	if err := writeSnapshotManifest(filepath.Join(snapPath, snapshotManifestName(snapshot)), manifest); err != nil {
+		return fmt.Errorf("failed to write snapshot manifest: %w", err)
+	}
+	if err := os.Rename(stagingPath, dstPath); err != nil {
+		return fmt.Errorf("failed to finalize snapshot archive %s: %w", dstPath, err)
+	}
+	klog.V(2).Infof("tar %s -> %s complete", srcPath, dstPath)
+	return nil
+}
+
+var errSizeLimitReached = errors.New("size limit reached")
+
+// isDirLargerThan reports whether the regular files under path take more than
+// limit bytes. The walk stops as soon as the limit is exceeded.
+func isDirLargerThan(path string, limit int64) (bool, error) {
+	var size int64
+	err := filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		if size > limit {
+			return errSizeLimitReached
+		}
+		return nil
+	})
+	if errors.Is(err, errSizeLimitReached) {
+		return true, nil
+	}
+	return false, err
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2026 Flant JSC
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nfs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestIsDirLargerThan(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0777); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sub", "file"), make([]byte, 100), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	for limit, expected := range map[int64]bool{0: true, 99: true, 100: false, 1000: false} {
+		large, err := isDirLargerThan(dir, limit)
+		if err != nil {
+			t.Fatalf("isDirLargerThan failed: %v", err)
+		}
+		if large != expected {
+			t.Errorf("limit %d: got %v, want %v", limit, large, expected)
+		}
+	}
+}
+
+func TestSnapshotJobLifecycle(t *testing.T) {
+	dstPath := filepath.Join(t.TempDir(), "src-pv.tar.gz")
+	snapshot := &nfsSnapshot{id: "snap-id", uuid: "snap-uuid", src: "src-pv"}
+	srcVol := &nfsVolume{id: "vol-id"}
+
+	job := &snapshotJob{dstPath: dstPath}
+	snapshotJobs.Lock()
+	snapshotJobs.jobs[snapshot.uuid] = job
+	snapshotJobs.Unlock()
+	defer func() {
+		snapshotJobs.Lock()
+		delete(snapshotJobs.jobs, snapshot.uuid)
+		snapshotJobs.Unlock()
+	}()
+
+	if err := os.WriteFile(dstPath+populatingSuffix, make([]byte, 10), 0644); err != nil {
+		t.Fatalf("failed to write staging archive: %v", err)
+	}
+	if !isSnapshotJobRunning(snapshot.uuid) {
+		t.Fatalf("job must be running")
+	}
+	resp := job.response(snapshot, srcVol)
+	if resp.Snapshot.ReadyToUse || resp.Snapshot.SizeBytes != 10 {
+		t.Fatalf("unexpected response for a running job: %+v", resp.Snapshot)
+	}
+
+	if err := os.Rename(dstPath+populatingSuffix, dstPath); err != nil {
+		t.Fatalf("failed to finalize archive: %v", err)
+	}
+	job.finish(nil)
+	if isSnapshotJobRunning(snapshot.uuid) {
+		t.Fatalf("job must be finished")
+	}
+	snapshotJobs.Lock()
+	found, err := lookupSnapshotJob(snapshot.uuid)
+	_, kept := snapshotJobs.jobs[snapshot.uuid]
+	snapshotJobs.Unlock()
+	if err != nil || found != job || kept {
+		t.Fatalf("finished job must be reported once and forgotten: %v, %v, %v", found, err, kept)
+	}
+	resp = found.response(snapshot, srcVol)
+	if !resp.Snapshot.ReadyToUse || resp.Snapshot.SizeBytes != 10 {
+		t.Fatalf("unexpected response for a finished job: %+v", resp.Snapshot)
+	}
+}
+
+func TestSnapshotJobFailure(t *testing.T) {
+	job := &snapshotJob{}
+	job.finish(errors.New("tar failed"))
+	snapshotJobs.Lock()
+	snapshotJobs.jobs["failed-uuid"] = job
+	found, err := lookupSnapshotJob("failed-uuid")
+	_, kept := snapshotJobs.jobs["failed-uuid"]
+	snapshotJobs.Unlock()
+	if found != nil || status.Code(err) != codes.Internal || kept {
+		t.Fatalf("failed job must return Internal and be forgotten: %v, %v, %v", found, err, kept)
+	}
+}
@@ -0,0 +1,233 @@
+/*
+Copyright 2026 Flant JSC
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	"k8s.io/klog/v2"
+)
+
+// The snapshot export target is passed in the provisioner and snapshotter
+// secret, the same way as the volume cleanup method.
+const (
+	snapshotExportS3EndpointKey        = "snapshotExportS3Endpoint"
+	snapshotExportS3BucketKey          = "snapshotExportS3Bucket"
+	snapshotExportS3RegionKey          = "snapshotExportS3Region"
+	snapshotExportS3PrefixKey          = "snapshotExportS3Prefix"
+	snapshotExportS3AccessKeyIDKey     = "snapshotExportS3AccessKeyID"
+	snapshotExportS3SecretAccessKeyKey = "snapshotExportS3SecretAccessKey"
+
+	// the object metadata key holding the archive checksum
+	snapshotExportChecksumMetadata = "sha256"
+)
+
+// snapshotExporter copies snapshot archives to an S3-compatible object store
+// and back. Objects are stored under <prefix>/<server>/<share>/<uuid>/ with
+// the same names as in the snapshot directory.
+type snapshotExporter struct {
+	client *s3Client
+	prefix string
+}
+
+// getSnapshotExporter returns the exporter configured by the secret, or nil if
+// snapshot export is not enabled.
+func getSnapshotExporter(secretData map[string]string) (*snapshotExporter, error) {
+	endpoint, ok := secretData[snapshotExportS3EndpointKey]
+	if !ok || endpoint == "" {
+		return nil, nil
+	}
+	accessKey := secretData[snapshotExportS3AccessKeyIDKey]
+	secretKey := secretData[snapshotExportS3SecretAccessKeyKey]
+	if accessKey == "" || secretKey == "" {
+		return nil, fmt.Errorf("snapshot export credentials are not set")
+	}
+	client, err := newS3Client(endpoint, secretData[snapshotExportS3RegionKey], secretData[snapshotExportS3BucketKey], accessKey, secretKey)
+	if err != nil {
+		return nil, err
+	}
+	return &snapshotExporter{client: client, prefix: secretData[snapshotExportS3PrefixKey]}, nil
+}
+
+// exportSnapshot exports the archive of the snapshot in snapDir if the secret
+// enables the snapshot export.
+func exportSnapshot(ctx context.Context, secretData map[string]string, snapDir string, snap *nfsSnapshot) error {
+	exporter, err := getSnapshotExporter(secretData)
+	if err != nil || exporter == nil {
+		return err
+	}
+	return exporter.exportSnapshotArchive(ctx, snapDir, snap)
+}
+
+// importSnapshot imports the archive of the snapshot to snapDir if it is
+// missing there and the secret enables the snapshot export.
+func importSnapshot(ctx context.Context, secretData map[string]string, snapDir string, snap *nfsSnapshot) error {
+	exporter, err := getSnapshotExporter(secretData)
+	if err != nil || exporter == nil {
+		return err
+	}
+	return exporter.importSnapshotArchive(ctx, snapDir, snap)
+}
+
+func (e *snapshotExporter) objectKey(snap *nfsSnapshot, name string) string {
+	return path.Join(e.prefix, snap.server, snap.baseDir, snap.uuid, name)
+}
+
+// exportSnapshotArchive uploads the archive of the snapshot in snapDir and its
+// manifest. An archive already uploaded with the same checksum is not uploaded
+// again, so the export may be retried.
+func (e *snapshotExporter) exportSnapshotArchive(ctx context.Context, snapDir string, snap *nfsSnapshot) error {
+	manifest, err := readSnapshotManifest(snapDir, snap)
+	if err != nil {
+		return fmt.Errorf("failed to read manifest of snapshot archive %s: %w", snap.archiveName(), err)
+	}
+	archivePath := filepath.Join(snapDir, manifest.Archive)
+	checksum := manifest.SHA256
+	if checksum == "" {
+		// archives created before the manifest was introduced
+		if checksum, err = fileSHA256(archivePath); err != nil {
+			return fmt.Errorf("failed to calculate checksum of snapshot archive %s: %w", archivePath, err)
+		}
+	}
+
+	archiveKey := e.objectKey(snap, manifest.Archive)
+	metadata, err := e.client.headObject(ctx, archiveKey)
+	if err != nil && !errors.Is(err, errS3ObjectNotFound) {
+		return err
+	}
+	if err == nil && metadata[snapshotExportChecksumMetadata] == checksum {
+		klog.V(2).Infof("snapshot archive %s is already exported to %s", archivePath, archiveKey)
+	} else {
+		klog.V(2).Infof("exporting snapshot archive %s to %s", archivePath, archiveKey)
+		if err := e.client.uploadFile(ctx, archiveKey, archivePath, map[string]string{snapshotExportChecksumMetadata: checksum}); err != nil {
+			return err
+		}
+	}
+
+	if manifest.SHA256 == "" {
+		return nil
+	}
+	data, err := os.ReadFile(filepath.Join(snapDir, snapshotManifestName(snap)))
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot manifest: %w", err)
+	}
+	return e.client.putObject(ctx, e.objectKey(snap, snapshotManifestName(snap)), data)
+}
+
+// importSnapshotArchive downloads the archive of the snapshot and its manifest
+// to snapDir if the archive is missing there, e.g. because the snapshot share
+// was lost or replaced. The archive is downloaded to a staging path and its
+// checksum verified before it is renamed into place.
+func (e *snapshotExporter) importSnapshotArchive(ctx context.Context, snapDir string, snap *nfsSnapshot) error {
+	manifest, err := readSnapshotManifest(snapDir, snap)
+	if err == nil {
+		if _, err = os.Stat(filepath.Join(snapDir, manifest.Archive)); err == nil {
+			return nil
+		}
+	}
+
+	var manifestData bytes.Buffer
+	err = e.client.getObject(ctx, e.objectKey(snap, snapshotManifestName(snap)), &manifestData)
+	switch {
+	case errors.Is(err, errS3ObjectNotFound):
+		manifest = &snapshotManifest{Format: defaultSnapshotCompression, Archive: snap.archiveName()}
+	case err != nil:
+		return err
+	default:
+		manifest = &snapshotManifest{}
+		if err := json.Unmarshal(manifestData.Bytes(), manifest); err != nil {
+			return fmt.Errorf("failed to parse exported snapshot manifest: %w", err)
+		}
+		if manifest.Archive == "" || manifest.Archive != filepath.Base(manifest.Archive) {
+			return fmt.Errorf("invalid archive name %q in exported snapshot manifest", manifest.Archive)
+		}
+	}
+
+	archiveKey := e.objectKey(snap, manifest.Archive)
+	metadata, err := e.client.headObject(ctx, archiveKey)
+	if err != nil {
+		return fmt.Errorf("snapshot archive %s is missing locally and could not be found in the object store: %w", manifest.Archive, err)
+	}
+	checksum := manifest.SHA256
+	if checksum == "" {
+		checksum = metadata[snapshotExportChecksumMetadata]
+	}
+
+	if err := os.MkdirAll(snapDir, 0o777); err != nil {
+		return fmt.Errorf("failed to create snapshot directory %s: %w", snapDir, err)
+	}
+	archivePath := filepath.Join(snapDir, manifest.Archive)
+	stagingPath := archivePath + populatingSuffix
+	klog.V(2).Infof("importing snapshot archive %s from %s", archivePath, archiveKey)
+	if err := downloadFile(ctx, e.client, archiveKey, stagingPath); err != nil {
+		os.Remove(stagingPath)
+		return err
+	}
+	if checksum != "" {
+		actual, err := fileSHA256(stagingPath)
+		if err != nil {
+			os.Remove(stagingPath)
+			return fmt.Errorf("failed to calculate checksum of imported snapshot archive: %w", err)
+		}
+		if actual != checksum {
+			os.Remove(stagingPath)
+			return fmt.Errorf("imported snapshot archive %s is corrupted: sha256 is %s, expected %s", archiveKey, actual, checksum)
+		}
+	}
+	if manifestData.Len() > 0 {
+		if err := writeSnapshotManifest(filepath.Join(snapDir, snapshotManifestName(snap)), manifest); err != nil {
+			os.Remove(stagingPath)
+			return fmt.Errorf("failed to write snapshot manifest: %w", err)
+		}
+	}
+	if err := os.Rename(stagingPath, archivePath); err != nil {
+		return fmt.Errorf("failed to finalize imported snapshot archive %s: %w", archivePath, err)
+	}
+	klog.V(2).Infof("snapshot archive %s imported from %s", archivePath, archiveKey)
+	return nil
+}
+
+// deleteExportedSnapshot deletes the exported archive of the snapshot in any
+// format and its manifest.
+func (e *snapshotExporter) deleteExportedSnapshot(ctx context.Context, snap *nfsSnapshot) error {
+	names := []string{snapshotManifestName(snap)}
+	for _, compression := range []string{snapshotCompressionNone, snapshotCompressionGzip, snapshotCompressionZstd} {
+		names = append(names, snapshotArchiveName(snap, compression))
+	}
+	for _, name := range names {
+		if err := e.client.deleteObject(ctx, e.objectKey(snap, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func downloadFile(ctx context.Context, client *s3Client, key, dstPath string) error {
+	f, err := os.Create(dstPath)
+	if err != nil {
+		return err
+	}
+	if err := client.getObject(ctx, key, f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
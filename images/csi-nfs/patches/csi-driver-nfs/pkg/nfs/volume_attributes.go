@@ -0,0 +1,329 @@
+/*
+Copyright 2026 Flant JSC
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nfs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	storagev1beta1 "k8s.io/api/storage/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+)
+
+// volumeAttributesMountOptionsKey is the parameter of a VolumeAttributesClass
+// of the driver with the comma-separated mount options overriding the ones
+// of the StorageClass.
+const volumeAttributesMountOptionsKey = "mountOptions"
+
+// mutableMountOptions are the mount options a VolumeAttributesClass may set,
+// with the check of their value. They only tune the caching and the
+// transport of the client: the options defining the protocol version, the
+// security and the consistency of the data (vers, sec, xprtsec, hard, soft,
+// lock, ...) stay those of the StorageClass. The list is kept in sync with
+// the validation of the NFSStorageClass in lib/go/common.
+var mutableMountOptions = map[string]func(value string) error{
+	"ac":          noMountOptionValue,
+	"noac":        noMountOptionValue,
+	"cto":         noMountOptionValue,
+	"nocto":       noMountOptionValue,
+	"rdirplus":    noMountOptionValue,
+	"nordirplus":  noMountOptionValue,
+	"actimeo":     mountOptionRange(0, 1<<31-1),
+	"acregmin":    mountOptionRange(0, 1<<31-1),
+	"acregmax":    mountOptionRange(0, 1<<31-1),
+	"acdirmin":    mountOptionRange(0, 1<<31-1),
+	"acdirmax":    mountOptionRange(0, 1<<31-1),
+	"nconnect":    mountOptionRange(1, 16),
+	"rsize":       mountOptionRange(1024, 1<<20),
+	"wsize":       mountOptionRange(1024, 1<<20),
+	"timeo":       mountOptionRange(1, 6000),
+	"retrans":     mountOptionRange(1, 100),
+	"lookupcache": mountOptionEnum("all", "none", "pos", "positive"),
+}
+
+// mountOptionGroups maps an option to the options of the StorageClass it
+// replaces.
+var mountOptionGroups = map[string][]string{
+	"ac":         {"ac", "noac"},
+	"noac":       {"ac", "noac"},
+	"cto":        {"cto", "nocto"},
+	"nocto":      {"cto", "nocto"},
+	"rdirplus":   {"rdirplus", "nordirplus"},
+	"nordirplus": {"rdirplus", "nordirplus"},
+	"actimeo":    {"actimeo", "acregmin", "acregmax", "acdirmin", "acdirmax"},
+}
+
+func noMountOptionValue(value string) error {
+	if value != "" {
+		return fmt.Errorf("takes no value")
+	}
+	return nil
+}
+
+func mountOptionRange(min, max int) func(value string) error {
+	return func(value string) error {
+		v, err := strconv.Atoi(value)
+		if err != nil || v < min || v > max {
+			return fmt.Errorf("must be a number from %d to %d", min, max)
+		}
+		return nil
+	}
+}
+
+func mountOptionEnum(values ...string) func(value string) error {
+	return func(value string) error {
+		if !slices.Contains(values, value) {
+			return fmt.Errorf("must be one of %s", strings.Join(values, ", "))
+		}
+		return nil
+	}
+}
+
+// splitMountOption returns the name and the value of a mount option.
+func splitMountOption(option string) (string, string) {
+	name, value, _ := strings.Cut(strings.TrimSpace(option), "=")
+	return name, value
+}
+
+// parseMutableParameters returns the mount options of the mutable
+// parameters of a volume.
+func parseMutableParameters(params map[string]string) ([]string, error) {
+	var options []string
+	for key, value := range params {
+		if key != volumeAttributesMountOptionsKey {
+			return nil, fmt.Errorf("parameter %q is not supported, only %q may be set", key, volumeAttributesMountOptionsKey)
+		}
+		for _, option := range strings.Split(value, ",") {
+			if strings.TrimSpace(option) == "" {
+				continue
+			}
+			name, optionValue := splitMountOption(option)
+			check, ok := mutableMountOptions[name]
+			if !ok {
+				return nil, fmt.Errorf("mount option %q cannot be changed per volume", name)
+			}
+			if err := check(optionValue); err != nil {
+				return nil, fmt.Errorf("mount option %q: %v", name, err)
+			}
+			options = append(options, strings.TrimSpace(option))
+		}
+	}
+	return options, nil
+}
+
+// overrideMountOptions replaces the mount options of the StorageClass with
+// the options of the VolumeAttributesClass.
+func overrideMountOptions(mountFlags, overrides []string) []string {
+	replaced := map[string]bool{}
+	for _, option := range overrides {
+		name, _ := splitMountOption(option)
+		replaced[name] = true
+		for _, other := range mountOptionGroups[name] {
+			replaced[other] = true
+		}
+	}
+	var result []string
+	for _, flags := range mountFlags {
+		for _, option := range strings.Split(flags, ",") {
+			if name, _ := splitMountOption(option); name != "" && !replaced[name] {
+				result = append(result, strings.TrimSpace(option))
+			}
+		}
+	}
+	return append(result, overrides...)
+}
+
+// volumeAttributesControllerServer accepts the VolumeAttributesClasses of
+// the driver. The mount options of a volume are applied by the node when it
+// is mounted, so ControllerModifyVolume only checks them: the external
+// resizer records the new class in the PersistentVolume on success.
+type volumeAttributesControllerServer struct {
+	csi.ControllerServer
+}
+
+// controllerServerWithVolumeAttributes returns the controller server
+// advertising MODIFY_VOLUME.
+func (n *Driver) controllerServerWithVolumeAttributes(cs csi.ControllerServer) csi.ControllerServer {
+	return &volumeAttributesControllerServer{ControllerServer: cs}
+}
+
+func (cs *volumeAttributesControllerServer) ControllerGetCapabilities(ctx context.Context, req *csi.ControllerGetCapabilitiesRequest) (*csi.ControllerGetCapabilitiesResponse, error) {
+	resp, err := cs.ControllerServer.ControllerGetCapabilities(ctx, req)
+	if err != nil || hasControllerCapability(resp.GetCapabilities(), csi.ControllerServiceCapability_RPC_MODIFY_VOLUME) {
+		return resp, err
+	}
+	// the response may share the capabilities of the driver, so they are copied
+	capabilities := append([]*csi.ControllerServiceCapability{}, resp.GetCapabilities()...)
+	capabilities = append(capabilities, &csi.ControllerServiceCapability{
+		Type: &csi.ControllerServiceCapability_Rpc{Rpc: &csi.ControllerServiceCapability_RPC{Type: csi.ControllerServiceCapability_RPC_MODIFY_VOLUME}},
+	})
+	return &csi.ControllerGetCapabilitiesResponse{Capabilities: capabilities}, nil
+}
+
+func (cs *volumeAttributesControllerServer) CreateVolume(ctx context.Context, req *csi.CreateVolumeRequest) (*csi.CreateVolumeResponse, error) {
+	if _, err := parseMutableParameters(req.GetMutableParameters()); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid VolumeAttributesClass parameters: %v", err)
+	}
+	return cs.ControllerServer.CreateVolume(ctx, req)
+}
+
+func (cs *volumeAttributesControllerServer) ControllerModifyVolume(_ context.Context, req *csi.ControllerModifyVolumeRequest) (*csi.ControllerModifyVolumeResponse, error) {
+	if req.GetVolumeId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "volume id is empty")
+	}
+	if _, err := getNfsVolFromID(req.GetVolumeId()); err != nil {
+		return nil, status.Errorf(codes.NotFound, "volume %s: %v", req.GetVolumeId(), err)
+	}
+	options, err := parseMutableParameters(req.GetMutableParameters())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid VolumeAttributesClass parameters: %v", err)
+	}
+	klog.V(2).Infof("ControllerModifyVolume: volume %s will be mounted with %v", req.GetVolumeId(), options)
+	return &csi.ControllerModifyVolumeResponse{}, nil
+}
+
+// volumeAttributesNodeServer mounts the volumes with the mount options of
+// the VolumeAttributesClass of their PersistentVolume. A volume already
+// mounted keeps its options until it is mounted again.
+type volumeAttributesNodeServer struct {
+	csi.NodeServer
+	driverName string
+	kubeClient kubernetes.Interface
+
+	// servesV1 is set once the discovery told whether the cluster serves
+	// the VolumeAttributesClasses as storage.k8s.io/v1
+	mu       sync.Mutex
+	servesV1 *bool
+}
+
+// nodeServerWithVolumeAttributes returns the node server applying the
+// VolumeAttributesClasses.
+func (n *Driver) nodeServerWithVolumeAttributes(ns csi.NodeServer) csi.NodeServer {
+	_, kubeClient, _, err := newInClusterClient(n.name, n.nodeID)
+	if err != nil {
+		klog.Errorf("the mount options of the VolumeAttributesClasses will not be applied: %v", err)
+		return ns
+	}
+	return &volumeAttributesNodeServer{NodeServer: ns, driverName: n.name, kubeClient: kubeClient}
+}
+
+// volumeMountOptions returns the mount options of the VolumeAttributesClass
+// of the PersistentVolume published at targetPath, if any.
+func (ns *volumeAttributesNodeServer) volumeMountOptions(ctx context.Context, targetPath string) ([]string, error) {
+	pvName, err := getPVName(targetPath)
+	if err != nil {
+		// the kubelet writes vol_data.json before publishing the volume
+		klog.Warningf("VolumeAttributesClass of the volume at %s is not applied: %v", targetPath, err)
+		return nil, nil
+	}
+	pv, err := ns.kubeClient.CoreV1().PersistentVolumes().Get(ctx, pvName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get PersistentVolume %s: %v", pvName, err)
+	}
+	if pv.Spec.VolumeAttributesClassName == nil || *pv.Spec.VolumeAttributesClassName == "" {
+		return nil, nil
+	}
+	vac, err := ns.getVolumeAttributesClass(ctx, *pv.Spec.VolumeAttributesClassName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get VolumeAttributesClass %s of PersistentVolume %s: %v", *pv.Spec.VolumeAttributesClassName, pvName, err)
+	}
+	if vac.DriverName != ns.driverName {
+		return nil, nil
+	}
+	return parseMutableParameters(vac.Parameters)
+}
+
+// getVolumeAttributesClass reads a VolumeAttributesClass with
+// storage.k8s.io/v1 when the cluster serves it (Kubernetes 1.34+) and with
+// v1beta1 otherwise. The client of the driver has no v1 type, the objects
+// of both versions have the same fields.
+func (ns *volumeAttributesNodeServer) getVolumeAttributesClass(ctx context.Context, name string) (*storagev1beta1.VolumeAttributesClass, error) {
+	if !ns.volumeAttributesClassV1() {
+		return ns.kubeClient.StorageV1beta1().VolumeAttributesClasses().Get(ctx, name, metav1.GetOptions{})
+	}
+	data, err := ns.kubeClient.StorageV1().RESTClient().Get().Resource("volumeattributesclasses").Name(name).DoRaw(ctx)
+	if err != nil {
+		return nil, err
+	}
+	vac := &storagev1beta1.VolumeAttributesClass{}
+	if err := json.Unmarshal(data, vac); err != nil {
+		return nil, fmt.Errorf("failed to decode VolumeAttributesClass %s: %v", name, err)
+	}
+	return vac, nil
+}
+
+// volumeAttributesClassV1 tells whether the cluster serves the
+// VolumeAttributesClasses as storage.k8s.io/v1. A failed discovery is
+// retried on the next call.
+func (ns *volumeAttributesNodeServer) volumeAttributesClassV1() bool {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	if ns.servesV1 != nil {
+		return *ns.servesV1
+	}
+	resources, err := ns.kubeClient.Discovery().ServerResourcesForGroupVersion("storage.k8s.io/v1")
+	if err != nil {
+		klog.Warningf("failed to discover the resources of storage.k8s.io/v1, the VolumeAttributesClasses are read with v1beta1: %v", err)
+		return false
+	}
+	servesV1 := slices.ContainsFunc(resources.APIResources, func(resource metav1.APIResource) bool {
+		return resource.Name == "volumeattributesclasses"
+	})
+	ns.servesV1 = &servesV1
+	return servesV1
+}
+
+func (ns *volumeAttributesNodeServer) NodePublishVolume(ctx context.Context, req *csi.NodePublishVolumeRequest) (*csi.NodePublishVolumeResponse, error) {
+	options, err := ns.volumeMountOptions(ctx, req.GetTargetPath())
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to get the mount options of volume %s: %v", req.GetVolumeId(), err)
+	}
+	if len(options) == 0 || req.GetVolumeCapability().GetMount() == nil {
+		return ns.NodeServer.NodePublishVolume(ctx, req)
+	}
+
+	klog.V(2).Infof("NodePublishVolume: volume %s is mounted with the options of its VolumeAttributesClass %v", req.GetVolumeId(), options)
+	mount := req.GetVolumeCapability().GetMount()
+	return ns.NodeServer.NodePublishVolume(ctx, &csi.NodePublishVolumeRequest{
+		VolumeId:          req.GetVolumeId(),
+		PublishContext:    req.GetPublishContext(),
+		StagingTargetPath: req.GetStagingTargetPath(),
+		TargetPath:        req.GetTargetPath(),
+		VolumeCapability: &csi.VolumeCapability{
+			AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{
+				FsType:           mount.GetFsType(),
+				MountFlags:       overrideMountOptions(mount.GetMountFlags(), options),
+				VolumeMountGroup: mount.GetVolumeMountGroup(),
+			}},
+			AccessMode: req.GetVolumeCapability().GetAccessMode(),
+		},
+		Readonly:      req.GetReadonly(),
+		Secrets:       req.GetSecrets(),
+		VolumeContext: req.GetVolumeContext(),
+	})
+}
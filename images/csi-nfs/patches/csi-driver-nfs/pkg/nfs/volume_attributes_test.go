@@ -0,0 +1,172 @@
+/*
+Copyright 2026 Flant JSC
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nfs
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	storagev1beta1 "k8s.io/api/storage/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestParseMutableParameters(t *testing.T) {
+	for _, test := range []struct {
+		params  map[string]string
+		options []string
+		err     string
+	}{
+		{params: nil},
+		{params: map[string]string{"mountOptions": "actimeo=0, nconnect=8,noac"}, options: []string{"actimeo=0", "nconnect=8", "noac"}},
+		{params: map[string]string{"mountOptions": "lookupcache=none,"}, options: []string{"lookupcache=none"}},
+		{params: map[string]string{"mountOptions": "vers=3"}, err: `"vers" cannot be changed`},
+		{params: map[string]string{"mountOptions": "soft"}, err: `"soft" cannot be changed`},
+		{params: map[string]string{"mountOptions": "nconnect=32"}, err: "from 1 to 16"},
+		{params: map[string]string{"mountOptions": "noac=1"}, err: "takes no value"},
+		{params: map[string]string{"mountOptions": "lookupcache=some"}, err: "must be one of"},
+		{params: map[string]string{"server": "other"}, err: `parameter "server" is not supported`},
+	} {
+		options, err := parseMutableParameters(test.params)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%v: expected error %q, got %v", test.params, test.err, err)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(options, test.options) {
+			t.Errorf("%v: unexpected options %v: %v", test.params, options, err)
+		}
+	}
+}
+
+func TestOverrideMountOptions(t *testing.T) {
+	flags := []string{"nfsvers=4.1,hard", "nconnect=4", "acregmin=10", "ac", "timeo=600"}
+	options := overrideMountOptions(flags, []string{"nconnect=8", "actimeo=0", "noac"})
+	expected := []string{"nfsvers=4.1", "hard", "timeo=600", "nconnect=8", "actimeo=0", "noac"}
+	if !reflect.DeepEqual(options, expected) {
+		t.Errorf("unexpected mount options %v, expected %v", options, expected)
+	}
+}
+
+func TestVolumeAttributesControllerServer(t *testing.T) {
+	ctx := context.Background()
+	cs := (&Driver{}).controllerServerWithVolumeAttributes(&ControllerServer{})
+
+	caps, err := cs.ControllerGetCapabilities(ctx, &csi.ControllerGetCapabilitiesRequest{})
+	if err != nil || !hasControllerCapability(caps.GetCapabilities(), csi.ControllerServiceCapability_RPC_MODIFY_VOLUME) ||
+		!hasControllerCapability(caps.GetCapabilities(), csi.ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME) {
+		t.Errorf("unexpected capabilities %v: %v", caps, err)
+	}
+
+	if _, err := cs.ControllerModifyVolume(ctx, &csi.ControllerModifyVolumeRequest{
+		VolumeId:          "server#share#pvc-1#pvc-1#",
+		MutableParameters: map[string]string{"mountOptions": "nconnect=8"},
+	}); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if _, err := cs.ControllerModifyVolume(ctx, &csi.ControllerModifyVolumeRequest{
+		VolumeId:          "server#share#pvc-1#pvc-1#",
+		MutableParameters: map[string]string{"mountOptions": "sec=none"},
+	}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument, got %v", err)
+	}
+	if _, err := cs.ControllerModifyVolume(ctx, &csi.ControllerModifyVolumeRequest{VolumeId: "bad"}); status.Code(err) != codes.NotFound {
+		t.Errorf("expected NotFound, got %v", err)
+	}
+	if _, err := cs.CreateVolume(ctx, &csi.CreateVolumeRequest{
+		Name:              "pvc-1",
+		MutableParameters: map[string]string{"mountOptions": "proto=udp"},
+	}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument, got %v", err)
+	}
+}
+
+// publishRecorder is the node server recording the publish requests.
+type publishRecorder struct {
+	csi.NodeServer
+	requests []*csi.NodePublishVolumeRequest
+}
+
+func (ns *publishRecorder) NodePublishVolume(_ context.Context, req *csi.NodePublishVolumeRequest) (*csi.NodePublishVolumeResponse, error) {
+	ns.requests = append(ns.requests, req)
+	return &csi.NodePublishVolumeResponse{}, nil
+}
+
+func TestVolumeAttributesNodeServer(t *testing.T) {
+	ctx := context.Background()
+	kubeletDir := t.TempDir()
+	withClass := createTestPublishedVolume(t, kubeletDir, "uid-1", "pvc-1", testWatchdogDriver)
+	withoutClass := createTestPublishedVolume(t, kubeletDir, "uid-1", "pvc-2", testWatchdogDriver)
+
+	pv1 := testWatchdogPV("pvc-1")
+	className := "nfs-fast"
+	pv1.Spec.VolumeAttributesClassName = &className
+	kubeClient := fake.NewSimpleClientset(pv1, testWatchdogPV("pvc-2"), &storagev1beta1.VolumeAttributesClass{
+		ObjectMeta: metav1.ObjectMeta{Name: className},
+		DriverName: testWatchdogDriver,
+		Parameters: map[string]string{"mountOptions": "nconnect=8,actimeo=0"},
+	})
+	wrapped := &publishRecorder{}
+	ns := &volumeAttributesNodeServer{NodeServer: wrapped, driverName: testWatchdogDriver, kubeClient: kubeClient}
+
+	request := func(target string) *csi.NodePublishVolumeRequest {
+		return &csi.NodePublishVolumeRequest{
+			VolumeId:   "server#share#pvc##",
+			TargetPath: target,
+			VolumeCapability: &csi.VolumeCapability{
+				AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{MountFlags: []string{"nfsvers=4.1", "nconnect=4", "acregmin=10"}}},
+			},
+			VolumeContext: map[string]string{"server": "server"},
+		}
+	}
+
+	req := request(withClass)
+	if _, err := ns.NodePublishVolume(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	published := wrapped.requests[0]
+	if flags := published.GetVolumeCapability().GetMount().GetMountFlags(); !reflect.DeepEqual(flags, []string{"nfsvers=4.1", "nconnect=8", "actimeo=0"}) {
+		t.Errorf("unexpected mount options %v", flags)
+	}
+	if published.GetTargetPath() != withClass || published.GetVolumeContext()["server"] != "server" {
+		t.Errorf("unexpected publish request %+v", published)
+	}
+	if flags := req.GetVolumeCapability().GetMount().GetMountFlags(); len(flags) != 3 || flags[1] != "nconnect=4" {
+		t.Errorf("the request of the kubelet was changed: %v", flags)
+	}
+
+	req = request(withoutClass)
+	if _, err := ns.NodePublishVolume(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	if wrapped.requests[1] != req {
+		t.Errorf("the request of a volume without a VolumeAttributesClass was changed")
+	}
+
+	// the class must be read to mount the volume with its options
+	missing := "missing"
+	pv1.Spec.VolumeAttributesClassName = &missing
+	if _, err := kubeClient.CoreV1().PersistentVolumes().Update(ctx, pv1, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ns.NodePublishVolume(ctx, request(withClass)); err == nil {
+		t.Errorf("expected an error for a missing VolumeAttributesClass")
+	}
+}
@@ -0,0 +1,224 @@
+/*
+Copyright 2026 Flant JSC
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"k8s.io/klog/v2"
+)
+
+// volumeProbeTimeout is how long the node waits for the mount point of a
+// volume to answer before reporting the mount as hung.
+const volumeProbeTimeout = 10 * time.Second
+
+func normalVolumeCondition(message string) *csi.VolumeCondition {
+	return &csi.VolumeCondition{Abnormal: false, Message: message}
+}
+
+func abnormalVolumeCondition(format string, args ...interface{}) *csi.VolumeCondition {
+	return &csi.VolumeCondition{Abnormal: true, Message: fmt.Sprintf(format, args...)}
+}
+
+// accessVolumeDir stats the directory and reads its first entry, which asks
+// the server even if the attributes are cached by the client.
+func accessVolumeDir(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", path)
+	}
+	dir, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	if _, err := dir.Readdirnames(1); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// volumeDirCondition returns the condition of the volume directory on the
+// share mounted by the controller.
+func volumeDirCondition(path string) *csi.VolumeCondition {
+	err := accessVolumeDir(path)
+	switch {
+	case err == nil:
+		return normalVolumeCondition("volume directory is accessible")
+	case errors.Is(err, fs.ErrNotExist):
+		return abnormalVolumeCondition("volume directory does not exist on the share")
+	default:
+		return abnormalVolumeCondition("volume directory is not accessible: %v", err)
+	}
+}
+
+// mountErrorCondition describes the error the mount point of a volume
+// answered with on the node.
+func mountErrorCondition(err error) *csi.VolumeCondition {
+	switch {
+	case errors.Is(err, syscall.ESTALE):
+		return abnormalVolumeCondition("stale NFS file handle: the export or the volume directory was removed or replaced on the server: %v", err)
+	case errors.Is(err, syscall.EIO):
+		return abnormalVolumeCondition("I/O error on the NFS mount: %v", err)
+	case errors.Is(err, fs.ErrPermission):
+		return abnormalVolumeCondition("volume is not accessible, the permissions of the export or the directory changed: %v", err)
+	default:
+		return abnormalVolumeCondition("volume is not accessible: %v", err)
+	}
+}
+
+// volumeProber checks the mount points of the published volumes. A stat of
+// a hung NFS mount never returns, so a mount point with a probe still
+// running is not probed again: the callers wait for the running probe.
+type volumeProber struct {
+	timeout time.Duration
+	access  func(path string) error
+
+	mu       sync.Mutex
+	inFlight map[string]*volumeProbe
+}
+
+type volumeProbe struct {
+	done chan struct{}
+	err  error
+}
+
+func newVolumeProber(timeout time.Duration) *volumeProber {
+	return &volumeProber{
+		timeout:  timeout,
+		access:   accessVolumeDir,
+		inFlight: map[string]*volumeProbe{},
+	}
+}
+
+// probe returns the condition of the mount point, or nil if it does not
+// exist, which is reported by the node server.
+func (p *volumeProber) probe(ctx context.Context, path string) *csi.VolumeCondition {
+	condition, _ := p.check(ctx, path)
+	return condition
+}
+
+// check is probe also reporting whether the mount point answered, so that
+// the caller does not block on a hung mount.
+func (p *volumeProber) check(ctx context.Context, path string) (*csi.VolumeCondition, bool) {
+	p.mu.Lock()
+	probe, running := p.inFlight[path]
+	if !running {
+		probe = &volumeProbe{done: make(chan struct{})}
+		p.inFlight[path] = probe
+		go func() {
+			probe.err = p.access(path)
+			p.mu.Lock()
+			delete(p.inFlight, path)
+			p.mu.Unlock()
+			close(probe.done)
+		}()
+	}
+	p.mu.Unlock()
+
+	timer := time.NewTimer(p.timeout)
+	defer timer.Stop()
+	select {
+	case <-probe.done:
+	case <-timer.C:
+		klog.Warningf("volume mount point %s did not respond in %s", path, p.timeout)
+		return abnormalVolumeCondition("NFS mount is not responding: the mount point did not answer in %s", p.timeout), false
+	case <-ctx.Done():
+		return abnormalVolumeCondition("NFS mount is not responding: %v", ctx.Err()), false
+	}
+
+	switch err := probe.err; {
+	case err == nil:
+		return normalVolumeCondition("volume is accessible"), true
+	case errors.Is(err, fs.ErrNotExist):
+		return nil, true
+	default:
+		klog.Warningf("volume mount point %s: %v", path, err)
+		return mountErrorCondition(err), true
+	}
+}
+
+// volumeConditionNodeServer reports the condition of the mount point of a
+// volume in NodeGetVolumeStats. The statfs of a hung mount blocks and that of
+// a stale one fails, so an abnormal volume is reported with zero usage
+// unless the statfs of a mount which answered succeeds: kubelet drops the
+// stats, condition included, of a response without usage.
+type volumeConditionNodeServer struct {
+	csi.NodeServer
+	prober *volumeProber
+}
+
+// nodeServerWithVolumeCondition returns the node server advertising
+// VOLUME_CONDITION.
+func (n *Driver) nodeServerWithVolumeCondition(ns csi.NodeServer) csi.NodeServer {
+	return &volumeConditionNodeServer{NodeServer: ns, prober: newVolumeProber(volumeProbeTimeout)}
+}
+
+func (ns *volumeConditionNodeServer) NodeGetCapabilities(ctx context.Context, req *csi.NodeGetCapabilitiesRequest) (*csi.NodeGetCapabilitiesResponse, error) {
+	resp, err := ns.NodeServer.NodeGetCapabilities(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+	for _, c := range resp.GetCapabilities() {
+		if c.GetRpc().GetType() == csi.NodeServiceCapability_RPC_VOLUME_CONDITION {
+			return resp, nil
+		}
+	}
+	// the response may share the capabilities of the driver, so they are copied
+	capabilities := append([]*csi.NodeServiceCapability{}, resp.GetCapabilities()...)
+	capabilities = append(capabilities, &csi.NodeServiceCapability{
+		Type: &csi.NodeServiceCapability_Rpc{Rpc: &csi.NodeServiceCapability_RPC{Type: csi.NodeServiceCapability_RPC_VOLUME_CONDITION}},
+	})
+	return &csi.NodeGetCapabilitiesResponse{Capabilities: capabilities}, nil
+}
+
+func (ns *volumeConditionNodeServer) NodeGetVolumeStats(ctx context.Context, req *csi.NodeGetVolumeStatsRequest) (*csi.NodeGetVolumeStatsResponse, error) {
+	condition, answered := ns.prober.check(ctx, req.GetVolumePath())
+	if !answered {
+		return &csi.NodeGetVolumeStatsResponse{Usage: zeroVolumeUsage(), VolumeCondition: condition}, nil
+	}
+	resp, err := ns.NodeServer.NodeGetVolumeStats(ctx, req)
+	if condition.GetAbnormal() {
+		usage := resp.GetUsage()
+		if err != nil || len(usage) == 0 {
+			klog.V(4).Infof("no usage of the abnormal volume %s: %v", req.GetVolumeId(), err)
+			usage = zeroVolumeUsage()
+		}
+		return &csi.NodeGetVolumeStatsResponse{Usage: usage, VolumeCondition: condition}, nil
+	}
+	if err != nil || condition == nil {
+		return resp, err
+	}
+	return &csi.NodeGetVolumeStatsResponse{Usage: resp.GetUsage(), VolumeCondition: condition}, nil
+}
+
+// zeroVolumeUsage is the usage reported for a volume which cannot be stated.
+func zeroVolumeUsage() []*csi.VolumeUsage {
+	return []*csi.VolumeUsage{
+		{Unit: csi.VolumeUsage_BYTES},
+		{Unit: csi.VolumeUsage_INODES},
+	}
+}
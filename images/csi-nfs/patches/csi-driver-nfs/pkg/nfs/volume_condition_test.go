@@ -0,0 +1,158 @@
+/*
+Copyright 2026 Flant JSC
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nfs
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+)
+
+// testNodeServer is the node server the volume condition wraps.
+type testNodeServer struct {
+	csi.NodeServer
+	statsCalls int
+}
+
+func (ns *testNodeServer) NodeGetVolumeStats(_ context.Context, req *csi.NodeGetVolumeStatsRequest) (*csi.NodeGetVolumeStatsResponse, error) {
+	ns.statsCalls++
+	if req.GetVolumePath() == "/stale" {
+		return nil, syscall.ESTALE
+	}
+	return &csi.NodeGetVolumeStatsResponse{Usage: []*csi.VolumeUsage{{Unit: csi.VolumeUsage_BYTES, Total: 100}}}, nil
+}
+
+func (ns *testNodeServer) NodeGetCapabilities(context.Context, *csi.NodeGetCapabilitiesRequest) (*csi.NodeGetCapabilitiesResponse, error) {
+	return &csi.NodeGetCapabilitiesResponse{}, nil
+}
+
+func TestVolumeDirCondition(t *testing.T) {
+	root := t.TempDir()
+	createTestShare(t, root, time.Now(), map[string]time.Duration{testUsedVolume: time.Hour})
+
+	if c := volumeDirCondition(filepath.Join(root, testUsedVolume)); c.GetAbnormal() {
+		t.Errorf("existing directory reported abnormal: %s", c.GetMessage())
+	}
+	if c := volumeDirCondition(filepath.Join(root, testOldVolume)); !c.GetAbnormal() || !strings.Contains(c.GetMessage(), "does not exist") {
+		t.Errorf("unexpected condition of a missing directory %v", c)
+	}
+	if c := volumeDirCondition(filepath.Join(root, testUsedVolume, "data")); !c.GetAbnormal() {
+		t.Errorf("file reported as a normal volume directory")
+	}
+}
+
+func TestVolumeProber(t *testing.T) {
+	ctx := context.Background()
+	hung := make(chan struct{})
+	defer close(hung)
+	var hungCalls atomic.Int32
+	p := newVolumeProber(50 * time.Millisecond)
+	p.access = func(path string) error {
+		switch filepath.Base(path) {
+		case "stale":
+			return &fs.PathError{Op: "stat", Path: path, Err: syscall.ESTALE}
+		case "eio":
+			return fmt.Errorf("readdirent: %w", syscall.EIO)
+		case "denied":
+			return &fs.PathError{Op: "open", Path: path, Err: syscall.EACCES}
+		case "unpublished":
+			return &fs.PathError{Op: "stat", Path: path, Err: syscall.ENOENT}
+		case "hung":
+			hungCalls.Add(1)
+			<-hung
+		}
+		return nil
+	}
+
+	for _, test := range []struct {
+		path     string
+		abnormal bool
+		message  string
+	}{
+		{path: "/ok", message: "accessible"},
+		{path: "/stale", abnormal: true, message: "stale NFS file handle"},
+		{path: "/eio", abnormal: true, message: "I/O error"},
+		{path: "/denied", abnormal: true, message: "permissions"},
+		{path: "/hung", abnormal: true, message: "not responding"},
+	} {
+		c := p.probe(ctx, test.path)
+		if c.GetAbnormal() != test.abnormal || !strings.Contains(c.GetMessage(), test.message) {
+			t.Errorf("%s: unexpected condition %v", test.path, c)
+		}
+	}
+	if c := p.probe(ctx, "/unpublished"); c != nil {
+		t.Errorf("unexpected condition of a missing mount point %v", c)
+	}
+
+	// the hung mount point is not probed again
+	if c := p.probe(ctx, "/hung"); !c.GetAbnormal() {
+		t.Errorf("hung mount reported as normal")
+	}
+	if calls := hungCalls.Load(); calls != 1 {
+		t.Errorf("expected one probe of the hung mount point, got %d", calls)
+	}
+}
+
+func TestVolumeConditionNodeServer(t *testing.T) {
+	ctx := context.Background()
+	wrapped := &testNodeServer{}
+	ns := (&Driver{}).nodeServerWithVolumeCondition(wrapped).(*volumeConditionNodeServer)
+	hung := make(chan struct{})
+	defer close(hung)
+	ns.prober.timeout = 50 * time.Millisecond
+	ns.prober.access = func(path string) error {
+		switch path {
+		case "/stale":
+			return syscall.ESTALE
+		case "/denied":
+			return syscall.EACCES
+		case "/hung":
+			<-hung
+		}
+		return nil
+	}
+
+	caps, err := ns.NodeGetCapabilities(ctx, &csi.NodeGetCapabilitiesRequest{})
+	if err != nil || len(caps.GetCapabilities()) != 1 || caps.GetCapabilities()[0].GetRpc().GetType() != csi.NodeServiceCapability_RPC_VOLUME_CONDITION {
+		t.Errorf("unexpected capabilities %v: %v", caps, err)
+	}
+
+	resp, err := ns.NodeGetVolumeStats(ctx, &csi.NodeGetVolumeStatsRequest{VolumeId: "vol", VolumePath: "/ok"})
+	if err != nil || len(resp.GetUsage()) != 1 || resp.GetVolumeCondition().GetAbnormal() {
+		t.Errorf("unexpected stats %v: %v", resp, err)
+	}
+	// the failed statfs of a stale mount is reported as zero usage
+	resp, err = ns.NodeGetVolumeStats(ctx, &csi.NodeGetVolumeStatsRequest{VolumeId: "vol", VolumePath: "/stale"})
+	if err != nil || len(resp.GetUsage()) != 2 || resp.GetUsage()[0].GetTotal() != 0 || !resp.GetVolumeCondition().GetAbnormal() || wrapped.statsCalls != 2 {
+		t.Errorf("unexpected stats %v: %v", resp, err)
+	}
+	// the usage of a mount which is not readable is still reported
+	resp, err = ns.NodeGetVolumeStats(ctx, &csi.NodeGetVolumeStatsRequest{VolumeId: "vol", VolumePath: "/denied"})
+	if err != nil || len(resp.GetUsage()) != 1 || resp.GetUsage()[0].GetTotal() != 100 || !resp.GetVolumeCondition().GetAbnormal() {
+		t.Errorf("unexpected stats %v: %v", resp, err)
+	}
+	// the statfs of a hung mount is not attempted
+	resp, err = ns.NodeGetVolumeStats(ctx, &csi.NodeGetVolumeStatsRequest{VolumeId: "vol", VolumePath: "/hung"})
+	if err != nil || len(resp.GetUsage()) != 2 || !resp.GetVolumeCondition().GetAbnormal() || wrapped.statsCalls != 3 {
+		t.Errorf("unexpected stats %v: %v", resp, err)
+	}
+}
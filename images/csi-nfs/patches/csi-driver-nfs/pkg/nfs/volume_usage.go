@@ -0,0 +1,604 @@
+/*
+Copyright 2026 Flant JSC
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nfs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// volumeUsageEnforcementAnnotation is set on the StorageClass by the
+	// controller from spec.volumeUsage.enforcement of the NFSStorageClass;
+	// without it the usage of the volumes of the class is not measured
+	volumeUsageEnforcementAnnotation = "storage.deckhouse.io/volume-usage-enforcement"
+
+	// the last measured usage and whether it exceeds the request, kept on
+	// the PersistentVolume so that the nodes the volume is mounted on share
+	// the state
+	volumeUsedBytesAnnotation     = "storage.deckhouse.io/volume-used-bytes"
+	volumeUsageExceededAnnotation = "storage.deckhouse.io/volume-usage-exceeded"
+
+	volumeUsageEnforcementWarn            = "Warn"
+	volumeUsageEnforcementReadOnlyRemount = "ReadOnlyRemount"
+	volumeUsageEnforcementRefuseReadWrite = "RefuseReadWrite"
+	// volumeUsageEnforcementBlock limits the volumes with a project quota on
+	// the server, see project_quota.go; the node enforces RefuseReadWrite for
+	// the volumes without a quota, e.g. created before it was enabled
+	volumeUsageEnforcementBlock = "Block"
+
+	volumeUsageExceededReason          = "VolumeUsageExceeded"
+	volumeUsageWithinRequestReason     = "VolumeUsageWithinRequest"
+	volumeUsageEnforcementFailedReason = "VolumeUsageEnforcementFailed"
+
+	// kubelet keeps the PersistentVolume name of a CSI volume next to its
+	// target path
+	kubeletVolumeDataFile = "vol_data.json"
+	// volumeUsageRemountedFile marks, next to vol_data.json, a target
+	// published read-write and remounted read-only because of the usage, so
+	// that a restarted plugin does not take it for a read-only publish
+	volumeUsageRemountedFile = "csi-nfs-usage-remounted"
+
+	// volumeUsageScanConcurrency limits the volumes scanned at once, so a
+	// large tree does not delay the scans of the other volumes
+	volumeUsageScanConcurrency = 4
+)
+
+// volumeUsageMonitor periodically measures the usage of the volumes published
+// on the node, reports it in NodeGetVolumeStats and compares it with the size
+// requested in the PVC.
+type volumeUsageMonitor struct {
+	driverName string
+	nodeID     string
+	interval   time.Duration
+	kubeClient kubernetes.Interface
+	recorder   record.EventRecorder
+	// remount changes the read-only flag of the mounts of a volume
+	remount func(targetPath string, readOnly bool) error
+
+	mu      sync.Mutex
+	volumes map[string]*trackedVolume // by volume ID
+	// scans limits the concurrent scans
+	scans chan struct{}
+}
+
+type trackedVolume struct {
+	pvName string
+	// targets maps the target paths the volume is published at to whether
+	// they were published read-only; those are never remounted read-write
+	targets map[string]bool
+	scanner *usageScanner
+	// remounted is set once the read-write targets are remounted read-only
+	// because of the usage
+	remounted bool
+	// scanning is set while the volume is scanned, the scanner is not shared
+	scanning bool
+	// stats is the result of the last scan, nil before the first one
+	stats *volumeStats
+}
+
+// volumeStats is the usage of a volume reported by NodeGetVolumeStats.
+type volumeStats struct {
+	usedBytes  int64
+	usedInodes int64
+	// requestedBytes is the size requested in the PVC, reported as the
+	// capacity of the volume
+	requestedBytes int64
+}
+
+func newVolumeUsageMonitor(driverName, nodeID string, interval time.Duration, kubeClient kubernetes.Interface, recorder record.EventRecorder) *volumeUsageMonitor {
+	return &volumeUsageMonitor{
+		driverName: driverName,
+		nodeID:     nodeID,
+		interval:   interval,
+		kubeClient: kubeClient,
+		recorder:   recorder,
+		remount:    remountVolume,
+		volumes:    map[string]*trackedVolume{},
+		scans:      make(chan struct{}, volumeUsageScanConcurrency),
+	}
+}
+
+// volumeUsageNodeServer measures the usage of the volumes it publishes and
+// reports it instead of the usage of the whole share.
+type volumeUsageNodeServer struct {
+	*NodeServer
+	usage *volumeUsageMonitor
+}
+
+// nodeServerWithVolumeUsage returns the node server to register: when the
+// usage accounting is enabled with --volume-usage-scan-interval, the node
+// server tracks the published volumes and the monitor is started.
+func (n *Driver) nodeServerWithVolumeUsage() csi.NodeServer {
+	if n.volumeUsageScanInterval <= 0 {
+		return n.ns
+	}
+	_, kubeClient, recorder, err := newInClusterClient(n.name, n.nodeID)
+	if err != nil {
+		klog.Errorf("volume usage accounting is disabled: %v", err)
+		return n.ns
+	}
+
+	usage := newVolumeUsageMonitor(n.name, n.nodeID, n.volumeUsageScanInterval, kubeClient, recorder)
+	go usage.run(context.Background())
+	return &volumeUsageNodeServer{NodeServer: n.ns, usage: usage}
+}
+
+func (ns *volumeUsageNodeServer) NodePublishVolume(ctx context.Context, req *csi.NodePublishVolumeRequest) (*csi.NodePublishVolumeResponse, error) {
+	if !req.GetReadonly() {
+		if err := ns.usage.checkPublish(ctx, req.GetTargetPath()); err != nil {
+			return nil, err
+		}
+	}
+	resp, err := ns.NodeServer.NodePublishVolume(ctx, req)
+	if err == nil {
+		ns.usage.track(req.GetVolumeId(), req.GetTargetPath(), req.GetReadonly())
+	}
+	return resp, err
+}
+
+// NodeGetVolumeStats reports the usage of the volume subdirectory measured by
+// the last scan: the capacity is the size requested in the PVC and the
+// available bytes are what remains of it. The inodes available are those of
+// the share. Until the volume is scanned, the statfs of the share is reported.
+func (ns *volumeUsageNodeServer) NodeGetVolumeStats(ctx context.Context, req *csi.NodeGetVolumeStatsRequest) (*csi.NodeGetVolumeStatsResponse, error) {
+	resp, err := ns.NodeServer.NodeGetVolumeStats(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+	if stats := ns.usage.getStats(req.GetVolumeId()); stats != nil {
+		return stats.response(resp), nil
+	}
+	return resp, nil
+}
+
+// response replaces the usage of the share in the statfs response with the
+// usage of the volume.
+func (stats *volumeStats) response(resp *csi.NodeGetVolumeStatsResponse) *csi.NodeGetVolumeStatsResponse {
+	usage := make([]*csi.VolumeUsage, 0, 2)
+	usage = append(usage, &csi.VolumeUsage{
+		Unit:      csi.VolumeUsage_BYTES,
+		Total:     stats.requestedBytes,
+		Used:      stats.usedBytes,
+		Available: max(stats.requestedBytes-stats.usedBytes, 0),
+	})
+	for _, u := range resp.GetUsage() {
+		if u.GetUnit() == csi.VolumeUsage_INODES {
+			usage = append(usage, &csi.VolumeUsage{
+				Unit:      csi.VolumeUsage_INODES,
+				Total:     stats.usedInodes + u.GetAvailable(),
+				Used:      stats.usedInodes,
+				Available: u.GetAvailable(),
+			})
+		}
+	}
+	return &csi.NodeGetVolumeStatsResponse{Usage: usage, VolumeCondition: resp.GetVolumeCondition()}
+}
+
+func (ns *volumeUsageNodeServer) NodeUnpublishVolume(ctx context.Context, req *csi.NodeUnpublishVolumeRequest) (*csi.NodeUnpublishVolumeResponse, error) {
+	resp, err := ns.NodeServer.NodeUnpublishVolume(ctx, req)
+	if err == nil {
+		ns.usage.untrack(req.GetVolumeId(), req.GetTargetPath())
+	}
+	return resp, err
+}
+
+// kubeletVolumeData is the part of vol_data.json the monitor needs.
+type kubeletVolumeData struct {
+	DriverName string `json:"driverName"`
+	SpecVolID  string `json:"specVolID"`
+}
+
+// readKubeletVolumeData reads vol_data.json the kubelet keeps next to the
+// target path of a volume.
+func readKubeletVolumeData(targetPath string) (*kubeletVolumeData, error) {
+	path := filepath.Join(filepath.Dir(targetPath), kubeletVolumeDataFile)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	volumeData := &kubeletVolumeData{}
+	if err := json.Unmarshal(data, volumeData); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return volumeData, nil
+}
+
+// getPVName returns the name of the PersistentVolume published at targetPath.
+func getPVName(targetPath string) (string, error) {
+	volumeData, err := readKubeletVolumeData(targetPath)
+	if err != nil {
+		return "", err
+	}
+	if volumeData.SpecVolID == "" {
+		return filepath.Base(filepath.Dir(targetPath)), nil
+	}
+	return volumeData.SpecVolID, nil
+}
+
+func (m *volumeUsageMonitor) track(volumeID, targetPath string, readOnly bool) {
+	pvName, err := getPVName(targetPath)
+	if err != nil {
+		klog.Warningf("usage of volume %s published at %s will not be measured: %v", volumeID, targetPath, err)
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, ok := m.volumes[volumeID]
+	if !ok {
+		v = &trackedVolume{pvName: pvName, targets: map[string]bool{}, scanner: newUsageScanner()}
+		m.volumes[volumeID] = v
+	}
+	v.targets[targetPath] = readOnly
+	if v.remounted && !readOnly {
+		// the next check remounts the new target too
+		v.remounted = false
+	}
+}
+
+// getStats returns the usage of the volume measured by the last scan, nil if
+// the volume was not scanned yet.
+func (m *volumeUsageMonitor) getStats(volumeID string) *volumeStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if v, ok := m.volumes[volumeID]; ok {
+		return v.stats
+	}
+	return nil
+}
+
+// remountedMarkerPath returns the path of the file marking the target as
+// remounted read-only because of the usage.
+func remountedMarkerPath(targetPath string) string {
+	return filepath.Join(filepath.Dir(targetPath), volumeUsageRemountedFile)
+}
+
+func (m *volumeUsageMonitor) untrack(volumeID, targetPath string) {
+	// kubelet removes the directory of the target after the unpublish, which
+	// fails while the marker is there
+	if err := os.Remove(remountedMarkerPath(targetPath)); err != nil && !os.IsNotExist(err) {
+		klog.Warningf("failed to remove %s: %v", remountedMarkerPath(targetPath), err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, ok := m.volumes[volumeID]
+	if !ok {
+		return
+	}
+	delete(v.targets, targetPath)
+	if len(v.targets) == 0 {
+		delete(m.volumes, volumeID)
+	}
+}
+
+// checkPublish refuses to publish a volume read-write while its usage
+// exceeds the request and the class enforcement is RefuseReadWrite or Block.
+func (m *volumeUsageMonitor) checkPublish(ctx context.Context, targetPath string) error {
+	pvName, err := getPVName(targetPath)
+	if err != nil {
+		klog.V(4).Infof("skipping the usage check of the volume published at %s: %v", targetPath, err)
+		return nil
+	}
+	pv, err := m.kubeClient.CoreV1().PersistentVolumes().Get(ctx, pvName, metav1.GetOptions{})
+	if err != nil {
+		klog.Warningf("skipping the usage check of PersistentVolume %s: %v", pvName, err)
+		return nil
+	}
+	if pv.Annotations[volumeUsageExceededAnnotation] != "true" {
+		return nil
+	}
+	enforcement, err := m.getEnforcement(ctx, pv)
+	if err != nil {
+		klog.Warningf("skipping the usage check of PersistentVolume %s: %v", pvName, err)
+		return nil
+	}
+	if enforcement != volumeUsageEnforcementRefuseReadWrite && enforcement != volumeUsageEnforcementBlock {
+		return nil
+	}
+	return status.Errorf(codes.ResourceExhausted, "the usage of volume %s (%s bytes) exceeds the requested size, it can only be mounted read-only until the PVC is expanded or the data is deleted", pvName, pv.Annotations[volumeUsedBytesAnnotation])
+}
+
+func (m *volumeUsageMonitor) run(ctx context.Context) {
+	m.recover()
+	klog.Infof("measuring the usage of the published volumes every %s", m.interval)
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.checkAll(ctx)
+		}
+	}
+}
+
+// recover tracks the volumes published before the plugin was restarted,
+// found by their kubelet target paths in the mount table. A read-only mount
+// is a read-only publish unless it is marked as remounted because of the
+// usage by the previous instance of the plugin.
+func (m *volumeUsageMonitor) recover() {
+	mounts, err := readMountInfo(filepath.Join(procPath, "self", "mountinfo"))
+	if err != nil {
+		klog.Errorf("failed to find the published volumes: %v", err)
+		return
+	}
+	// the volumes with the read-write publishes all remounted read-only
+	remounted := map[string]bool{}
+	for _, mount := range mounts {
+		if filepath.Base(mount.mountPoint) != "mount" || !strings.Contains(mount.mountPoint, "/volumes/kubernetes.io~csi/") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(filepath.Dir(mount.mountPoint), kubeletVolumeDataFile))
+		if err != nil {
+			continue
+		}
+		var volumeData struct {
+			kubeletVolumeData
+			VolumeHandle string `json:"volumeHandle"`
+		}
+		if err := json.Unmarshal(data, &volumeData); err != nil || volumeData.DriverName != m.driverName || volumeData.VolumeHandle == "" {
+			continue
+		}
+		mountedReadOnly := slices.Contains(mount.options, "ro")
+		_, err = os.Stat(remountedMarkerPath(mount.mountPoint))
+		marked := err == nil
+		klog.V(2).Infof("tracking the usage of volume %s published at %s", volumeData.VolumeHandle, mount.mountPoint)
+		m.track(volumeData.VolumeHandle, mount.mountPoint, mountedReadOnly && !marked)
+
+		switch {
+		case !mountedReadOnly:
+			remounted[volumeData.VolumeHandle] = false
+		case marked:
+			if _, ok := remounted[volumeData.VolumeHandle]; !ok {
+				remounted[volumeData.VolumeHandle] = true
+			}
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for volumeID, readOnly := range remounted {
+		if v := m.volumes[volumeID]; v != nil && readOnly {
+			v.remounted = true
+		}
+	}
+}
+
+// checkAll starts the checks of the tracked volumes in the background. A
+// volume whose previous scan is still running is skipped.
+func (m *volumeUsageMonitor) checkAll(ctx context.Context) {
+	m.mu.Lock()
+	ids := make([]string, 0, len(m.volumes))
+	for id, v := range m.volumes {
+		if v.scanning {
+			klog.V(4).Infof("volume %s is still being scanned, skipping", id)
+			continue
+		}
+		v.scanning = true
+		ids = append(ids, id)
+	}
+	m.mu.Unlock()
+
+	for _, id := range ids {
+		go func() {
+			m.scans <- struct{}{}
+			defer func() { <-m.scans }()
+			if err := m.check(ctx, id); err != nil {
+				klog.Errorf("failed to check the usage of volume %s: %v", id, err)
+			}
+			m.mu.Lock()
+			if v, ok := m.volumes[id]; ok {
+				v.scanning = false
+			}
+			m.mu.Unlock()
+		}()
+	}
+}
+
+// check measures the usage of a volume for NodeGetVolumeStats and, if the
+// class enforces the usage, records it on the PersistentVolume, creates an
+// event for the PVC when the usage crosses the requested size and applies the
+// enforcement.
+func (m *volumeUsageMonitor) check(ctx context.Context, volumeID string) error {
+	m.mu.Lock()
+	v, ok := m.volumes[volumeID]
+	if !ok {
+		m.mu.Unlock()
+		return nil
+	}
+	pvName := v.pvName
+	targets := make([]string, 0, len(v.targets))
+	for target := range v.targets {
+		targets = append(targets, target)
+	}
+	m.mu.Unlock()
+	if len(targets) == 0 {
+		return nil
+	}
+	sort.Strings(targets)
+
+	pv, err := m.kubeClient.CoreV1().PersistentVolumes().Get(ctx, pvName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	result, err := v.scanner.scan(targets[0])
+	if err != nil {
+		return fmt.Errorf("failed to scan %s: %v", targets[0], err)
+	}
+	klog.V(4).Infof("volume %s uses %d bytes in %d files (%d of %d directories listed in %s)", volumeID, result.UsedBytes, result.Files, result.ListedDirs, result.Dirs, result.Duration)
+
+	pvc, requested, err := m.getRequestedBytes(ctx, pv)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	v.stats = &volumeStats{usedBytes: result.UsedBytes, usedInodes: result.Files + result.Dirs, requestedBytes: requested}
+	m.mu.Unlock()
+
+	enforcement, err := m.getEnforcement(ctx, pv)
+	if err != nil {
+		return err
+	}
+	if enforcement == "" {
+		// the usage is not enforced for the class or no longer is
+		return m.setReadOnly(volumeID, false)
+	}
+
+	exceeded := requested > 0 && result.UsedBytes > requested
+	wasExceeded := pv.Annotations[volumeUsageExceededAnnotation] == "true"
+
+	if err := m.annotateUsage(ctx, pv, result.UsedBytes, exceeded); err != nil {
+		return err
+	}
+	if pvc != nil && exceeded != wasExceeded {
+		used := resource.NewQuantity(result.UsedBytes, resource.BinarySI).String()
+		request := resource.NewQuantity(requested, resource.BinarySI).String()
+		if exceeded {
+			m.recorder.Eventf(pvc, corev1.EventTypeWarning, volumeUsageExceededReason,
+				"Volume %s uses %s, more than the requested %s (enforcement: %s)", pv.Name, used, request, enforcement)
+		} else {
+			m.recorder.Eventf(pvc, corev1.EventTypeNormal, volumeUsageWithinRequestReason,
+				"Volume %s uses %s, within the requested %s", pv.Name, used, request)
+		}
+	}
+
+	readOnly := exceeded && enforcement != volumeUsageEnforcementWarn
+	if err := m.setReadOnly(volumeID, readOnly); err != nil {
+		if pvc != nil {
+			m.recorder.Eventf(pvc, corev1.EventTypeWarning, volumeUsageEnforcementFailedReason,
+				"Failed to remount volume %s on node %s (read-only: %t): %v", pv.Name, m.nodeID, readOnly, err)
+		}
+		return err
+	}
+	return nil
+}
+
+// setReadOnly remounts the targets of the volume published read-write.
+func (m *volumeUsageMonitor) setReadOnly(volumeID string, readOnly bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, ok := m.volumes[volumeID]
+	if !ok || v.remounted == readOnly {
+		return nil
+	}
+	for target, publishedReadOnly := range v.targets {
+		if publishedReadOnly {
+			continue
+		}
+		if err := m.setRemounted(target, readOnly); err != nil {
+			return err
+		}
+	}
+	v.remounted = readOnly
+	klog.Infof("volume %s remounted (read-only: %t) because of its usage", volumeID, readOnly)
+	return nil
+}
+
+// setRemounted remounts the target published read-write. The marker of the
+// read-only remount is written before and removed after the remount, so that
+// it is there whenever the target may be read-only.
+func (m *volumeUsageMonitor) setRemounted(target string, readOnly bool) error {
+	marker := remountedMarkerPath(target)
+	if readOnly {
+		if err := os.WriteFile(marker, nil, 0600); err != nil {
+			return fmt.Errorf("failed to mark %s as remounted: %v", target, err)
+		}
+	}
+	if err := m.remount(target, readOnly); err != nil {
+		return err
+	}
+	if !readOnly {
+		if err := os.Remove(marker); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %v", marker, err)
+		}
+	}
+	return nil
+}
+
+// getEnforcement returns the enforcement of the class of the volume, empty
+// if the usage of its volumes is not measured.
+func (m *volumeUsageMonitor) getEnforcement(ctx context.Context, pv *corev1.PersistentVolume) (string, error) {
+	if pv.Spec.StorageClassName == "" {
+		return "", nil
+	}
+	sc, err := m.kubeClient.StorageV1().StorageClasses().Get(ctx, pv.Spec.StorageClassName, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	return sc.Annotations[volumeUsageEnforcementAnnotation], nil
+}
+
+// getRequestedBytes returns the size requested in the PVC bound to the
+// volume, or the capacity of the volume if the PVC is not found.
+func (m *volumeUsageMonitor) getRequestedBytes(ctx context.Context, pv *corev1.PersistentVolume) (*corev1.PersistentVolumeClaim, int64, error) {
+	capacity := pv.Spec.Capacity[corev1.ResourceStorage]
+	ref := pv.Spec.ClaimRef
+	if ref == nil {
+		return nil, capacity.Value(), nil
+	}
+	pvc, err := m.kubeClient.CoreV1().PersistentVolumeClaims(ref.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+	if err != nil {
+		klog.Warningf("failed to get PVC %s/%s of volume %s: %v", ref.Namespace, ref.Name, pv.Name, err)
+		return nil, capacity.Value(), nil
+	}
+	request, ok := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+	if !ok {
+		return pvc, capacity.Value(), nil
+	}
+	return pvc, request.Value(), nil
+}
+
+func (m *volumeUsageMonitor) annotateUsage(ctx context.Context, pv *corev1.PersistentVolume, usedBytes int64, exceeded bool) error {
+	used := strconv.FormatInt(usedBytes, 10)
+	if pv.Annotations[volumeUsedBytesAnnotation] == used &&
+		(pv.Annotations[volumeUsageExceededAnnotation] == "true") == exceeded {
+		return nil
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{
+				volumeUsedBytesAnnotation:     used,
+				volumeUsageExceededAnnotation: strconv.FormatBool(exceeded),
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = m.kubeClient.CoreV1().PersistentVolumes().Patch(ctx, pv.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
@@ -0,0 +1,240 @@
+/*
+Copyright 2026 Flant JSC
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nfs
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+	"k8s.io/klog/v2"
+)
+
+var procPath = "/proc"
+
+// mountInfo is a line of /proc/<pid>/mountinfo.
+type mountInfo struct {
+	device     string // major:minor
+	root       string
+	mountPoint string
+	options    []string // per mount point options
+	fsType     string
+}
+
+func readMountInfo(path string) ([]mountInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var mounts []mountInfo
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// 36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw,errors=continue
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 6 {
+			continue
+		}
+		m := mountInfo{
+			device:     fields[2],
+			root:       unescapeMountPath(fields[3]),
+			mountPoint: unescapeMountPath(fields[4]),
+			options:    strings.Split(fields[5], ","),
+		}
+		// the optional fields end with a single hyphen
+		for i := 6; i+2 < len(fields); i++ {
+			if fields[i] == "-" {
+				m.fsType = fields[i+1]
+				break
+			}
+		}
+		mounts = append(mounts, m)
+	}
+	return mounts, scanner.Err()
+}
+
+// contains reports whether the mount m exposes the tree of the mount volume or
+// a part of it, as the bind mounts of a volume into containers (including
+// subPath mounts) do.
+func (m mountInfo) contains(volume mountInfo) bool {
+	return m.device == volume.device &&
+		(m.root == volume.root || strings.HasPrefix(m.root, strings.TrimSuffix(volume.root, "/")+"/"))
+}
+
+// remountVolume changes the read-only flag of the mounts of the volume
+// published at targetPath: the mount at targetPath and the bind mounts of it
+// in the mount namespaces of the containers of the pod the volume is published
+// for, which are separate mount points and are not affected by a remount of
+// targetPath. Only the processes of that pod are entered. The other volumes
+// of the share, which may use the same NFS superblock, keep their flags.
+func remountVolume(targetPath string, readOnly bool) error {
+	podUID, err := podUIDFromTargetPath(targetPath)
+	if err != nil {
+		return err
+	}
+	selfMounts, err := readMountInfo(filepath.Join(procPath, "self", "mountinfo"))
+	if err != nil {
+		return err
+	}
+	var volume *mountInfo
+	for i := range selfMounts {
+		if selfMounts[i].mountPoint == targetPath {
+			volume = &selfMounts[i]
+		}
+	}
+	if volume == nil {
+		return fmt.Errorf("%s is not mounted", targetPath)
+	}
+	if err := remountMountPoints([]mountInfo{*volume}, readOnly); err != nil {
+		return err
+	}
+
+	selfNS, err := os.Readlink(filepath.Join(procPath, "self", "ns", "mnt"))
+	if err != nil {
+		return err
+	}
+	pids, err := podProcesses(podUID)
+	if err != nil {
+		return err
+	}
+	seen := map[string]bool{selfNS: true}
+	var errs []error
+	for _, pid := range pids {
+		ns, err := os.Readlink(filepath.Join(procPath, strconv.Itoa(pid), "ns", "mnt"))
+		if err != nil || seen[ns] {
+			// the process has exited or its namespace is already handled
+			continue
+		}
+		seen[ns] = true
+		mounts, err := readMountInfo(filepath.Join(procPath, strconv.Itoa(pid), "mountinfo"))
+		if err != nil {
+			continue
+		}
+		var matched []mountInfo
+		for _, m := range mounts {
+			if m.contains(*volume) {
+				matched = append(matched, m)
+			}
+		}
+		if len(matched) == 0 {
+			continue
+		}
+		if err := remountInNamespace(pid, matched, readOnly); err != nil {
+			errs = append(errs, fmt.Errorf("pid %d: %w", pid, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// podUIDFromTargetPath returns the UID of the pod of the kubelet target path
+// <kubelet dir>/pods/<uid>/volumes/kubernetes.io~csi/<pv>/mount.
+func podUIDFromTargetPath(targetPath string) (string, error) {
+	parts := strings.Split(filepath.Clean(targetPath), string(filepath.Separator))
+	for i := len(parts) - 1; i > 0; i-- {
+		if parts[i] == "volumes" && parts[i-1] != "" && i >= 2 && parts[i-2] == "pods" {
+			return parts[i-1], nil
+		}
+	}
+	return "", fmt.Errorf("%s is not a target path of a pod", targetPath)
+}
+
+// podProcesses returns the processes in the cgroup of the pod, with the
+// cgroupfs (pod<uid>) and the systemd (pod<uid with underscores>) drivers.
+func podProcesses(podUID string) ([]int, error) {
+	entries, err := os.ReadDir(procPath)
+	if err != nil {
+		return nil, err
+	}
+	cgroupfs, systemd := "pod"+podUID, "pod"+strings.ReplaceAll(podUID, "-", "_")
+	var pids []int
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		cgroup, err := os.ReadFile(filepath.Join(procPath, entry.Name(), "cgroup"))
+		if err != nil {
+			continue
+		}
+		if strings.Contains(string(cgroup), cgroupfs) || strings.Contains(string(cgroup), systemd) {
+			pids = append(pids, pid)
+		}
+	}
+	return pids, nil
+}
+
+// remountInNamespace remounts the mount points in the mount namespace of the
+// process pid. setns(CLONE_NEWNS) is not allowed for a thread sharing its file
+// system attributes with the others, so a dedicated thread unshares them
+// first; the thread is not returned to the Go scheduler and exits with the
+// goroutine.
+func remountInNamespace(pid int, mounts []mountInfo, readOnly bool) error {
+	errCh := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+		errCh <- func() error {
+			if err := unix.Unshare(unix.CLONE_FS); err != nil {
+				return fmt.Errorf("unshare: %w", err)
+			}
+			fd, err := unix.Open(filepath.Join(procPath, strconv.Itoa(pid), "ns", "mnt"), unix.O_RDONLY|unix.O_CLOEXEC, 0)
+			if err != nil {
+				return err
+			}
+			defer unix.Close(fd)
+			if err := unix.Setns(fd, unix.CLONE_NEWNS); err != nil {
+				return fmt.Errorf("setns: %w", err)
+			}
+			return remountMountPoints(mounts, readOnly)
+		}()
+	}()
+	return <-errCh
+}
+
+func remountMountPoints(mounts []mountInfo, readOnly bool) error {
+	var errs []error
+	for _, m := range mounts {
+		flags := uintptr(unix.MS_REMOUNT | unix.MS_BIND)
+		for _, option := range m.options {
+			switch option {
+			case "nosuid":
+				flags |= unix.MS_NOSUID
+			case "nodev":
+				flags |= unix.MS_NODEV
+			case "noexec":
+				flags |= unix.MS_NOEXEC
+			case "noatime":
+				flags |= unix.MS_NOATIME
+			case "nodiratime":
+				flags |= unix.MS_NODIRATIME
+			case "relatime":
+				flags |= unix.MS_RELATIME
+			}
+		}
+		if readOnly {
+			flags |= unix.MS_RDONLY
+		}
+		klog.V(2).Infof("remounting %s (read-only: %t)", m.mountPoint, readOnly)
+		if err := unix.Mount("", m.mountPoint, "", flags, ""); err != nil {
+			errs = append(errs, fmt.Errorf("remount %s: %w", m.mountPoint, err))
+		}
+	}
+	return errors.Join(errs...)
+}
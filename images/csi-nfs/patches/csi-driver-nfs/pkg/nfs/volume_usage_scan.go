@@ -0,0 +1,153 @@
+/*
+Copyright 2026 Flant JSC
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nfs
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// usageScanner measures the disk usage of a directory tree like 'du'. The
+// entries of every directory are cached together with its modification time,
+// so a rescan lists only the directories changed since the previous scan and
+// stats the files; on NFS the READDIR calls dominate the scan time.
+type usageScanner struct {
+	dirs map[string]*scannedDir
+	// generation marks the directories seen by the current scan; the
+	// others were removed and are dropped from the cache
+	generation uint64
+}
+
+type scannedDir struct {
+	modTime    time.Time
+	files      []string
+	subdirs    []string
+	generation uint64
+}
+
+// usageScanResult is the outcome of a scan.
+type usageScanResult struct {
+	// UsedBytes is the space allocated for the files and directories
+	UsedBytes int64
+	Files     int64
+	Dirs      int64
+	// ListedDirs is the number of directories whose entries were read,
+	// the others were unchanged since the previous scan
+	ListedDirs int64
+	// ChangeTime is the latest status change of the entries, which unlike
+	// the modification time cannot be set back by a copy keeping the times
+	ChangeTime time.Time
+	Duration   time.Duration
+}
+
+func newUsageScanner() *usageScanner {
+	return &usageScanner{dirs: map[string]*scannedDir{}}
+}
+
+// scan measures the usage of the tree root.
+func (s *usageScanner) scan(root string) (usageScanResult, error) {
+	start := time.Now()
+	s.generation++
+	var result usageScanResult
+	if err := s.scanDir(root, "", &result); err != nil {
+		return result, err
+	}
+	for rel, dir := range s.dirs {
+		if dir.generation != s.generation {
+			delete(s.dirs, rel)
+		}
+	}
+	result.Duration = time.Since(start)
+	return result, nil
+}
+
+func (s *usageScanner) scanDir(root, rel string, result *usageScanResult) error {
+	path := filepath.Join(root, rel)
+	info, err := os.Lstat(path)
+	if err != nil {
+		if rel != "" && errors.Is(err, fs.ErrNotExist) {
+			// removed during the scan
+			return nil
+		}
+		return err
+	}
+	result.Dirs++
+	result.UsedBytes += allocatedBytes(info)
+	result.addChangeTime(info)
+
+	dir, ok := s.dirs[rel]
+	if !ok || !dir.modTime.Equal(info.ModTime()) {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			if rel != "" && errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		result.ListedDirs++
+		dir = &scannedDir{modTime: info.ModTime()}
+		for _, entry := range entries {
+			name := filepath.Join(rel, entry.Name())
+			if entry.IsDir() {
+				dir.subdirs = append(dir.subdirs, name)
+			} else {
+				dir.files = append(dir.files, name)
+			}
+		}
+		s.dirs[rel] = dir
+	}
+	dir.generation = s.generation
+
+	for _, name := range dir.files {
+		info, err := os.Lstat(filepath.Join(root, name))
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return err
+		}
+		result.Files++
+		result.UsedBytes += allocatedBytes(info)
+		result.addChangeTime(info)
+	}
+	for _, name := range dir.subdirs {
+		if err := s.scanDir(root, name, result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *usageScanResult) addChangeTime(info fs.FileInfo) {
+	changeTime := info.ModTime()
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		changeTime = time.Unix(st.Ctim.Unix())
+	}
+	if changeTime.After(r.ChangeTime) {
+		r.ChangeTime = changeTime
+	}
+}
+
+// allocatedBytes returns the space allocated for a file, which is smaller
+// than its size for sparse files.
+func allocatedBytes(info fs.FileInfo) int64 {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return st.Blocks * 512
+	}
+	return info.Size()
+}
@@ -0,0 +1,375 @@
+/*
+Copyright 2026 Flant JSC
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nfs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestUsageScannerIncremental(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "a", "b"), 0755); err != nil {
+		t.Fatalf("failed to create dirs: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "a", "b", "file"), make([]byte, 8192), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	s := newUsageScanner()
+	first, err := s.scan(root)
+	if err != nil {
+		t.Fatalf("scan failed: %v", err)
+	}
+	if first.Files != 1 || first.Dirs != 3 || first.ListedDirs != 3 {
+		t.Errorf("unexpected first scan %+v", first)
+	}
+
+	// growing a file does not change the directories, they are not listed again
+	if err := os.WriteFile(filepath.Join(root, "a", "b", "file"), make([]byte, 65536), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	second, err := s.scan(root)
+	if err != nil {
+		t.Fatalf("scan failed: %v", err)
+	}
+	if second.ListedDirs != 0 || second.UsedBytes <= first.UsedBytes {
+		t.Errorf("unexpected second scan %+v, first %+v", second, first)
+	}
+
+	// a removed directory is dropped from the cache
+	time.Sleep(10 * time.Millisecond)
+	if err := os.RemoveAll(filepath.Join(root, "a", "b")); err != nil {
+		t.Fatalf("failed to remove dir: %v", err)
+	}
+	third, err := s.scan(root)
+	if err != nil {
+		t.Fatalf("scan failed: %v", err)
+	}
+	if third.Files != 0 || third.Dirs != 2 || third.ListedDirs != 1 {
+		t.Errorf("unexpected third scan %+v", third)
+	}
+	if _, ok := s.dirs[filepath.Join("a", "b")]; ok {
+		t.Errorf("removed directory is still cached")
+	}
+}
+
+// publishTestVolume creates a kubelet-like target path of the volume.
+func publishTestVolume(t *testing.T, pvName string) string {
+	dir := filepath.Join(t.TempDir(), "pods", "uid", "volumes", "kubernetes.io~csi", pvName)
+	target := filepath.Join(dir, "mount")
+	if err := os.MkdirAll(target, 0755); err != nil {
+		t.Fatalf("failed to create target: %v", err)
+	}
+	data := `{"driverName":"nfs.csi.k8s.io","specVolID":"` + pvName + `","volumeHandle":"server#share#` + pvName + `#"}`
+	if err := os.WriteFile(filepath.Join(dir, kubeletVolumeDataFile), []byte(data), 0644); err != nil {
+		t.Fatalf("failed to write volume data: %v", err)
+	}
+	return target
+}
+
+func newTestUsageMonitor(enforcement string, pvAnnotations map[string]string) (*volumeUsageMonitor, *fake.Clientset, *record.FakeRecorder) {
+	sc := &storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{
+		Name:        "nfs",
+		Annotations: map[string]string{volumeUsageEnforcementAnnotation: enforcement},
+	}}
+	pv := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "pv-1", Annotations: pvAnnotations},
+		Spec: corev1.PersistentVolumeSpec{
+			StorageClassName: "nfs",
+			Capacity:         corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")},
+			ClaimRef:         &corev1.ObjectReference{Namespace: "default", Name: "data"},
+		},
+	}
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "data"},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("16Ki")},
+			},
+		},
+	}
+	client := fake.NewSimpleClientset(sc, pv, pvc)
+	recorder := record.NewFakeRecorder(10)
+	return newVolumeUsageMonitor("nfs.csi.k8s.io", "node-1", time.Minute, client, recorder), client, recorder
+}
+
+func TestVolumeUsageMonitorCheck(t *testing.T) {
+	m, client, recorder := newTestUsageMonitor(volumeUsageEnforcementReadOnlyRemount, nil)
+	var remounts []string
+	m.remount = func(targetPath string, readOnly bool) error {
+		remounts = append(remounts, filepath.Base(filepath.Dir(targetPath))+":"+map[bool]string{true: "ro", false: "rw"}[readOnly])
+		return nil
+	}
+
+	target := publishTestVolume(t, "pv-1")
+	m.track("vol-1", target, false)
+	if err := os.WriteFile(filepath.Join(target, "file"), make([]byte, 64*1024), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	ctx := context.Background()
+	if err := m.check(ctx, "vol-1"); err != nil {
+		t.Fatalf("check failed: %v", err)
+	}
+	pv, err := client.CoreV1().PersistentVolumes().Get(ctx, "pv-1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get pv: %v", err)
+	}
+	if pv.Annotations[volumeUsageExceededAnnotation] != "true" || pv.Annotations[volumeUsedBytesAnnotation] == "" {
+		t.Errorf("unexpected annotations %v", pv.Annotations)
+	}
+	if event := <-recorder.Events; !strings.HasPrefix(event, "Warning "+volumeUsageExceededReason) {
+		t.Errorf("unexpected event %q", event)
+	}
+	if len(remounts) != 1 || remounts[0] != "pv-1:ro" {
+		t.Errorf("unexpected remounts %v", remounts)
+	}
+	if _, err := os.Stat(remountedMarkerPath(target)); err != nil {
+		t.Errorf("remounted target is not marked: %v", err)
+	}
+
+	// no new event and no remount while the usage stays over the request
+	if err := m.check(ctx, "vol-1"); err != nil {
+		t.Fatalf("check failed: %v", err)
+	}
+	if len(recorder.Events) != 0 || len(remounts) != 1 {
+		t.Errorf("unexpected events %d or remounts %v", len(recorder.Events), remounts)
+	}
+
+	if err := os.Remove(filepath.Join(target, "file")); err != nil {
+		t.Fatalf("failed to remove file: %v", err)
+	}
+	if err := m.check(ctx, "vol-1"); err != nil {
+		t.Fatalf("check failed: %v", err)
+	}
+	if event := <-recorder.Events; !strings.HasPrefix(event, "Normal "+volumeUsageWithinRequestReason) {
+		t.Errorf("unexpected event %q", event)
+	}
+	if len(remounts) != 2 || remounts[1] != "pv-1:rw" {
+		t.Errorf("unexpected remounts %v", remounts)
+	}
+}
+
+func TestVolumeUsageStats(t *testing.T) {
+	// the usage of the volumes of a class without enforcement is measured too
+	m, client, recorder := newTestUsageMonitor("", nil)
+	target := publishTestVolume(t, "pv-1")
+	m.track("vol-1", target, false)
+	if err := os.MkdirAll(filepath.Join(target, "dir"), 0755); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(target, "dir", "file"), make([]byte, 8192), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if m.getStats("vol-1") != nil {
+		t.Fatalf("unexpected stats before the first scan")
+	}
+
+	ctx := context.Background()
+	if err := m.check(ctx, "vol-1"); err != nil {
+		t.Fatalf("check failed: %v", err)
+	}
+	stats := m.getStats("vol-1")
+	if stats == nil || stats.usedInodes != 3 || stats.usedBytes < 8192 || stats.requestedBytes != 16*1024 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	pv, err := client.CoreV1().PersistentVolumes().Get(ctx, "pv-1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get pv: %v", err)
+	}
+	if len(pv.Annotations) != 0 || len(recorder.Events) != 0 {
+		t.Errorf("unexpected annotations %v or events %d", pv.Annotations, len(recorder.Events))
+	}
+
+	share := &csi.NodeGetVolumeStatsResponse{
+		Usage: []*csi.VolumeUsage{
+			{Unit: csi.VolumeUsage_BYTES, Total: 1 << 40, Used: 1 << 39, Available: 1 << 39},
+			{Unit: csi.VolumeUsage_INODES, Total: 1000, Used: 400, Available: 600},
+		},
+	}
+	resp := (&volumeStats{usedBytes: 20 * 1024, usedInodes: 3, requestedBytes: 16 * 1024}).response(share)
+	if len(resp.Usage) != 2 {
+		t.Fatalf("unexpected usage %v", resp.Usage)
+	}
+	if bytes := resp.Usage[0]; bytes.Unit != csi.VolumeUsage_BYTES || bytes.Total != 16*1024 || bytes.Used != 20*1024 || bytes.Available != 0 {
+		t.Errorf("unexpected bytes usage %+v", bytes)
+	}
+	if inodes := resp.Usage[1]; inodes.Unit != csi.VolumeUsage_INODES || inodes.Total != 603 || inodes.Used != 3 || inodes.Available != 600 {
+		t.Errorf("unexpected inodes usage %+v", inodes)
+	}
+}
+
+func TestVolumeUsageMonitorRecover(t *testing.T) {
+	exceeded := map[string]string{volumeUsageExceededAnnotation: "true", volumeUsedBytesAnnotation: "65536"}
+	m, _, _ := newTestUsageMonitor(volumeUsageEnforcementReadOnlyRemount, exceeded)
+	var remounts []string
+	m.remount = func(targetPath string, readOnly bool) error {
+		remounts = append(remounts, targetPath+":"+map[bool]string{true: "ro", false: "rw"}[readOnly])
+		return nil
+	}
+
+	// both targets are mounted read-only, only the first one was published
+	// read-write and remounted by the previous instance of the plugin
+	remountedTarget := publishTestVolume(t, "pv-1")
+	readOnlyTarget := publishTestVolume(t, "pv-1")
+	if err := os.WriteFile(remountedMarkerPath(remountedTarget), nil, 0600); err != nil {
+		t.Fatalf("failed to write marker: %v", err)
+	}
+	proc := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(proc, "self"), 0755); err != nil {
+		t.Fatalf("failed to create proc: %v", err)
+	}
+	mountinfo := "36 25 0:52 /pv-1 " + remountedTarget + " ro,relatime shared:1 - nfs4 server:/share/pv-1 rw,vers=4.1\n" +
+		"37 25 0:52 /pv-1 " + readOnlyTarget + " ro,relatime shared:2 - nfs4 server:/share/pv-1 rw,vers=4.1\n"
+	if err := os.WriteFile(filepath.Join(proc, "self", "mountinfo"), []byte(mountinfo), 0644); err != nil {
+		t.Fatalf("failed to write mountinfo: %v", err)
+	}
+	defer func(path string) { procPath = path }(procPath)
+	procPath = proc
+
+	m.recover()
+	v := m.volumes["server#share#pv-1#"]
+	if v == nil || !v.remounted {
+		t.Fatalf("unexpected recovered volume %+v", v)
+	}
+	if v.targets[remountedTarget] || !v.targets[readOnlyTarget] {
+		t.Errorf("unexpected targets %v", v.targets)
+	}
+
+	// only the target published read-write is remounted read-write
+	if err := m.setReadOnly("server#share#pv-1#", false); err != nil {
+		t.Fatalf("setReadOnly failed: %v", err)
+	}
+	if len(remounts) != 1 || remounts[0] != remountedTarget+":rw" {
+		t.Errorf("unexpected remounts %v", remounts)
+	}
+	if _, err := os.Stat(remountedMarkerPath(remountedTarget)); !os.IsNotExist(err) {
+		t.Errorf("marker is not removed: %v", err)
+	}
+}
+
+func TestVolumeUsageMonitorCheckPublish(t *testing.T) {
+	exceeded := map[string]string{volumeUsageExceededAnnotation: "true", volumeUsedBytesAnnotation: "65536"}
+	target := publishTestVolume(t, "pv-1")
+
+	m, _, _ := newTestUsageMonitor(volumeUsageEnforcementRefuseReadWrite, exceeded)
+	if err := m.checkPublish(context.Background(), target); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("expected ResourceExhausted, got %v", err)
+	}
+
+	// a volume without a project quota
+	m, _, _ = newTestUsageMonitor(volumeUsageEnforcementBlock, exceeded)
+	if err := m.checkPublish(context.Background(), target); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("expected ResourceExhausted, got %v", err)
+	}
+
+	m, _, _ = newTestUsageMonitor(volumeUsageEnforcementReadOnlyRemount, exceeded)
+	if err := m.checkPublish(context.Background(), target); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	m, _, _ = newTestUsageMonitor(volumeUsageEnforcementRefuseReadWrite, nil)
+	if err := m.checkPublish(context.Background(), target); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestMountInfoContains(t *testing.T) {
+	mountinfo := filepath.Join(t.TempDir(), "mountinfo")
+	content := `36 25 0:52 / /var/lib/kubelet/pods/uid/volumes/kubernetes.io~csi/pv-1/mount rw,nosuid,relatime shared:1 - nfs4 server:/share/pv-1 rw,vers=4.1
+37 25 0:52 / /var/lib/kubelet/pods/uid/volumes/kubernetes.io~csi/pv-2/mount rw,relatime shared:2 - nfs4 server:/share/pv-2 rw,vers=4.1
+`
+	if err := os.WriteFile(mountinfo, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write mountinfo: %v", err)
+	}
+	mounts, err := readMountInfo(mountinfo)
+	if err != nil || len(mounts) != 2 {
+		t.Fatalf("unexpected mounts %v, %v", mounts, err)
+	}
+	if mounts[0].options[1] != "nosuid" {
+		t.Errorf("unexpected options %v", mounts[0].options)
+	}
+
+	volume := mountInfo{device: "0:52", root: "/pv-1"}
+	tests := []struct {
+		mount    mountInfo
+		contains bool
+	}{
+		{mountInfo{device: "0:52", root: "/pv-1"}, true},
+		{mountInfo{device: "0:52", root: "/pv-1/sub"}, true},
+		{mountInfo{device: "0:52", root: "/pv-10"}, false},
+		{mountInfo{device: "0:53", root: "/pv-1"}, false},
+	}
+	for _, test := range tests {
+		if test.mount.contains(volume) != test.contains {
+			t.Errorf("%+v contains %+v: expected %t", test.mount, volume, test.contains)
+		}
+	}
+}
+
+func TestPodProcesses(t *testing.T) {
+	podUID := "1f2e3d4c-0000-4000-8000-000000000001"
+	uid, err := podUIDFromTargetPath("/var/lib/kubelet/pods/" + podUID + "/volumes/kubernetes.io~csi/pv-1/mount")
+	if err != nil || uid != podUID {
+		t.Fatalf("unexpected pod UID %q, %v", uid, err)
+	}
+	if _, err := podUIDFromTargetPath("/var/lib/kubelet/plugins/kubernetes.io/csi/nfs.csi.k8s.io/globalmount"); err == nil {
+		t.Errorf("expected an error for a path out of the pods")
+	}
+
+	proc := t.TempDir()
+	cgroups := map[string]string{
+		// systemd and cgroupfs drivers
+		"10": "0::/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod" + strings.ReplaceAll(podUID, "-", "_") + ".slice/cri-containerd-a.scope\n",
+		"11": "0::/kubepods/besteffort/pod" + podUID + "/b\n",
+		// another pod and a host process
+		"12": "0::/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod1f2e3d4c_0000_4000_8000_000000000002.slice/cri-containerd-c.scope\n",
+		"13": "0::/system.slice/kubelet.service\n",
+	}
+	for pid, cgroup := range cgroups {
+		if err := os.MkdirAll(filepath.Join(proc, pid), 0755); err != nil {
+			t.Fatalf("failed to create proc: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(proc, pid, "cgroup"), []byte(cgroup), 0644); err != nil {
+			t.Fatalf("failed to write cgroup: %v", err)
+		}
+	}
+	defer func(path string) { procPath = path }(procPath)
+	procPath = proc
+
+	pids, err := podProcesses(podUID)
+	if err != nil {
+		t.Fatalf("podProcesses failed: %v", err)
+	}
+	sort.Ints(pids)
+	if len(pids) != 2 || pids[0] != 10 || pids[1] != 11 {
+		t.Errorf("unexpected pids %v", pids)
+	}
+}
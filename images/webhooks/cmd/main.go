@@ -24,6 +24,7 @@ import (
 
 	"github.com/sirupsen/logrus"
 	kwhlogrus "github.com/slok/kubewebhook/v2/pkg/log/logrus"
+	corev1 "k8s.io/api/core/v1"
 	storagev1 "k8s.io/api/storage/v1"
 
 	cn "github.com/deckhouse/csi-nfs/api/v1alpha1"
@@ -57,6 +58,7 @@ const (
 	NSCValidatorID = "NSCValidator"
 	SCValidatorID  = "SCValidator"
 	MCValidatorID  = "MCValidator"
+	PodMutatorID   = "PodMutator"
 )
 
 func main() {
@@ -84,10 +86,17 @@ func main() {
 		os.Exit(1)
 	}
 
+	podMutatingWebhookHandler, err := handlers.GetMutatingWebhookHandler(handlers.PodMutate, PodMutatorID, &corev1.Pod{}, logger)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error creating podMutatingWebhookHandler: %s", err)
+		os.Exit(1)
+	}
+
 	mux := http.NewServeMux()
 	mux.Handle("/sc-validate", scValidatingWebhookHandler)
 	mux.Handle("/nsc-validate", nscValidatingWebhookHandler)
 	mux.Handle("/mc-validate", mcValidatingWebhookHandler)
+	mux.Handle("/pod-mutate", podMutatingWebhookHandler)
 	mux.HandleFunc("/healthz", httpHandlerHealthz)
 
 	logger.Infof("Feature TLSEnabled:%t", commonfeature.TLSEnabled())
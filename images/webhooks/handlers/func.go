@@ -18,7 +18,11 @@ package handlers
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
 	kwhhttp "github.com/slok/kubewebhook/v2/pkg/http"
 	"github.com/slok/kubewebhook/v2/pkg/log"
@@ -28,6 +32,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	cn "github.com/deckhouse/csi-nfs/api/v1alpha1"
+	commonfeature "github.com/deckhouse/csi-nfs/lib/go/common/pkg/feature"
 	commonvalidating "github.com/deckhouse/csi-nfs/lib/go/common/pkg/validating"
 	d8commonapi "github.com/deckhouse/sds-common-lib/api/v1alpha1"
 )
@@ -72,12 +77,58 @@ func GetValidatingWebhookHandler(validationFunc func(ctx context.Context, _ *mod
 	return mutationWebhookHandler, err
 }
 
-func validateModuleConfig(mc *d8commonapi.ModuleConfig, nscList *cn.NFSStorageClassList) error {
+// validateModuleConfig checks the certificates of the tlsParameters setting
+// and the NFSStorageClasses against the settings of the ModuleConfig and
+// returns the warnings and the errors of all of them. A warning repeated by
+// several NFSStorageClasses is returned once with their names.
+func validateModuleConfig(mc *d8commonapi.ModuleConfig, nscList *cn.NFSStorageClassList) ([]string, error) {
+	var (
+		warnings []string
+		errs     []error
+		// the raw warnings in the order of appearance and their NFSStorageClasses
+		nscWarnings []string
+		nscNames    = map[string][]string{}
+	)
+
+	// the certificates are checked even if no NFSStorageClass uses them yet
+	// and are reported once instead of for every NFSStorageClass
+	if commonfeature.TLSEnabled() {
+		_, tlsErrs, tlsWarnings := commonvalidating.ValidateTLSParameters(mc, time.Now())
+		for _, warning := range tlsWarnings {
+			warning = fmt.Sprintf("ModuleConfig %s: %s", mc.Name, warning)
+			warnings = append(warnings, warning)
+			// the NFSStorageClasses using the certificates repeat the warning
+			nscNames[warning] = nil
+		}
+		if len(tlsErrs) > 0 {
+			return warnings, fmt.Errorf("ModuleConfig %s has invalid TLS certificates: %w", mc.Name, tlsErrs.ToAggregate())
+		}
+	}
+
 	for _, nsc := range nscList.Items {
-		if err := commonvalidating.ValidateNFSStorageClass(mc, &nsc); err != nil {
-			return err
+		nscErrs, warningsOfNSC := commonvalidating.ValidateNFSStorageClass(mc, &nsc)
+		for _, warning := range warningsOfNSC {
+			names, seen := nscNames[warning]
+			if !seen {
+				nscWarnings = append(nscWarnings, warning)
+			} else if names == nil {
+				continue
+			}
+			nscNames[warning] = append(names, nsc.Name)
+		}
+		if len(nscErrs) > 0 {
+			errs = append(errs, fmt.Errorf("ModuleConfig %s does not suit NFSStorageClass %s: %w", mc.Name, nsc.Name, nscErrs.ToAggregate()))
+		}
+	}
+
+	for _, warning := range nscWarnings {
+		names := nscNames[warning]
+		if len(names) == 1 {
+			warnings = append(warnings, fmt.Sprintf("NFSStorageClass %s: %s", names[0], warning))
+			continue
 		}
+		warnings = append(warnings, fmt.Sprintf("NFSStorageClasses %s: %s", strings.Join(names, ", "), warning))
 	}
 
-	return nil
+	return warnings, errors.Join(errs...)
 }
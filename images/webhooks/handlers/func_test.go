@@ -0,0 +1,142 @@
+/*
+Copyright 2026 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handlers
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	cn "github.com/deckhouse/csi-nfs/api/v1alpha1"
+	commonfeature "github.com/deckhouse/csi-nfs/lib/go/common/pkg/feature"
+	d8commonapi "github.com/deckhouse/sds-common-lib/api/v1alpha1"
+)
+
+// testCAValue returns a base64 encoded self-signed CA valid until notAfter.
+func testCAValue(t *testing.T, notAfter time.Time) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "nfs-ca"},
+		NotBefore:             notAfter.Add(-365 * 24 * time.Hour),
+		NotAfter:              notAfter,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func testNFSStorageClass(name string, tls bool, mountMode string) cn.NFSStorageClass {
+	return cn.NFSStorageClass{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: cn.NFSStorageClassSpec{
+			Connection:        &cn.NFSStorageClassConnection{Host: "192.168.1.100", Share: "/data", NFSVersion: "4.2", Tls: tls},
+			MountOptions:      &cn.NFSStorageClassMountOptions{MountMode: mountMode},
+			ReclaimPolicy:     "Delete",
+			VolumeBindingMode: "WaitForFirstConsumer",
+		},
+	}
+}
+
+func TestValidateModuleConfigWarnings(t *testing.T) {
+	if !commonfeature.TLSEnabled() {
+		t.Skip("RPC-with-TLS is not available in the edition")
+	}
+	expiringCA := testCAValue(t, time.Now().Add(7*24*time.Hour))
+	validCA := testCAValue(t, time.Now().Add(365*24*time.Hour))
+
+	tt := []struct {
+		name             string
+		ca               string
+		nscs             []cn.NFSStorageClass
+		expectedWarnings []string
+	}{
+		{
+			name: "valid certificates",
+			ca:   validCA,
+			nscs: []cn.NFSStorageClass{testNFSStorageClass("first", true, "hard"), testNFSStorageClass("second", true, "hard")},
+		},
+		{
+			name:             "expiring certificate is reported once",
+			ca:               expiringCA,
+			nscs:             []cn.NFSStorageClass{testNFSStorageClass("first", true, "hard"), testNFSStorageClass("second", true, "hard")},
+			expectedWarnings: []string{"ModuleConfig csi-nfs: spec.settings.tlsParameters.ca"},
+		},
+		{
+			name:             "warning of one NFSStorageClass",
+			ca:               validCA,
+			nscs:             []cn.NFSStorageClass{testNFSStorageClass("first", false, "soft"), testNFSStorageClass("second", false, "hard")},
+			expectedWarnings: []string{"NFSStorageClass first: spec.mountOptions.mountMode"},
+		},
+		{
+			name:             "same warning of several NFSStorageClasses",
+			ca:               validCA,
+			nscs:             []cn.NFSStorageClass{testNFSStorageClass("first", false, "soft"), testNFSStorageClass("second", false, "hard"), testNFSStorageClass("third", false, "soft")},
+			expectedWarnings: []string{"NFSStorageClasses first, third: spec.mountOptions.mountMode"},
+		},
+		{
+			name: "expiring certificate and warnings of NFSStorageClasses",
+			ca:   expiringCA,
+			nscs: []cn.NFSStorageClass{testNFSStorageClass("first", true, "soft"), testNFSStorageClass("second", true, "soft")},
+			expectedWarnings: []string{
+				"ModuleConfig csi-nfs: spec.settings.tlsParameters.ca",
+				"NFSStorageClasses first, second: spec.mountOptions.mountMode",
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			mc := &d8commonapi.ModuleConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: "csi-nfs"},
+				Spec: d8commonapi.ModuleConfigSpec{
+					Settings: d8commonapi.SettingsValues{"tlsParameters": map[string]any{"ca": tc.ca}},
+				},
+			}
+			warnings, err := validateModuleConfig(mc, &cn.NFSStorageClassList{Items: tc.nscs})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(warnings) != len(tc.expectedWarnings) {
+				t.Fatalf("Expected warnings %v, but got %v", tc.expectedWarnings, warnings)
+			}
+			for i, expected := range tc.expectedWarnings {
+				if !strings.HasPrefix(warnings[i], expected) {
+					t.Errorf("Expected the warning %d to start with %q, but got %q", i, expected, warnings[i])
+				}
+			}
+		})
+	}
+}
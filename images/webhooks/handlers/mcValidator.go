@@ -64,13 +64,15 @@ func MCValidate(ctx context.Context, arReview *model.AdmissionReview, obj metav1
 		klog.Fatal(err)
 	}
 
-	if err := validateModuleConfig(nfsModuleConfig, nscList); err != nil {
+	warnings, err := validateModuleConfig(nfsModuleConfig, nscList)
+	if err != nil {
 		klog.Error(err)
 		return &kwhvalidating.ValidatorResult{
-			Valid:   false,
-			Message: fmt.Sprintf("%v", err),
+			Valid:    false,
+			Message:  fmt.Sprintf("%v", err),
+			Warnings: warnings,
 		}, nil
 	}
 
-	return &kwhvalidating.ValidatorResult{Valid: true}, nil
+	return &kwhvalidating.ValidatorResult{Valid: true, Warnings: warnings}, nil
 }
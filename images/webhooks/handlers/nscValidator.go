@@ -70,13 +70,22 @@ func NSCValidate(ctx context.Context, arReview *model.AdmissionReview, obj metav
 		klog.Fatal(err)
 	}
 
-	if err := commonvalidating.ValidateNFSStorageClass(nfsModuleConfig, nsc); err != nil {
+	errs, warnings := commonvalidating.ValidateNFSStorageClass(nfsModuleConfig, nsc)
+	if arReview.Operation == model.OperationCreate {
+		errs = append(errs, commonvalidating.ValidateNewNFSStorageClass(nsc)...)
+	}
+	for _, warning := range warnings {
+		klog.Warningf("NFSStorageClass %s: %s", nsc.Name, warning)
+	}
+	if len(errs) > 0 {
+		err := fmt.Errorf("NFSStorageClass %s is invalid: %w", nsc.Name, errs.ToAggregate())
 		klog.Error(err)
 		return &kwhvalidating.ValidatorResult{
-			Valid:   false,
-			Message: fmt.Sprintf("%v", err),
+			Valid:    false,
+			Message:  err.Error(),
+			Warnings: warnings,
 		}, nil
 	}
 
-	return &kwhvalidating.ValidatorResult{Valid: true}, nil
+	return &kwhvalidating.ValidatorResult{Valid: true, Warnings: warnings}, nil
 }
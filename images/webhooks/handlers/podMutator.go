@@ -0,0 +1,237 @@
+/*
+Copyright 2026 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"reflect"
+	"slices"
+
+	"github.com/slok/kubewebhook/v2/pkg/model"
+	kwhmutating "github.com/slok/kubewebhook/v2/pkg/webhook/mutating"
+	v1 "k8s.io/api/core/v1"
+	sv1 "k8s.io/api/storage/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	cn "github.com/deckhouse/csi-nfs/api/v1alpha1"
+	"github.com/deckhouse/sds-common-lib/kubeclient"
+)
+
+const (
+	annotationDefaultStorageClass     = "storageclass.kubernetes.io/is-default-class"
+	annotationBetaDefaultStorageClass = "storageclass.beta.kubernetes.io/is-default-class"
+)
+
+// PodMutate adds to the required node affinity of the Pod the node selectors
+// of the NFSStorageClasses of its PVCs, generic ephemeral volumes and inline
+// CSI volumes, so the Pod is scheduled only to the workloadNodes of the
+// NFSStorageClasses.
+func PodMutate(ctx context.Context, arReview *model.AdmissionReview, obj metav1.Object) (*kwhmutating.MutatorResult, error) {
+	pod, ok := obj.(*v1.Pod)
+	if !ok {
+		// If not a pod just continue the mutation chain(if there is one) and do nothing.
+		return &kwhmutating.MutatorResult{}, nil
+	}
+
+	cl, err := kubeclient.New(cn.AddToScheme,
+		clientgoscheme.AddToScheme,
+	)
+	if err != nil {
+		klog.Fatal(err) // pod restarting
+	}
+
+	namespace := pod.Namespace
+	if namespace == "" {
+		namespace = arReview.Namespace
+	}
+	requirements, err := getWorkloadNodesRequirements(ctx, cl, namespace, pod)
+	if err != nil {
+		klog.Error(err)
+		return nil, err
+	}
+	if len(requirements) == 0 {
+		return &kwhmutating.MutatorResult{}, nil
+	}
+
+	addRequiredNodeAffinity(pod, requirements)
+	klog.Infof("Added node affinity %+v of the NFSStorageClasses to pod %s/%s", requirements, namespace, pod.GetGenerateName()+pod.Name)
+	return &kwhmutating.MutatorResult{MutatedObject: pod}, nil
+}
+
+// getWorkloadNodesRequirements returns the node selector requirements of the
+// workloadNodes of the NFSStorageClasses of the Pod volumes.
+func getWorkloadNodesRequirements(ctx context.Context, cl client.Client, namespace string, pod *v1.Pod) ([]v1.NodeSelectorRequirement, error) {
+	nscList := &cn.NFSStorageClassList{}
+	if err := cl.List(ctx, nscList); err != nil {
+		return nil, fmt.Errorf("failed to list NFSStorageClasses: %w", err)
+	}
+	// the volumes are not looked up if there is nothing to enforce
+	if !slices.ContainsFunc(nscList.Items, hasWorkloadNodesSelector) {
+		return nil, nil
+	}
+
+	var requirements []v1.NodeSelectorRequirement
+	for _, volume := range pod.Spec.Volumes {
+		nsc, err := getVolumeNFSStorageClass(ctx, cl, namespace, volume, nscList)
+		if err != nil {
+			return nil, err
+		}
+		if nsc == nil || !hasWorkloadNodesSelector(*nsc) {
+			continue
+		}
+
+		for _, requirement := range labelSelectorToNodeSelectorRequirements(nsc.Spec.WorkloadNodes.NodeSelector) {
+			if !slices.ContainsFunc(requirements, func(r v1.NodeSelectorRequirement) bool { return reflect.DeepEqual(r, requirement) }) {
+				requirements = append(requirements, requirement)
+			}
+		}
+	}
+	return requirements, nil
+}
+
+func hasWorkloadNodesSelector(nsc cn.NFSStorageClass) bool {
+	return nsc.Spec.WorkloadNodes != nil && nsc.Spec.WorkloadNodes.NodeSelector != nil
+}
+
+// getVolumeNFSStorageClass returns the NFSStorageClass of the volume, nil if
+// the volume is not of an NFSStorageClass. An inline CSI volume of the driver
+// belongs to the NFSStorageClass with its server and share, if there is one.
+func getVolumeNFSStorageClass(ctx context.Context, cl client.Client, namespace string, volume v1.Volume, nscList *cn.NFSStorageClassList) (*cn.NFSStorageClass, error) {
+	if volume.CSI != nil {
+		if volume.CSI.Driver != NFSStorageClassProvisioner {
+			return nil, nil
+		}
+		server, share := volume.CSI.VolumeAttributes["server"], path.Clean(volume.CSI.VolumeAttributes["share"])
+		for i, nsc := range nscList.Items {
+			if nsc.Spec.Connection != nil && nsc.Spec.Connection.Host == server && path.Clean(nsc.Spec.Connection.Share) == share {
+				return &nscList.Items[i], nil
+			}
+		}
+		return nil, nil
+	}
+
+	storageClassName, err := getVolumeStorageClassName(ctx, cl, namespace, volume)
+	if err != nil || storageClassName == "" {
+		return nil, err
+	}
+	for i, nsc := range nscList.Items {
+		if nsc.Name == storageClassName {
+			return &nscList.Items[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// getVolumeStorageClassName returns the storage class of the PVC of the
+// volume or of the PVC template of the generic ephemeral volume, or an empty
+// string if there is none. A PVC which does not exist yet is an error: the
+// node affinity is only added when the Pod is created, so the Pod would not
+// be kept on the workloadNodes of the NFSStorageClass of the PVC.
+func getVolumeStorageClassName(ctx context.Context, cl client.Client, namespace string, volume v1.Volume) (string, error) {
+	switch {
+	case volume.PersistentVolumeClaim != nil:
+		pvc := &v1.PersistentVolumeClaim{}
+		err := cl.Get(ctx, client.ObjectKey{Namespace: namespace, Name: volume.PersistentVolumeClaim.ClaimName}, pvc)
+		if err != nil {
+			if k8serrors.IsNotFound(err) {
+				return "", fmt.Errorf("PVC %s/%s of volume %s does not exist yet, create it before the pod so that the pod is kept on the workloadNodes of its NFSStorageClass", namespace, volume.PersistentVolumeClaim.ClaimName, volume.Name)
+			}
+			return "", fmt.Errorf("failed to get PVC %s/%s: %w", namespace, volume.PersistentVolumeClaim.ClaimName, err)
+		}
+		if pvc.Spec.StorageClassName == nil {
+			return "", nil
+		}
+		return *pvc.Spec.StorageClassName, nil
+
+	case volume.Ephemeral != nil && volume.Ephemeral.VolumeClaimTemplate != nil:
+		if storageClassName := volume.Ephemeral.VolumeClaimTemplate.Spec.StorageClassName; storageClassName != nil {
+			return *storageClassName, nil
+		}
+		scList := &sv1.StorageClassList{}
+		if err := cl.List(ctx, scList); err != nil {
+			return "", fmt.Errorf("failed to list StorageClasses: %w", err)
+		}
+		for _, sc := range scList.Items {
+			if sc.Annotations[annotationDefaultStorageClass] == "true" || sc.Annotations[annotationBetaDefaultStorageClass] == "true" {
+				return sc.Name, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// labelSelectorToNodeSelectorRequirements converts the label selector of the
+// nodes to the node selector requirements of the node affinity.
+func labelSelectorToNodeSelectorRequirements(selector *metav1.LabelSelector) []v1.NodeSelectorRequirement {
+	keys := make([]string, 0, len(selector.MatchLabels))
+	for key := range selector.MatchLabels {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	requirements := make([]v1.NodeSelectorRequirement, 0, len(keys)+len(selector.MatchExpressions))
+	for _, key := range keys {
+		requirements = append(requirements, v1.NodeSelectorRequirement{
+			Key:      key,
+			Operator: v1.NodeSelectorOpIn,
+			Values:   []string{selector.MatchLabels[key]},
+		})
+	}
+	for _, expression := range selector.MatchExpressions {
+		// the operators of the label selectors are a subset of the node selector ones
+		requirements = append(requirements, v1.NodeSelectorRequirement{
+			Key:      expression.Key,
+			Operator: v1.NodeSelectorOperator(expression.Operator),
+			Values:   expression.Values,
+		})
+	}
+	return requirements
+}
+
+// addRequiredNodeAffinity adds the requirements to every required node
+// selector term of the Pod, so they are met whichever term matches the node.
+func addRequiredNodeAffinity(pod *v1.Pod, requirements []v1.NodeSelectorRequirement) {
+	if pod.Spec.Affinity == nil {
+		pod.Spec.Affinity = &v1.Affinity{}
+	}
+	if pod.Spec.Affinity.NodeAffinity == nil {
+		pod.Spec.Affinity.NodeAffinity = &v1.NodeAffinity{}
+	}
+	nodeAffinity := pod.Spec.Affinity.NodeAffinity
+	if nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution = &v1.NodeSelector{}
+	}
+	nodeSelector := nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+	if len(nodeSelector.NodeSelectorTerms) == 0 {
+		nodeSelector.NodeSelectorTerms = []v1.NodeSelectorTerm{{}}
+	}
+
+	for i := range nodeSelector.NodeSelectorTerms {
+		term := &nodeSelector.NodeSelectorTerms[i]
+		for _, requirement := range requirements {
+			if !slices.ContainsFunc(term.MatchExpressions, func(r v1.NodeSelectorRequirement) bool { return reflect.DeepEqual(r, requirement) }) {
+				term.MatchExpressions = append(term.MatchExpressions, requirement)
+			}
+		}
+	}
+}
@@ -0,0 +1,196 @@
+/*
+Copyright 2026 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handlers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	sv1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	cn "github.com/deckhouse/csi-nfs/api/v1alpha1"
+)
+
+const testNamespace = "default"
+
+func newTestClient(t *testing.T, objects ...client.Object) client.Client {
+	scheme := runtime.NewScheme()
+	for _, addToScheme := range []func(*runtime.Scheme) error{clientgoscheme.AddToScheme, cn.AddToScheme} {
+		if err := addToScheme(scheme); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+}
+
+func testWorkloadNodesNSC(name, share string, matchLabels map[string]string) *cn.NFSStorageClass {
+	nsc := &cn.NFSStorageClass{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: cn.NFSStorageClassSpec{
+			Connection: &cn.NFSStorageClassConnection{Host: "192.168.1.100", Share: share, NFSVersion: "4.2"},
+		},
+	}
+	if matchLabels != nil {
+		nsc.Spec.WorkloadNodes = &cn.NFSStorageClassWorkloadNodes{NodeSelector: &metav1.LabelSelector{MatchLabels: matchLabels}}
+	}
+	return nsc
+}
+
+func testPVC(name, storageClassName string) *v1.PersistentVolumeClaim {
+	return &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace},
+		Spec:       v1.PersistentVolumeClaimSpec{StorageClassName: &storageClassName},
+	}
+}
+
+func pvcVolume(claimName string) v1.Volume {
+	return v1.Volume{Name: claimName, VolumeSource: v1.VolumeSource{
+		PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: claimName},
+	}}
+}
+
+func inlineVolume(driver, share string) v1.Volume {
+	return v1.Volume{Name: "inline", VolumeSource: v1.VolumeSource{
+		CSI: &v1.CSIVolumeSource{Driver: driver, VolumeAttributes: map[string]string{"server": "192.168.1.100", "share": share}},
+	}}
+}
+
+func TestGetWorkloadNodesRequirements(t *testing.T) {
+	zoneA := []v1.NodeSelectorRequirement{{Key: "zone", Operator: v1.NodeSelectorOpIn, Values: []string{"a"}}}
+	defaultSC := &sv1.StorageClass{
+		ObjectMeta:  metav1.ObjectMeta{Name: "nfs-zone-a", Annotations: map[string]string{annotationDefaultStorageClass: "true"}},
+		Provisioner: NFSStorageClassProvisioner,
+	}
+
+	tt := []struct {
+		name                 string
+		objects              []client.Object
+		volumes              []v1.Volume
+		expectedRequirements []v1.NodeSelectorRequirement
+		expectedErr          bool
+	}{
+		{
+			name:    "no NFSStorageClass with workloadNodes",
+			objects: []client.Object{testWorkloadNodesNSC("nfs", "/data", nil)},
+			// the PVCs are not looked up
+			volumes: []v1.Volume{pvcVolume("missing")},
+		},
+		{
+			name:                 "PVC of an NFSStorageClass with workloadNodes",
+			objects:              []client.Object{testWorkloadNodesNSC("nfs-zone-a", "/data", map[string]string{"zone": "a"}), testPVC("data", "nfs-zone-a")},
+			volumes:              []v1.Volume{pvcVolume("data")},
+			expectedRequirements: zoneA,
+		},
+		{
+			name:    "PVC of another storage class",
+			objects: []client.Object{testWorkloadNodesNSC("nfs-zone-a", "/data", map[string]string{"zone": "a"}), testPVC("data", "local")},
+			volumes: []v1.Volume{pvcVolume("data")},
+		},
+		{
+			name:        "PVC which does not exist yet",
+			objects:     []client.Object{testWorkloadNodesNSC("nfs-zone-a", "/data", map[string]string{"zone": "a"})},
+			volumes:     []v1.Volume{pvcVolume("missing")},
+			expectedErr: true,
+		},
+		{
+			name:    "generic ephemeral volume of the default storage class",
+			objects: []client.Object{testWorkloadNodesNSC("nfs-zone-a", "/data", map[string]string{"zone": "a"}), defaultSC},
+			volumes: []v1.Volume{{Name: "scratch", VolumeSource: v1.VolumeSource{
+				Ephemeral: &v1.EphemeralVolumeSource{VolumeClaimTemplate: &v1.PersistentVolumeClaimTemplate{}},
+			}}},
+			expectedRequirements: zoneA,
+		},
+		{
+			name:                 "inline CSI volume of an NFSStorageClass",
+			objects:              []client.Object{testWorkloadNodesNSC("nfs-zone-a", "/data", map[string]string{"zone": "a"})},
+			volumes:              []v1.Volume{inlineVolume(NFSStorageClassProvisioner, "/data/")},
+			expectedRequirements: zoneA,
+		},
+		{
+			name:    "inline CSI volume without an NFSStorageClass",
+			objects: []client.Object{testWorkloadNodesNSC("nfs-zone-a", "/data", map[string]string{"zone": "a"})},
+			volumes: []v1.Volume{inlineVolume(NFSStorageClassProvisioner, "/other")},
+		},
+		{
+			name:    "inline CSI volume of another driver",
+			objects: []client.Object{testWorkloadNodesNSC("nfs-zone-a", "/data", map[string]string{"zone": "a"})},
+			volumes: []v1.Volume{inlineVolume("smb.csi.k8s.io", "/data")},
+		},
+		{
+			name: "same selector of several volumes",
+			objects: []client.Object{
+				testWorkloadNodesNSC("nfs-zone-a", "/data", map[string]string{"zone": "a"}),
+				testPVC("first", "nfs-zone-a"),
+				testPVC("second", "nfs-zone-a"),
+			},
+			volumes:              []v1.Volume{pvcVolume("first"), pvcVolume("second"), inlineVolume(NFSStorageClassProvisioner, "/data")},
+			expectedRequirements: zoneA,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			pod := &v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: testNamespace},
+				Spec:       v1.PodSpec{Volumes: tc.volumes},
+			}
+			requirements, err := getWorkloadNodesRequirements(context.Background(), newTestClient(t, tc.objects...), testNamespace, pod)
+			if (err != nil) != tc.expectedErr {
+				t.Fatalf("Expected error %t, but got %v", tc.expectedErr, err)
+			}
+			if !reflect.DeepEqual(requirements, tc.expectedRequirements) {
+				t.Errorf("Expected requirements %v, but got %v", tc.expectedRequirements, requirements)
+			}
+		})
+	}
+}
+
+func TestAddRequiredNodeAffinity(t *testing.T) {
+	zoneA := v1.NodeSelectorRequirement{Key: "zone", Operator: v1.NodeSelectorOpIn, Values: []string{"a"}}
+	ssd := v1.NodeSelectorRequirement{Key: "disk", Operator: v1.NodeSelectorOpExists}
+	hdd := v1.NodeSelectorRequirement{Key: "disk", Operator: v1.NodeSelectorOpDoesNotExist}
+
+	pod := &v1.Pod{Spec: v1.PodSpec{Affinity: &v1.Affinity{NodeAffinity: &v1.NodeAffinity{
+		RequiredDuringSchedulingIgnoredDuringExecution: &v1.NodeSelector{NodeSelectorTerms: []v1.NodeSelectorTerm{
+			{MatchExpressions: []v1.NodeSelectorRequirement{ssd}},
+			{MatchExpressions: []v1.NodeSelectorRequirement{hdd, zoneA}},
+		}},
+	}}}}
+	addRequiredNodeAffinity(pod, []v1.NodeSelectorRequirement{zoneA})
+
+	expected := []v1.NodeSelectorTerm{
+		{MatchExpressions: []v1.NodeSelectorRequirement{ssd, zoneA}},
+		{MatchExpressions: []v1.NodeSelectorRequirement{hdd, zoneA}},
+	}
+	if terms := pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms; !reflect.DeepEqual(terms, expected) {
+		t.Errorf("Expected node selector terms %v, but got %v", expected, terms)
+	}
+
+	pod = &v1.Pod{}
+	addRequiredNodeAffinity(pod, []v1.NodeSelectorRequirement{zoneA})
+	expected = []v1.NodeSelectorTerm{{MatchExpressions: []v1.NodeSelectorRequirement{zoneA}}}
+	if terms := pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms; !reflect.DeepEqual(terms, expected) {
+		t.Errorf("Expected node selector terms %v, but got %v", expected, terms)
+	}
+}
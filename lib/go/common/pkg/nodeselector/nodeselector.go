@@ -0,0 +1,136 @@
+/*
+Copyright 2026 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package nodeselector selects the nodes by the workloadNodes of the
+// NFSStorageClasses, the same way in the controller and in the scheduler
+// extender.
+package nodeselector
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	cn "github.com/deckhouse/csi-nfs/api/v1alpha1"
+)
+
+// DefaultNodeSelector selects the nodes of the NFSStorageClasses without
+// workloadNodes.
+var DefaultNodeSelector = &metav1.LabelSelector{
+	MatchLabels: map[string]string{
+		"kubernetes.io/os": "linux",
+	},
+}
+
+// ForNFSStorageClass returns the node selector of the workloadNodes of the
+// NFSStorageClass, or DefaultNodeSelector if it has none.
+func ForNFSStorageClass(nsc *cn.NFSStorageClass) *metav1.LabelSelector {
+	if nsc.Spec.WorkloadNodes == nil || nsc.Spec.WorkloadNodes.NodeSelector == nil {
+		return DefaultNodeSelector
+	}
+	return nsc.Spec.WorkloadNodes.NodeSelector
+}
+
+// ForNFSStorageClasses returns the node selectors of the NFSStorageClasses,
+// one per class without the duplicates, or DefaultNodeSelector if there are
+// no classes.
+func ForNFSStorageClasses(nscs []cn.NFSStorageClass) ([]*metav1.LabelSelector, error) {
+	if len(nscs) == 0 {
+		return []*metav1.LabelSelector{DefaultNodeSelector}, nil
+	}
+
+	nodeSelectors := make([]*metav1.LabelSelector, 0, len(nscs))
+	keys := make([]string, 0, len(nscs))
+	for i := range nscs {
+		nodeSelector := ForNFSStorageClass(&nscs[i])
+		selector, err := Selector(nodeSelector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid node selector of NFSStorageClass %s: %w", nscs[i].Name, err)
+		}
+		if key := selector.String(); !slices.Contains(keys, key) {
+			keys = append(keys, key)
+			nodeSelectors = append(nodeSelectors, nodeSelector)
+		}
+	}
+	return nodeSelectors, nil
+}
+
+// Selector converts the node selector with both its matchLabels and
+// matchExpressions to labels.Selector.
+func Selector(nodeSelector *metav1.LabelSelector) (labels.Selector, error) {
+	if nodeSelector == nil {
+		return nil, errors.New("node selector is nil")
+	}
+	return metav1.LabelSelectorAsSelector(nodeSelector)
+}
+
+// Selectors converts the node selectors to labels.Selector.
+func Selectors(nodeSelectors []*metav1.LabelSelector) ([]labels.Selector, error) {
+	selectors := make([]labels.Selector, 0, len(nodeSelectors))
+	for _, nodeSelector := range nodeSelectors {
+		selector, err := Selector(nodeSelector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid node selector %+v: %w", nodeSelector, err)
+		}
+		selectors = append(selectors, selector)
+	}
+	return selectors, nil
+}
+
+// MatchesAll reports whether the node labels match all the selectors.
+func MatchesAll(selectors []labels.Selector, nodeLabels map[string]string) bool {
+	for _, selector := range selectors {
+		if !selector.Matches(labels.Set(nodeLabels)) {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchesAny reports whether the node labels match any of the selectors.
+func MatchesAny(selectors []labels.Selector, nodeLabels map[string]string) bool {
+	for _, selector := range selectors {
+		if selector.Matches(labels.Set(nodeLabels)) {
+			return true
+		}
+	}
+	return false
+}
+
+// CommonNodeNames returns the sorted names of the nodes, given by their
+// labels, which match all the node selectors. A Pod with the volumes of
+// several NFSStorageClasses may only run on such nodes.
+func CommonNodeNames(nodeLabels map[string]map[string]string, nodeSelectors []*metav1.LabelSelector) ([]string, error) {
+	if len(nodeSelectors) == 0 {
+		return nil, errors.New("no node selectors")
+	}
+	selectors, err := Selectors(nodeSelectors)
+	if err != nil {
+		return nil, err
+	}
+
+	nodeNames := make([]string, 0, len(nodeLabels))
+	for nodeName, nodeLabels := range nodeLabels {
+		if MatchesAll(selectors, nodeLabels) {
+			nodeNames = append(nodeNames, nodeName)
+		}
+	}
+	slices.Sort(nodeNames)
+	return nodeNames, nil
+}
@@ -0,0 +1,160 @@
+/*
+Copyright 2026 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodeselector
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	cn "github.com/deckhouse/csi-nfs/api/v1alpha1"
+)
+
+var testNodes = map[string]map[string]string{
+	"linux-a-ssd":  {"kubernetes.io/os": "linux", "zone": "a", "disk": "ssd"},
+	"linux-a-hdd":  {"kubernetes.io/os": "linux", "zone": "a", "disk": "hdd"},
+	"linux-b-ssd":  {"kubernetes.io/os": "linux", "zone": "b", "disk": "ssd", "nfs": "true"},
+	"linux-c":      {"kubernetes.io/os": "linux", "zone": "c", "nfs": "true"},
+	"windows-a":    {"kubernetes.io/os": "windows", "zone": "a", "disk": "ssd"},
+	"unlabeled-os": {"zone": "a"},
+}
+
+func nfsStorageClass(name string, nodeSelector *metav1.LabelSelector) cn.NFSStorageClass {
+	nsc := cn.NFSStorageClass{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	if nodeSelector != nil {
+		nsc.Spec.WorkloadNodes = &cn.NFSStorageClassWorkloadNodes{NodeSelector: nodeSelector}
+	}
+	return nsc
+}
+
+func TestCommonNodeNames(t *testing.T) {
+	zonesAB := &metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "zone", Operator: metav1.LabelSelectorOpIn, Values: []string{"a", "b"}}},
+	}
+	ssdNotWindows := &metav1.LabelSelector{
+		MatchLabels: map[string]string{"disk": "ssd"},
+		MatchExpressions: []metav1.LabelSelectorRequirement{
+			{Key: "kubernetes.io/os", Operator: metav1.LabelSelectorOpNotIn, Values: []string{"windows"}},
+		},
+	}
+	withoutNFS := &metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "nfs", Operator: metav1.LabelSelectorOpDoesNotExist}},
+	}
+	withDisk := &metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "disk", Operator: metav1.LabelSelectorOpExists}},
+	}
+
+	tt := []struct {
+		name              string
+		nodeSelectors     []*metav1.LabelSelector
+		expectedNodeNames []string
+		expectedError     bool
+	}{
+		{
+			name:              "default node selector",
+			nodeSelectors:     []*metav1.LabelSelector{DefaultNodeSelector},
+			expectedNodeNames: []string{"linux-a-hdd", "linux-a-ssd", "linux-b-ssd", "linux-c"},
+		},
+		{
+			name:              "In expression",
+			nodeSelectors:     []*metav1.LabelSelector{zonesAB},
+			expectedNodeNames: []string{"linux-a-hdd", "linux-a-ssd", "linux-b-ssd", "unlabeled-os", "windows-a"},
+		},
+		{
+			name:              "matchLabels and NotIn expression",
+			nodeSelectors:     []*metav1.LabelSelector{ssdNotWindows},
+			expectedNodeNames: []string{"linux-a-ssd", "linux-b-ssd"},
+		},
+		{
+			name:              "Exists and DoesNotExist expressions",
+			nodeSelectors:     []*metav1.LabelSelector{{MatchExpressions: append(withDisk.MatchExpressions, withoutNFS.MatchExpressions...)}},
+			expectedNodeNames: []string{"linux-a-hdd", "linux-a-ssd", "windows-a"},
+		},
+		{
+			name:              "intersection of several node selectors",
+			nodeSelectors:     []*metav1.LabelSelector{zonesAB, ssdNotWindows, withoutNFS},
+			expectedNodeNames: []string{"linux-a-ssd"},
+		},
+		{
+			name:              "intersection with default node selector",
+			nodeSelectors:     []*metav1.LabelSelector{DefaultNodeSelector, withDisk},
+			expectedNodeNames: []string{"linux-a-hdd", "linux-a-ssd", "linux-b-ssd"},
+		},
+		{
+			name:              "empty intersection",
+			nodeSelectors:     []*metav1.LabelSelector{withoutNFS, {MatchLabels: map[string]string{"nfs": "true"}}},
+			expectedNodeNames: []string{},
+		},
+		{
+			name: "invalid expression",
+			nodeSelectors: []*metav1.LabelSelector{{
+				MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "zone", Operator: metav1.LabelSelectorOpIn}},
+			}},
+			expectedError: true,
+		},
+		{
+			name:          "no node selectors",
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			nodeNames, err := CommonNodeNames(testNodes, tc.nodeSelectors)
+			if (err != nil) != tc.expectedError {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !tc.expectedError && !reflect.DeepEqual(nodeNames, tc.expectedNodeNames) {
+				t.Errorf("Expected nodes %v, but got %v", tc.expectedNodeNames, nodeNames)
+			}
+		})
+	}
+}
+
+func TestForNFSStorageClasses(t *testing.T) {
+	zoneA := &metav1.LabelSelector{MatchLabels: map[string]string{"zone": "a"}}
+	sameZoneA := &metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "zone", Operator: metav1.LabelSelectorOpIn, Values: []string{"a"}}},
+	}
+
+	nodeSelectors, err := ForNFSStorageClasses(nil)
+	if err != nil || !reflect.DeepEqual(nodeSelectors, []*metav1.LabelSelector{DefaultNodeSelector}) {
+		t.Errorf("Expected default node selector without NFSStorageClasses, but got %v, %v", nodeSelectors, err)
+	}
+
+	// the NFSStorageClass without workloadNodes keeps the selectors of the others
+	nodeSelectors, err = ForNFSStorageClasses([]cn.NFSStorageClass{
+		nfsStorageClass("zone-a", zoneA),
+		nfsStorageClass("default", nil),
+		nfsStorageClass("same-zone-a", sameZoneA),
+		nfsStorageClass("zone-a-again", zoneA),
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(nodeSelectors, []*metav1.LabelSelector{zoneA, DefaultNodeSelector, sameZoneA}) {
+		t.Errorf("Expected node selectors of zone-a, default and same-zone-a, but got %v", nodeSelectors)
+	}
+
+	_, err = ForNFSStorageClasses([]cn.NFSStorageClass{nfsStorageClass("invalid", &metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "zone", Operator: "Gt", Values: []string{"1"}}},
+	})})
+	if err == nil {
+		t.Error("Expected error for invalid node selector")
+	}
+}
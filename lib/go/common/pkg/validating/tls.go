@@ -0,0 +1,220 @@
+/*
+Copyright 2026 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package validating
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	d8commonapi "github.com/deckhouse/sds-common-lib/api/v1alpha1"
+)
+
+// CertificateExpiryWarningPeriod is how long before their expiry the
+// certificates of the tlsParameters setting are warned about.
+const CertificateExpiryWarningPeriod = 30 * 24 * time.Hour
+
+// the certificates are not secret, but too long to be shown in the errors
+const omittedValue = "<omitted>"
+
+// TLSCertificate is a certificate of the tlsParameters setting of the
+// ModuleConfig.
+type TLSCertificate struct {
+	// Setting is tlsParameters.ca or tlsParameters.mtls.clientCert.
+	Setting   string
+	Subject   string
+	NotBefore time.Time
+	NotAfter  time.Time
+}
+
+// ValidAt returns whether the certificate is valid at the time.
+func (c TLSCertificate) ValidAt(now time.Time) bool {
+	return !now.Before(c.NotBefore) && !now.After(c.NotAfter)
+}
+
+// ValidateTLSParameters decodes and parses the certificates and the client
+// key of the tlsParameters setting of the ModuleConfig. It checks that the
+// client key matches the client certificate and that the client certificate
+// chains to the CA. The certificates which are expired, not valid yet or
+// expire within CertificateExpiryWarningPeriod are returned with a warning:
+// an expired certificate is renewed without changing the objects using it, so
+// it does not make the settings invalid. Only the settings which are set are
+// checked.
+func ValidateTLSParameters(nfsModuleConfig *d8commonapi.ModuleConfig, now time.Time) ([]TLSCertificate, field.ErrorList, []string) {
+	var (
+		certificates []TLSCertificate
+		allErrs      field.ErrorList
+		warnings     []string
+		fldPath      = field.NewPath("spec", "settings", tlsParametersSetting)
+	)
+
+	if nfsModuleConfig == nil {
+		return nil, nil, nil
+	}
+	value, ok := nfsModuleConfig.Spec.Settings[tlsParametersSetting]
+	if !ok {
+		return nil, nil, nil
+	}
+	tlsParameters, ok := value.(map[string]any)
+	if !ok {
+		return nil, append(allErrs, field.Invalid(fldPath, omittedValue, "must be an object")), nil
+	}
+
+	caPath := fldPath.Child("ca")
+	var roots *x509.CertPool
+	if nonEmptyString(tlsParameters, "ca") {
+		cas, err := decodeCertificates(tlsParameters["ca"].(string))
+		if err != nil {
+			allErrs = append(allErrs, field.Invalid(caPath, omittedValue, err.Error()))
+		} else {
+			roots = x509.NewCertPool()
+			for _, ca := range cas {
+				roots.AddCert(ca)
+				certificates = append(certificates, TLSCertificate{Setting: "tlsParameters.ca", Subject: ca.Subject.String(), NotBefore: ca.NotBefore, NotAfter: ca.NotAfter})
+				warnings = checkValidity(caPath, ca, now, warnings)
+			}
+		}
+	}
+
+	mtls, ok := tlsParameters["mtls"].(map[string]any)
+	if !ok {
+		if _, set := tlsParameters["mtls"]; set {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("mtls"), omittedValue, "must be an object"))
+		}
+		return certificates, allErrs, warnings
+	}
+
+	mtlsPath := fldPath.Child("mtls")
+	certPath := mtlsPath.Child("clientCert")
+	keyPath := mtlsPath.Child("clientKey")
+	hasCert, hasKey := nonEmptyString(mtls, "clientCert"), nonEmptyString(mtls, "clientKey")
+	switch {
+	case hasCert && !hasKey:
+		return certificates, append(allErrs, field.Required(keyPath, "the key of the client certificate must be set")), warnings
+	case !hasCert && hasKey:
+		return certificates, append(allErrs, field.Required(certPath, "the certificate of the client key must be set")), warnings
+	case !hasCert:
+		return certificates, allErrs, warnings
+	}
+
+	chain, err := decodeCertificates(mtls["clientCert"].(string))
+	if err != nil {
+		return certificates, append(allErrs, field.Invalid(certPath, omittedValue, err.Error())), warnings
+	}
+	clientCert := chain[0]
+	certificates = append(certificates, TLSCertificate{Setting: "tlsParameters.mtls.clientCert", Subject: clientCert.Subject.String(), NotBefore: clientCert.NotBefore, NotAfter: clientCert.NotAfter})
+	warnings = checkValidity(certPath, clientCert, now, warnings)
+
+	if err := checkKeyPair(mtls["clientCert"].(string), mtls["clientKey"].(string)); err != nil {
+		allErrs = append(allErrs, field.Invalid(keyPath, omittedValue, err.Error()))
+	}
+
+	if roots != nil {
+		if err := verifyChain(clientCert, chain[1:], roots, now); err != nil {
+			allErrs = append(allErrs, field.Invalid(certPath, omittedValue, fmt.Sprintf("is not signed by tlsParameters.ca for the client authentication: %v", err)))
+		}
+	}
+
+	return certificates, allErrs, warnings
+}
+
+// decodeCertificates decodes the base64 encoded PEM certificates.
+func decodeCertificates(value string) ([]*x509.Certificate, error) {
+	data, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("is not base64 encoded: %v", err)
+	}
+
+	var certs []*x509.Certificate
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			return nil, fmt.Errorf("has a PEM block of type %s instead of CERTIFICATE", block.Type)
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("has an invalid certificate: %v", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("has no PEM certificates")
+	}
+	return certs, nil
+}
+
+// checkKeyPair checks that the base64 encoded PEM key is a valid key of the
+// certificate.
+func checkKeyPair(certValue, keyValue string) error {
+	keyPEM, err := base64.StdEncoding.DecodeString(keyValue)
+	if err != nil {
+		return fmt.Errorf("is not base64 encoded: %v", err)
+	}
+	if block, _ := pem.Decode(keyPEM); block == nil {
+		return errors.New("has no PEM private key")
+	}
+	certPEM, _ := base64.StdEncoding.DecodeString(certValue)
+	if _, err := tls.X509KeyPair(certPEM, keyPEM); err != nil {
+		return fmt.Errorf("is not the key of tlsParameters.mtls.clientCert: %v", err)
+	}
+	return nil
+}
+
+// verifyChain checks that the client certificate chains to the roots. The
+// validity periods are checked by checkValidity, so the chain of a
+// certificate which is not valid now is verified within its validity period.
+func verifyChain(clientCert *x509.Certificate, intermediateCerts []*x509.Certificate, roots *x509.CertPool, now time.Time) error {
+	intermediates := x509.NewCertPool()
+	for _, cert := range intermediateCerts {
+		intermediates.AddCert(cert)
+	}
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if now.After(clientCert.NotAfter) {
+		opts.CurrentTime = clientCert.NotAfter
+	} else if now.Before(clientCert.NotBefore) {
+		opts.CurrentTime = clientCert.NotBefore
+	}
+	_, err := clientCert.Verify(opts)
+	// the CA or an intermediate certificate is not valid at that time
+	var invalidErr x509.CertificateInvalidError
+	if errors.As(err, &invalidErr) && invalidErr.Reason == x509.Expired {
+		return nil
+	}
+	return err
+}
+
+func checkValidity(fldPath *field.Path, cert *x509.Certificate, now time.Time, warnings []string) []string {
+	switch {
+	case now.After(cert.NotAfter):
+		return append(warnings, fmt.Sprintf("%s: the certificate %q expired at %s", fldPath, cert.Subject, cert.NotAfter.UTC().Format(time.RFC3339)))
+	case now.Before(cert.NotBefore):
+		return append(warnings, fmt.Sprintf("%s: the certificate %q is not valid before %s", fldPath, cert.Subject, cert.NotBefore.UTC().Format(time.RFC3339)))
+	case cert.NotAfter.Sub(now) < CertificateExpiryWarningPeriod:
+		return append(warnings, fmt.Sprintf("%s: the certificate %q expires at %s, in %d days", fldPath, cert.Subject, cert.NotAfter.UTC().Format(time.RFC3339), int(cert.NotAfter.Sub(now).Hours()/24)))
+	}
+	return warnings
+}
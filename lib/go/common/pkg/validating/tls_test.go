@@ -0,0 +1,207 @@
+/*
+Copyright 2026 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package validating
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"slices"
+	"testing"
+	"time"
+
+	d8commonapi "github.com/deckhouse/sds-common-lib/api/v1alpha1"
+)
+
+type testCertificate struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// newTestCertificate issues a certificate valid until notAfter, signed by the
+// parent or self-signed if the parent is nil.
+func newTestCertificate(t *testing.T, name string, notAfter time.Time, parent *testCertificate) *testCertificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+		template.ExtKeyUsage = nil
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCertificate{cert: cert, key: key}
+}
+
+func (c *testCertificate) certValue() string {
+	return base64.StdEncoding.EncodeToString(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw}))
+}
+
+func (c *testCertificate) keyValue(t *testing.T) string {
+	der, err := x509.MarshalPKCS8PrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+}
+
+func TestValidateTLSParameters(t *testing.T) {
+	now := time.Now()
+	year := now.Add(365 * 24 * time.Hour)
+	ca := newTestCertificate(t, "ca", year, nil)
+	otherCA := newTestCertificate(t, "other-ca", year, nil)
+	client := newTestCertificate(t, "client", year, ca)
+	expiringClient := newTestCertificate(t, "expiring-client", now.Add(7*24*time.Hour), ca)
+	expiredClient := newTestCertificate(t, "expired-client", now.Add(-time.Minute), ca)
+	expiredForeignClient := newTestCertificate(t, "expired-foreign-client", now.Add(-time.Minute), otherCA)
+	expiredCA := newTestCertificate(t, "expired-ca", now.Add(-time.Minute), nil)
+	clientOfExpiredCA := newTestCertificate(t, "client-of-expired-ca", year, expiredCA)
+	foreignClient := newTestCertificate(t, "foreign-client", year, otherCA)
+
+	tlsParameters := func(ca string, client *testCertificate, key string) d8commonapi.SettingsValues {
+		parameters := map[string]any{"ca": ca}
+		if client != nil {
+			parameters["mtls"] = map[string]any{"clientCert": client.certValue(), "clientKey": key}
+		}
+		return d8commonapi.SettingsValues{"tlsParameters": parameters}
+	}
+
+	tt := []struct {
+		name                 string
+		settings             d8commonapi.SettingsValues
+		expectedFields       []string
+		expectedWarnings     int
+		expectedCertificates int
+	}{
+		{
+			name: "no tlsParameters",
+		},
+		{
+			name:                 "valid CA",
+			settings:             tlsParameters(ca.certValue(), nil, ""),
+			expectedCertificates: 1,
+		},
+		{
+			name:                 "several CAs",
+			settings:             tlsParameters(base64.StdEncoding.EncodeToString(append(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw}), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: otherCA.cert.Raw})...)), nil, ""),
+			expectedCertificates: 2,
+		},
+		{
+			name:                 "valid client certificate",
+			settings:             tlsParameters(ca.certValue(), client, client.keyValue(t)),
+			expectedCertificates: 2,
+		},
+		{
+			name:           "malformed tlsParameters",
+			settings:       d8commonapi.SettingsValues{"tlsParameters": "ca"},
+			expectedFields: []string{"spec.settings.tlsParameters"},
+		},
+		{
+			name:           "CA is not base64",
+			settings:       tlsParameters("not base64!", nil, ""),
+			expectedFields: []string{"spec.settings.tlsParameters.ca"},
+		},
+		{
+			name:           "CA is not PEM",
+			settings:       tlsParameters(base64.StdEncoding.EncodeToString([]byte("Root certificate in PEM format")), nil, ""),
+			expectedFields: []string{"spec.settings.tlsParameters.ca"},
+		},
+		{
+			name:                 "client certificate expires soon",
+			settings:             tlsParameters(ca.certValue(), expiringClient, expiringClient.keyValue(t)),
+			expectedWarnings:     1,
+			expectedCertificates: 2,
+		},
+		{
+			name:                 "expired client certificate",
+			settings:             tlsParameters(ca.certValue(), expiredClient, expiredClient.keyValue(t)),
+			expectedWarnings:     1,
+			expectedCertificates: 2,
+		},
+		{
+			name:                 "expired client certificate of another CA",
+			settings:             tlsParameters(ca.certValue(), expiredForeignClient, expiredForeignClient.keyValue(t)),
+			expectedFields:       []string{"spec.settings.tlsParameters.mtls.clientCert"},
+			expectedWarnings:     1,
+			expectedCertificates: 2,
+		},
+		{
+			name:                 "expired CA",
+			settings:             tlsParameters(expiredCA.certValue(), clientOfExpiredCA, clientOfExpiredCA.keyValue(t)),
+			expectedWarnings:     1,
+			expectedCertificates: 2,
+		},
+		{
+			name:                 "key of another certificate",
+			settings:             tlsParameters(ca.certValue(), client, foreignClient.keyValue(t)),
+			expectedFields:       []string{"spec.settings.tlsParameters.mtls.clientKey"},
+			expectedCertificates: 2,
+		},
+		{
+			name:                 "client certificate of another CA",
+			settings:             tlsParameters(ca.certValue(), foreignClient, foreignClient.keyValue(t)),
+			expectedFields:       []string{"spec.settings.tlsParameters.mtls.clientCert"},
+			expectedCertificates: 2,
+		},
+		{
+			name:                 "client certificate without key",
+			settings:             tlsParameters(ca.certValue(), client, ""),
+			expectedFields:       []string{"spec.settings.tlsParameters.mtls.clientKey"},
+			expectedCertificates: 1,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			certificates, errs, warnings := ValidateTLSParameters(moduleConfig(tc.settings), now)
+			if fields := errorFields(errs); !slices.Equal(fields, tc.expectedFields) {
+				t.Errorf("Expected errors of fields %v, but got %v", tc.expectedFields, errs)
+			}
+			if len(warnings) != tc.expectedWarnings {
+				t.Errorf("Expected %d warnings, but got %v", tc.expectedWarnings, warnings)
+			}
+			if len(certificates) != tc.expectedCertificates {
+				t.Errorf("Expected %d certificates, but got %v", tc.expectedCertificates, certificates)
+			}
+		})
+	}
+}
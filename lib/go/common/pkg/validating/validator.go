@@ -18,81 +18,504 @@ package validating
 
 import (
 	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
 
 	cn "github.com/deckhouse/csi-nfs/api/v1alpha1"
 	feature "github.com/deckhouse/csi-nfs/lib/go/common/pkg/feature"
+	"github.com/deckhouse/csi-nfs/lib/go/common/pkg/nodeselector"
 	d8commonapi "github.com/deckhouse/sds-common-lib/api/v1alpha1"
 )
 
-func ValidateNFSStorageClass(nfsModuleConfig *d8commonapi.ModuleConfig, nsc *cn.NFSStorageClass) error {
-	var logPostfix = "Such a combination of parameters is not allowed"
-
-	if nsc.Spec.Connection.NFSVersion == "3" {
-		if value, ok := nfsModuleConfig.Spec.Settings["v3support"]; !ok {
-			return fmt.Errorf(
-				"ModuleConfig: %s (the v3support parameter is missing); NFSStorageClass: %s (nfsVersion is set to 3); %s",
-				nfsModuleConfig.Name, nsc.Name, logPostfix,
-			)
-		} else if value == false {
-			return fmt.Errorf(
-				"ModuleConfig: %s (the v3support parameter is disabled); NFSStorageClass: %s (nfsVersion is set to 3); %s",
-				nfsModuleConfig.Name, nsc.Name, logPostfix,
-			)
-		}
-	}
-
-	if feature.TLSEnabled() {
-		if nsc.Spec.Connection.Tls || nsc.Spec.Connection.Mtls {
-			var tlsParameters map[string]any
-
-			value, ok := nfsModuleConfig.Spec.Settings["tlsParameters"]
-			if !ok {
-				return fmt.Errorf(
-					"ModuleConfig: %s (the tlsParameters parameter is missing); NFSStorageClass: %s (tls or mtls is enabled); %s",
-					nfsModuleConfig.Name, nsc.Name, logPostfix,
-				)
-			}
-			tlsParameters = value.(map[string]any)
+const (
+	v3supportSetting          = "v3support"
+	tlsParametersSetting      = "tlsParameters"
+	orphanScanIntervalSetting = "orphanScanInterval"
+)
 
-			if value, ok := tlsParameters["ca"]; !ok || len(value.(string)) == 0 {
-				return fmt.Errorf(
-					"ModuleConfig: %s (the tlsParameters.ca parameter is either missing or has a zero length); NFSStorageClass: %s (tls or mtls is enabled); %s",
-					nfsModuleConfig.Name, nsc.Name, logPostfix,
-				)
-			}
+var (
+	nfsVersions         = []string{"3", "4.1", "4.2"}
+	mountModes          = []string{"hard", "soft"}
+	reclaimPolicies     = []string{"Delete", "Retain"}
+	volumeBindingModes  = []string{"Immediate", "WaitForFirstConsumer"}
+	volumeCleanups      = []string{"Discard", "RandomFillSinglePass", "RandomFillThreePass"}
+	snapshotCompression = []string{"none", "gzip", "zstd"}
+	enforcements        = []string{"Warn", "ReadOnlyRemount", "RefuseReadWrite", "Block"}
+	onDeletes           = []string{"Delete", "Retain", "Archive"}
+
+	chmodPermissionsRegexp = regexp.MustCompile(`^[0-7]{3,4}$`)
+	durationRegexp         = regexp.MustCompile(`^([0-9]+h)?([0-9]+m)?([0-9]+s)?$`)
+	vacNameRegexp          = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+)
+
+const relativeShareMessage = "must be an absolute path of the export"
+
+// ValidateNewNFSStorageClass checks the fields of an NFSStorageClass being
+// created which are only warned about by ValidateNFSStorageClass to keep the
+// existing NFSStorageClasses valid.
+func ValidateNewNFSStorageClass(nsc *cn.NFSStorageClass) field.ErrorList {
+	var allErrs field.ErrorList
+	connection := nsc.Spec.Connection
+	if connection != nil && connection.Share != "" && !path.IsAbs(connection.Share) {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "connection", "share"), connection.Share, relativeShareMessage))
+	}
+	return allErrs
+}
+
+// ValidateNFSStorageClass checks all the fields of the NFSStorageClass spec
+// and their combination with the settings of the csi-nfs ModuleConfig. It
+// returns every invalid field and the warnings about the valid, but risky,
+// settings.
+func ValidateNFSStorageClass(nfsModuleConfig *d8commonapi.ModuleConfig, nsc *cn.NFSStorageClass) (field.ErrorList, []string) {
+	var (
+		allErrs  field.ErrorList
+		warnings []string
+		specPath = field.NewPath("spec")
+		spec     = &nsc.Spec
+	)
+
+	var settings d8commonapi.SettingsValues
+	moduleConfigName := "csi-nfs"
+	if nfsModuleConfig != nil {
+		settings = nfsModuleConfig.Spec.Settings
+		moduleConfigName = nfsModuleConfig.Name
+	}
+
+	errs, connectionWarnings := validateConnection(nfsModuleConfig, moduleConfigName, settings, spec.Connection, specPath.Child("connection"))
+	allErrs = append(allErrs, errs...)
+	warnings = append(warnings, connectionWarnings...)
+	// the share is immutable, so the existing NFSStorageClasses keep working
+	// and only the new ones are refused by ValidateNewNFSStorageClass
+	if spec.Connection != nil && spec.Connection.Share != "" && !path.IsAbs(spec.Connection.Share) {
+		warnings = append(warnings, fmt.Sprintf("%s: %s", specPath.Child("connection", "share"), relativeShareMessage))
+	}
+
+	if spec.MountOptions != nil {
+		errs, mountWarnings := validateMountOptions(spec.MountOptions, specPath.Child("mountOptions"))
+		allErrs = append(allErrs, errs...)
+		warnings = append(warnings, mountWarnings...)
+	}
+
+	if spec.ChmodPermissions != "" && !chmodPermissionsRegexp.MatchString(spec.ChmodPermissions) {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("chmodPermissions"), spec.ChmodPermissions, "must be 3 or 4 octal digits, for example 0775"))
+	}
 
-			if nsc.Spec.Connection.Mtls {
-				var mtls map[string]any
-
-				value, ok := tlsParameters["mtls"]
-				if !ok {
-					return fmt.Errorf(
-						"ModuleConfig: %s (the tlsParameters.mtls parameter is missing); NFSStorageClass: %s (mtls is enabled); %s",
-						nfsModuleConfig.Name, nsc.Name, logPostfix,
-					)
-				}
-				mtls = value.(map[string]any)
-
-				if value, ok := mtls["clientCert"]; !ok || len(value.(string)) == 0 {
-					return fmt.Errorf(
-						"ModuleConfig: %s (the tlsParameters.mtls.clientCert parameter is either missing or has a zero length); NFSStorageClass: %s (mtls is enabled); %s",
-						nfsModuleConfig.Name, nsc.Name, logPostfix,
-					)
-				}
-				if value, ok := mtls["clientKey"]; !ok || len(value.(string)) == 0 {
-					return fmt.Errorf(
-						"ModuleConfig: %s (the tlsParameters.mtls.clientKey parameter is either missing or has a zero length); NFSStorageClass: %s (mtls is enabled); %s",
-						nfsModuleConfig.Name, nsc.Name, logPostfix,
-					)
-				}
+	allErrs = append(allErrs, validateEnum(specPath.Child("reclaimPolicy"), spec.ReclaimPolicy, reclaimPolicies, true)...)
+	allErrs = append(allErrs, validateEnum(specPath.Child("volumeBindingMode"), spec.VolumeBindingMode, volumeBindingModes, true)...)
+
+	if spec.WorkloadNodes != nil {
+		nodeSelectorPath := specPath.Child("workloadNodes", "nodeSelector")
+		if spec.WorkloadNodes.NodeSelector == nil {
+			allErrs = append(allErrs, field.Required(nodeSelectorPath, "the nodes of the workloads must be selected"))
+		} else if _, err := nodeselector.Selector(spec.WorkloadNodes.NodeSelector); err != nil {
+			allErrs = append(allErrs, field.Invalid(nodeSelectorPath, spec.WorkloadNodes.NodeSelector, err.Error()))
+		}
+	}
+
+	allErrs = append(allErrs, validateVolumeCleanup(spec, specPath.Child("volumeCleanup"))...)
+	allErrs = append(allErrs, validateEnum(specPath.Child("snapshotCompression"), spec.SnapshotCompression, snapshotCompression, false)...)
+
+	if spec.SnapshotExport != nil {
+		errs, exportWarnings := validateSnapshotExport(spec.SnapshotExport, specPath.Child("snapshotExport"))
+		allErrs = append(allErrs, errs...)
+		warnings = append(warnings, exportWarnings...)
+	}
+
+	if spec.VolumeUsage != nil {
+		allErrs = append(allErrs, validateVolumeUsage(spec.VolumeUsage, specPath.Child("volumeUsage"))...)
+	}
+
+	allErrs = append(allErrs, validateOnDelete(moduleConfigName, settings, spec, specPath)...)
+
+	allErrs = append(allErrs, validateVolumeAttributesClasses(spec.VolumeAttributesClasses, specPath.Child("volumeAttributesClasses"))...)
+
+	return allErrs, warnings
+}
+
+func validateConnection(
+	nfsModuleConfig *d8commonapi.ModuleConfig,
+	moduleConfigName string,
+	settings d8commonapi.SettingsValues,
+	connection *cn.NFSStorageClassConnection,
+	fldPath *field.Path,
+) (field.ErrorList, []string) {
+	var allErrs field.ErrorList
+	if connection == nil {
+		return append(allErrs, field.Required(fldPath, "the NFS server and share must be set")), nil
+	}
+
+	if connection.Host == "" {
+		allErrs = append(allErrs, field.Required(fldPath.Child("host"), ""))
+	}
+	if connection.Share == "" {
+		allErrs = append(allErrs, field.Required(fldPath.Child("share"), ""))
+	}
+
+	allErrs = append(allErrs, validateEnum(fldPath.Child("nfsVersion"), connection.NFSVersion, nfsVersions, true)...)
+	if connection.NFSVersion == "3" {
+		if value, ok := settings[v3supportSetting]; !ok {
+			allErrs = append(allErrs, field.Forbidden(fldPath.Child("nfsVersion"), fmt.Sprintf("NFSv3 requires the v3support setting of ModuleConfig %s, which is missing", moduleConfigName)))
+		} else if enabled, _ := value.(bool); !enabled {
+			allErrs = append(allErrs, field.Forbidden(fldPath.Child("nfsVersion"), fmt.Sprintf("NFSv3 requires the v3support setting of ModuleConfig %s, which is disabled", moduleConfigName)))
+		}
+	}
+
+	if connection.Mtls && !connection.Tls {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("mtls"), connection.Mtls, "tls must also be enabled"))
+	}
+
+	_, hasTLSParameters := settings[tlsParametersSetting]
+	if !feature.TLSEnabled() {
+		if connection.Tls || connection.Mtls {
+			allErrs = append(allErrs, field.Forbidden(fldPath.Child("tls"), "RPC-with-TLS is not available in your edition"))
+		} else if hasTLSParameters {
+			allErrs = append(allErrs, field.Forbidden(fldPath, fmt.Sprintf("the tlsParameters setting of ModuleConfig %s is not allowed because RPC-with-TLS is not available in your edition", moduleConfigName)))
+		}
+		return allErrs, nil
+	}
+
+	if !connection.Tls && !connection.Mtls {
+		return allErrs, nil
+	}
+	tlsPath := fldPath.Child("tls")
+	if connection.Mtls {
+		tlsPath = fldPath.Child("mtls")
+	}
+	if !hasTLSParameters {
+		return append(allErrs, field.Forbidden(tlsPath, fmt.Sprintf("RPC-with-TLS requires the tlsParameters setting of ModuleConfig %s, which is missing", moduleConfigName))), nil
+	}
+	tlsParameters, ok := settings[tlsParametersSetting].(map[string]any)
+	if !ok {
+		return append(allErrs, field.Forbidden(tlsPath, fmt.Sprintf("the tlsParameters setting of ModuleConfig %s must be an object", moduleConfigName))), nil
+	}
+	if !nonEmptyString(tlsParameters, "ca") {
+		allErrs = append(allErrs, field.Forbidden(tlsPath, fmt.Sprintf("RPC-with-TLS requires the tlsParameters.ca setting of ModuleConfig %s, which is missing or empty", moduleConfigName)))
+	}
+	if connection.Mtls {
+		mtls, ok := tlsParameters["mtls"].(map[string]any)
+		if !ok {
+			return append(allErrs, field.Forbidden(tlsPath, fmt.Sprintf("mutual TLS requires the tlsParameters.mtls setting of ModuleConfig %s, which is missing or is not an object", moduleConfigName))), nil
+		}
+		for _, key := range []string{"clientCert", "clientKey"} {
+			if !nonEmptyString(mtls, key) {
+				allErrs = append(allErrs, field.Forbidden(tlsPath, fmt.Sprintf("mutual TLS requires the tlsParameters.mtls.%s setting of ModuleConfig %s, which is missing or empty", key, moduleConfigName)))
 			}
 		}
-	} else {
-		_, ok := nfsModuleConfig.Spec.Settings["tlsParameters"]
-		if nsc.Spec.Connection.Tls || nsc.Spec.Connection.Mtls || ok {
-			return fmt.Errorf("RPC-with-TLS related parameters are not allowed because the TLS feature is not available in your edition")
+	}
+
+	// the certificates are reported once for the NFSStorageClass using them
+	_, tlsErrs, tlsWarnings := ValidateTLSParameters(nfsModuleConfig, time.Now())
+	for _, err := range tlsErrs {
+		allErrs = append(allErrs, field.Forbidden(tlsPath, fmt.Sprintf("ModuleConfig %s: %s", moduleConfigName, err.Error())))
+	}
+	warnings := make([]string, 0, len(tlsWarnings))
+	for _, warning := range tlsWarnings {
+		warnings = append(warnings, fmt.Sprintf("ModuleConfig %s: %s", moduleConfigName, warning))
+	}
+	return allErrs, warnings
+}
+
+func nonEmptyString(settings map[string]any, key string) bool {
+	value, _ := settings[key].(string)
+	return value != ""
+}
+
+func validateMountOptions(mountOptions *cn.NFSStorageClassMountOptions, fldPath *field.Path) (field.ErrorList, []string) {
+	var (
+		allErrs  field.ErrorList
+		warnings []string
+	)
+
+	allErrs = append(allErrs, validateEnum(fldPath.Child("mountMode"), mountOptions.MountMode, mountModes, false)...)
+	if mountOptions.Timeout != 0 {
+		if err := mutableMountOptions["timeo"](strconv.Itoa(mountOptions.Timeout)); err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("timeout"), mountOptions.Timeout, err.Error()))
+		}
+	}
+	if mountOptions.Retransmissions != 0 {
+		if err := mutableMountOptions["retrans"](strconv.Itoa(mountOptions.Retransmissions)); err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("retransmissions"), mountOptions.Retransmissions, err.Error()))
 		}
 	}
 
+	readOnly := mountOptions.ReadOnly != nil && *mountOptions.ReadOnly
+	if mountOptions.MountMode == "soft" && !readOnly {
+		warnings = append(warnings, fmt.Sprintf("%s: the soft mounts for writing may silently lose or corrupt the data when the NFS server does not answer in time, use the hard mounts or readOnly", fldPath.Child("mountMode")))
+	}
+	return allErrs, warnings
+}
+
+func validateVolumeCleanup(spec *cn.NFSStorageClassSpec, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if spec.VolumeCleanup == "" {
+		return allErrs
+	}
+
+	allErrs = append(allErrs, validateEnum(fldPath, spec.VolumeCleanup, volumeCleanups, false)...)
+	if !feature.VolumeCleanupEnabled() {
+		allErrs = append(allErrs, field.Forbidden(fldPath, "volume cleanup is not available in your edition"))
+	}
+	if spec.ReclaimPolicy == "Retain" {
+		allErrs = append(allErrs, field.Forbidden(fldPath, "must be omitted if reclaimPolicy is Retain"))
+	}
+	if spec.VolumeCleanup == "Discard" && spec.Connection != nil && spec.Connection.NFSVersion != "4.2" {
+		allErrs = append(allErrs, field.Forbidden(fldPath, "Discard is only available if connection.nfsVersion is 4.2"))
+	}
+	return allErrs
+}
+
+func validateSnapshotExport(snapshotExport *cn.NFSStorageClassSnapshotExport, fldPath *field.Path) (field.ErrorList, []string) {
+	var (
+		allErrs  field.ErrorList
+		warnings []string
+	)
+
+	s3Path := fldPath.Child("s3")
+	s3 := snapshotExport.S3
+	if s3 == nil {
+		return append(allErrs, field.Required(s3Path, "")), warnings
+	}
+
+	endpoint, err := url.Parse(s3.Endpoint)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		allErrs = append(allErrs, field.Invalid(s3Path.Child("endpoint"), s3.Endpoint, "must be an http or https URL"))
+	} else if endpoint.Scheme == "http" {
+		warnings = append(warnings, fmt.Sprintf("%s: the snapshots and the S3 credentials are sent unencrypted over http", s3Path.Child("endpoint")))
+	}
+	if s3.Bucket == "" {
+		allErrs = append(allErrs, field.Required(s3Path.Child("bucket"), ""))
+	}
+	if s3.CredentialsSecretName == "" {
+		allErrs = append(allErrs, field.Required(s3Path.Child("credentialsSecretName"), ""))
+	}
+	return allErrs, warnings
+}
+
+func validateVolumeUsage(volumeUsage *cn.NFSStorageClassVolumeUsage, fldPath *field.Path) field.ErrorList {
+	allErrs := validateEnum(fldPath.Child("enforcement"), volumeUsage.Enforcement, enforcements, false)
+
+	quotaPath := fldPath.Child("projectQuota")
+	quota := volumeUsage.ProjectQuota
+	switch {
+	case volumeUsage.Enforcement == "Block" && quota == nil:
+		allErrs = append(allErrs, field.Required(quotaPath, "the project quota on the server is required by the Block enforcement"))
+	case volumeUsage.Enforcement != "Block" && quota != nil:
+		allErrs = append(allErrs, field.Forbidden(quotaPath, "is only allowed if enforcement is Block"))
+	}
+	if quota == nil {
+		return allErrs
+	}
+	if quota.SSHSecretName == "" {
+		allErrs = append(allErrs, field.Required(quotaPath.Child("sshSecretName"), ""))
+	}
+	if quota.ExportPath != "" && (!path.IsAbs(quota.ExportPath) || strings.ContainsAny(quota.ExportPath, " \t\n'\"\\")) {
+		allErrs = append(allErrs, field.Invalid(quotaPath.Child("exportPath"), quota.ExportPath, "must be an absolute path without whitespace and quotes"))
+	}
+	return allErrs
+}
+
+func validateOnDelete(moduleConfigName string, settings d8commonapi.SettingsValues, spec *cn.NFSStorageClassSpec, specPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	// the archives and the orphans are deleted by the share scan only
+	scanDisabled := settings[orphanScanIntervalSetting] == "0"
+
+	onDeletePath := specPath.Child("onDelete")
+	allErrs = append(allErrs, validateEnum(onDeletePath, spec.OnDelete, onDeletes, false)...)
+	if spec.OnDelete != "" && spec.ReclaimPolicy == "Retain" {
+		allErrs = append(allErrs, field.Forbidden(onDeletePath, "must be omitted if reclaimPolicy is Retain"))
+	}
+
+	if spec.RetainArchivedFor != "" {
+		retainPath := specPath.Child("retainArchivedFor")
+		allErrs = append(allErrs, validateDuration(retainPath, spec.RetainArchivedFor)...)
+		if spec.OnDelete != "Archive" {
+			allErrs = append(allErrs, field.Forbidden(retainPath, "is only allowed if onDelete is Archive"))
+		}
+		if scanDisabled {
+			allErrs = append(allErrs, field.Forbidden(retainPath, fmt.Sprintf("the archives are deleted by the share scan, which is disabled by the orphanScanInterval setting of ModuleConfig %s", moduleConfigName)))
+		}
+	}
+
+	if spec.OrphanCleanup != nil {
+		orphanPath := specPath.Child("orphanCleanup")
+		if spec.OrphanCleanup.DeleteOlderThan == "" {
+			allErrs = append(allErrs, field.Required(orphanPath.Child("deleteOlderThan"), ""))
+		} else {
+			allErrs = append(allErrs, validateDuration(orphanPath.Child("deleteOlderThan"), spec.OrphanCleanup.DeleteOlderThan)...)
+		}
+		if spec.OnDelete == "Retain" {
+			allErrs = append(allErrs, field.Forbidden(orphanPath, "must be omitted if onDelete is Retain: the retained directories are orphans"))
+		}
+		if scanDisabled {
+			allErrs = append(allErrs, field.Forbidden(orphanPath, fmt.Sprintf("the orphans are deleted by the share scan, which is disabled by the orphanScanInterval setting of ModuleConfig %s", moduleConfigName)))
+		}
+	}
+	return allErrs
+}
+
+func validateVolumeAttributesClasses(vacs []cn.NFSStorageClassVolumeAttributesClass, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	names := make(map[string]bool, len(vacs))
+	for i, vac := range vacs {
+		idxPath := fldPath.Index(i)
+		if !vacNameRegexp.MatchString(vac.Name) || len(vac.Name) > 63 {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("name"), vac.Name, "must be a lowercase RFC 1123 label"))
+		}
+		if names[vac.Name] {
+			allErrs = append(allErrs, field.Duplicate(idxPath.Child("name"), vac.Name))
+		}
+		names[vac.Name] = true
+
+		if err := ValidateVolumeAttributesMountOptions(vac.MountOptions); err != nil {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("mountOptions"), vac.MountOptions, err.Error()))
+		}
+	}
+	return allErrs
+}
+
+func validateEnum(fldPath *field.Path, value string, values []string, required bool) field.ErrorList {
+	var allErrs field.ErrorList
+	switch {
+	case value == "" && required:
+		allErrs = append(allErrs, field.Required(fldPath, ""))
+	case value != "" && !slices.Contains(values, value):
+		allErrs = append(allErrs, field.NotSupported(fldPath, value, values))
+	}
+	return allErrs
+}
+
+func validateDuration(fldPath *field.Path, value string) field.ErrorList {
+	var allErrs field.ErrorList
+	if !durationRegexp.MatchString(value) {
+		return append(allErrs, field.Invalid(fldPath, value, "must be a duration in hours, minutes and seconds, for example 72h or 1h30m"))
+	}
+	if d, err := time.ParseDuration(value); err != nil || d <= 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath, value, "must be a positive duration"))
+	}
+	return allErrs
+}
+
+// the ranges of timeo and retrans, the same as those of mountOptions.timeout
+// and mountOptions.retransmissions in the CRD
+const (
+	minTimeout         = 1
+	maxTimeout         = 6000
+	minRetransmissions = 1
+	maxRetransmissions = 100
+)
+
+// mutableMountOptions are the NFS mount options a VolumeAttributesClass may
+// override on an existing volume, with the check of their value. Only the
+// options tuning the caching and the transport of the client are allowed;
+// the list is kept in sync with the csi-nfs driver, which rejects the others.
+var mutableMountOptions = map[string]func(value string) error{
+	"ac":          noMountOptionValue,
+	"noac":        noMountOptionValue,
+	"cto":         noMountOptionValue,
+	"nocto":       noMountOptionValue,
+	"rdirplus":    noMountOptionValue,
+	"nordirplus":  noMountOptionValue,
+	"actimeo":     mountOptionRange(0, 1<<31-1),
+	"acregmin":    mountOptionRange(0, 1<<31-1),
+	"acregmax":    mountOptionRange(0, 1<<31-1),
+	"acdirmin":    mountOptionRange(0, 1<<31-1),
+	"acdirmax":    mountOptionRange(0, 1<<31-1),
+	"nconnect":    mountOptionRange(1, 16),
+	"rsize":       mountOptionRange(1024, 1<<20),
+	"wsize":       mountOptionRange(1024, 1<<20),
+	"timeo":       mountOptionRange(minTimeout, maxTimeout),
+	"retrans":     mountOptionRange(minRetransmissions, maxRetransmissions),
+	"lookupcache": mountOptionEnum("all", "none", "pos", "positive"),
+}
+
+func noMountOptionValue(value string) error {
+	if value != "" {
+		return fmt.Errorf("takes no value")
+	}
+	return nil
+}
+
+func mountOptionRange(min, max int) func(value string) error {
+	return func(value string) error {
+		v, err := strconv.Atoi(value)
+		if err != nil || v < min || v > max {
+			return fmt.Errorf("must be a number from %d to %d", min, max)
+		}
+		return nil
+	}
+}
+
+func mountOptionEnum(values ...string) func(value string) error {
+	return func(value string) error {
+		if !slices.Contains(values, value) {
+			return fmt.Errorf("must be one of %s", strings.Join(values, ", "))
+		}
+		return nil
+	}
+}
+
+// ValidateVolumeAttributesMountOptions checks that the mount options of a
+// VolumeAttributesClass may be changed on an existing volume.
+func ValidateVolumeAttributesMountOptions(options []string) error {
+	if len(options) == 0 {
+		return fmt.Errorf("no mount options are set")
+	}
+
+	for _, option := range options {
+		name, value, _ := strings.Cut(option, "=")
+		check, ok := mutableMountOptions[name]
+		if !ok {
+			return fmt.Errorf("mount option %q cannot be changed per volume", name)
+		}
+		if err := check(value); err != nil {
+			return fmt.Errorf("mount option %q: %v", name, err)
+		}
+	}
+
+	return nil
+}
+
+// ValidateNFSStorageClassContentSource checks that volumes of the destination
+// NFSStorageClass may be restored or cloned from snapshots and volumes of the
+// source NFSStorageClass, which may be on another NFS server.
+func ValidateNFSStorageClassContentSource(source, destination *cn.NFSStorageClass) error {
+	var logPostfix = "Volumes of the destination cannot be restored or cloned from the source"
+
+	src := source.Spec.Connection
+	dst := destination.Spec.Connection
+	if src == nil || dst == nil {
+		return fmt.Errorf("NFSStorageClass: %s or %s has no connection parameters; %s", source.Name, destination.Name, logPostfix)
+	}
+
+	if (src.Tls || src.Mtls) && !(dst.Tls || dst.Mtls) {
+		return fmt.Errorf(
+			"source NFSStorageClass: %s (tls or mtls is enabled); destination NFSStorageClass: %s (tls and mtls are disabled); %s",
+			source.Name, destination.Name, logPostfix,
+		)
+	}
+
+	if src.Mtls && !dst.Mtls {
+		return fmt.Errorf(
+			"source NFSStorageClass: %s (mtls is enabled); destination NFSStorageClass: %s (mtls is disabled); %s",
+			source.Name, destination.Name, logPostfix,
+		)
+	}
+
+	// NFSv4 ACLs and name-based ownership cannot be represented over NFSv3
+	if src.NFSVersion != "3" && dst.NFSVersion == "3" {
+		return fmt.Errorf(
+			"source NFSStorageClass: %s (nfsVersion is set to %s); destination NFSStorageClass: %s (nfsVersion is set to 3); %s",
+			source.Name, src.NFSVersion, destination.Name, logPostfix,
+		)
+	}
+
 	return nil
 }
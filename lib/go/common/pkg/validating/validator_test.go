@@ -0,0 +1,255 @@
+/*
+Copyright 2026 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package validating
+
+import (
+	"slices"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	cn "github.com/deckhouse/csi-nfs/api/v1alpha1"
+	feature "github.com/deckhouse/csi-nfs/lib/go/common/pkg/feature"
+	d8commonapi "github.com/deckhouse/sds-common-lib/api/v1alpha1"
+)
+
+func validNFSStorageClass() *cn.NFSStorageClass {
+	return &cn.NFSStorageClass{
+		ObjectMeta: metav1.ObjectMeta{Name: "nfs"},
+		Spec: cn.NFSStorageClassSpec{
+			Connection:        &cn.NFSStorageClassConnection{Host: "server", Share: "/share", NFSVersion: "4.1"},
+			ReclaimPolicy:     "Delete",
+			VolumeBindingMode: "WaitForFirstConsumer",
+		},
+	}
+}
+
+func moduleConfig(settings d8commonapi.SettingsValues) *d8commonapi.ModuleConfig {
+	mc := &d8commonapi.ModuleConfig{ObjectMeta: metav1.ObjectMeta{Name: "csi-nfs"}}
+	mc.Spec.Settings = settings
+	return mc
+}
+
+func errorFields(errs field.ErrorList) []string {
+	fields := make([]string, 0, len(errs))
+	for _, err := range errs {
+		fields = append(fields, err.Field)
+	}
+	return fields
+}
+
+func TestValidateNFSStorageClass(t *testing.T) {
+	readWrite := false
+
+	tt := []struct {
+		name             string
+		settings         d8commonapi.SettingsValues
+		mutate           func(nsc *cn.NFSStorageClass)
+		expectedFields   []string
+		expectedWarnings int
+	}{
+		{
+			name:   "valid",
+			mutate: func(*cn.NFSStorageClass) {},
+		},
+		{
+			name:           "no connection",
+			mutate:         func(nsc *cn.NFSStorageClass) { nsc.Spec.Connection = nil },
+			expectedFields: []string{"spec.connection"},
+		},
+		{
+			name: "NFSv3 without v3support",
+			mutate: func(nsc *cn.NFSStorageClass) {
+				nsc.Spec.Connection.NFSVersion = "3"
+			},
+			expectedFields: []string{"spec.connection.nfsVersion"},
+		},
+		{
+			name:           "NFSv3 with malformed v3support",
+			settings:       d8commonapi.SettingsValues{"v3support": "yes"},
+			mutate:         func(nsc *cn.NFSStorageClass) { nsc.Spec.Connection.NFSVersion = "3" },
+			expectedFields: []string{"spec.connection.nfsVersion"},
+		},
+		{
+			name:     "NFSv3 with v3support",
+			settings: d8commonapi.SettingsValues{"v3support": true},
+			mutate:   func(nsc *cn.NFSStorageClass) { nsc.Spec.Connection.NFSVersion = "3" },
+		},
+		{
+			name: "all the invalid fields are returned",
+			mutate: func(nsc *cn.NFSStorageClass) {
+				nsc.Spec.MountOptions = &cn.NFSStorageClassMountOptions{MountMode: "hard", Timeout: 6001, Retransmissions: 101}
+				nsc.Spec.ChmodPermissions = "0789"
+				nsc.Spec.VolumeBindingMode = "Later"
+				nsc.Spec.WorkloadNodes = &cn.NFSStorageClassWorkloadNodes{NodeSelector: &metav1.LabelSelector{
+					MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "zone", Operator: metav1.LabelSelectorOpIn}},
+				}}
+				nsc.Spec.SnapshotCompression = "lz4"
+			},
+			expectedFields: []string{
+				"spec.mountOptions.timeout",
+				"spec.mountOptions.retransmissions",
+				"spec.chmodPermissions",
+				"spec.volumeBindingMode",
+				"spec.workloadNodes.nodeSelector",
+				"spec.snapshotCompression",
+			},
+		},
+		{
+			name:             "relative share of an existing NFSStorageClass",
+			mutate:           func(nsc *cn.NFSStorageClass) { nsc.Spec.Connection.Share = "share" },
+			expectedWarnings: 1,
+		},
+		{
+			name: "soft mounts for writing",
+			mutate: func(nsc *cn.NFSStorageClass) {
+				nsc.Spec.MountOptions = &cn.NFSStorageClassMountOptions{MountMode: "soft", ReadOnly: &readWrite}
+			},
+			expectedWarnings: 1,
+		},
+		{
+			name: "snapshot export over http",
+			mutate: func(nsc *cn.NFSStorageClass) {
+				nsc.Spec.SnapshotExport = &cn.NFSStorageClassSnapshotExport{S3: &cn.NFSStorageClassSnapshotExportS3{
+					Endpoint: "http://s3.example.com", Bucket: "snapshots", CredentialsSecretName: "s3",
+				}}
+			},
+			expectedWarnings: 1,
+		},
+		{
+			name: "onDelete with Retain and malformed retainArchivedFor",
+			mutate: func(nsc *cn.NFSStorageClass) {
+				nsc.Spec.ReclaimPolicy = "Retain"
+				nsc.Spec.OnDelete = "Archive"
+				nsc.Spec.RetainArchivedFor = "1d"
+			},
+			expectedFields: []string{"spec.onDelete", "spec.retainArchivedFor"},
+		},
+		{
+			name: "orphanCleanup with retained directories",
+			mutate: func(nsc *cn.NFSStorageClass) {
+				nsc.Spec.OnDelete = "Retain"
+				nsc.Spec.OrphanCleanup = &cn.NFSStorageClassOrphanCleanup{DeleteOlderThan: "72h"}
+			},
+			expectedFields: []string{"spec.orphanCleanup"},
+		},
+		{
+			name:     "archives and orphans deleted without the share scan",
+			settings: d8commonapi.SettingsValues{"orphanScanInterval": "0"},
+			mutate: func(nsc *cn.NFSStorageClass) {
+				nsc.Spec.OnDelete = "Archive"
+				nsc.Spec.RetainArchivedFor = "720h"
+				nsc.Spec.OrphanCleanup = &cn.NFSStorageClassOrphanCleanup{DeleteOlderThan: "72h"}
+			},
+			expectedFields: []string{"spec.retainArchivedFor", "spec.orphanCleanup"},
+		},
+		{
+			name:     "archives deleted by the share scan",
+			settings: d8commonapi.SettingsValues{"orphanScanInterval": "12h"},
+			mutate: func(nsc *cn.NFSStorageClass) {
+				nsc.Spec.OnDelete = "Archive"
+				nsc.Spec.RetainArchivedFor = "720h"
+			},
+		},
+		{
+			name: "Block without a project quota",
+			mutate: func(nsc *cn.NFSStorageClass) {
+				nsc.Spec.VolumeUsage = &cn.NFSStorageClassVolumeUsage{Enforcement: "Block"}
+			},
+			expectedFields: []string{"spec.volumeUsage.projectQuota"},
+		},
+		{
+			name: "project quota without Block and with a relative export path",
+			mutate: func(nsc *cn.NFSStorageClass) {
+				nsc.Spec.VolumeUsage = &cn.NFSStorageClassVolumeUsage{
+					Enforcement:  "RefuseReadWrite",
+					ProjectQuota: &cn.NFSStorageClassProjectQuota{SSHSecretName: "nfs-ssh", ExportPath: "srv/nfs"},
+				}
+			},
+			expectedFields: []string{"spec.volumeUsage.projectQuota", "spec.volumeUsage.projectQuota.exportPath"},
+		},
+		{
+			name: "duplicate VolumeAttributesClasses",
+			mutate: func(nsc *cn.NFSStorageClass) {
+				nsc.Spec.VolumeAttributesClasses = []cn.NFSStorageClassVolumeAttributesClass{
+					{Name: "fast", MountOptions: []string{"nconnect=8"}},
+					{Name: "fast", MountOptions: []string{"vers=3"}},
+				}
+			},
+			expectedFields: []string{"spec.volumeAttributesClasses[1].name", "spec.volumeAttributesClasses[1].mountOptions"},
+		},
+		{
+			name: "VolumeAttributesClass with the ranges of the mount options",
+			mutate: func(nsc *cn.NFSStorageClass) {
+				nsc.Spec.VolumeAttributesClasses = []cn.NFSStorageClassVolumeAttributesClass{
+					{Name: "no-retries", MountOptions: []string{"retrans=0"}},
+					{Name: "slow", MountOptions: []string{"timeo=6000", "retrans=100"}},
+				}
+			},
+			expectedFields: []string{"spec.volumeAttributesClasses[0].mountOptions"},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			nsc := validNFSStorageClass()
+			tc.mutate(nsc)
+			errs, warnings := ValidateNFSStorageClass(moduleConfig(tc.settings), nsc)
+			if fields := errorFields(errs); !slices.Equal(fields, tc.expectedFields) {
+				t.Errorf("Expected errors of fields %v, but got %v", tc.expectedFields, errs)
+			}
+			if len(warnings) != tc.expectedWarnings {
+				t.Errorf("Expected %d warnings, but got %v", tc.expectedWarnings, warnings)
+			}
+		})
+	}
+}
+
+func TestValidateNFSStorageClassEdition(t *testing.T) {
+	nsc := validNFSStorageClass()
+	nsc.Spec.VolumeCleanup = "RandomFillSinglePass"
+	errs, _ := ValidateNFSStorageClass(moduleConfig(nil), nsc)
+	if (len(errs) == 0) != feature.VolumeCleanupEnabled() {
+		t.Errorf("Expected volumeCleanup to be allowed only in the editions with volume cleanup, but got %v", errs)
+	}
+
+	nsc = validNFSStorageClass()
+	nsc.Spec.Connection.Tls = true
+	nsc.Spec.Connection.Mtls = true
+	errs, _ = ValidateNFSStorageClass(moduleConfig(d8commonapi.SettingsValues{
+		"tlsParameters": map[string]any{"ca": "ca", "mtls": "malformed"},
+	}), nsc)
+	expectedField := "spec.connection.tls"
+	if feature.TLSEnabled() {
+		expectedField = "spec.connection.mtls"
+	}
+	if fields := errorFields(errs); !slices.Equal(fields, []string{expectedField}) {
+		t.Errorf("Expected an error of field %s, but got %v", expectedField, errs)
+	}
+}
+
+func TestValidateNewNFSStorageClass(t *testing.T) {
+	nsc := validNFSStorageClass()
+	if errs := ValidateNewNFSStorageClass(nsc); len(errs) > 0 {
+		t.Errorf("Expected no errors, but got %v", errs)
+	}
+	nsc.Spec.Connection.Share = "share"
+	if fields := errorFields(ValidateNewNFSStorageClass(nsc)); !slices.Equal(fields, []string{"spec.connection.share"}) {
+		t.Errorf("Expected the error of spec.connection.share, but got %v", fields)
+	}
+}